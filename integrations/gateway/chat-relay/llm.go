@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// openAIChat calls an OpenAI-compatible /chat/completions endpoint.
+type openAIChat struct {
+	baseURL string // e.g. https://api.openai.com/v1
+	apiKey  string
+	model   string
+	system  string
+	http    *http.Client
+}
+
+func (c *openAIChat) Complete(ctx context.Context, messages []chatMessage) (string, error) {
+	if c.system != "" {
+		messages = append([]chatMessage{{Role: "system", Content: c.system}}, messages...)
+	}
+	body, err := json.Marshal(map[string]any{"model": c.model, "messages": messages})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimRight(c.baseURL, "/")+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return "", fmt.Errorf("llm: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var out struct {
+		Choices []struct {
+			Message chatMessage `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("llm: decode: %w", err)
+	}
+	if len(out.Choices) == 0 {
+		return "", errors.New("llm: response has no choices")
+	}
+	return out.Choices[0].Message.Content, nil
+}
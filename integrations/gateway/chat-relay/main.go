@@ -0,0 +1,136 @@
+// Command ogr-chat-relay is an OpenGuardrails gateway-hook integration for
+// chatbots that never pass through an API gateway: it sits between a chat
+// platform (Slack Events API, Microsoft Teams via the Bot Framework) and an
+// OpenAI-compatible LLM backend, and guards both directions of every turn.
+//
+//	Slack / Teams ──▶ ogr-chat-relay ──▶ LLM (/chat/completions)
+//	                      │
+//	                      └── GuardEvent → POST /api/public/ogr/v1/evaluate → Verdict
+//
+// A user message is a `user_input` event; the bot's reply is a `model_output`
+// event with the same guard_id. block and require_approval answer with a
+// refusal; redact and modify mask the verdict's spans before the text moves
+// on (into the model, or back to the user). The relay is a pure PEP.
+//
+// Env:
+//
+//	OGR_RUNTIME_URL          runtime base URL (default http://localhost:3000)
+//	OGR_API_KEY              workspace API key, sent as a bearer token
+//	OGR_EVAL_TIMEOUT         seconds per turn, guard and model calls included (default 60)
+//	OGR_FAIL_MODE_CLOSED     refuse while the runtime is unreachable (default true)
+//	OGR_AGENT_ID             subject.agent_id for the bot (default "chat-relay")
+//	OGR_RELAY_LISTEN         listen address (default :8895)
+//	OGR_RELAY_REFUSAL        reply sent instead of blocked content
+//	OGR_RELAY_HISTORY        messages of context kept per conversation (default 10)
+//	OGR_RELAY_LLM_URL        OpenAI-compatible base URL (default https://api.openai.com/v1)
+//	OGR_RELAY_LLM_KEY        LLM API key
+//	OGR_RELAY_LLM_MODEL      model name (default gpt-4o-mini)
+//	OGR_RELAY_SYSTEM_PROMPT  optional system prompt
+//	SLACK_SIGNING_SECRET     enables POST /slack/events
+//	SLACK_BOT_TOKEN          xoxb- token used for chat.postMessage
+//	TEAMS_APP_ID             enables POST /teams/messages
+//	TEAMS_APP_PASSWORD       Bot Framework app secret
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go/ogr"
+)
+
+func main() {
+	logger := log.New(os.Stderr, "ogr-chat-relay: ", log.LstdFlags)
+
+	secs, err := strconv.ParseFloat(env("OGR_EVAL_TIMEOUT", "60"), 64)
+	if err != nil || secs <= 0 {
+		logger.Fatalf("OGR_EVAL_TIMEOUT: invalid value %q", os.Getenv("OGR_EVAL_TIMEOUT"))
+	}
+	timeout := time.Duration(secs * float64(time.Second))
+	turns, err := strconv.Atoi(env("OGR_RELAY_HISTORY", "10"))
+	if err != nil {
+		logger.Fatalf("OGR_RELAY_HISTORY: %v", err)
+	}
+	apiKey := os.Getenv("OGR_API_KEY")
+	if apiKey == "" {
+		logger.Print("OGR_API_KEY is not set — runtime calls will be rejected (401).")
+	}
+
+	client := &http.Client{Timeout: timeout}
+	r := &relay{
+		pdp: ogr.NewClient(env("OGR_RUNTIME_URL", "http://localhost:3000"), apiKey, timeout),
+		llm: &openAIChat{
+			baseURL: env("OGR_RELAY_LLM_URL", "https://api.openai.com/v1"),
+			apiKey:  os.Getenv("OGR_RELAY_LLM_KEY"),
+			model:   env("OGR_RELAY_LLM_MODEL", "gpt-4o-mini"),
+			system:  os.Getenv("OGR_RELAY_SYSTEM_PROMPT"),
+			http:    client,
+		},
+		history:  newHistory(turns, 10000),
+		timeout:  timeout,
+		refusal:  env("OGR_RELAY_REFUSAL", "Sorry, I can't help with that request."),
+		failOpen: !truthy(os.Getenv("OGR_FAIL_MODE_CLOSED"), true),
+		agentID:  env("OGR_AGENT_ID", "chat-relay"),
+		logger:   logger,
+	}
+	async := func(f func()) { go f() }
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	if secret := os.Getenv("SLACK_SIGNING_SECRET"); secret != "" {
+		mux.Handle("/slack/events", &slackHandler{
+			relay: r, signingSecret: secret, botToken: os.Getenv("SLACK_BOT_TOKEN"),
+			apiBase: "https://slack.com/api", http: client, logger: logger,
+			now: time.Now, dispatch: async,
+		})
+		logger.Print("slack: POST /slack/events")
+	}
+	if appID := os.Getenv("TEAMS_APP_ID"); appID != "" {
+		mux.Handle("/teams/messages", &teamsHandler{
+			relay: r, appID: appID, appSecret: os.Getenv("TEAMS_APP_PASSWORD"),
+			tokenURL: botFrameworkTokenURL,
+			keys:     &jwks{openIDURL: botFrameworkOpenID, http: client},
+			http:     client, logger: logger, now: time.Now, dispatch: async,
+		})
+		logger.Print("teams: POST /teams/messages")
+	}
+
+	srv := &http.Server{Addr: env("OGR_RELAY_LISTEN", ":8895"), Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+	logger.Printf("listening on %s (fail_%s)", srv.Addr, map[bool]string{true: "open", false: "closed"}[r.failOpen])
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Fatal(err)
+	}
+}
+
+func env(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return def
+}
+
+func truthy(v string, def bool) bool {
+	v = strings.ToLower(strings.TrimSpace(v))
+	if v == "" {
+		return def
+	}
+	return v != "0" && v != "false" && v != "no" && v != "off"
+}
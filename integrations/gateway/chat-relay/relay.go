@@ -0,0 +1,190 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go/ogr"
+	"github.com/openguardrails/openguardrails-go/verdict"
+)
+
+// evaluator is the slice of ogr.Client the relay needs; tests substitute it.
+type evaluator interface {
+	Evaluate(ctx context.Context, ev *ogr.GuardEvent) (*verdict.Verdict, error)
+}
+
+// completer produces the bot's reply to a conversation.
+type completer interface {
+	Complete(ctx context.Context, messages []chatMessage) (string, error)
+}
+
+// turn identifies who said something, where.
+type turn struct {
+	Channel      string // "slack" | "teams"
+	Conversation string // platform conversation (and thread) key
+	User         string // platform user id
+	Text         string
+}
+
+// relay runs the guard pipeline for one chat turn:
+//
+//	user message ──user_input──▶ PDP ──(block│redact)──▶ LLM ──model_output──▶ PDP ──▶ reply
+//
+// Both events share one guard_id, so the runtime correlates a reply with the
+// message that produced it.
+type relay struct {
+	pdp      evaluator
+	llm      completer
+	history  *history
+	timeout  time.Duration
+	refusal  string
+	failOpen bool
+	agentID  string
+	logger   *log.Logger
+}
+
+func (r *relay) handle(ctx context.Context, t turn) string {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	subject := ogr.Subject{AgentID: r.agentID, AgentType: "chat-relay", Principal: t.Channel + ":" + t.User}
+	payload := map[string]any{"text": t.Text, "channel": t.Channel}
+
+	in := ogr.NewEvent("user_input", subject, payload)
+	in.SessionID = t.Conversation
+	in.Provenance = []ogr.Provenance{{Source: "user", Trust: "unverified"}}
+	text, ok := r.enforce(ctx, in, t.Text)
+	if !ok {
+		return r.refusal
+	}
+
+	prior := r.history.get(t.Conversation)
+	reply, err := r.llm.Complete(ctx, append(prior, chatMessage{Role: "user", Content: text}))
+	if err != nil {
+		r.logger.Printf("%s: completion: %v", t.Conversation, err)
+		return "Sorry, I couldn't reach the assistant. Please try again."
+	}
+
+	out := ogr.NewEvent("model_output", subject, map[string]any{"text": reply, "channel": t.Channel})
+	out.GuardID = in.GuardID
+	out.SessionID = t.Conversation
+	out.Provenance = []ogr.Provenance{{Source: "model", Trust: "unverified"}}
+	reply, ok = r.enforce(ctx, out, reply)
+	if !ok {
+		return r.refusal
+	}
+
+	// History holds only what passed the guard, so a masked value never
+	// re-enters the model's context in a later turn.
+	r.history.append(t.Conversation,
+		chatMessage{Role: "user", Content: text},
+		chatMessage{Role: "assistant", Content: reply})
+	return reply
+}
+
+// enforce evaluates ev and returns the text to carry forward, or false when
+// the turn must be refused.
+func (r *relay) enforce(ctx context.Context, ev *ogr.GuardEvent, text string) (string, bool) {
+	v, err := r.pdp.Evaluate(ctx, ev)
+	if err != nil {
+		r.logger.Printf("%s %s: evaluate: %v", ev.EventID, ev.Kind, err)
+		return text, r.failOpen
+	}
+	r.logger.Printf("%s %s session=%s guard=%s decision=%s", ev.EventID, ev.Kind, ev.SessionID, ev.GuardID, v.Decision)
+	switch v.Decision {
+	case verdict.Block, verdict.RequireApproval:
+		// A chat has no approval flow to suspend into; refusing is the safe
+		// reading of require_approval here.
+		return "", false
+	case verdict.Redact, verdict.Modify:
+		return v.Masked("payload.text", text), true
+	}
+	return text, true
+}
+
+// history keeps the last few guarded messages per conversation, bounded in
+// both directions so a long-running relay does not grow without limit.
+type history struct {
+	mu       sync.Mutex
+	perConv  int
+	maxConvs int
+	order    *list.List
+	convs    map[string]*list.Element
+}
+
+type convEntry struct {
+	key  string
+	msgs []chatMessage
+}
+
+func newHistory(perConv, maxConvs int) *history {
+	return &history{perConv: perConv, maxConvs: maxConvs, order: list.New(), convs: map[string]*list.Element{}}
+}
+
+func (h *history) get(key string) []chatMessage {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	el, ok := h.convs[key]
+	if !ok {
+		return nil
+	}
+	h.order.MoveToFront(el)
+	return append([]chatMessage(nil), el.Value.(*convEntry).msgs...)
+}
+
+func (h *history) append(key string, msgs ...chatMessage) {
+	if h.perConv <= 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	el, ok := h.convs[key]
+	if !ok {
+		el = h.order.PushFront(&convEntry{key: key})
+		h.convs[key] = el
+		for h.order.Len() > h.maxConvs {
+			old := h.order.Back()
+			h.order.Remove(old)
+			delete(h.convs, old.Value.(*convEntry).key)
+		}
+	} else {
+		h.order.MoveToFront(el)
+	}
+	e := el.Value.(*convEntry)
+	e.msgs = append(e.msgs, msgs...)
+	if n := len(e.msgs) - h.perConv; n > 0 {
+		e.msgs = append([]chatMessage(nil), e.msgs[n:]...)
+	}
+}
+
+// stripMentions removes platform mention markup (Slack "<@U123>", Teams
+// "<at>Bot</at>") so the guard and the model see the user's words.
+func stripMentions(s string) string {
+	for {
+		i := strings.Index(s, "<@")
+		if i < 0 {
+			break
+		}
+		j := strings.Index(s[i:], ">")
+		if j < 0 {
+			break
+		}
+		s = s[:i] + s[i+j+1:]
+	}
+	for {
+		i := strings.Index(s, "<at>")
+		if i < 0 {
+			break
+		}
+		j := strings.Index(s[i:], "</at>")
+		if j < 0 {
+			break
+		}
+		s = s[:i] + s[i+j+len("</at>"):]
+	}
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go/ogr"
+	"github.com/openguardrails/openguardrails-go/verdict"
+)
+
+// scriptedPDP returns a verdict per event kind.
+type scriptedPDP struct {
+	verdicts map[string]*verdict.Verdict
+	err      error
+	events   []*ogr.GuardEvent
+}
+
+func (p *scriptedPDP) Evaluate(_ context.Context, ev *ogr.GuardEvent) (*verdict.Verdict, error) {
+	p.events = append(p.events, ev)
+	if p.err != nil {
+		return nil, p.err
+	}
+	if v, ok := p.verdicts[ev.Kind]; ok {
+		return v, nil
+	}
+	return &verdict.Verdict{Decision: verdict.Allow}, nil
+}
+
+type fakeLLM struct {
+	reply string
+	seen  [][]chatMessage
+}
+
+func (f *fakeLLM) Complete(_ context.Context, msgs []chatMessage) (string, error) {
+	f.seen = append(f.seen, msgs)
+	return f.reply, nil
+}
+
+func newRelay(pdp evaluator, llm completer) *relay {
+	return &relay{
+		pdp: pdp, llm: llm, history: newHistory(10, 100), timeout: time.Second,
+		refusal: "refused", agentID: "chat-relay", logger: log.New(io.Discard, "", 0),
+	}
+}
+
+func redactAll(n int) *verdict.Verdict {
+	return &verdict.Verdict{Decision: verdict.Redact, Modifications: &verdict.Modifications{Kind: "redact",
+		Spans: []verdict.Span{{Path: "payload.text", Start: 0, End: n, Replacement: "[PII]"}}}}
+}
+
+func TestRelayAllow(t *testing.T) {
+	pdp, llm := &scriptedPDP{}, &fakeLLM{reply: "Paris."}
+	r := newRelay(pdp, llm)
+	got := r.handle(context.Background(), turn{Channel: "slack", Conversation: "c1", User: "U1", Text: "Capital of France?"})
+	if got != "Paris." {
+		t.Fatalf("reply = %q", got)
+	}
+	if len(pdp.events) != 2 || pdp.events[0].Kind != "user_input" || pdp.events[1].Kind != "model_output" {
+		t.Fatalf("events = %v", pdp.events)
+	}
+	if pdp.events[0].GuardID != pdp.events[1].GuardID || pdp.events[0].SessionID != "c1" {
+		t.Fatal("input and output events are not correlated")
+	}
+	if pdp.events[0].Subject.Principal != "slack:U1" {
+		t.Fatalf("subject = %+v", pdp.events[0].Subject)
+	}
+}
+
+func TestRelayBlocksInputWithoutCallingModel(t *testing.T) {
+	pdp := &scriptedPDP{verdicts: map[string]*verdict.Verdict{"user_input": {Decision: verdict.Block}}}
+	llm := &fakeLLM{reply: "never"}
+	if got := newRelay(pdp, llm).handle(context.Background(), turn{Conversation: "c", Text: "bad"}); got != "refused" {
+		t.Fatalf("reply = %q", got)
+	}
+	if len(llm.seen) != 0 {
+		t.Fatal("model was called for a blocked message")
+	}
+}
+
+func TestRelayMasksBothDirections(t *testing.T) {
+	pdp := &scriptedPDP{verdicts: map[string]*verdict.Verdict{
+		"user_input":   redactAll(4),
+		"model_output": redactAll(3),
+	}}
+	llm := &fakeLLM{reply: "Bob is fine"}
+	r := newRelay(pdp, llm)
+	got := r.handle(context.Background(), turn{Conversation: "c", Text: "1234 what now"})
+	if got != "[PII] is fine" {
+		t.Fatalf("reply = %q", got)
+	}
+	if sent := llm.seen[0][0].Content; sent != "[PII] what now" {
+		t.Fatalf("model saw %q", sent)
+	}
+	// The masked forms are what later turns see as context.
+	r.handle(context.Background(), turn{Conversation: "c", Text: "and then?"})
+	ctx := llm.seen[1]
+	if len(ctx) != 3 || strings.Contains(ctx[0].Content, "1234") || ctx[1].Content != "[PII] is fine" {
+		t.Fatalf("history = %+v", ctx)
+	}
+}
+
+func TestRelayFailMode(t *testing.T) {
+	pdp := &scriptedPDP{err: errors.New("unreachable")}
+	r := newRelay(pdp, &fakeLLM{reply: "ok"})
+	if got := r.handle(context.Background(), turn{Conversation: "c", Text: "hi"}); got != "refused" {
+		t.Fatalf("fail closed reply = %q", got)
+	}
+	r.failOpen = true
+	if got := r.handle(context.Background(), turn{Conversation: "c", Text: "hi"}); got != "ok" {
+		t.Fatalf("fail open reply = %q", got)
+	}
+}
+
+func TestHistoryBounds(t *testing.T) {
+	h := newHistory(2, 2)
+	h.append("a", chatMessage{Content: "1"}, chatMessage{Content: "2"}, chatMessage{Content: "3"})
+	if got := h.get("a"); len(got) != 2 || got[0].Content != "2" {
+		t.Fatalf("per-conversation cap: %+v", got)
+	}
+	h.append("b", chatMessage{Content: "x"})
+	h.append("c", chatMessage{Content: "y"})
+	if h.get("a") != nil {
+		t.Fatal("least recently used conversation was not evicted")
+	}
+}
+
+func TestStripMentions(t *testing.T) {
+	if got := stripMentions("<@U024BE7LH> hello <at>Guard Bot</at> there"); got != "hello  there" {
+		t.Fatalf("stripMentions = %q", got)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// slackMaxSkew is how old a signed request may be before it is treated as a
+// replay (Slack's own recommendation).
+const slackMaxSkew = 5 * time.Minute
+
+// slackHandler serves the Slack Events API request URL. Events are
+// acknowledged immediately — Slack retries anything slower than three
+// seconds — and answered in the message's thread once the guard pipeline has
+// run.
+type slackHandler struct {
+	relay         *relay
+	signingSecret string
+	botToken      string
+	apiBase       string // https://slack.com/api
+	http          *http.Client
+	logger        *log.Logger
+	now           func() time.Time
+	// dispatch runs the pipeline; tests make it synchronous.
+	dispatch func(func())
+}
+
+type slackEnvelope struct {
+	Type      string     `json:"type"`
+	Challenge string     `json:"challenge"`
+	TeamID    string     `json:"team_id"`
+	Event     slackEvent `json:"event"`
+}
+
+type slackEvent struct {
+	Type        string `json:"type"`
+	Subtype     string `json:"subtype"`
+	ChannelType string `json:"channel_type"`
+	Channel     string `json:"channel"`
+	User        string `json:"user"`
+	BotID       string `json:"bot_id"`
+	Text        string `json:"text"`
+	TS          string `json:"ts"`
+	ThreadTS    string `json:"thread_ts"`
+}
+
+func (h *slackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "read error", http.StatusBadRequest)
+		return
+	}
+	if !h.verify(r.Header, body) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	var env slackEnvelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	if env.Type == "url_verification" {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, env.Challenge)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+
+	// A retry means the first delivery is (or was) already being handled.
+	if r.Header.Get("X-Slack-Retry-Num") != "" || env.Type != "event_callback" {
+		return
+	}
+	ev := env.Event
+	if !h.addressed(ev) {
+		return
+	}
+	thread := ev.ThreadTS
+	if thread == "" {
+		thread = ev.TS
+	}
+	t := turn{
+		Channel:      "slack",
+		Conversation: fmt.Sprintf("slack:%s:%s:%s", env.TeamID, ev.Channel, thread),
+		User:         ev.User,
+		Text:         stripMentions(ev.Text),
+	}
+	if t.Text == "" {
+		return
+	}
+	h.dispatch(func() {
+		reply := h.relay.handle(context.Background(), t)
+		if err := h.post(ev.Channel, thread, reply); err != nil {
+			h.logger.Printf("%s: chat.postMessage: %v", t.Conversation, err)
+		}
+	})
+}
+
+// addressed reports whether ev is a human message meant for the bot: a direct
+// message or an @-mention. Bot messages (including the relay's own replies)
+// and edits/joins are ignored, which also prevents reply loops.
+func (h *slackHandler) addressed(ev slackEvent) bool {
+	if ev.BotID != "" || ev.Subtype != "" || ev.User == "" {
+		return false
+	}
+	return ev.Type == "app_mention" || (ev.Type == "message" && ev.ChannelType == "im")
+}
+
+// verify checks Slack's v0 request signature over "v0:<timestamp>:<body>".
+func (h *slackHandler) verify(hdr http.Header, body []byte) bool {
+	ts := hdr.Get("X-Slack-Request-Timestamp")
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return false
+	}
+	if d := h.now().Sub(time.Unix(sec, 0)); d > slackMaxSkew || d < -slackMaxSkew {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(h.signingSecret))
+	fmt.Fprintf(mac, "v0:%s:", ts)
+	mac.Write(body)
+	want := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(want), []byte(hdr.Get("X-Slack-Signature")))
+}
+
+func (h *slackHandler) post(channel, thread, text string) error {
+	body, _ := json.Marshal(map[string]string{"channel": channel, "thread_ts": thread, "text": text})
+	req, err := http.NewRequest(http.MethodPost, strings.TrimRight(h.apiBase, "/")+"/chat.postMessage", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+h.botToken)
+	resp, err := h.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Slack reports API errors in a 200 body: {"ok": false, "error": "..."}.
+	var out struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return fmt.Errorf("%s: %w", resp.Status, err)
+	}
+	if !out.OK {
+		return fmt.Errorf("slack: %s", out.Error)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSigningSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+
+func signedSlackRequest(t *testing.T, body string, at time.Time) *http.Request {
+	t.Helper()
+	ts := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(testSigningSecret))
+	mac.Write([]byte("v0:" + ts + ":" + body))
+	req := httptest.NewRequest(http.MethodPost, "/slack/events", strings.NewReader(body))
+	req.Header.Set("X-Slack-Request-Timestamp", ts)
+	req.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return req
+}
+
+func newSlackHandler(r *relay, apiBase string, now time.Time) *slackHandler {
+	return &slackHandler{
+		relay: r, signingSecret: testSigningSecret, botToken: "xoxb-test", apiBase: apiBase,
+		http: http.DefaultClient, logger: log.New(io.Discard, "", 0),
+		now: func() time.Time { return now }, dispatch: func(f func()) { f() },
+	}
+}
+
+func TestSlackURLVerification(t *testing.T) {
+	now := time.Now()
+	h := newSlackHandler(nil, "", now)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, signedSlackRequest(t, `{"type":"url_verification","challenge":"abc123"}`, now))
+	if rec.Code != http.StatusOK || rec.Body.String() != "abc123" {
+		t.Fatalf("got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestSlackRejectsBadSignatureAndReplay(t *testing.T) {
+	now := time.Now()
+	h := newSlackHandler(nil, "", now)
+
+	req := signedSlackRequest(t, `{"type":"url_verification"}`, now)
+	req.Header.Set("X-Slack-Signature", "v0=deadbeef")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("bad signature: status %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, signedSlackRequest(t, `{"type":"url_verification"}`, now.Add(-10*time.Minute)))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("stale timestamp: status %d", rec.Code)
+	}
+}
+
+func TestSlackMentionRepliesInThread(t *testing.T) {
+	var posted map[string]string
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat.postMessage" || r.Header.Get("Authorization") != "Bearer xoxb-test" {
+			t.Errorf("post %s %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		json.NewDecoder(r.Body).Decode(&posted)
+		io.WriteString(w, `{"ok":true}`)
+	}))
+	defer slack.Close()
+
+	pdp, llm := &scriptedPDP{}, &fakeLLM{reply: "Hi!"}
+	now := time.Now()
+	h := newSlackHandler(newRelay(pdp, llm), slack.URL, now)
+	body := `{"type":"event_callback","team_id":"T1","event":{"type":"app_mention","channel":"C1","user":"U1","text":"<@UBOT> hello","ts":"1700000000.000100"}}`
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, signedSlackRequest(t, body, now))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+	if posted["channel"] != "C1" || posted["thread_ts"] != "1700000000.000100" || posted["text"] != "Hi!" {
+		t.Fatalf("posted %+v", posted)
+	}
+	if llm.seen[0][0].Content != "hello" || pdp.events[0].SessionID != "slack:T1:C1:1700000000.000100" {
+		t.Fatalf("turn: model saw %+v, session %q", llm.seen[0], pdp.events[0].SessionID)
+	}
+}
+
+func TestSlackIgnoresBotsAndRetries(t *testing.T) {
+	llm := &fakeLLM{reply: "x"}
+	now := time.Now()
+	h := newSlackHandler(newRelay(&scriptedPDP{}, llm), "http://unused", now)
+
+	bot := `{"type":"event_callback","event":{"type":"message","channel_type":"im","bot_id":"B1","user":"U1","text":"loop"}}`
+	h.ServeHTTP(httptest.NewRecorder(), signedSlackRequest(t, bot, now))
+
+	retry := signedSlackRequest(t, `{"type":"event_callback","event":{"type":"app_mention","user":"U1","text":"hi"}}`, now)
+	retry.Header.Set("X-Slack-Retry-Num", "1")
+	h.ServeHTTP(httptest.NewRecorder(), retry)
+
+	if len(llm.seen) != 0 {
+		t.Fatalf("model called %d times, want 0", len(llm.seen))
+	}
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bot Framework endpoints. Inbound activities are signed by the Bot Connector
+// service; replies are authorized with an app token from Entra ID.
+const (
+	botFrameworkIssuer   = "https://api.botframework.com"
+	botFrameworkOpenID   = "https://login.botframework.com/v1/.well-known/openidconfiguration"
+	botFrameworkTokenURL = "https://login.microsoftonline.com/botframework.com/oauth2/v2.0/token"
+	botFrameworkScope    = "https://api.botframework.com/.default"
+	// jwtLeeway tolerates clock skew between the relay and the connector.
+	jwtLeeway = 5 * time.Minute
+)
+
+// teamsHandler serves a Bot Framework messaging endpoint (Microsoft Teams
+// and any other Bot Connector channel).
+type teamsHandler struct {
+	relay     *relay
+	appID     string
+	appSecret string
+	tokenURL  string
+	keys      *jwks
+	http      *http.Client
+	logger    *log.Logger
+	now       func() time.Time
+	dispatch  func(func())
+
+	mu       sync.Mutex
+	token    string
+	tokenExp time.Time
+}
+
+type activity struct {
+	Type       string `json:"type"`
+	ID         string `json:"id"`
+	Text       string `json:"text"`
+	ServiceURL string `json:"serviceUrl"`
+	From       struct {
+		ID string `json:"id"`
+	} `json:"from"`
+	Conversation struct {
+		ID string `json:"id"`
+	} `json:"conversation"`
+}
+
+func (h *teamsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "read error", http.StatusBadRequest)
+		return
+	}
+	var act activity
+	if err := json.Unmarshal(body, &act); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+	claims, err := h.authenticate(r.Context(), r.Header.Get("Authorization"))
+	if err != nil {
+		h.logger.Printf("teams: rejected activity: %v", err)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	// The token binds the activity to the connector that sent it; replying
+	// to any other serviceUrl would let a forged body redirect bot output.
+	if su, _ := claims["serviceurl"].(string); su != "" && !strings.EqualFold(strings.TrimRight(su, "/"), strings.TrimRight(act.ServiceURL, "/")) {
+		http.Error(w, "serviceUrl mismatch", http.StatusUnauthorized)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+
+	if act.Type != "message" {
+		return
+	}
+	t := turn{
+		Channel:      "teams",
+		Conversation: "teams:" + act.Conversation.ID,
+		User:         act.From.ID,
+		Text:         stripMentions(act.Text),
+	}
+	if t.Text == "" {
+		return
+	}
+	h.dispatch(func() {
+		reply := h.relay.handle(context.Background(), t)
+		if err := h.reply(context.Background(), act, reply); err != nil {
+			h.logger.Printf("%s: reply: %v", t.Conversation, err)
+		}
+	})
+}
+
+// authenticate validates the connector's RS256 bearer token and returns its
+// claims.
+func (h *teamsHandler) authenticate(ctx context.Context, authz string) (map[string]any, error) {
+	raw, ok := strings.CutPrefix(authz, "Bearer ")
+	if !ok {
+		return nil, errors.New("missing bearer token")
+	}
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var hdr struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &hdr); err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+	if hdr.Alg != "RS256" {
+		return nil, fmt.Errorf("unexpected alg %q", hdr.Alg)
+	}
+	key, err := h.keys.key(ctx, hdr.Kid)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("signature: %w", err)
+	}
+	sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig); err != nil {
+		return nil, errors.New("bad signature")
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("claims: %w", err)
+	}
+	if iss, _ := claims["iss"].(string); iss != botFrameworkIssuer {
+		return nil, fmt.Errorf("unexpected issuer %q", iss)
+	}
+	if aud, _ := claims["aud"].(string); aud != h.appID {
+		return nil, fmt.Errorf("unexpected audience %q", aud)
+	}
+	now := h.now()
+	if exp, ok := claims["exp"].(float64); !ok || now.After(time.Unix(int64(exp), 0).Add(jwtLeeway)) {
+		return nil, errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(jwtLeeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New("token not yet valid")
+	}
+	return claims, nil
+}
+
+func (h *teamsHandler) reply(ctx context.Context, act activity, text string) error {
+	token, err := h.appToken(ctx)
+	if err != nil {
+		return err
+	}
+	body, _ := json.Marshal(map[string]any{
+		"type":      "message",
+		"text":      text,
+		"replyToId": act.ID,
+	})
+	u := fmt.Sprintf("%s/v3/conversations/%s/activities/%s",
+		strings.TrimRight(act.ServiceURL, "/"), url.PathEscape(act.Conversation.ID), url.PathEscape(act.ID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := h.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// appToken returns a cached client-credentials token, refreshing it five
+// minutes before expiry.
+func (h *teamsHandler) appToken(ctx context.Context) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.token != "" && h.now().Before(h.tokenExp) {
+		return h.token, nil
+	}
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {h.appID},
+		"client_secret": {h.appSecret},
+		"scope":         {botFrameworkScope},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := h.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var out struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("token endpoint: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("token endpoint: %w", err)
+	}
+	h.token = out.AccessToken
+	h.tokenExp = h.now().Add(time.Duration(out.ExpiresIn)*time.Second - 5*time.Minute)
+	return h.token, nil
+}
+
+// jwks fetches and caches the connector's signing keys. Keys are refreshed
+// daily, and on demand when a token names an unknown kid (key rollover).
+type jwks struct {
+	openIDURL string
+	http      *http.Client
+
+	mu      sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}
+
+func (j *jwks) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if k, ok := j.keys[kid]; ok && time.Since(j.fetched) < 24*time.Hour {
+		return k, nil
+	}
+	// Unknown kids are attacker-controlled; refetch at most once a minute.
+	if j.keys != nil && time.Since(j.fetched) < time.Minute {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if err := j.refresh(ctx); err != nil {
+		return nil, fmt.Errorf("signing keys: %w", err)
+	}
+	if k, ok := j.keys[kid]; ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func (j *jwks) refresh(ctx context.Context) error {
+	var meta struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := j.getJSON(ctx, j.openIDURL, &meta); err != nil {
+		return err
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := j.getJSON(ctx, meta.JWKSURI, &set); err != nil {
+		return err
+	}
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+		e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	j.keys, j.fetched = keys, time.Now()
+	return nil
+}
+
+func (j *jwks) getJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := j.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("GET %s: %s", u, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func decodeSegment(seg string, v any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
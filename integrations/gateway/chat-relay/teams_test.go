@@ -0,0 +1,161 @@
+package main
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// connector fakes the Bot Framework: OpenID metadata, JWKS, the token
+// endpoint and the conversation reply API.
+type connector struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu      sync.Mutex
+	replies []map[string]any
+	paths   []string
+}
+
+func newConnector(t *testing.T) *connector {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &connector{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/openid", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"jwks_uri":%q}`, c.URL+"/keys")
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"access_token":"app-token","expires_in":3600}`)
+	})
+	mux.HandleFunc("/v3/conversations/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer app-token" {
+			t.Errorf("reply authorization = %q", r.Header.Get("Authorization"))
+		}
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		c.mu.Lock()
+		c.replies = append(c.replies, body)
+		c.paths = append(c.paths, r.URL.EscapedPath())
+		c.mu.Unlock()
+	})
+	c.Server = httptest.NewServer(mux)
+	t.Cleanup(c.Close)
+	return c
+}
+
+func (c *connector) sign(t *testing.T, claims map[string]any) string {
+	t.Helper()
+	enc := func(v any) string {
+		b, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	signing := enc(map[string]string{"alg": "RS256", "kid": "k1", "typ": "JWT"}) + "." + enc(claims)
+	sum := sha256.Sum256([]byte(signing))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, c.key, crypto.SHA256, sum[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signing + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func (c *connector) claims(now time.Time) map[string]any {
+	return map[string]any{
+		"iss": botFrameworkIssuer, "aud": "app-1", "serviceurl": c.URL,
+		"exp": now.Add(time.Hour).Unix(), "nbf": now.Add(-time.Minute).Unix(),
+	}
+}
+
+func newTeamsHandler(c *connector, r *relay, now time.Time) *teamsHandler {
+	return &teamsHandler{
+		relay: r, appID: "app-1", appSecret: "secret", tokenURL: c.URL + "/token",
+		keys: &jwks{openIDURL: c.URL + "/openid", http: http.DefaultClient},
+		http: http.DefaultClient, logger: log.New(io.Discard, "", 0),
+		now: func() time.Time { return now }, dispatch: func(f func()) { f() },
+	}
+}
+
+func teamsRequest(c *connector, token string) *http.Request {
+	body := fmt.Sprintf(`{"type":"message","id":"act/1","text":"<at>Guard</at> hi there",
+		"serviceUrl":%q,"from":{"id":"29:user"},"conversation":{"id":"a:conv"}}`, c.URL)
+	req := httptest.NewRequest(http.MethodPost, "/teams/messages", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestTeamsMessageReplies(t *testing.T) {
+	c := newConnector(t)
+	now := time.Now()
+	llm := &fakeLLM{reply: "Hello from the bot"}
+	h := newTeamsHandler(c, newRelay(&scriptedPDP{}, llm), now)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, teamsRequest(c, c.sign(t, c.claims(now))))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d", rec.Code)
+	}
+	if llm.seen[0][0].Content != "hi there" {
+		t.Fatalf("model saw %q", llm.seen[0][0].Content)
+	}
+	if len(c.replies) != 1 || c.replies[0]["text"] != "Hello from the bot" || c.replies[0]["replyToId"] != "act/1" {
+		t.Fatalf("replies %+v", c.replies)
+	}
+	if c.paths[0] != "/v3/conversations/a:conv/activities/act%2F1" {
+		t.Fatalf("reply path %q", c.paths[0])
+	}
+}
+
+func TestTeamsRejectsInvalidTokens(t *testing.T) {
+	c := newConnector(t)
+	now := time.Now()
+	h := newTeamsHandler(c, newRelay(&scriptedPDP{}, &fakeLLM{}), now)
+
+	cases := map[string]func(map[string]any){
+		"wrong audience": func(m map[string]any) { m["aud"] = "someone-else" },
+		"wrong issuer":   func(m map[string]any) { m["iss"] = "https://evil.example" },
+		"expired":        func(m map[string]any) { m["exp"] = now.Add(-time.Hour).Unix() },
+		"other service":  func(m map[string]any) { m["serviceurl"] = "https://evil.example" },
+	}
+	for name, mutate := range cases {
+		claims := c.claims(now)
+		mutate(claims)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, teamsRequest(c, c.sign(t, claims)))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: status %d", name, rec.Code)
+		}
+	}
+
+	token := c.sign(t, c.claims(now))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, teamsRequest(c, token[:len(token)-4]+"AAAA"))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("tampered signature: status %d", rec.Code)
+	}
+	if len(c.replies) != 0 {
+		t.Fatalf("replied to %d unauthenticated activities", len(c.replies))
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+)
+
+// api is what the controller needs from the Kubernetes API; kube
+// implements it.
+type api interface {
+	list(ctx context.Context, ns string) ([]policy, string, error)
+	watch(ctx context.Context, ns, version string, timeout int, f func(event) error) error
+	apply(ctx context.Context, obj object, subresource string) error
+	remove(ctx context.Context, obj object) error
+}
+
+// controller keeps each GuardrailPolicy's ogw deployment in line with its
+// spec. Deleting a policy needs no work: everything rendered from it is
+// owned by it and garbage-collected with it.
+type controller struct {
+	kube      api
+	namespace string // watched namespace; empty watches all
+	image     string
+	baseURL   string
+	resync    time.Duration
+	logger    *log.Logger
+
+	// seen holds the generation last reconciled per policy UID, so status
+	// writes, which fire watch events of their own, do not reconcile again.
+	// Failed reconciles are retried at the next resync.
+	seen map[string]int64
+}
+
+// errRelist ends a watch the controller must restart from a fresh list.
+var errRelist = errors.New("watch expired")
+
+// run reconciles every policy, then each change, relisting every resync
+// period to repair drift, until ctx is done.
+func (c *controller) run(ctx context.Context) error {
+	c.seen = map[string]int64{}
+	backoff := time.Second
+	for ctx.Err() == nil {
+		err := c.cycle(ctx)
+		if err == nil || errors.Is(err, errRelist) {
+			backoff = time.Second
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		c.logger.Printf("%v (retrying in %s)", err, backoff)
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		backoff = min(2*backoff, time.Minute)
+	}
+	return ctx.Err()
+}
+
+// cycle lists and reconciles all policies, then follows changes until the
+// watch ends.
+func (c *controller) cycle(ctx context.Context) error {
+	items, version, err := c.kube.list(ctx, c.namespace)
+	if err != nil {
+		return err
+	}
+	for i := range items {
+		c.reconcile(ctx, &items[i])
+	}
+	return c.kube.watch(ctx, c.namespace, version, int(c.resync/time.Second), func(ev event) error {
+		switch ev.Type {
+		case "ADDED", "MODIFIED":
+			var p policy
+			if err := json.Unmarshal(ev.Object, &p); err != nil {
+				return err
+			}
+			if c.seen[p.Metadata.UID] != p.Metadata.Generation {
+				c.reconcile(ctx, &p)
+			}
+		case "DELETED":
+			var p policy
+			if json.Unmarshal(ev.Object, &p) == nil {
+				delete(c.seen, p.Metadata.UID)
+			}
+		case "ERROR":
+			// Usually 410 Gone: the version fell out of the watch cache.
+			return errRelist
+		}
+		return nil
+	})
+}
+
+// reconcile applies what p renders to and records the outcome in its
+// status.
+func (c *controller) reconcile(ctx context.Context, p *policy) {
+	id := p.Metadata.Namespace + "/" + p.Metadata.Name
+	synced := c.sync(ctx, p)
+	ready := condition{Type: "Ready", Status: "True", Reason: "Applied", Message: "ogw deployment rendered"}
+	if synced != nil {
+		c.logger.Printf("%s: %v", id, synced)
+		ready = condition{Type: "Ready", Status: "False", Reason: "ApplyFailed", Message: synced.Error()}
+		var invalid *invalidError
+		if errors.As(synced, &invalid) {
+			ready.Reason = "Invalid"
+		}
+	}
+	ready.ObservedGeneration = p.Metadata.Generation
+	ready.LastTransitionTime = time.Now().UTC().Format(time.RFC3339)
+	for _, old := range p.Status.Conditions {
+		if old.Type == ready.Type && old.Status == ready.Status {
+			ready.LastTransitionTime = old.LastTransitionTime
+		}
+	}
+	status := object{
+		"apiVersion": apiVersion, "kind": kind,
+		"metadata": map[string]any{"name": p.Metadata.Name, "namespace": p.Metadata.Namespace},
+		"status": map[string]any{
+			"observedGeneration": p.Metadata.Generation,
+			"conditions":         []any{ready},
+		},
+	}
+	if err := c.kube.apply(ctx, status, "status"); err != nil {
+		c.logger.Printf("%s: status: %v", id, err)
+		return
+	}
+	c.seen[p.Metadata.UID] = p.Metadata.Generation
+}
+
+// invalidError is a spec the controller cannot render.
+type invalidError struct{ err error }
+
+func (e *invalidError) Error() string { return "invalid spec: " + e.err.Error() }
+
+func (c *controller) sync(ctx context.Context, p *policy) error {
+	objs, extension, err := render(p, c.image, c.baseURL)
+	if err != nil {
+		return &invalidError{err}
+	}
+	for _, obj := range objs {
+		if err := c.kube.apply(ctx, obj, ""); err != nil {
+			return err
+		}
+	}
+	if extension != nil {
+		return c.kube.apply(ctx, extension, "")
+	}
+	// Targets were removed: detach the processor from the routes.
+	return c.kube.remove(ctx, object{
+		"kind":     "EnvoyExtensionPolicy",
+		"metadata": map[string]any{"name": "ogr-" + p.Metadata.Name, "namespace": p.Metadata.Namespace},
+	})
+}
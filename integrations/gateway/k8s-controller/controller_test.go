@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeAPI records what the controller writes.
+type fakeAPI struct {
+	applied []string // kind/name, status as kind/name/status
+	removed []string
+	status  []map[string]any
+	fail    string // kind whose apply fails
+}
+
+func (f *fakeAPI) list(context.Context, string) ([]policy, string, error) { return nil, "", nil }
+
+func (f *fakeAPI) watch(context.Context, string, string, int, func(event) error) error { return nil }
+
+func (f *fakeAPI) apply(_ context.Context, obj object, sub string) error {
+	id := fmt.Sprintf("%s/%s", obj["kind"], obj["metadata"].(map[string]any)["name"])
+	if sub != "" {
+		id += "/" + sub
+		f.status = append(f.status, wire(obj))
+	}
+	if obj["kind"] == f.fail {
+		return fmt.Errorf("apply %s: %w", id, &apiError{Code: 404, Message: "the server could not find the requested resource"})
+	}
+	f.applied = append(f.applied, id)
+	return nil
+}
+
+func (f *fakeAPI) remove(_ context.Context, obj object) error {
+	f.removed = append(f.removed, fmt.Sprintf("%s/%s", obj["kind"], obj["metadata"].(map[string]any)["name"]))
+	return nil
+}
+
+func testController(f *fakeAPI) *controller {
+	return &controller{kube: f, image: "ogw", resync: time.Minute, logger: log.New(io.Discard, "", 0), seen: map[string]int64{}}
+}
+
+func ready(t *testing.T, f *fakeAPI) map[string]any {
+	t.Helper()
+	if len(f.status) == 0 {
+		t.Fatal("no status written")
+	}
+	st := f.status[len(f.status)-1]["status"]
+	if dig(st, "observedGeneration") != 3.0 {
+		t.Fatalf("status %v", st)
+	}
+	return dig(st, "conditions").([]any)[0].(map[string]any)
+}
+
+func TestReconcile(t *testing.T) {
+	f := &fakeAPI{}
+	c := testController(f)
+	c.reconcile(context.Background(), testPolicy(t, `{"apiKeySecretRef": {"name": "ogr"}, "targetRefs": [{"name": "chat"}]}`))
+	want := "ConfigMap/ogr-chat Deployment/ogr-chat Service/ogr-chat EnvoyExtensionPolicy/ogr-chat GuardrailPolicy/chat/status"
+	if got := strings.Join(f.applied, " "); got != want {
+		t.Fatalf("applied %s", got)
+	}
+	if cond := ready(t, f); cond["status"] != "True" || cond["reason"] != "Applied" {
+		t.Fatalf("condition %v", cond)
+	}
+	if c.seen["u-1"] != 3 {
+		t.Fatalf("seen %v", c.seen)
+	}
+
+	// Without targets the extension policy goes.
+	f = &fakeAPI{}
+	testController(f).reconcile(context.Background(), testPolicy(t, `{"apiKeySecretRef": {"name": "ogr"}}`))
+	if len(f.removed) != 1 || f.removed[0] != "EnvoyExtensionPolicy/ogr-chat" {
+		t.Fatalf("removed %v", f.removed)
+	}
+}
+
+func TestReconcileFailures(t *testing.T) {
+	f := &fakeAPI{}
+	testController(f).reconcile(context.Background(), testPolicy(t, `{"apiKeySecretRef": {"name": "ogr"}, "sensitivity": "max"}`))
+	if len(f.applied) != 1 {
+		t.Fatalf("applied %v", f.applied)
+	}
+	if cond := ready(t, f); cond["status"] != "False" || cond["reason"] != "Invalid" {
+		t.Fatalf("condition %v", cond)
+	}
+
+	// Envoy Gateway is not installed.
+	f = &fakeAPI{fail: "EnvoyExtensionPolicy"}
+	p := testPolicy(t, `{"apiKeySecretRef": {"name": "ogr"}, "targetRefs": [{"name": "chat"}]}`)
+	p.Status.Conditions = []condition{{Type: "Ready", Status: "False", LastTransitionTime: "2026-01-02T03:04:05Z"}}
+	testController(f).reconcile(context.Background(), p)
+	cond := ready(t, f)
+	if cond["reason"] != "ApplyFailed" || !strings.Contains(cond["message"].(string), "EnvoyExtensionPolicy") {
+		t.Fatalf("condition %v", cond)
+	}
+	// The condition did not change state, so its transition time stands.
+	if cond["lastTransitionTime"] != "2026-01-02T03:04:05Z" {
+		t.Fatalf("condition %v", cond)
+	}
+}
+
+func TestKube(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == http.MethodPatch:
+			if r.Header.Get("Content-Type") != "application/apply-patch+yaml" ||
+				r.URL.Query().Get("fieldManager") != manager || r.URL.Query().Get("force") != "true" {
+				t.Errorf("apply %s %v", r.URL, r.Header)
+			}
+			w.Write([]byte(`{}`))
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"kind": "Status", "message": "not found"}`))
+		case r.URL.Query().Get("watch") == "1":
+			if r.URL.Query().Get("resourceVersion") != "7" || r.URL.Query().Get("timeoutSeconds") != "60" {
+				t.Errorf("watch %s", r.URL)
+			}
+			w.Write([]byte(`{"type": "ADDED", "object": {"metadata": {"name": "chat", "uid": "u-1", "generation": 1}}}
+{"type": "ERROR", "object": {"kind": "Status", "code": 410}}
+`))
+		default:
+			w.Write([]byte(`{"metadata": {"resourceVersion": "7"}, "items": [{"metadata": {"name": "chat", "namespace": "ml"}}]}`))
+		}
+	}))
+	defer srv.Close()
+	k := &kube{base: srv.URL, http: srv.Client()}
+	ctx := context.Background()
+
+	items, version, err := k.list(ctx, "ml")
+	if err != nil || version != "7" || len(items) != 1 || items[0].Metadata.Name != "chat" {
+		t.Fatalf("list: %v %q %v", items, version, err)
+	}
+	var types []string
+	err = k.watch(ctx, "ml", version, 60, func(ev event) error {
+		types = append(types, ev.Type)
+		return nil
+	})
+	if err != nil || strings.Join(types, ",") != "ADDED,ERROR" {
+		t.Fatalf("watch: %v %v", types, err)
+	}
+	objs, _, _ := render(testPolicy(t, `{"apiKeySecretRef": {"name": "ogr"}}`), "ogw", "")
+	if err := k.apply(ctx, objs[1], ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.remove(ctx, object{"kind": "EnvoyExtensionPolicy", "metadata": map[string]any{"name": "ogr-chat", "namespace": "ml"}}); err != nil {
+		t.Fatalf("remove of a missing object: %v", err)
+	}
+	want := []string{
+		"GET /apis/guardrails.openguardrails.com/v1alpha1/namespaces/ml/guardrailpolicies",
+		"GET /apis/guardrails.openguardrails.com/v1alpha1/namespaces/ml/guardrailpolicies",
+		"PATCH /apis/apps/v1/namespaces/ml/deployments/ogr-chat",
+		"DELETE /apis/gateway.envoyproxy.io/v1alpha1/namespaces/ml/envoyextensionpolicies/ogr-chat",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("requests:\n%s", strings.Join(got, "\n"))
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/openguardrails/openguardrails/integrations/gateway/k8s-controller
+
+go 1.22
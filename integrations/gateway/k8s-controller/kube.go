@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// kube is a minimal Kubernetes API client: list, watch, server-side apply
+// and delete, which is all the controller does.
+type kube struct {
+	base string
+	http *http.Client
+	// tokenFile is re-read on every call: projected service account
+	// tokens are rotated in place.
+	tokenFile string
+}
+
+const serviceAccount = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+// inCluster connects with the pod's service account.
+func inCluster() (*kube, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("not running in a cluster (KUBERNETES_SERVICE_HOST is not set)")
+	}
+	ca, err := os.ReadFile(serviceAccount + "/ca.crt")
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, errors.New("no certificates in the service account CA bundle")
+	}
+	return &kube{
+		base:      "https://" + net.JoinHostPort(host, port),
+		http:      &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}},
+		tokenFile: serviceAccount + "/token",
+	}, nil
+}
+
+// apiError is a failed call, with the API's status.
+type apiError struct {
+	Code    int
+	Message string
+}
+
+func (e *apiError) Error() string { return fmt.Sprintf("kubernetes API: %d %s", e.Code, e.Message) }
+
+func isNotFound(err error) bool {
+	var e *apiError
+	return errors.As(err, &e) && e.Code == http.StatusNotFound
+}
+
+// do sends a request and returns the response for a 2xx status.
+func (k *kube) do(ctx context.Context, method, path, ctype string, body []byte) (*http.Response, error) {
+	var rd io.Reader
+	if body != nil {
+		rd = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, k.base+path, rd)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if ctype != "" {
+		req.Header.Set("Content-Type", ctype)
+	}
+	if k.tokenFile != "" {
+		token, err := os.ReadFile(k.tokenFile)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+	resp, err := k.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		var status struct{ Message string }
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		if json.Unmarshal(b, &status) != nil || status.Message == "" {
+			status.Message = strings.TrimSpace(string(b))
+		}
+		return nil, &apiError{Code: resp.StatusCode, Message: status.Message}
+	}
+	return resp, nil
+}
+
+// policiesPath is where GuardrailPolicies are listed and watched: in ns,
+// or everywhere when ns is empty.
+func policiesPath(ns string) string {
+	if ns == "" {
+		return "/apis/" + apiVersion + "/guardrailpolicies"
+	}
+	return "/apis/" + apiVersion + "/namespaces/" + url.PathEscape(ns) + "/guardrailpolicies"
+}
+
+// list returns the GuardrailPolicies and the resource version to watch
+// from.
+func (k *kube) list(ctx context.Context, ns string) ([]policy, string, error) {
+	resp, err := k.do(ctx, http.MethodGet, policiesPath(ns), "", nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Metadata struct {
+			ResourceVersion string `json:"resourceVersion"`
+		} `json:"metadata"`
+		Items []policy `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, "", err
+	}
+	return out.Items, out.Metadata.ResourceVersion, nil
+}
+
+// event is one watch event.
+type event struct {
+	Type   string          `json:"type"` // ADDED, MODIFIED, DELETED, BOOKMARK or ERROR
+	Object json.RawMessage `json:"object"`
+}
+
+// watch streams changes to GuardrailPolicies after version to f, until
+// the server ends the watch after timeout seconds, f fails or ctx is done.
+func (k *kube) watch(ctx context.Context, ns, version string, timeout int, f func(event) error) error {
+	q := url.Values{
+		"watch": {"1"}, "resourceVersion": {version},
+		"allowWatchBookmarks": {"true"}, "timeoutSeconds": {fmt.Sprint(timeout)},
+	}
+	resp, err := k.do(ctx, http.MethodGet, policiesPath(ns)+"?"+q.Encode(), "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var ev event
+		if err := dec.Decode(&ev); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		if err := f(ev); err != nil {
+			return err
+		}
+	}
+}
+
+// resources maps the kinds the controller writes to their API paths.
+var resources = map[string]string{
+	"ConfigMap":            "/api/v1/namespaces/%s/configmaps/%s",
+	"Service":              "/api/v1/namespaces/%s/services/%s",
+	"Deployment":           "/apis/apps/v1/namespaces/%s/deployments/%s",
+	"EnvoyExtensionPolicy": "/apis/gateway.envoyproxy.io/v1alpha1/namespaces/%s/envoyextensionpolicies/%s",
+	kind:                   "/apis/" + apiVersion + "/namespaces/%s/guardrailpolicies/%s",
+}
+
+func objectPath(obj object) string {
+	meta := obj["metadata"].(map[string]any)
+	return fmt.Sprintf(resources[obj["kind"].(string)], url.PathEscape(meta["namespace"].(string)), url.PathEscape(meta["name"].(string)))
+}
+
+// apply server-side applies obj (to its subresource, if any), taking over
+// fields other managers set.
+func (k *kube) apply(ctx context.Context, obj object, subresource string) error {
+	body, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	path := objectPath(obj)
+	if subresource != "" {
+		path += "/" + subresource
+	}
+	q := url.Values{"fieldManager": {manager}, "force": {"true"}}
+	resp, err := k.do(ctx, http.MethodPatch, path+"?"+q.Encode(), "application/apply-patch+yaml", body)
+	if err != nil {
+		return fmt.Errorf("apply %s %s: %w", obj["kind"], obj["metadata"].(map[string]any)["name"], err)
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// remove deletes obj if it exists.
+func (k *kube) remove(ctx context.Context, obj object) error {
+	resp, err := k.do(ctx, http.MethodDelete, objectPath(obj), "", nil)
+	if isNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("delete %s %s: %w", obj["kind"], obj["metadata"].(map[string]any)["name"], err)
+	}
+	resp.Body.Close()
+	return nil
+}
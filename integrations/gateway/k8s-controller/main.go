@@ -0,0 +1,81 @@
+// Command ogr-policy-controller is an OpenGuardrails gateway-hook
+// integration for Kubernetes: a controller that turns GuardrailPolicy
+// resources into running guardrails, so policies live in Git next to the
+// routes they protect and reach the cluster through the usual GitOps tools.
+//
+//	GuardrailPolicy ──▶ controller ──▶ ConfigMap + Deployment + Service (ogw, ext_proc mode)
+//	                                └─▶ EnvoyExtensionPolicy ──▶ target HTTPRoutes
+//
+// Each policy gets its own ogw deployment serving Envoy's external
+// processing protocol with the policy's risk threshold, category actions
+// and deny message, and an Envoy Gateway EnvoyExtensionPolicy attaching it
+// to the policy's target routes. Everything rendered is owned by the
+// policy and deleted with it. The outcome is reported in the policy's
+// Ready condition. See README.md and deploy/crd.yaml for the resource.
+//
+// Env:
+//
+//	OGR_CONTROLLER_IMAGE      ogw image the deployments run (required)
+//	OGR_CONTROLLER_NAMESPACE  watch only this namespace (default: all)
+//	OGR_CONTROLLER_RESYNC     full reconcile interval (default 5m)
+//	OGR_BASE_URL              detection API base URL passed to ogw (default: ogw's)
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func main() {
+	logger := log.New(os.Stderr, "ogr-policy-controller: ", log.LstdFlags)
+	c, err := controllerFromEnv()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if c.kube, err = inCluster(); err != nil {
+		logger.Fatal(err)
+	}
+	c.logger = logger
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	scope := c.namespace
+	if scope == "" {
+		scope = "all namespaces"
+	}
+	logger.Printf("watching GuardrailPolicies in %s (ogw %s)", scope, c.image)
+	if err := c.run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		logger.Fatal(err)
+	}
+}
+
+func controllerFromEnv() (*controller, error) {
+	image := os.Getenv("OGR_CONTROLLER_IMAGE")
+	if image == "" {
+		return nil, errors.New("OGR_CONTROLLER_IMAGE is required")
+	}
+	resync, err := time.ParseDuration(env("OGR_CONTROLLER_RESYNC", "5m"))
+	if err != nil || resync < time.Second {
+		return nil, fmt.Errorf("OGR_CONTROLLER_RESYNC: invalid value %q", os.Getenv("OGR_CONTROLLER_RESYNC"))
+	}
+	return &controller{
+		namespace: os.Getenv("OGR_CONTROLLER_NAMESPACE"),
+		image:     image,
+		baseURL:   os.Getenv("OGR_BASE_URL"),
+		resync:    resync,
+	}, nil
+}
+
+func env(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	group      = "guardrails.openguardrails.com"
+	apiVersion = group + "/v1alpha1"
+	kind       = "GuardrailPolicy"
+	manager    = "ogr-policy-controller"
+
+	extProcPort = 9002
+	httpPort    = 8080
+)
+
+// policy is a GuardrailPolicy.
+type policy struct {
+	APIVersion string     `json:"apiVersion"`
+	Kind       string     `json:"kind"`
+	Metadata   objectMeta `json:"metadata"`
+	Spec       policySpec `json:"spec"`
+	Status     struct {
+		Conditions []condition `json:"conditions,omitempty"`
+	} `json:"status"`
+}
+
+// condition is a metav1.Condition.
+type condition struct {
+	Type               string `json:"type"`
+	Status             string `json:"status"`
+	ObservedGeneration int64  `json:"observedGeneration"`
+	LastTransitionTime string `json:"lastTransitionTime"`
+	Reason             string `json:"reason"`
+	Message            string `json:"message"`
+}
+
+type objectMeta struct {
+	Name            string `json:"name"`
+	Namespace       string `json:"namespace"`
+	UID             string `json:"uid"`
+	Generation      int64  `json:"generation"`
+	ResourceVersion string `json:"resourceVersion"`
+}
+
+type policySpec struct {
+	// Sensitivity thresholds the overall risk level: high, medium or low
+	// blocks from low_risk, medium_risk or high_risk. Empty follows the
+	// platform's suggested action.
+	Sensitivity string `json:"sensitivity,omitempty"`
+	// Categories maps risk categories (S1…S21) to block or allow.
+	Categories map[string]string `json:"categories,omitempty"`
+	// DenyMessage replaces the platform's suggested answer in refusals.
+	DenyMessage string `json:"denyMessage,omitempty"`
+	// FailOpen lets traffic through while the detection API, or the
+	// processor itself, is unreachable.
+	FailOpen bool `json:"failOpen,omitempty"`
+	// APIKeySecretRef is the Secret key holding the detection API key.
+	APIKeySecretRef secretKeyRef `json:"apiKeySecretRef"`
+	// TargetRefs are the routes the policy applies to.
+	TargetRefs []targetRef `json:"targetRefs,omitempty"`
+	Replicas   *int32      `json:"replicas,omitempty"`
+}
+
+type secretKeyRef struct {
+	Name string `json:"name"`
+	Key  string `json:"key,omitempty"` // default api-key
+}
+
+type targetRef struct {
+	Group string `json:"group,omitempty"` // default gateway.networking.k8s.io
+	Kind  string `json:"kind,omitempty"`  // default HTTPRoute
+	Name  string `json:"name"`
+}
+
+// object is a Kubernetes object to apply.
+type object = map[string]any
+
+// render returns the objects that enforce p: the ogw configuration, its
+// Deployment and Service, and the Envoy Gateway extension policy that puts
+// it in front of the target routes (nil when there are none).
+func render(p *policy, image, baseURL string) (objs []object, extension object, err error) {
+	if err := validate(&p.Spec); err != nil {
+		return nil, nil, err
+	}
+	name := "ogr-" + p.Metadata.Name
+	ns := p.Metadata.Namespace
+	meta := func() map[string]any {
+		return map[string]any{
+			"name":            name,
+			"namespace":       ns,
+			"labels":          labels(p),
+			"ownerReferences": []any{ownerRef(p)},
+		}
+	}
+
+	// ogw reads YAML, of which JSON is a subset.
+	conf := map[string]any{
+		"version":  1,
+		"listen":   fmt.Sprintf(":%d", httpPort),
+		"ext_proc": map[string]any{"listen": fmt.Sprintf(":%d", extProcPort)},
+	}
+	if p.Spec.FailOpen {
+		conf["fail_open"] = true
+	}
+	if p.Spec.Sensitivity != "" {
+		conf["sensitivity"] = p.Spec.Sensitivity
+	}
+	if len(p.Spec.Categories) > 0 {
+		conf["categories"] = p.Spec.Categories
+	}
+	if p.Spec.DenyMessage != "" {
+		conf["deny_message"] = p.Spec.DenyMessage
+	}
+	yaml, _ := json.MarshalIndent(conf, "", "  ")
+	sum := sha256.Sum256(yaml)
+
+	key := p.Spec.APIKeySecretRef.Key
+	if key == "" {
+		key = "api-key"
+	}
+	env := []any{
+		map[string]any{"name": "OGW_CONFIG", "value": "/etc/ogw/ogw.yaml"},
+		map[string]any{"name": "OGR_API_KEY", "valueFrom": map[string]any{
+			"secretKeyRef": map[string]any{"name": p.Spec.APIKeySecretRef.Name, "key": key},
+		}},
+	}
+	if baseURL != "" {
+		env = append(env, map[string]any{"name": "OGR_BASE_URL", "value": baseURL})
+	}
+	replicas := int32(2)
+	if p.Spec.Replicas != nil {
+		replicas = *p.Spec.Replicas
+	}
+	selector := map[string]any{"app.kubernetes.io/name": "ogw", "app.kubernetes.io/instance": name}
+
+	objs = []object{
+		{
+			"apiVersion": "v1", "kind": "ConfigMap", "metadata": meta(),
+			"data": map[string]any{"ogw.yaml": string(yaml) + "\n"},
+		},
+		{
+			"apiVersion": "apps/v1", "kind": "Deployment", "metadata": meta(),
+			"spec": map[string]any{
+				"replicas": replicas,
+				"selector": map[string]any{"matchLabels": selector},
+				"template": map[string]any{
+					"metadata": map[string]any{
+						"labels": selector,
+						"annotations": map[string]any{
+							// Rolls the pods when the configuration changes.
+							group + "/config-hash": hex.EncodeToString(sum[:8]),
+							// The processor needs no sidecar of its own.
+							group + "/inject": "false",
+						},
+					},
+					"spec": map[string]any{
+						"containers": []any{map[string]any{
+							"name":  "ogw",
+							"image": image,
+							"env":   env,
+							"ports": []any{
+								map[string]any{"name": "http", "containerPort": httpPort},
+								map[string]any{"name": "grpc", "containerPort": extProcPort},
+							},
+							"readinessProbe": map[string]any{
+								"httpGet": map[string]any{"path": "/healthz", "port": "http"},
+							},
+							"volumeMounts": []any{map[string]any{"name": "config", "mountPath": "/etc/ogw", "readOnly": true}},
+							"securityContext": map[string]any{
+								"runAsNonRoot":             true,
+								"readOnlyRootFilesystem":   true,
+								"allowPrivilegeEscalation": false,
+								"capabilities":             map[string]any{"drop": []any{"ALL"}},
+							},
+						}},
+						"volumes": []any{map[string]any{
+							"name": "config", "configMap": map[string]any{"name": name},
+						}},
+					},
+				},
+			},
+		},
+		{
+			"apiVersion": "v1", "kind": "Service", "metadata": meta(),
+			"spec": map[string]any{
+				"selector": selector,
+				"ports": []any{map[string]any{
+					"name": "grpc", "port": extProcPort, "targetPort": "grpc",
+					// ext_proc is gRPC over cleartext HTTP/2.
+					"appProtocol": "kubernetes.io/h2c",
+				}},
+			},
+		},
+	}
+	if len(p.Spec.TargetRefs) == 0 {
+		return objs, nil, nil
+	}
+
+	var targets []any
+	for _, t := range p.Spec.TargetRefs {
+		g, k := t.Group, t.Kind
+		if g == "" {
+			g = "gateway.networking.k8s.io"
+		}
+		if k == "" {
+			k = "HTTPRoute"
+		}
+		targets = append(targets, map[string]any{"group": g, "kind": k, "name": t.Name})
+	}
+	extProc := map[string]any{
+		"backendRefs": []any{map[string]any{"name": name, "port": extProcPort}},
+		// Requests are checked whole; answers stream through as they are
+		// checked.
+		"processingMode": map[string]any{
+			"request":  map[string]any{"body": "Buffered"},
+			"response": map[string]any{"body": "Streamed"},
+		},
+		"messageTimeout": "30s",
+	}
+	if p.Spec.FailOpen {
+		extProc["failOpen"] = true
+	}
+	extension = object{
+		"apiVersion": "gateway.envoyproxy.io/v1alpha1", "kind": "EnvoyExtensionPolicy", "metadata": meta(),
+		"spec": map[string]any{"targetRefs": targets, "extProc": []any{extProc}},
+	}
+	return objs, extension, nil
+}
+
+// validate checks what the CRD schema cannot.
+func validate(s *policySpec) error {
+	switch s.Sensitivity {
+	case "", "high", "medium", "low":
+	default:
+		return fmt.Errorf("sensitivity %q is not one of high, medium, low", s.Sensitivity)
+	}
+	for c, a := range s.Categories {
+		if a != "block" && a != "allow" {
+			return fmt.Errorf("categories.%s: %q is not one of block, allow", c, a)
+		}
+	}
+	if s.APIKeySecretRef.Name == "" {
+		return fmt.Errorf("apiKeySecretRef.name is required")
+	}
+	for i, t := range s.TargetRefs {
+		if strings.TrimSpace(t.Name) == "" {
+			return fmt.Errorf("targetRefs[%d].name is required", i)
+		}
+	}
+	return nil
+}
+
+func labels(p *policy) map[string]any {
+	return map[string]any{
+		"app.kubernetes.io/name":       "ogw",
+		"app.kubernetes.io/instance":   "ogr-" + p.Metadata.Name,
+		"app.kubernetes.io/managed-by": manager,
+		group + "/policy":              p.Metadata.Name,
+	}
+}
+
+// ownerRef makes p own an object, so deleting p deletes it.
+func ownerRef(p *policy) map[string]any {
+	return map[string]any{
+		"apiVersion":         apiVersion,
+		"kind":               kind,
+		"name":               p.Metadata.Name,
+		"uid":                p.Metadata.UID,
+		"controller":         true,
+		"blockOwnerDeletion": true,
+	}
+}
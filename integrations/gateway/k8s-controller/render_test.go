@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func testPolicy(t *testing.T, spec string) *policy {
+	t.Helper()
+	var p policy
+	err := json.Unmarshal([]byte(`{"apiVersion": "guardrails.openguardrails.com/v1alpha1", "kind": "GuardrailPolicy",
+		"metadata": {"name": "chat", "namespace": "ml", "uid": "u-1", "generation": 3}, "spec": `+spec+`}`), &p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &p
+}
+
+// wire round-trips obj so it reads as the API server would.
+func wire(obj object) map[string]any {
+	var v map[string]any
+	b, _ := json.Marshal(obj)
+	json.Unmarshal(b, &v)
+	return v
+}
+
+func dig(v any, path ...string) any {
+	for _, k := range path {
+		m, _ := v.(map[string]any)
+		v = m[k]
+	}
+	return v
+}
+
+func TestRender(t *testing.T) {
+	p := testPolicy(t, `{
+		"sensitivity": "medium",
+		"categories": {"S9": "block", "S5": "allow"},
+		"denyMessage": "Not here.",
+		"failOpen": true,
+		"apiKeySecretRef": {"name": "ogr"},
+		"targetRefs": [{"name": "chat"}, {"kind": "Gateway", "name": "edge"}]
+	}`)
+	objs, ext, err := render(p, "registry.example.com/ogw:1", "https://ogr.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 3 || ext == nil {
+		t.Fatalf("rendered %d objects, extension %v", len(objs), ext)
+	}
+	for _, obj := range append(objs, ext) {
+		o := wire(obj)
+		if dig(o, "metadata", "name") != "ogr-chat" || dig(o, "metadata", "namespace") != "ml" {
+			t.Fatalf("%s metadata %v", o["kind"], o["metadata"])
+		}
+		owner := dig(o, "metadata", "ownerReferences").([]any)[0]
+		if dig(owner, "uid") != "u-1" || dig(owner, "kind") != kind || dig(owner, "controller") != true {
+			t.Fatalf("%s owner %v", o["kind"], owner)
+		}
+	}
+
+	// The ConfigMap carries an ogw configuration.
+	var conf map[string]any
+	if err := json.Unmarshal([]byte(dig(wire(objs[0]), "data", "ogw.yaml").(string)), &conf); err != nil {
+		t.Fatal(err)
+	}
+	if conf["version"] != 1.0 || dig(conf, "ext_proc", "listen") != ":9002" || conf["sensitivity"] != "medium" ||
+		dig(conf, "categories", "S9") != "block" || conf["deny_message"] != "Not here." || conf["fail_open"] != true {
+		t.Fatalf("ogw.yaml %v", conf)
+	}
+
+	deploy := wire(objs[1])
+	pod := dig(deploy, "spec", "template")
+	if dig(pod, "metadata", "annotations", group+"/inject") != "false" || dig(pod, "metadata", "annotations", group+"/config-hash") == nil {
+		t.Fatalf("pod annotations %v", dig(pod, "metadata", "annotations"))
+	}
+	if dig(deploy, "spec", "replicas") != 2.0 {
+		t.Fatalf("replicas %v", dig(deploy, "spec", "replicas"))
+	}
+	container := dig(pod, "spec", "containers").([]any)[0]
+	env := map[string]any{}
+	for _, e := range dig(container, "env").([]any) {
+		env[dig(e, "name").(string)] = e
+	}
+	if dig(container, "image") != "registry.example.com/ogw:1" ||
+		dig(env["OGR_API_KEY"], "valueFrom", "secretKeyRef", "key") != "api-key" ||
+		dig(env["OGR_BASE_URL"], "value") != "https://ogr.example.com" {
+		t.Fatalf("container %v", container)
+	}
+
+	spec := wire(ext)["spec"]
+	targets := dig(spec, "targetRefs").([]any)
+	if dig(targets[0], "kind") != "HTTPRoute" || dig(targets[0], "group") != "gateway.networking.k8s.io" || dig(targets[1], "kind") != "Gateway" {
+		t.Fatalf("targets %v", targets)
+	}
+	proc := dig(spec, "extProc").([]any)[0]
+	if dig(proc, "processingMode", "request", "body") != "Buffered" || dig(proc, "processingMode", "response", "body") != "Streamed" ||
+		dig(proc, "failOpen") != true || dig(proc, "backendRefs").([]any)[0].(map[string]any)["port"] != 9002.0 {
+		t.Fatalf("extProc %v", proc)
+	}
+}
+
+func TestRenderConfigHash(t *testing.T) {
+	hash := func(spec string) any {
+		objs, _, err := render(testPolicy(t, spec), "ogw", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dig(wire(objs[1]), "spec", "template", "metadata", "annotations", group+"/config-hash")
+	}
+	a := hash(`{"apiKeySecretRef": {"name": "ogr"}}`)
+	if b := hash(`{"apiKeySecretRef": {"name": "ogr"}, "targetRefs": [{"name": "chat"}]}`); a != b {
+		t.Fatal("hash changed with the targets")
+	}
+	if b := hash(`{"apiKeySecretRef": {"name": "ogr"}, "sensitivity": "high"}`); a == b {
+		t.Fatal("hash unchanged with the configuration")
+	}
+}
+
+func TestRenderInvalid(t *testing.T) {
+	for spec, want := range map[string]string{
+		`{"apiKeySecretRef": {"name": "ogr"}, "sensitivity": "max"}`:         "sensitivity",
+		`{"apiKeySecretRef": {"name": "ogr"}, "categories": {"S9": "warn"}}`: "categories.S9",
+		`{}`: "apiKeySecretRef.name",
+		`{"apiKeySecretRef": {"name": "ogr"}, "targetRefs": [{"name": " "}]}`: "targetRefs[0]",
+	} {
+		if _, _, err := render(testPolicy(t, spec), "ogw", ""); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: %v, want %s", spec, err, want)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/openguardrails/openguardrails/integrations/gateway/k8s-injector
+
+go 1.22
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Annotations a pod may carry to tune its sidecar.
+const (
+	annotationPrefix = "guardrails.openguardrails.com/"
+	// annInject "false" opts a pod out in a namespace that is injected.
+	annInject = annotationPrefix + "inject"
+	// annUpstream overrides the model API the sidecar forwards to.
+	annUpstream = annotationPrefix + "upstream"
+	// annConfig names a ConfigMap holding an ogw.yaml, for several backends
+	// or anything the env configuration does not cover.
+	annConfig = annotationPrefix + "config"
+	// annFailOpen "true" lets traffic through while the detection API is
+	// unreachable.
+	annFailOpen = annotationPrefix + "fail-open"
+	// annStatus marks a pod as injected, so a reinvocation leaves it alone.
+	annStatus = annotationPrefix + "status"
+)
+
+const sidecarName = "openguardrails"
+
+// injector holds what every injected sidecar shares.
+type injector struct {
+	image    string
+	port     int
+	upstream string // default OGW_UPSTREAM_URL
+	baseURL  string // OGR_BASE_URL, if not the default
+	secret   string // Secret with api-key and, optionally, upstream-key
+	// envs are the variables pointed at the sidecar in every app container.
+	envs []string
+}
+
+// pod is the part of a Pod the injector reads. Env entries are kept raw
+// so the ones it does not touch are written back exactly.
+type pod struct {
+	Metadata struct {
+		Name         string            `json:"name"`
+		GenerateName string            `json:"generateName"`
+		Annotations  map[string]string `json:"annotations"`
+	} `json:"metadata"`
+	Spec struct {
+		Containers     []container       `json:"containers"`
+		InitContainers []container       `json:"initContainers"`
+		Volumes        []json.RawMessage `json:"volumes"`
+	} `json:"spec"`
+}
+
+type container struct {
+	Name string            `json:"name"`
+	Env  []json.RawMessage `json:"env"`
+}
+
+// patchOp is one RFC 6902 JSON Patch operation.
+type patchOp struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value,omitempty"`
+}
+
+// patch returns the JSON Patch that injects the sidecar into p, or nil when
+// p opts out or already has one.
+func (in *injector) patch(p *pod) ([]patchOp, error) {
+	ann := p.Metadata.Annotations
+	if ann[annInject] == "false" || ann[annStatus] != "" {
+		return nil, nil
+	}
+	for _, c := range append(p.Spec.InitContainers, p.Spec.Containers...) {
+		if c.Name == sidecarName {
+			return nil, nil
+		}
+	}
+
+	var ops []patchOp
+	// Every app container talks to the sidecar instead of the provider.
+	base := fmt.Sprintf("http://127.0.0.1:%d/v1", in.port)
+	for i, c := range p.Spec.Containers {
+		env, err := setEnv(c.Env, in.envs, base)
+		if err != nil {
+			return nil, fmt.Errorf("container %s: %w", c.Name, err)
+		}
+		ops = append(ops, patchOp{Op: "add", Path: fmt.Sprintf("/spec/containers/%d/env", i), Value: env})
+	}
+
+	sidecar, volume := in.sidecar(ann)
+	// A native sidecar (an init container that keeps running) starts
+	// before the app containers and stops after them.
+	if p.Spec.InitContainers == nil {
+		ops = append(ops, patchOp{Op: "add", Path: "/spec/initContainers", Value: []any{sidecar}})
+	} else {
+		ops = append(ops, patchOp{Op: "add", Path: "/spec/initContainers/-", Value: sidecar})
+	}
+	if volume != nil {
+		if p.Spec.Volumes == nil {
+			ops = append(ops, patchOp{Op: "add", Path: "/spec/volumes", Value: []any{volume}})
+		} else {
+			ops = append(ops, patchOp{Op: "add", Path: "/spec/volumes/-", Value: volume})
+		}
+	}
+	if ann == nil {
+		ops = append(ops, patchOp{Op: "add", Path: "/metadata/annotations", Value: map[string]string{annStatus: "injected"}})
+	} else {
+		ops = append(ops, patchOp{Op: "add", Path: "/metadata/annotations/" + escape(annStatus), Value: "injected"})
+	}
+	return ops, nil
+}
+
+// setEnv returns env with each of names set to value, in place where the
+// container already sets it.
+func setEnv(env []json.RawMessage, names []string, value string) ([]json.RawMessage, error) {
+	out := make([]json.RawMessage, 0, len(env)+len(names))
+	set := map[string]bool{}
+	for _, e := range env {
+		var v struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(e, &v); err != nil {
+			return nil, err
+		}
+		for _, n := range names {
+			if v.Name == n {
+				e, set[n] = envVar(n, value), true
+			}
+		}
+		out = append(out, e)
+	}
+	for _, n := range names {
+		if !set[n] {
+			out = append(out, envVar(n, value))
+		}
+	}
+	return out, nil
+}
+
+func envVar(name, value string) json.RawMessage {
+	b, _ := json.Marshal(map[string]string{"name": name, "value": value})
+	return b
+}
+
+// sidecar returns the ogw container for a pod with the given annotations,
+// and the volume its configuration comes from, if any.
+func (in *injector) sidecar(ann map[string]string) (map[string]any, map[string]any) {
+	upstream := in.upstream
+	if u := ann[annUpstream]; u != "" {
+		upstream = u
+	}
+	env := []any{
+		map[string]any{"name": "OGW_LISTEN", "value": "127.0.0.1:" + strconv.Itoa(in.port)},
+		map[string]any{"name": "OGW_UPSTREAM_URL", "value": upstream},
+		map[string]any{"name": "OGR_API_KEY", "valueFrom": map[string]any{
+			"secretKeyRef": map[string]any{"name": in.secret, "key": "api-key"},
+		}},
+		map[string]any{"name": "OGW_UPSTREAM_KEY", "valueFrom": map[string]any{
+			"secretKeyRef": map[string]any{"name": in.secret, "key": "upstream-key", "optional": true},
+		}},
+	}
+	if in.baseURL != "" {
+		env = append(env, map[string]any{"name": "OGR_BASE_URL", "value": in.baseURL})
+	}
+	if ann[annFailOpen] == "true" {
+		env = append(env, map[string]any{"name": "OGR_FAIL_MODE_CLOSED", "value": "false"})
+	}
+	c := map[string]any{
+		"name":          sidecarName,
+		"image":         in.image,
+		"restartPolicy": "Always",
+		"env":           env,
+		"resources": map[string]any{
+			"requests": map[string]any{"cpu": "50m", "memory": "64Mi"},
+		},
+		"securityContext": map[string]any{
+			"runAsNonRoot":             true,
+			"readOnlyRootFilesystem":   true,
+			"allowPrivilegeEscalation": false,
+			"capabilities":             map[string]any{"drop": []string{"ALL"}},
+		},
+	}
+	cm := ann[annConfig]
+	if cm == "" {
+		return c, nil
+	}
+	c["env"] = append(env, map[string]any{"name": "OGW_CONFIG", "value": "/etc/ogw/ogw.yaml"})
+	c["volumeMounts"] = []any{map[string]any{"name": sidecarName + "-config", "mountPath": "/etc/ogw", "readOnly": true}}
+	return c, map[string]any{
+		"name":      sidecarName + "-config",
+		"configMap": map[string]any{"name": cm},
+	}
+}
+
+// escape escapes a JSON Pointer reference token (RFC 6901).
+func escape(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
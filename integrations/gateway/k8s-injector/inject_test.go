@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func testInjector() *injector {
+	return &injector{
+		image: "registry.example.com/ogw:1", port: 15480,
+		upstream: "https://api.openai.com/v1", secret: "openguardrails",
+		envs: []string{"OPENAI_BASE_URL", "OPENAI_API_BASE"},
+	}
+}
+
+// apply applies the add operations the injector emits to doc.
+func apply(t *testing.T, doc map[string]any, ops []patchOp) {
+	t.Helper()
+	for _, op := range ops {
+		if op.Op != "add" {
+			t.Fatalf("unexpected op %+v", op)
+		}
+		// Round-trip the value so it looks as it would on the wire.
+		var v any
+		b, _ := json.Marshal(op.Value)
+		json.Unmarshal(b, &v)
+
+		tokens := strings.Split(op.Path, "/")[1:]
+		var parent any = doc
+		for _, tok := range tokens[:len(tokens)-1] {
+			switch p := parent.(type) {
+			case map[string]any:
+				parent = p[tok]
+			case []any:
+				i, _ := strconv.Atoi(tok)
+				parent = p[i]
+			}
+		}
+		last := strings.NewReplacer("~1", "/", "~0", "~").Replace(tokens[len(tokens)-1])
+		switch p := parent.(type) {
+		case map[string]any:
+			if last == "-" {
+				t.Fatalf("append to an object: %s", op.Path)
+			}
+			p[last] = v
+		case []any:
+			if last != "-" {
+				t.Fatalf("insert into a list: %s", op.Path)
+			}
+			// Lists are reached through their parent; rebuild it there.
+			apply(t, doc, []patchOp{{Op: "add", Path: "/" + strings.Join(tokens[:len(tokens)-1], "/"), Value: append(p, v)}})
+		default:
+			t.Fatalf("no parent for %s", op.Path)
+		}
+	}
+}
+
+func decode(t *testing.T, s string) (map[string]any, *pod) {
+	t.Helper()
+	var doc map[string]any
+	var p pod
+	if err := json.Unmarshal([]byte(s), &doc); err != nil {
+		t.Fatal(err)
+	}
+	json.Unmarshal([]byte(s), &p)
+	return doc, &p
+}
+
+func TestPatch(t *testing.T) {
+	in := testInjector()
+	doc, p := decode(t, `{
+		"metadata": {"name": "app", "annotations": {"guardrails.openguardrails.com/config": "llm-routes"}},
+		"spec": {
+			"initContainers": [{"name": "migrate", "image": "migrate"}],
+			"containers": [
+				{"name": "app", "image": "app", "env": [
+					{"name": "OPENAI_BASE_URL", "value": "https://api.openai.com/v1"},
+					{"name": "OPENAI_API_KEY", "valueFrom": {"secretKeyRef": {"name": "openai", "key": "key"}}}
+				]},
+				{"name": "worker", "image": "worker"}
+			],
+			"volumes": [{"name": "data", "emptyDir": {}}]
+		}
+	}`)
+	ops, err := in.patch(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apply(t, doc, ops)
+
+	spec := doc["spec"].(map[string]any)
+	containers := spec["containers"].([]any)
+	appEnv := containers[0].(map[string]any)["env"].([]any)
+	if len(appEnv) != 3 ||
+		appEnv[0].(map[string]any)["value"] != "http://127.0.0.1:15480/v1" ||
+		appEnv[1].(map[string]any)["valueFrom"] == nil ||
+		appEnv[2].(map[string]any)["name"] != "OPENAI_API_BASE" {
+		t.Fatalf("app env %v", appEnv)
+	}
+	if env := containers[1].(map[string]any)["env"].([]any); len(env) != 2 {
+		t.Fatalf("worker env %v", env)
+	}
+
+	inits := spec["initContainers"].([]any)
+	if len(inits) != 2 || inits[0].(map[string]any)["name"] != "migrate" {
+		t.Fatalf("init containers %v", inits)
+	}
+	sidecar := inits[1].(map[string]any)
+	if sidecar["name"] != sidecarName || sidecar["image"] != in.image || sidecar["restartPolicy"] != "Always" {
+		t.Fatalf("sidecar %v", sidecar)
+	}
+	env := map[string]any{}
+	for _, e := range sidecar["env"].([]any) {
+		e := e.(map[string]any)
+		env[e["name"].(string)] = e["value"]
+		if e["value"] == nil {
+			env[e["name"].(string)] = e["valueFrom"]
+		}
+	}
+	if env["OGW_LISTEN"] != "127.0.0.1:15480" || env["OGW_CONFIG"] != "/etc/ogw/ogw.yaml" || env["OGR_API_KEY"] == nil {
+		t.Fatalf("sidecar env %v", env)
+	}
+	if vols := spec["volumes"].([]any); len(vols) != 2 || vols[1].(map[string]any)["configMap"].(map[string]any)["name"] != "llm-routes" {
+		t.Fatalf("volumes %v", vols)
+	}
+	if ann := doc["metadata"].(map[string]any)["annotations"].(map[string]any); ann[annStatus] != "injected" {
+		t.Fatalf("annotations %v", ann)
+	}
+
+	// The patched pod is left alone, as are pods that opt out.
+	b, _ := json.Marshal(doc)
+	json.Unmarshal(b, p)
+	if ops, _ := in.patch(p); ops != nil {
+		t.Fatalf("reinjected: %+v", ops)
+	}
+	_, p = decode(t, `{"metadata": {"annotations": {"guardrails.openguardrails.com/inject": "false"}}, "spec": {"containers": [{"name": "app"}]}}`)
+	if ops, _ := in.patch(p); ops != nil {
+		t.Fatalf("opted-out pod injected: %+v", ops)
+	}
+}
+
+func TestPatchBarePod(t *testing.T) {
+	in := testInjector()
+	doc, p := decode(t, `{"metadata": {"name": "app", "annotations": null},
+		"spec": {"containers": [{"name": "app", "image": "app"}]}}`)
+	p.Metadata.Annotations = nil
+	ops, err := in.patch(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	apply(t, doc, ops)
+	spec := doc["spec"].(map[string]any)
+	if inits := spec["initContainers"].([]any); len(inits) != 1 {
+		t.Fatalf("init containers %v", inits)
+	}
+	if _, ok := spec["volumes"]; ok {
+		t.Fatal("volume added without a config")
+	}
+	if ann := doc["metadata"].(map[string]any)["annotations"].(map[string]any); ann[annStatus] != "injected" {
+		t.Fatalf("annotations %v", ann)
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	h := &webhook{in: testInjector(), logger: log.New(io.Discard, "", 0)}
+	review := func(op, object string) admissionResponse {
+		t.Helper()
+		body := `{"apiVersion": "admission.k8s.io/v1", "kind": "AdmissionReview", "request": {"uid": "u-1", "namespace": "ml", "operation": "` +
+			op + `", "object": ` + object + `}}`
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/mutate", bytes.NewReader([]byte(body))))
+		var out admissionReview
+		if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil || out.Response == nil || out.Kind != "AdmissionReview" {
+			t.Fatalf("%d %s", w.Code, w.Body)
+		}
+		return *out.Response
+	}
+
+	resp := review("CREATE", `{"metadata": {"generateName": "app-"}, "spec": {"containers": [{"name": "app"}]}}`)
+	if resp.UID != "u-1" || !resp.Allowed || resp.PatchType != "JSONPatch" {
+		t.Fatalf("create: %+v", resp)
+	}
+	var ops []patchOp
+	if err := json.Unmarshal(resp.Patch, &ops); err != nil || len(ops) != 3 {
+		t.Fatalf("patch %s: %v", resp.Patch, err)
+	}
+
+	if resp := review("UPDATE", `{"spec": {"containers": [{"name": "app"}]}}`); !resp.Allowed || resp.Patch != nil {
+		t.Fatalf("update: %+v", resp)
+	}
+	// A pod the injector cannot read is admitted unchanged.
+	if resp := review("CREATE", `{"spec": {"containers": [{"name": "app", "env": [1]}]}}`); !resp.Allowed || resp.Patch != nil {
+		t.Fatalf("unreadable pod: %+v", resp)
+	}
+}
@@ -0,0 +1,110 @@
+// Command ogr-injector is an OpenGuardrails gateway-hook integration for
+// Kubernetes: a mutating admission webhook that adds the ogw security
+// gateway to pods as a sidecar and points their OpenAI clients at it, so a
+// platform team guards every LLM call in a namespace without app changes.
+//
+//	app container ──OPENAI_BASE_URL=http://127.0.0.1:<port>/v1──▶ ogw sidecar ──▶ model API
+//	                                                                   │
+//	                                                                   └── POST /v1/guardrails
+//
+// Pods in namespaces selected by the webhook configuration get the sidecar
+// as a native sidecar (an init container with restartPolicy Always,
+// Kubernetes 1.29+), and their containers get OGR_INJECT_ENV set to the
+// sidecar's address. The sidecar listens on loopback only. Its keys come
+// from a Secret in the pod's namespace: api-key for the detection API and,
+// optionally, upstream-key for the model API. Pod annotations
+// (guardrails.openguardrails.com/…) opt out or tune the sidecar; see
+// README.md.
+//
+// Env:
+//
+//	OGR_INJECT_LISTEN    listen address (default :8443)
+//	OGR_INJECT_TLS_CERT  serving certificate (default /etc/webhook/tls/tls.crt)
+//	OGR_INJECT_TLS_KEY   its key (default /etc/webhook/tls/tls.key)
+//	OGR_INJECT_IMAGE     sidecar image: an ogw build (required)
+//	OGR_INJECT_PORT      sidecar loopback port (default 15480)
+//	OGR_INJECT_UPSTREAM  default model API base URL (default https://api.openai.com/v1)
+//	OGR_INJECT_SECRET    Secret holding the sidecar's keys (default openguardrails)
+//	OGR_INJECT_ENV       comma-separated variables pointed at the sidecar
+//	                     (default OPENAI_BASE_URL,OPENAI_API_BASE)
+//	OGR_BASE_URL         detection API base URL passed to the sidecar (default: ogw's)
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+func main() {
+	logger := log.New(os.Stderr, "ogr-injector: ", log.LstdFlags)
+	in, err := injectorFromEnv()
+	if err != nil {
+		logger.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	mux.Handle("POST /mutate", &webhook{in: in, logger: logger})
+
+	srv := &http.Server{Addr: env("OGR_INJECT_LISTEN", ":8443"), Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+	logger.Printf("listening on %s (sidecar %s on :%d)", srv.Addr, in.image, in.port)
+	err = srv.ListenAndServeTLS(env("OGR_INJECT_TLS_CERT", "/etc/webhook/tls/tls.crt"), env("OGR_INJECT_TLS_KEY", "/etc/webhook/tls/tls.key"))
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Fatal(err)
+	}
+}
+
+func injectorFromEnv() (*injector, error) {
+	image := os.Getenv("OGR_INJECT_IMAGE")
+	if image == "" {
+		return nil, errors.New("OGR_INJECT_IMAGE is required")
+	}
+	port, err := strconv.Atoi(env("OGR_INJECT_PORT", "15480"))
+	if err != nil || port <= 0 || port > 65535 {
+		return nil, fmt.Errorf("OGR_INJECT_PORT: invalid value %q", os.Getenv("OGR_INJECT_PORT"))
+	}
+	return &injector{
+		image:    image,
+		port:     port,
+		upstream: env("OGR_INJECT_UPSTREAM", "https://api.openai.com/v1"),
+		baseURL:  os.Getenv("OGR_BASE_URL"),
+		secret:   env("OGR_INJECT_SECRET", "openguardrails"),
+		envs:     list(env("OGR_INJECT_ENV", "OPENAI_BASE_URL,OPENAI_API_BASE")),
+	}, nil
+}
+
+func env(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return def
+}
+
+func list(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
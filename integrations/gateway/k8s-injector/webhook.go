@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// admissionReview is an admission.k8s.io/v1 AdmissionReview, reduced to
+// what a mutating webhook reads and writes.
+type admissionReview struct {
+	APIVersion string             `json:"apiVersion"`
+	Kind       string             `json:"kind"`
+	Request    *admissionRequest  `json:"request,omitempty"`
+	Response   *admissionResponse `json:"response,omitempty"`
+}
+
+type admissionRequest struct {
+	UID       string          `json:"uid"`
+	Namespace string          `json:"namespace"`
+	Operation string          `json:"operation"`
+	Object    json.RawMessage `json:"object"`
+}
+
+type admissionResponse struct {
+	UID       string `json:"uid"`
+	Allowed   bool   `json:"allowed"`
+	PatchType string `json:"patchType,omitempty"`
+	Patch     []byte `json:"patch,omitempty"`
+}
+
+// webhook answers AdmissionReviews for pod creation. It never denies a
+// pod: one it cannot read is admitted as it is, and logged.
+type webhook struct {
+	in     *injector
+	logger *log.Logger
+}
+
+func (h *webhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var review admissionReview
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4<<20)).Decode(&review); err != nil || review.Request == nil {
+		http.Error(w, "invalid AdmissionReview", http.StatusBadRequest)
+		return
+	}
+	req := review.Request
+	resp := &admissionResponse{UID: req.UID, Allowed: true}
+	if req.Operation == "CREATE" {
+		var p pod
+		var ops []patchOp
+		err := json.Unmarshal(req.Object, &p)
+		if err == nil {
+			ops, err = h.in.patch(&p)
+		}
+		name := p.Metadata.Name
+		if name == "" {
+			name = p.Metadata.GenerateName + "*"
+		}
+		switch {
+		case err != nil:
+			h.logger.Printf("%s/%s: not injected: %v", req.Namespace, name, err)
+		case ops != nil:
+			resp.PatchType = "JSONPatch"
+			resp.Patch, _ = json.Marshal(ops)
+			h.logger.Printf("%s/%s: injected", req.Namespace, name)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(admissionReview{
+		APIVersion: "admission.k8s.io/v1",
+		Kind:       "AdmissionReview",
+		Response:   resp,
+	})
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/openguardrails/openguardrails-go"
+)
+
+// logPayload is the part of LiteLLM's StandardLoggingPayload the service
+// reads. The generic API logger posts a list of them; older versions post
+// one at a time.
+type logPayload struct {
+	ID       string          `json:"id"`
+	CallType string          `json:"call_type"`
+	Status   string          `json:"status"` // "success" | "failure"
+	Model    string          `json:"model"`
+	Messages json.RawMessage `json:"messages"`
+	Response json.RawMessage `json:"response"`
+	Metadata map[string]any  `json:"metadata"`
+	EndUser  string          `json:"end_user"`
+	ErrorStr string          `json:"error_str"`
+}
+
+// callbackHandler receives LiteLLM's success and failure logs and checks
+// the conversations they record after the fact. Nothing is blocked: the
+// checks land in the platform's detection log, and risky ones in this
+// service's log, for moderation.
+type callbackHandler struct {
+	g *guard
+	// dispatch runs the checks off the request, so LiteLLM's logger is
+	// never held up.
+	dispatch func(func())
+}
+
+func (h *callbackHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var b bytes.Buffer
+	if _, err := b.ReadFrom(http.MaxBytesReader(w, r.Body, guardrails.DefaultMaxBody)); err != nil {
+		http.Error(w, "invalid log payload", http.StatusBadRequest)
+		return
+	}
+	var logs []logPayload
+	if json.Unmarshal(b.Bytes(), &logs) != nil {
+		var one logPayload
+		if err := json.Unmarshal(b.Bytes(), &one); err != nil {
+			http.Error(w, "invalid log payload", http.StatusBadRequest)
+			return
+		}
+		logs = []logPayload{one}
+	}
+	h.dispatch(func() {
+		for i := range logs {
+			h.g.moderate(context.Background(), &logs[i])
+		}
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// moderate checks what a logged call sent, and what it answered if it
+// succeeded.
+func (g *guard) moderate(ctx context.Context, p *logPayload) {
+	user := p.EndUser
+	if user == "" {
+		user = g.userID(p.Metadata)
+	}
+	if p.Status == "failure" {
+		g.logger.Printf("call %s (%s, user %q) failed: %s", p.ID, p.Model, user, p.ErrorStr)
+	}
+	conv := loggedMessages(p.Messages)
+	if p.Status != "failure" {
+		if answer := loggedAnswer(p.Response); answer != "" {
+			conv = append(conv, guardrails.Message{Role: "assistant", Content: answer})
+		}
+	}
+	if len(conv) == 0 {
+		return
+	}
+	resp, err := g.check(ctx, conv, user)
+	switch {
+	case err != nil:
+		g.logger.Printf("call %s: check failed: %v", p.ID, err)
+	case !resp.IsSafe():
+		g.logger.Printf("call %s (%s, user %q) flagged: %s", p.ID, p.Model, user, resp.Describe())
+	}
+}
+
+// loggedMessages reads the logged input: chat messages, or the prompt of a
+// text completion.
+func loggedMessages(raw json.RawMessage) []guardrails.Message {
+	var msgs []chatMessage
+	if json.Unmarshal(raw, &msgs) == nil {
+		return conversation(msgs)
+	}
+	if text := content(raw); text != "" {
+		return []guardrails.Message{{Role: "user", Content: text}}
+	}
+	return nil
+}
+
+// loggedAnswer reads the first choice of a logged chat or text completion.
+func loggedAnswer(raw json.RawMessage) string {
+	var resp struct {
+		Choices []struct {
+			Message struct {
+				Content json.RawMessage `json:"content"`
+			} `json:"message"`
+			Text string `json:"text"`
+		} `json:"choices"`
+	}
+	if json.Unmarshal(raw, &resp) != nil {
+		return content(raw)
+	}
+	if len(resp.Choices) == 0 {
+		return ""
+	}
+	if c := resp.Choices[0]; c.Text != "" {
+		return c.Text
+	}
+	return content(resp.Choices[0].Message.Content)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go"
+)
+
+// guard holds what the hook and callback endpoints share.
+type guard struct {
+	c       *guardrails.Client
+	timeout time.Duration
+	// redact masks the texts of a call whose only findings are
+	// data-security ones, instead of blocking it.
+	redact bool
+	// failClosed blocks calls while the detection API is unreachable.
+	failClosed bool
+	// userKeys are the LiteLLM metadata fields tried, in order, for the
+	// end user a check is attributed to.
+	userKeys []string
+	prompts  *prompts
+	logger   *log.Logger
+}
+
+// chatMessage is an OpenAI chat message as LiteLLM passes it on; content
+// is a string or a list of parts.
+type chatMessage struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// conversation converts msgs to the SDK's, keeping their text.
+func conversation(msgs []chatMessage) []guardrails.Message {
+	var out []guardrails.Message
+	for _, m := range msgs {
+		if text := content(m.Content); text != "" {
+			out = append(out, guardrails.Message{Role: m.Role, Content: text})
+		}
+	}
+	return out
+}
+
+// content returns the text of a message's content.
+func content(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var parts []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	json.Unmarshal(raw, &parts)
+	var texts []string
+	for _, p := range parts {
+		if p.Type == "text" && p.Text != "" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// userID finds the end user in LiteLLM metadata, looking at the top level
+// and then under "metadata".
+func (g *guard) userID(data map[string]any) string {
+	nested, _ := data["metadata"].(map[string]any)
+	for _, k := range g.userKeys {
+		for _, m := range []map[string]any{data, nested} {
+			if v, ok := m[k].(string); ok && v != "" {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+// check checks conv for user. resp is nil when the check failed.
+func (g *guard) check(ctx context.Context, conv []guardrails.Message, user string) (*guardrails.Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	var opts []guardrails.CheckOption
+	if user != "" {
+		opts = append(opts, guardrails.WithUserID(user))
+	}
+	return g.c.CheckConversation(ctx, conv, opts...)
+}
+
+// prompts keeps the prompts checked before a call, by LiteLLM call ID, so
+// the answer is checked in their context. It is bounded, since calls that
+// fail upstream never come back for the post-call check.
+type prompts struct {
+	mu    sync.Mutex
+	max   int
+	order *list.List
+	calls map[string]*list.Element
+}
+
+type promptEntry struct {
+	id   string
+	conv []guardrails.Message
+}
+
+func newPrompts(max int) *prompts {
+	return &prompts{max: max, order: list.New(), calls: map[string]*list.Element{}}
+}
+
+func (p *prompts) put(id string, conv []guardrails.Message) {
+	if id == "" {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if el, ok := p.calls[id]; ok {
+		el.Value.(*promptEntry).conv = conv
+		return
+	}
+	p.calls[id] = p.order.PushFront(&promptEntry{id: id, conv: conv})
+	for p.order.Len() > p.max {
+		old := p.order.Back()
+		p.order.Remove(old)
+		delete(p.calls, old.Value.(*promptEntry).id)
+	}
+}
+
+// take returns and forgets the prompt of call id.
+func (p *prompts) take(id string) []guardrails.Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	el, ok := p.calls[id]
+	if !ok {
+		return nil
+	}
+	p.order.Remove(el)
+	delete(p.calls, id)
+	return el.Value.(*promptEntry).conv
+}
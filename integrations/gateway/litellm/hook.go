@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/openguardrails/openguardrails-go"
+)
+
+// hookRequest is what LiteLLM's generic guardrail API posts, once before
+// the call (input_type "request") and once after it ("response").
+type hookRequest struct {
+	Texts              []string       `json:"texts"`
+	StructuredMessages []chatMessage  `json:"structured_messages"`
+	RequestData        map[string]any `json:"request_data"`
+	InputType          string         `json:"input_type"`
+	CallID             string         `json:"litellm_call_id"`
+}
+
+// hookResponse tells LiteLLM what to do: NONE lets the call through,
+// BLOCKED fails it with blocked_reason, and GUARDRAIL_INTERVENED replaces
+// the texts with Texts.
+type hookResponse struct {
+	Action        string   `json:"action"`
+	BlockedReason string   `json:"blocked_reason,omitempty"`
+	Texts         []string `json:"texts,omitempty"`
+}
+
+// hookHandler serves the pre-call and post-call hooks.
+type hookHandler struct {
+	g *guard
+}
+
+func (h *hookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req hookRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, guardrails.DefaultMaxBody)).Decode(&req); err != nil {
+		http.Error(w, "invalid guardrail request", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.g.hook(r.Context(), &req))
+}
+
+func (g *guard) hook(ctx context.Context, req *hookRequest) hookResponse {
+	user := g.userID(req.RequestData)
+	var conv []guardrails.Message
+	if req.InputType == "response" {
+		// The answer is judged in the context of the prompt that asked for
+		// it, when this service checked that prompt.
+		conv = g.prompts.take(req.CallID)
+		if text := strings.Join(req.Texts, "\n"); text != "" {
+			conv = append(conv, guardrails.Message{Role: "assistant", Content: text})
+		}
+	} else {
+		conv = conversation(req.StructuredMessages)
+		if len(conv) == 0 {
+			for _, t := range req.Texts {
+				if t != "" {
+					conv = append(conv, guardrails.Message{Role: "user", Content: t})
+				}
+			}
+		}
+	}
+	// System messages alone are the deployment's own; any other text is
+	// checked, wherever it sits in the conversation.
+	if !hasTurns(conv) {
+		return hookResponse{Action: "NONE"}
+	}
+
+	resp, err := g.check(ctx, conv, user)
+	out := hookResponse{Action: "NONE"}
+	switch {
+	case err != nil:
+		g.logger.Printf("%s %s: check failed: %v", req.InputType, req.CallID, err)
+		if g.failClosed {
+			return hookResponse{Action: "BLOCKED", BlockedReason: "content safety check unavailable"}
+		}
+	case resp.IsSafe():
+	case g.redact && resp.DataOnly():
+		texts, err := g.mask(ctx, req.Texts, user)
+		if err != nil {
+			g.logger.Printf("%s %s: redaction failed, blocking: %v", req.InputType, req.CallID, err)
+			return hookResponse{Action: "BLOCKED", BlockedReason: refusal(resp)}
+		}
+		g.logger.Printf("%s %s redacted (user %q): %s", req.InputType, req.CallID, user, resp.Describe())
+		out = hookResponse{Action: "GUARDRAIL_INTERVENED", Texts: texts}
+	default:
+		g.logger.Printf("%s %s blocked (user %q): %s", req.InputType, req.CallID, user, resp.Describe())
+		return hookResponse{Action: "BLOCKED", BlockedReason: refusal(resp)}
+	}
+	if req.InputType != "response" {
+		g.prompts.put(req.CallID, conv)
+	}
+	return out
+}
+
+// hasTurns reports whether conv has a message other than a system one.
+func hasTurns(conv []guardrails.Message) bool {
+	for _, m := range conv {
+		if m.Role != "system" {
+			return true
+		}
+	}
+	return false
+}
+
+// mask anonymizes each text, keeping their order and count.
+func (g *guard) mask(ctx context.Context, texts []string, user string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	var opts []guardrails.CheckOption
+	if user != "" {
+		opts = append(opts, guardrails.WithUserID(user))
+	}
+	out := make([]string, len(texts))
+	for i, t := range texts {
+		if strings.TrimSpace(t) == "" {
+			out[i] = t
+			continue
+		}
+		a, err := g.c.Anonymize(ctx, t, opts...)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = a.Text
+	}
+	return out, nil
+}
+
+func refusal(resp *guardrails.Response) string {
+	if resp.SuggestAnswer != "" {
+		return resp.SuggestAnswer
+	}
+	return guardrails.DefaultRefusal
+}
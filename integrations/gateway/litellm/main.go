@@ -0,0 +1,158 @@
+// Command ogr-litellm is an OpenGuardrails gateway-hook integration for the
+// LiteLLM proxy: a receiver for the hooks and callbacks LiteLLM can already
+// call over HTTP, so a LiteLLM deployment adopts OpenGuardrails with
+// configuration alone.
+//
+//	client ──▶ LiteLLM proxy ──▶ model
+//	               │ generic_guardrail_api (pre_call, post_call)
+//	               │ generic_api logger (success, failure)
+//	               ▼
+//	          ogr-litellm ──▶ POST /v1/guardrails
+//
+// POST /beta/litellm_basic_guardrail_api checks a call's prompt before it
+// runs and its answer after, in the context of the prompt. A risky call is
+// BLOCKED with the platform's suggested answer; a call whose only findings
+// are data-security ones has its texts masked instead. POST /callbacks
+// checks the logged calls after the fact, for moderation: nothing is
+// blocked, and what the platform flags is logged.
+//
+// Env:
+//
+//	OGR_BASE_URL          detection API base URL (default https://api.openguardrails.com/v1)
+//	OGR_API_KEY           application API key for the detection API
+//	OGR_EVAL_TIMEOUT      seconds per check (default 10)
+//	OGR_FAIL_MODE_CLOSED  block calls while the API is unreachable (default true)
+//	OGR_LITELLM_LISTEN    listen address (default :8897)
+//	OGR_LITELLM_TOKEN     token LiteLLM must send (Authorization: Bearer or x-api-key)
+//	OGR_LITELLM_REDACT    mask data-security findings instead of blocking (default true)
+//	OGR_LITELLM_USER_KEYS LiteLLM metadata fields naming the end user, tried in order
+//	                      (default user_api_key_end_user_id,user_api_key_user_id)
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go"
+)
+
+func main() {
+	logger := log.New(os.Stderr, "ogr-litellm: ", log.LstdFlags)
+	g, err := guardFromEnv(logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	token := os.Getenv("OGR_LITELLM_TOKEN")
+	if token == "" {
+		logger.Print("OGR_LITELLM_TOKEN is not set — any client can use the hooks.")
+	}
+
+	// The moderation checks run a few at a time, so a burst of logs does
+	// not become a burst of detection calls.
+	slots := make(chan struct{}, 8)
+	async := func(f func()) {
+		go func() {
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			f()
+		}()
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	mux.Handle("POST /beta/litellm_basic_guardrail_api", authorized(token, &hookHandler{g: g}))
+	mux.Handle("POST /callbacks", authorized(token, &callbackHandler{g: g, dispatch: async}))
+
+	srv := &http.Server{Addr: env("OGR_LITELLM_LISTEN", ":8897"), Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+	logger.Printf("listening on %s (fail_%s)", srv.Addr, map[bool]string{true: "closed", false: "open"}[g.failClosed])
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Fatal(err)
+	}
+}
+
+func guardFromEnv(logger *log.Logger) (*guard, error) {
+	key := os.Getenv("OGR_API_KEY")
+	if key == "" {
+		return nil, errors.New("OGR_API_KEY is required")
+	}
+	secs, err := strconv.ParseFloat(env("OGR_EVAL_TIMEOUT", "10"), 64)
+	if err != nil || secs <= 0 {
+		return nil, fmt.Errorf("OGR_EVAL_TIMEOUT: invalid value %q", os.Getenv("OGR_EVAL_TIMEOUT"))
+	}
+	return &guard{
+		c: guardrails.NewClient(
+			guardrails.WithBaseURL(env("OGR_BASE_URL", guardrails.DefaultBaseURL)),
+			guardrails.WithAPIKey(key),
+		),
+		timeout:    time.Duration(secs * float64(time.Second)),
+		redact:     truthy(os.Getenv("OGR_LITELLM_REDACT"), true),
+		failClosed: truthy(os.Getenv("OGR_FAIL_MODE_CLOSED"), true),
+		userKeys:   fields(env("OGR_LITELLM_USER_KEYS", "user_api_key_end_user_id,user_api_key_user_id")),
+		prompts:    newPrompts(10000),
+		logger:     logger,
+	}, nil
+}
+
+// authorized requires token, as a bearer token or an x-api-key header,
+// unless it is empty.
+func authorized(token string, h http.Handler) http.Handler {
+	if token == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := r.Header.Get("x-api-key")
+		if got == "" {
+			got = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func env(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return def
+}
+
+func truthy(v string, def bool) bool {
+	v = strings.ToLower(strings.TrimSpace(v))
+	if v == "" {
+		return def
+	}
+	return v != "0" && v != "false" && v != "no" && v != "off"
+}
+
+func fields(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
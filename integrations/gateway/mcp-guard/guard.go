@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go"
+)
+
+// guard judges tool calls and their results.
+type guard struct {
+	c       *guardrails.Client
+	timeout time.Duration
+	// redact masks the sensitive data in a call or result whose only
+	// findings are data-security ones, instead of blocking it.
+	redact bool
+	// failClosed blocks tool calls while the detection API is unreachable.
+	failClosed bool
+	userID     string
+	logger     *log.Logger
+}
+
+// message is a JSON-RPC 2.0 message; which fields are set tells requests,
+// notifications and responses apart.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   json.RawMessage `json:"error,omitempty"`
+}
+
+type callParams struct {
+	Name      string         `json:"name"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+}
+
+// toolResult is what a tools/call returns.
+type toolResult struct {
+	Content           []map[string]any `json:"content"`
+	StructuredContent any              `json:"structuredContent,omitempty"`
+	IsError           bool             `json:"isError,omitempty"`
+}
+
+// session tracks the tool calls in flight on one MCP connection, so their
+// results can be judged in context.
+type session struct {
+	g     *guard
+	mu    sync.Mutex
+	calls map[string]string // request ID → tool name
+}
+
+func (g *guard) session() *session {
+	return &session{g: g, calls: map[string]string{}}
+}
+
+// fromClient judges a message from the agent. It returns the message to
+// forward to the server, or nil and the reply the agent gets instead.
+func (s *session) fromClient(ctx context.Context, raw []byte) (forward, reply []byte) {
+	if batch(raw) {
+		return s.batchFromClient(raw)
+	}
+	var m message
+	if json.Unmarshal(raw, &m) != nil || m.Method != "tools/call" || m.ID == nil {
+		return raw, nil
+	}
+	var p callParams
+	if json.Unmarshal(m.Params, &p) != nil {
+		return raw, nil
+	}
+	args, _ := json.Marshal(p.Arguments)
+	conv := []guardrails.Message{
+		{Role: "user", Content: "Call the tool " + p.Name + "."},
+		{Role: "assistant", Content: string(args)},
+	}
+	// Arguments are text the model wrote and sends out of the agent: they
+	// are judged as model output, where data exfiltration shows.
+	switch resp, verdict := s.g.judge(ctx, conv); verdict {
+	case blocked:
+		s.g.logger.Printf("tool call %s blocked: %s", p.Name, resp.Describe())
+		return nil, errorResult(m.ID, refusal(resp))
+	case redactable:
+		masked, err := s.g.mask(ctx, p.Arguments)
+		if err != nil {
+			s.g.logger.Printf("tool call %s: redaction failed, blocking: %v", p.Name, err)
+			return nil, errorResult(m.ID, refusal(resp))
+		}
+		// Rewrite the arguments alone, keeping the rest of params (_meta).
+		var params map[string]any
+		json.Unmarshal(m.Params, &params)
+		params["arguments"] = masked
+		m.Params, _ = json.Marshal(params)
+		raw, _ = json.Marshal(m)
+		s.g.logger.Printf("tool call %s redacted: %s", p.Name, resp.Describe())
+	}
+	s.mu.Lock()
+	s.calls[string(m.ID)] = p.Name
+	s.mu.Unlock()
+	return raw, nil
+}
+
+// batchFromClient refuses batches carrying tool calls: each would need
+// judging on its own, and MCP no longer batches.
+func (s *session) batchFromClient(raw []byte) (forward, reply []byte) {
+	var ms []message
+	if json.Unmarshal(raw, &ms) != nil {
+		return raw, nil
+	}
+	var errs []json.RawMessage
+	calls := false
+	for _, m := range ms {
+		calls = calls || m.Method == "tools/call"
+		if m.ID != nil && m.Method != "" {
+			errs = append(errs, rpcError(m.ID, -32600, "batched requests with tools/call are not supported"))
+		}
+	}
+	if !calls {
+		return raw, nil
+	}
+	reply, _ = json.Marshal(errs)
+	return nil, reply
+}
+
+// fromServer judges a message from the server, returning what the agent
+// gets.
+func (s *session) fromServer(ctx context.Context, raw []byte) []byte {
+	var m message
+	if batch(raw) || json.Unmarshal(raw, &m) != nil || m.ID == nil || m.Method != "" {
+		return raw
+	}
+	s.mu.Lock()
+	tool, ok := s.calls[string(m.ID)]
+	delete(s.calls, string(m.ID))
+	s.mu.Unlock()
+	if !ok || m.Result == nil {
+		return raw
+	}
+	var res toolResult
+	if json.Unmarshal(m.Result, &res) != nil {
+		return raw
+	}
+	text := resultText(res)
+	if text == "" {
+		return raw
+	}
+	// A result is text the model will read: it is judged as input, where
+	// indirect prompt injection hides.
+	switch resp, verdict := s.g.judge(ctx, []guardrails.Message{{Role: "user", Content: text}}); verdict {
+	case blocked:
+		s.g.logger.Printf("result of %s blocked: %s", tool, resp.Describe())
+		return errorResult(m.ID, refusal(resp))
+	case redactable:
+		var generic map[string]any
+		json.Unmarshal(m.Result, &generic)
+		masked, err := s.g.maskResult(ctx, generic)
+		if err != nil {
+			s.g.logger.Printf("result of %s: redaction failed, blocking: %v", tool, err)
+			return errorResult(m.ID, refusal(resp))
+		}
+		m.Result, _ = json.Marshal(masked)
+		raw, _ = json.Marshal(m)
+		s.g.logger.Printf("result of %s redacted: %s", tool, resp.Describe())
+	}
+	return raw
+}
+
+type verdict int
+
+const (
+	passed verdict = iota
+	blocked
+	redactable
+)
+
+// judge checks conv. resp is nil when the check failed.
+func (g *guard) judge(ctx context.Context, conv []guardrails.Message) (*guardrails.Response, verdict) {
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	var opts []guardrails.CheckOption
+	if g.userID != "" {
+		opts = append(opts, guardrails.WithUserID(g.userID))
+	}
+	resp, err := g.c.CheckConversation(ctx, conv, opts...)
+	switch {
+	case err != nil:
+		g.logger.Printf("check failed: %v", err)
+		if g.failClosed {
+			return nil, blocked
+		}
+		return nil, passed
+	case resp.IsSafe():
+		return resp, passed
+	case g.redact && resp.DataOnly():
+		return resp, redactable
+	}
+	return resp, blocked
+}
+
+// mask anonymizes the string values in v, a decoded JSON value.
+func (g *guard) mask(ctx context.Context, v any) (any, error) {
+	switch v := v.(type) {
+	case string:
+		if strings.TrimSpace(v) == "" {
+			return v, nil
+		}
+		ctx, cancel := context.WithTimeout(ctx, g.timeout)
+		defer cancel()
+		a, err := g.c.Anonymize(ctx, v)
+		if err != nil {
+			return nil, err
+		}
+		return a.Text, nil
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			m, err := g.mask(ctx, e)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = m
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			m, err := g.mask(ctx, e)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = m
+		}
+		return out, nil
+	}
+	return v, nil
+}
+
+// maskResult anonymizes the text res hands the model, the fields
+// resultText reads, leaving protocol fields such as type, mimeType and uri
+// as they are. res is changed in place.
+func (g *guard) maskResult(ctx context.Context, res map[string]any) (map[string]any, error) {
+	content, _ := res["content"].([]any)
+	for _, c := range content {
+		item, _ := c.(map[string]any)
+		field := item
+		switch item["type"] {
+		case "text":
+		case "resource":
+			field, _ = item["resource"].(map[string]any)
+		default:
+			continue
+		}
+		if text, ok := field["text"].(string); ok {
+			masked, err := g.mask(ctx, text)
+			if err != nil {
+				return nil, err
+			}
+			field["text"] = masked
+		}
+	}
+	if sc := res["structuredContent"]; sc != nil {
+		masked, err := g.mask(ctx, sc)
+		if err != nil {
+			return nil, err
+		}
+		res["structuredContent"] = masked
+	}
+	return res, nil
+}
+
+// resultText is the text a tool result hands the model: its text items,
+// embedded text resources and structured content.
+func resultText(res toolResult) string {
+	var parts []string
+	for _, item := range res.Content {
+		switch item["type"] {
+		case "text":
+			if s, _ := item["text"].(string); s != "" {
+				parts = append(parts, s)
+			}
+		case "resource":
+			r, _ := item["resource"].(map[string]any)
+			if s, _ := r["text"].(string); s != "" {
+				parts = append(parts, s)
+			}
+		}
+	}
+	if res.StructuredContent != nil {
+		b, _ := json.Marshal(res.StructuredContent)
+		parts = append(parts, string(b))
+	}
+	return strings.Join(parts, "\n")
+}
+
+func refusal(resp *guardrails.Response) string {
+	if resp == nil {
+		return "The content safety check is unavailable; the tool interaction was blocked."
+	}
+	if resp.SuggestAnswer != "" {
+		return resp.SuggestAnswer
+	}
+	return "The tool interaction was blocked by content policy."
+}
+
+// errorResult is a tool result that tells the model why it got nothing:
+// MCP reports tool failures in the result, where the model sees them.
+func errorResult(id json.RawMessage, text string) []byte {
+	result, _ := json.Marshal(toolResult{Content: []map[string]any{{"type": "text", "text": text}}, IsError: true})
+	b, _ := json.Marshal(message{JSONRPC: "2.0", ID: id, Result: result})
+	return b
+}
+
+func rpcError(id json.RawMessage, code int, msg string) []byte {
+	e, _ := json.Marshal(map[string]any{"code": code, "message": msg})
+	b, _ := json.Marshal(message{JSONRPC: "2.0", ID: id, Error: e})
+	return b
+}
+
+func batch(raw []byte) bool {
+	for _, b := range raw {
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b == '['
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/openguardrails/openguardrails-go"
+)
+
+// proxy guards an MCP server on the Streamable HTTP transport. Responses to
+// a POST come back on that POST, as JSON or an SSE stream, so each POST gets
+// a session of its own. GET streams carry only server-initiated messages and
+// pass through, like DELETE.
+type proxy struct {
+	g        *guard
+	upstream *url.URL
+	client   *http.Client
+	logger   *log.Logger
+}
+
+// hopHeaders are not forwarded either way. Accept-Encoding is dropped so the
+// upstream answers in plain text the proxy can read.
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Connection", "Te", "Trailer",
+	"Transfer-Encoding", "Upgrade", "Content-Length", "Accept-Encoding",
+}
+
+func (p *proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader = r.Body
+	var s *session
+	if r.Method == http.MethodPost {
+		b, err := io.ReadAll(http.MaxBytesReader(w, r.Body, guardrails.DefaultMaxBody))
+		if err != nil {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		s = p.g.session()
+		forward, reply := s.fromClient(r.Context(), b)
+		if reply != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(reply)
+			return
+		}
+		body = bytes.NewReader(forward)
+	}
+
+	target := *p.upstream
+	target.RawQuery = r.URL.RawQuery
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, target.String(), body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	req.Header = r.Header.Clone()
+	for _, h := range hopHeaders {
+		req.Header.Del(h)
+	}
+	if r.Method != http.MethodPost {
+		req.Body, req.ContentLength = r.Body, r.ContentLength
+	}
+	res, err := p.client.Do(req)
+	if err != nil {
+		p.logger.Printf("upstream: %v", err)
+		http.Error(w, "upstream unavailable", http.StatusBadGateway)
+		return
+	}
+	defer res.Body.Close()
+
+	for k, v := range res.Header {
+		w.Header()[k] = v
+	}
+	for _, h := range hopHeaders {
+		w.Header().Del(h)
+	}
+	w.WriteHeader(res.StatusCode)
+	ctype, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	switch {
+	case s == nil || res.StatusCode != http.StatusOK:
+		err = copyFlushing(w, res.Body)
+	case ctype == "application/json":
+		var b []byte
+		if b, err = io.ReadAll(res.Body); err == nil {
+			_, err = w.Write(s.fromServer(r.Context(), b))
+		}
+	case ctype == "text/event-stream":
+		err = events(w, res.Body, func(data []byte) []byte { return s.fromServer(r.Context(), data) })
+	default:
+		err = copyFlushing(w, res.Body)
+	}
+	if err != nil && !errors.Is(err, r.Context().Err()) {
+		p.logger.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+}
+
+// events copies an SSE stream, passing each event's data through rewrite
+// and flushing after every event.
+func events(w http.ResponseWriter, r io.Reader, rewrite func([]byte) []byte) error {
+	rc := http.NewResponseController(w)
+	br := bufio.NewReader(r)
+	var fields []string
+	var data [][]byte
+	for {
+		line, err := br.ReadString('\n')
+		trimmed := strings.TrimRight(line, "\r\n")
+		switch {
+		case trimmed == "" && line != "":
+			var out bytes.Buffer
+			for _, f := range fields {
+				out.WriteString(f + "\n")
+			}
+			if data != nil {
+				for _, l := range bytes.Split(rewrite(bytes.Join(data, []byte("\n"))), []byte("\n")) {
+					out.WriteString("data: ")
+					out.Write(l)
+					out.WriteString("\n")
+				}
+			}
+			out.WriteString("\n")
+			if _, werr := w.Write(out.Bytes()); werr != nil {
+				return werr
+			}
+			rc.Flush()
+			fields, data = nil, nil
+		case strings.HasPrefix(trimmed, "data:"):
+			d := strings.TrimPrefix(trimmed[len("data:"):], " ")
+			data = append(data, []byte(d))
+		case trimmed != "":
+			fields = append(fields, trimmed)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// copyFlushing copies r to w, flushing after each read so streams are not
+// held up.
+func copyFlushing(w http.ResponseWriter, r io.Reader) error {
+	rc := http.NewResponseController(w)
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			rc.Flush()
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,173 @@
+// Command ogr-mcp-guard is an OpenGuardrails gateway-hook integration for
+// agents using MCP (Model Context Protocol) tools: a proxy between the agent
+// and an MCP server that checks every tool call's arguments and every tool
+// result against the detection API.
+//
+//	agent ──stdio or HTTP──▶ ogr-mcp-guard ──▶ MCP server
+//	                              │
+//	                              └── POST /v1/guardrails
+//
+// Arguments are what the model sends out of the agent, and are checked as
+// its output (data leaving through a tool). Results are what the model will
+// read, and are checked as input (indirect prompt injection). A risky call
+// is never forwarded; the agent gets a tool result with isError set and the
+// platform's suggested answer instead, as it does for a risky result. When
+// every finding is a data-security one, the sensitive values are masked and
+// the call or result goes through. Other messages pass untouched.
+//
+// The proxy speaks both MCP transports:
+//
+//	ogr-mcp-guard -- npx -y @modelcontextprotocol/server-filesystem /srv   # stdio
+//	ogr-mcp-guard -listen :8896 -upstream http://localhost:3001/mcp       # Streamable HTTP
+//
+// Env:
+//
+//	OGR_BASE_URL          detection API base URL (default https://api.openguardrails.com/v1)
+//	OGR_API_KEY           application API key for the detection API
+//	OGR_EVAL_TIMEOUT      seconds per check (default 10)
+//	OGR_FAIL_MODE_CLOSED  block tool calls while the API is unreachable (default true)
+//	OGR_MCP_REDACT        mask data-security findings instead of blocking (default true)
+//	OGR_MCP_USER_ID       user ID the checks are attributed to, for ban policies
+//	OGR_MCP_LISTEN        Streamable HTTP listen address
+//	OGR_MCP_UPSTREAM      Streamable HTTP MCP endpoint to guard
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go"
+)
+
+func main() {
+	listen := flag.String("listen", env("OGR_MCP_LISTEN", ""), "serve Streamable HTTP on this address instead of stdio")
+	upstream := flag.String("upstream", env("OGR_MCP_UPSTREAM", ""), "Streamable HTTP MCP endpoint to guard (with -listen)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: ogr-mcp-guard [flags] -- command [args...]\n       ogr-mcp-guard -listen addr -upstream url\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "ogr-mcp-guard: ", log.LstdFlags)
+	g, err := guardFromEnv(logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	switch {
+	case *listen != "":
+		u, err := url.Parse(*upstream)
+		if err != nil || u.Host == "" {
+			logger.Fatalf("-upstream: an http(s) URL is required, got %q", *upstream)
+		}
+		os.Exit(serveHTTP(ctx, g, *listen, u, logger))
+	case flag.NArg() > 0:
+		os.Exit(serveStdio(ctx, g, flag.Args(), logger))
+	}
+	flag.Usage()
+	os.Exit(2)
+}
+
+// serveStdio runs the server command and relays the process's own stdin
+// and stdout to it, exiting with its status.
+func serveStdio(ctx context.Context, g *guard, args []string, logger *log.Logger) int {
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Cancel = func() error { return cmd.Process.Signal(syscall.SIGTERM) }
+	cmd.WaitDelay = 5 * time.Second
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		logger.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		logger.Fatal(err)
+	}
+	logger.Printf("guarding %s over stdio (fail_%s)", args[0], map[bool]string{true: "closed", false: "open"}[g.failClosed])
+	if err := relay(ctx, g.session(), os.Stdin, os.Stdout, in, out); err != nil {
+		logger.Print(err)
+	}
+	if err := cmd.Wait(); err != nil {
+		var exit *exec.ExitError
+		if errors.As(err, &exit) && exit.ExitCode() >= 0 {
+			return exit.ExitCode()
+		}
+		logger.Print(err)
+		return 1
+	}
+	return 0
+}
+
+func serveHTTP(ctx context.Context, g *guard, addr string, upstream *url.URL, logger *log.Logger) int {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           &proxy{g: g, upstream: upstream, client: &http.Client{}, logger: logger},
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		<-ctx.Done()
+		sctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		srv.Shutdown(sctx)
+	}()
+	logger.Printf("listening on %s → %s (fail_%s)", addr, upstream, map[bool]string{true: "closed", false: "open"}[g.failClosed])
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Print(err)
+		return 1
+	}
+	return 0
+}
+
+func guardFromEnv(logger *log.Logger) (*guard, error) {
+	key := os.Getenv("OGR_API_KEY")
+	if key == "" {
+		return nil, errors.New("OGR_API_KEY is required")
+	}
+	secs, err := strconv.ParseFloat(env("OGR_EVAL_TIMEOUT", "10"), 64)
+	if err != nil || secs <= 0 {
+		return nil, fmt.Errorf("OGR_EVAL_TIMEOUT: invalid value %q", os.Getenv("OGR_EVAL_TIMEOUT"))
+	}
+	return &guard{
+		c: guardrails.NewClient(
+			guardrails.WithBaseURL(env("OGR_BASE_URL", guardrails.DefaultBaseURL)),
+			guardrails.WithAPIKey(key),
+		),
+		timeout:    time.Duration(secs * float64(time.Second)),
+		redact:     truthy(os.Getenv("OGR_MCP_REDACT"), true),
+		failClosed: truthy(os.Getenv("OGR_FAIL_MODE_CLOSED"), true),
+		userID:     os.Getenv("OGR_MCP_USER_ID"),
+		logger:     logger,
+	}, nil
+}
+
+func env(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return def
+}
+
+func truthy(v string, def bool) bool {
+	v = strings.ToLower(strings.TrimSpace(v))
+	if v == "" {
+		return def
+	}
+	return v != "0" && v != "false" && v != "no" && v != "off"
+}
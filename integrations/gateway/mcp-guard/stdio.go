@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"sync"
+)
+
+// relay carries newline-delimited JSON-RPC between an agent and a stdio MCP
+// server through s. It returns once the server's output ends; the end of the
+// agent's input closes the server's.
+func relay(ctx context.Context, s *session, agentIn io.Reader, agentOut io.Writer, serverIn io.WriteCloser, serverOut io.Reader) error {
+	var mu sync.Mutex // both directions answer the agent
+	toAgent := func(b []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		_, err := agentOut.Write(append(b, '\n'))
+		return err
+	}
+
+	go func() {
+		defer serverIn.Close()
+		lines(agentIn, func(line []byte) error {
+			forward, reply := s.fromClient(ctx, line)
+			if reply != nil {
+				return toAgent(reply)
+			}
+			_, err := serverIn.Write(append(forward, '\n'))
+			return err
+		})
+	}()
+	return lines(serverOut, func(line []byte) error {
+		return toAgent(s.fromServer(ctx, line))
+	})
+}
+
+// lines calls f with each non-empty line of r, without its line ending,
+// until r ends or f fails.
+func lines(r io.Reader, f func([]byte) error) error {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if line = bytes.TrimRight(line, "\r\n"); len(line) > 0 {
+			if ferr := f(line); ferr != nil {
+				return ferr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"regexp"
+	"strings"
+)
+
+// maxMIMEDepth bounds multipart nesting so a hostile message cannot recurse
+// the extractor indefinitely.
+const maxMIMEDepth = 8
+
+var wordDecoder = new(mime.WordDecoder)
+
+// decodeHeader returns an RFC 2047 encoded header value as UTF-8, falling
+// back to the raw value when it cannot be decoded.
+func decodeHeader(v string) string {
+	if s, err := wordDecoder.DecodeHeader(v); err == nil {
+		return s
+	}
+	return v
+}
+
+// bodyText extracts the human-readable text of a message body. text/plain
+// parts are preferred; text/html is used, with markup stripped, only when a
+// multipart/alternative offers no plain rendering. Attachments are skipped.
+func bodyText(h textproto.MIMEHeader, body []byte) string {
+	return strings.TrimSpace(partText(h, body, 0))
+}
+
+func partText(h textproto.MIMEHeader, body []byte, depth int) string {
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	if disp, _, _ := mime.ParseMediaType(h.Get("Content-Disposition")); disp == "attachment" {
+		return ""
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		if depth >= maxMIMEDepth || params["boundary"] == "" {
+			return ""
+		}
+		return multipartText(mediaType, params["boundary"], body, depth)
+	}
+
+	decoded := decodeTransfer(h.Get("Content-Transfer-Encoding"), body)
+	switch mediaType {
+	case "text/plain":
+		return string(decoded)
+	case "text/html":
+		return htmlText(string(decoded))
+	}
+	return ""
+}
+
+func multipartText(mediaType, boundary string, body []byte, depth int) string {
+	mr := multipart.NewReader(bytes.NewReader(body), boundary)
+	var plain, html []string
+	for {
+		p, err := mr.NextRawPart()
+		if err != nil {
+			break
+		}
+		raw, err := io.ReadAll(p)
+		if err != nil {
+			break
+		}
+		text := partText(p.Header, raw, depth+1)
+		if text == "" {
+			continue
+		}
+		if ct, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type")); ct == "text/html" {
+			html = append(html, text)
+		} else {
+			plain = append(plain, text)
+		}
+	}
+	if mediaType == "multipart/alternative" && len(plain) > 0 {
+		return strings.Join(plain, "\n\n")
+	}
+	return strings.Join(append(plain, html...), "\n\n")
+}
+
+func decodeTransfer(encoding string, body []byte) []byte {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		if out, err := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(body))); err == nil {
+			return out
+		}
+	case "base64":
+		clean := strings.Map(func(r rune) rune {
+			if r == '\r' || r == '\n' || r == ' ' || r == '\t' {
+				return -1
+			}
+			return r
+		}, string(body))
+		if out, err := base64.StdEncoding.DecodeString(clean); err == nil {
+			return out
+		}
+	}
+	return body
+}
+
+var (
+	htmlDropRE = regexp.MustCompile(`(?is)<(script|style)\b.*?</(script|style)>`)
+	htmlTagRE  = regexp.MustCompile(`(?s)<[^>]*>`)
+	spaceRE    = regexp.MustCompile(`[ \t]+`)
+)
+
+// htmlText is a deliberately small markup stripper: the runtime judges the
+// words a recipient reads, not the layout, so tags are dropped and common
+// entities unescaped.
+func htmlText(s string) string {
+	s = htmlDropRE.ReplaceAllString(s, " ")
+	s = htmlTagRE.ReplaceAllString(s, " ")
+	s = strings.NewReplacer("&nbsp;", " ", "&lt;", "<", "&gt;", ">", "&quot;", `"`, "&#39;", "'", "&amp;", "&").Replace(s)
+	return strings.TrimSpace(spaceRE.ReplaceAllString(s, " "))
+}
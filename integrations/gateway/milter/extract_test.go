@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/textproto"
+	"strings"
+	"testing"
+)
+
+func header(kv ...string) textproto.MIMEHeader {
+	h := make(textproto.MIMEHeader)
+	for i := 0; i < len(kv); i += 2 {
+		h.Set(kv[i], kv[i+1])
+	}
+	return h
+}
+
+func TestBodyTextQuotedPrintable(t *testing.T) {
+	h := header("Content-Type", "text/plain; charset=utf-8", "Content-Transfer-Encoding", "quoted-printable")
+	got := bodyText(h, []byte("Your card 4111=\r\n 1111 is on file=2E\r\n"))
+	if got != "Your card 4111 1111 is on file." {
+		t.Fatalf("bodyText = %q", got)
+	}
+}
+
+func TestBodyTextMultipartAlternativePrefersPlain(t *testing.T) {
+	body := strings.ReplaceAll(`--b1
+Content-Type: text/plain
+
+plain version
+--b1
+Content-Type: text/html
+
+<p>html version</p>
+--b1--
+`, "\n", "\r\n")
+	got := bodyText(header("Content-Type", `multipart/alternative; boundary="b1"`), []byte(body))
+	if got != "plain version" {
+		t.Fatalf("bodyText = %q", got)
+	}
+}
+
+func TestBodyTextMixedSkipsAttachments(t *testing.T) {
+	body := strings.ReplaceAll(`--outer
+Content-Type: text/html
+Content-Transfer-Encoding: base64
+
+PHA+SGVsbG8gJmFtcDsgd2VsY29tZTwvcD48c2NyaXB0PnguKTwvc2NyaXB0Pg==
+--outer
+Content-Type: application/pdf
+Content-Disposition: attachment; filename="a.pdf"
+
+%PDF-1.4
+--outer--
+`, "\n", "\r\n")
+	got := bodyText(header("Content-Type", `multipart/mixed; boundary=outer`), []byte(body))
+	if got != "Hello & welcome" {
+		t.Fatalf("bodyText = %q", got)
+	}
+}
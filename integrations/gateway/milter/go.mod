@@ -0,0 +1,7 @@
+module github.com/openguardrails/openguardrails/integrations/gateway/milter
+
+go 1.22
+
+require github.com/openguardrails/openguardrails-go v0.0.0
+
+replace github.com/openguardrails/openguardrails-go => ../../../packages/go
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go/ogr"
+	"github.com/openguardrails/openguardrails-go/verdict"
+	"github.com/openguardrails/openguardrails/integrations/gateway/milter/internal/milter"
+)
+
+// evaluator is the slice of ogr.Client the guard needs; tests substitute it.
+type evaluator interface {
+	Evaluate(ctx context.Context, ev *ogr.GuardEvent) (*verdict.Verdict, error)
+}
+
+// guard maps one message to an OGR model_output event and the runtime's
+// Verdict back to a milter disposition.
+type guard struct {
+	pdp     evaluator
+	timeout time.Duration
+
+	// senders are envelope-sender patterns (path.Match syntax, e.g.
+	// "*@bots.example.com") selecting AI-generated mail. Empty selects all.
+	senders []string
+	// marker, when set, also selects any message carrying this header.
+	marker string
+	// categories are taxonomy prefixes the milter enforces ("safety.pii",
+	// "security.secret_leak"). Empty enforces every non-allow verdict.
+	categories []string
+
+	// blockAction is what a block verdict does: reject or quarantine.
+	blockAction milter.Action
+	// failClosed tempfails mail while the runtime is unreachable so the MTA
+	// retries later; otherwise the message is accepted and tagged.
+	failClosed bool
+
+	agentID   string
+	agentType string
+	logger    *log.Logger
+}
+
+// selects reports whether m is AI-generated mail this milter should judge.
+func (g *guard) selects(m *milter.Message) bool {
+	if len(g.senders) == 0 && g.marker == "" {
+		return true
+	}
+	if g.marker != "" && m.Header.Get(g.marker) != "" {
+		return true
+	}
+	sender := strings.ToLower(m.Sender)
+	for _, pat := range g.senders {
+		if ok, _ := path.Match(strings.ToLower(pat), sender); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *guard) filter(ctx context.Context, m *milter.Message) milter.Decision {
+	if !g.selects(m) {
+		return milter.Decision{Action: milter.Accept}
+	}
+	text := bodyText(m.Header, m.Body)
+	if text == "" {
+		return milter.Decision{Action: milter.Accept}
+	}
+
+	ev := ogr.NewEvent("model_output", g.subject(m), map[string]any{
+		"text":       text,
+		"channel":    "email",
+		"from":       m.Sender,
+		"to":         m.Recipients,
+		"subject":    decodeHeader(m.Header.Get("Subject")),
+		"message_id": m.Header.Get("Message-Id"),
+		"truncated":  m.Truncated,
+	})
+	ev.Provenance = []ogr.Provenance{{Source: "model", Trust: "unverified"}}
+
+	ctx, cancel := context.WithTimeout(ctx, g.timeout)
+	defer cancel()
+	v, err := g.pdp.Evaluate(ctx, ev)
+	if err != nil {
+		g.logger.Printf("evaluate %s from %s: %v", ev.EventID, m.Sender, err)
+		if g.failClosed {
+			return milter.Decision{
+				Action: milter.TempFail,
+				Reply:  "451 4.7.1 OpenGuardrails policy check unavailable, try again later",
+			}
+		}
+		return milter.Decision{Action: milter.Accept, Headers: []milter.Header{
+			{Name: "X-OGR-Decision", Value: "unavailable"},
+		}}
+	}
+
+	d := g.decide(v)
+	g.logger.Printf("%s from=%s guard=%s decision=%s action=%s",
+		ev.EventID, m.Sender, v.GuardID, v.Decision, d.Action)
+	return d
+}
+
+// decide maps a Verdict to a disposition. block rejects (or quarantines);
+// require_approval, redact and modify quarantine the message for a human:
+// a MIME body cannot be edited span-by-span reliably, and holding it is the
+// mail-native form of "needs review".
+func (g *guard) decide(v *verdict.Verdict) milter.Decision {
+	headers := []milter.Header{
+		{Name: "X-OGR-Decision", Value: string(v.Decision)},
+		{Name: "X-OGR-Guard-Id", Value: v.GuardID},
+	}
+	if v.Decision == verdict.Allow || !g.enforces(v) {
+		return milter.Decision{Action: milter.Accept, Headers: headers}
+	}
+	reason := summary(v)
+	if v.Decision == verdict.Block && g.blockAction == milter.Reject {
+		return milter.Decision{
+			Action: milter.Reject,
+			Reply:  "550 5.7.1 Message blocked by OpenGuardrails policy: " + smtpText(reason),
+		}
+	}
+	return milter.Decision{
+		Action:  milter.Quarantine,
+		Reason:  "OpenGuardrails " + string(v.Decision) + ": " + smtpText(reason),
+		Headers: headers,
+	}
+}
+
+// enforces reports whether any of the verdict's categories falls under the
+// configured prefixes. A verdict without categories is enforced only when no
+// prefixes are configured.
+func (g *guard) enforces(v *verdict.Verdict) bool {
+	if len(g.categories) == 0 {
+		return true
+	}
+	for _, c := range v.Categories {
+		for _, p := range g.categories {
+			if c.ID == p || strings.HasPrefix(c.ID, p+".") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (g *guard) subject(m *milter.Message) ogr.Subject {
+	s := ogr.Subject{AgentID: g.agentID, AgentType: g.agentType}
+	if s.AgentID == "" {
+		// The sending assistant is the actor; its envelope address is the
+		// most stable identity an MTA has for it.
+		s.AgentID = "mail:" + strings.ToLower(m.Sender)
+	}
+	return s
+}
+
+func summary(v *verdict.Verdict) string {
+	var ids []string
+	for _, c := range v.Categories {
+		ids = append(ids, c.ID)
+	}
+	if len(ids) > 0 {
+		return strings.Join(ids, ", ")
+	}
+	if len(v.Reasons) > 0 {
+		return v.Reasons[0]
+	}
+	return "policy violation"
+}
+
+// smtpText makes s safe for a single-line SMTP reply: printable ASCII only,
+// no '%' (the MTA treats it as a format directive), at most 200 bytes.
+func smtpText(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e || r == '%' {
+			return ' '
+		}
+		return r
+	}, s)
+	if len(s) > 200 {
+		s = s[:200]
+	}
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go/ogr"
+	"github.com/openguardrails/openguardrails-go/verdict"
+	"github.com/openguardrails/openguardrails/integrations/gateway/milter/internal/milter"
+)
+
+type fakePDP struct {
+	verdict *verdict.Verdict
+	err     error
+	events  []*ogr.GuardEvent
+}
+
+func (f *fakePDP) Evaluate(_ context.Context, ev *ogr.GuardEvent) (*verdict.Verdict, error) {
+	f.events = append(f.events, ev)
+	if f.err != nil {
+		return nil, f.err
+	}
+	v := *f.verdict
+	v.GuardID = ev.GuardID
+	return &v, nil
+}
+
+func newGuard(pdp evaluator) *guard {
+	return &guard{
+		pdp:         pdp,
+		timeout:     time.Second,
+		blockAction: milter.Reject,
+		failClosed:  true,
+		logger:      log.New(io.Discard, "", 0),
+	}
+}
+
+func message(sender, body string) *milter.Message {
+	h := make(textproto.MIMEHeader)
+	h.Set("Subject", "=?UTF-8?Q?R=C3=A9sum=C3=A9?=")
+	return &milter.Message{Sender: sender, Recipients: []string{"alice@example.org"}, Header: h, Body: []byte(body)}
+}
+
+func TestFilterDecisions(t *testing.T) {
+	cases := []struct {
+		decision verdict.Decision
+		cats     []verdict.Category
+		want     milter.Action
+	}{
+		{verdict.Allow, nil, milter.Accept},
+		{verdict.Block, []verdict.Category{{ID: "safety.pii.email"}}, milter.Reject},
+		{verdict.RequireApproval, nil, milter.Quarantine},
+		{verdict.Redact, []verdict.Category{{ID: "security.secret_leak"}}, milter.Quarantine},
+	}
+	for _, tc := range cases {
+		pdp := &fakePDP{verdict: &verdict.Verdict{Decision: tc.decision, Categories: tc.cats}}
+		d := newGuard(pdp).filter(context.Background(), message("bot@example.com", "Dear customer"))
+		if d.Action != tc.want {
+			t.Errorf("%s: action = %s, want %s", tc.decision, d.Action, tc.want)
+		}
+	}
+}
+
+func TestFilterEvent(t *testing.T) {
+	pdp := &fakePDP{verdict: &verdict.Verdict{Decision: verdict.Allow}}
+	newGuard(pdp).filter(context.Background(), message("Bot@Example.com", "Dear customer"))
+
+	ev := pdp.events[0]
+	if ev.Kind != "model_output" || ev.Subject.AgentID != "mail:bot@example.com" {
+		t.Fatalf("event = %+v", ev)
+	}
+	if ev.Payload["text"] != "Dear customer" || ev.Payload["subject"] != "Résumé" {
+		t.Fatalf("payload = %+v", ev.Payload)
+	}
+}
+
+func TestFilterSelection(t *testing.T) {
+	pdp := &fakePDP{verdict: &verdict.Verdict{Decision: verdict.Block}}
+	g := newGuard(pdp)
+	g.senders = []string{"*@bots.example.com"}
+	g.marker = "X-AI-Generated"
+
+	if d := g.filter(context.Background(), message("human@example.com", "hi")); d.Action != milter.Accept {
+		t.Fatalf("unselected sender: action = %s", d.Action)
+	}
+	if d := g.filter(context.Background(), message("support@bots.example.com", "hi")); d.Action != milter.Reject {
+		t.Fatalf("selected sender: action = %s", d.Action)
+	}
+	m := message("human@example.com", "hi")
+	m.Header.Set("X-AI-Generated", "yes")
+	if d := g.filter(context.Background(), m); d.Action != milter.Reject {
+		t.Fatalf("marker header: action = %s", d.Action)
+	}
+	if len(pdp.events) != 2 {
+		t.Fatalf("evaluated %d messages, want 2", len(pdp.events))
+	}
+}
+
+func TestFilterCategoryPrefixes(t *testing.T) {
+	pdp := &fakePDP{verdict: &verdict.Verdict{Decision: verdict.Block,
+		Categories: []verdict.Category{{ID: "safety.toxicity.profanity"}}}}
+	g := newGuard(pdp)
+	g.categories = []string{"safety.pii", "security.data_exfiltration"}
+	if d := g.filter(context.Background(), message("bot@example.com", "hi")); d.Action != milter.Accept {
+		t.Fatalf("out-of-scope category: action = %s", d.Action)
+	}
+	pdp.verdict.Categories = []verdict.Category{{ID: "safety.pii.bank_card"}}
+	if d := g.filter(context.Background(), message("bot@example.com", "hi")); d.Action != milter.Reject {
+		t.Fatalf("in-scope category: action = %s", d.Action)
+	}
+}
+
+func TestFilterUnreachable(t *testing.T) {
+	pdp := &fakePDP{err: errors.New("connection refused")}
+	g := newGuard(pdp)
+	if d := g.filter(context.Background(), message("bot@example.com", "hi")); d.Action != milter.TempFail {
+		t.Fatalf("fail closed: action = %s", d.Action)
+	}
+	g.failClosed = false
+	if d := g.filter(context.Background(), message("bot@example.com", "hi")); d.Action != milter.Accept {
+		t.Fatalf("fail open: action = %s", d.Action)
+	}
+}
+
+func TestSMTPText(t *testing.T) {
+	if got := smtpText("100% bad\r\nnext"); strings.ContainsAny(got, "%\r\n") {
+		t.Fatalf("smtpText = %q", got)
+	}
+}
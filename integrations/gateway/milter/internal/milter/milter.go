@@ -0,0 +1,393 @@
+// Package milter implements the filter side of the Sendmail milter protocol
+// (version 6): it answers the SMFIC_* commands Postfix and Sendmail send,
+// closely enough to filter message bodies.
+//
+// Only the parts a content filter needs are implemented: option negotiation,
+// envelope sender and recipients, headers, body chunks, and the end-of-body
+// decision with header insertion and quarantine. Connection, HELO and DATA
+// callbacks are negotiated away.
+package milter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// Commands sent by the MTA.
+const (
+	cmdAbort  = 'A'
+	cmdBody   = 'B'
+	cmdMacro  = 'D'
+	cmdEOB    = 'E'
+	cmdQuitNC = 'K'
+	cmdHeader = 'L'
+	cmdMail   = 'M'
+	cmdOptNeg = 'O'
+	cmdQuit   = 'Q'
+	cmdRcpt   = 'R'
+)
+
+// Responses sent by the filter.
+const (
+	respAccept     = 'a'
+	respContinue   = 'c'
+	respDiscard    = 'd'
+	respAddHeader  = 'h'
+	respOptNeg     = 'O'
+	respQuarantine = 'q'
+	respReject     = 'r'
+	respTempFail   = 't'
+	respReplyCode  = 'y'
+)
+
+// Negotiated action and protocol flags.
+const (
+	actAddHeaders = 0x01
+	actQuarantine = 0x20
+
+	protoNoConnect = 0x01
+	protoNoHelo    = 0x02
+	protoNoUnknown = 0x100
+	protoNoData    = 0x200
+)
+
+const (
+	protocolVersion = 6
+	maxPacket       = 1 << 20
+)
+
+// Action is the filter's final disposition of a message.
+type Action int
+
+const (
+	Accept Action = iota
+	Reject
+	TempFail
+	Quarantine
+	Discard
+)
+
+func (a Action) String() string {
+	switch a {
+	case Accept:
+		return "accept"
+	case Reject:
+		return "reject"
+	case TempFail:
+		return "tempfail"
+	case Quarantine:
+		return "quarantine"
+	case Discard:
+		return "discard"
+	}
+	return fmt.Sprintf("Action(%d)", int(a))
+}
+
+// Header is a header field to add to an accepted or quarantined message.
+type Header struct {
+	Name, Value string
+}
+
+// Decision is what a Filter returns at end of body.
+type Decision struct {
+	Action Action
+	// Reply is an SMTP reply such as "550 5.7.1 blocked" used for Reject and
+	// TempFail. The MTA's default reply is used when empty.
+	Reply string
+	// Reason is the quarantine reason shown to mail operators.
+	Reason string
+	// Headers are added to the message when it is accepted or quarantined.
+	Headers []Header
+}
+
+// Message is the envelope, headers and (possibly truncated) body of one
+// message as seen by the filter.
+type Message struct {
+	Sender     string
+	Recipients []string
+	Header     textproto.MIMEHeader
+	Body       []byte
+	// Truncated reports that the body exceeded Server.MaxBodySize and only
+	// its prefix is in Body.
+	Truncated bool
+}
+
+// Filter decides the fate of one message.
+type Filter func(ctx context.Context, m *Message) Decision
+
+// Server accepts milter connections from an MTA.
+type Server struct {
+	Filter Filter
+	// MaxBodySize caps the body bytes buffered per message. Zero means 1 MiB.
+	MaxBodySize int
+	// ErrorLog receives connection errors. Nil uses the log package default.
+	ErrorLog *log.Logger
+
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+}
+
+// Serve accepts connections on l until it is closed.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		s.track(conn, true)
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer s.track(conn, false)
+			defer conn.Close()
+			if err := s.ServeConn(context.Background(), conn); err != nil {
+				s.logf("milter: %s: %v", conn.RemoteAddr(), err)
+			}
+		}()
+	}
+}
+
+// Close closes every open connection and waits for their handlers to return.
+// The listener passed to Serve must be closed by the caller.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+func (s *Server) track(c net.Conn, add bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conns == nil {
+		s.conns = make(map[net.Conn]struct{})
+	}
+	if add {
+		s.conns[c] = struct{}{}
+	} else {
+		delete(s.conns, c)
+	}
+}
+
+func (s *Server) logf(format string, args ...any) {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+func (s *Server) maxBody() int {
+	if s.MaxBodySize > 0 {
+		return s.MaxBodySize
+	}
+	return 1 << 20
+}
+
+// session is the per-connection protocol state.
+type session struct {
+	s       *Server
+	r       *bufio.Reader
+	w       io.Writer
+	actions uint32
+	msg     *Message
+}
+
+// ServeConn runs the milter protocol on one MTA connection until the MTA
+// quits or the connection fails. A clean quit returns nil.
+func (s *Server) ServeConn(ctx context.Context, rw io.ReadWriter) error {
+	ss := &session{s: s, r: bufio.NewReader(rw), w: rw}
+	ss.reset()
+	for {
+		cmd, data, err := readPacket(ss.r)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		switch cmd {
+		case cmdOptNeg:
+			err = ss.negotiate(data)
+		case cmdMacro:
+			// Macros are not used; no reply is expected.
+		case cmdMail:
+			ss.reset()
+			if args := splitNUL(data); len(args) > 0 {
+				ss.msg.Sender = trimAddr(args[0])
+			}
+			err = ss.reply(respContinue, nil)
+		case cmdRcpt:
+			if args := splitNUL(data); len(args) > 0 {
+				ss.msg.Recipients = append(ss.msg.Recipients, trimAddr(args[0]))
+			}
+			err = ss.reply(respContinue, nil)
+		case cmdHeader:
+			if kv := splitNUL(data); len(kv) >= 2 {
+				ss.msg.Header.Add(kv[0], strings.TrimSpace(kv[1]))
+			}
+			err = ss.reply(respContinue, nil)
+		case cmdBody:
+			ss.appendBody(data)
+			err = ss.reply(respContinue, nil)
+		case cmdEOB:
+			err = ss.endOfBody(ctx)
+			ss.reset()
+		case cmdAbort:
+			ss.reset()
+		case cmdQuit:
+			return nil
+		case cmdQuitNC:
+			ss.reset()
+		default:
+			// Connect, HELO, DATA, end-of-headers and unknown commands are
+			// not inspected; they only need a continue.
+			err = ss.reply(respContinue, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (ss *session) reset() {
+	ss.msg = &Message{Header: make(textproto.MIMEHeader)}
+}
+
+func (ss *session) negotiate(data []byte) error {
+	if len(data) < 12 {
+		return fmt.Errorf("milter: short option negotiation (%d bytes)", len(data))
+	}
+	version := binary.BigEndian.Uint32(data[0:4])
+	mtaActions := binary.BigEndian.Uint32(data[4:8])
+	mtaProto := binary.BigEndian.Uint32(data[8:12])
+	if version > protocolVersion {
+		version = protocolVersion
+	}
+	ss.actions = mtaActions & (actAddHeaders | actQuarantine)
+	proto := mtaProto & (protoNoConnect | protoNoHelo | protoNoUnknown | protoNoData)
+
+	var out [12]byte
+	binary.BigEndian.PutUint32(out[0:4], version)
+	binary.BigEndian.PutUint32(out[4:8], ss.actions)
+	binary.BigEndian.PutUint32(out[8:12], proto)
+	return ss.reply(respOptNeg, out[:])
+}
+
+func (ss *session) appendBody(chunk []byte) {
+	room := ss.s.maxBody() - len(ss.msg.Body)
+	if room <= 0 {
+		ss.msg.Truncated = true
+		return
+	}
+	if len(chunk) > room {
+		chunk = chunk[:room]
+		ss.msg.Truncated = true
+	}
+	ss.msg.Body = append(ss.msg.Body, chunk...)
+}
+
+func (ss *session) endOfBody(ctx context.Context) error {
+	d := ss.s.Filter(ctx, ss.msg)
+	if d.Action == Accept || d.Action == Quarantine {
+		if ss.actions&actAddHeaders != 0 {
+			for _, h := range d.Headers {
+				if err := ss.reply(respAddHeader, nul(h.Name, h.Value)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	switch d.Action {
+	case Reject:
+		if d.Reply != "" {
+			return ss.reply(respReplyCode, nul(d.Reply))
+		}
+		return ss.reply(respReject, nil)
+	case TempFail:
+		if d.Reply != "" {
+			return ss.reply(respReplyCode, nul(d.Reply))
+		}
+		return ss.reply(respTempFail, nil)
+	case Discard:
+		return ss.reply(respDiscard, nil)
+	case Quarantine:
+		if ss.actions&actQuarantine == 0 {
+			// The MTA did not grant quarantine; holding the message in the
+			// queue for a retry is the closest safe substitute.
+			return ss.reply(respTempFail, nil)
+		}
+		if err := ss.reply(respQuarantine, nul(d.Reason)); err != nil {
+			return err
+		}
+	}
+	return ss.reply(respAccept, nil)
+}
+
+func (ss *session) reply(code byte, data []byte) error {
+	return writePacket(ss.w, code, data)
+}
+
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:])
+	if n == 0 || n > maxPacket {
+		return 0, nil, fmt.Errorf("milter: invalid packet length %d", n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return 0, nil, err
+	}
+	return buf[0], buf[1:], nil
+}
+
+func writePacket(w io.Writer, code byte, data []byte) error {
+	buf := make([]byte, 5+len(data))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(1+len(data)))
+	buf[4] = code
+	copy(buf[5:], data)
+	_, err := w.Write(buf)
+	return err
+}
+
+// splitNUL splits a NUL-terminated string list.
+func splitNUL(b []byte) []string {
+	b = bytes.TrimSuffix(b, []byte{0})
+	if len(b) == 0 {
+		return nil
+	}
+	return strings.Split(string(b), "\x00")
+}
+
+func nul(parts ...string) []byte {
+	var b bytes.Buffer
+	for _, p := range parts {
+		b.WriteString(p)
+		b.WriteByte(0)
+	}
+	return b.Bytes()
+}
+
+func trimAddr(s string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(s), "<"), ">")
+}
@@ -0,0 +1,149 @@
+package milter
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+// mta drives the MTA side of a milter conversation over a pipe.
+type mta struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (m *mta) send(cmd byte, data []byte) {
+	m.t.Helper()
+	if err := writePacket(m.conn, cmd, data); err != nil {
+		m.t.Fatalf("send %q: %v", cmd, err)
+	}
+}
+
+func (m *mta) recv() (byte, []byte) {
+	m.t.Helper()
+	cmd, data, err := readPacket(m.r)
+	if err != nil {
+		m.t.Fatalf("recv: %v", err)
+	}
+	return cmd, data
+}
+
+func (m *mta) expect(want byte) []byte {
+	m.t.Helper()
+	cmd, data := m.recv()
+	if cmd != want {
+		m.t.Fatalf("got response %q, want %q", cmd, want)
+	}
+	return data
+}
+
+func startSession(t *testing.T, f Filter) *mta {
+	t.Helper()
+	client, server := net.Pipe()
+	s := &Server{Filter: f, MaxBodySize: 16}
+	done := make(chan error, 1)
+	go func() { done <- s.ServeConn(context.Background(), server) }()
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+		<-done
+	})
+	m := &mta{t: t, conn: client, r: bufio.NewReader(client)}
+
+	var opt [12]byte
+	binary.BigEndian.PutUint32(opt[0:4], 6)
+	binary.BigEndian.PutUint32(opt[4:8], 0x1ff)
+	binary.BigEndian.PutUint32(opt[8:12], 0x1fffff)
+	m.send(cmdOptNeg, opt[:])
+	got := m.expect(respOptNeg)
+	if v := binary.BigEndian.Uint32(got[0:4]); v != 6 {
+		t.Fatalf("negotiated version %d, want 6", v)
+	}
+	if a := binary.BigEndian.Uint32(got[4:8]); a != actAddHeaders|actQuarantine {
+		t.Fatalf("negotiated actions %#x", a)
+	}
+	return m
+}
+
+func sendMessage(m *mta, body string) {
+	m.send(cmdMacro, nul("M", "i", "4ABC"))
+	m.send(cmdMail, nul("<bot@example.com>", "SIZE=100"))
+	m.expect(respContinue)
+	m.send(cmdRcpt, nul("<alice@example.org>"))
+	m.expect(respContinue)
+	m.send(cmdHeader, nul("Subject", " Your ticket"))
+	m.expect(respContinue)
+	m.send('N', nil)
+	m.expect(respContinue)
+	m.send(cmdBody, []byte(body))
+	m.expect(respContinue)
+	m.send(cmdEOB, nil)
+}
+
+func TestAcceptAddsHeaders(t *testing.T) {
+	var seen *Message
+	m := startSession(t, func(_ context.Context, msg *Message) Decision {
+		seen = msg
+		return Decision{Action: Accept, Headers: []Header{{"X-OGR-Decision", "allow"}}}
+	})
+	sendMessage(m, "hello there")
+	if got := splitNUL(m.expect(respAddHeader)); got[0] != "X-OGR-Decision" || got[1] != "allow" {
+		t.Fatalf("add header = %q", got)
+	}
+	m.expect(respAccept)
+
+	if seen.Sender != "bot@example.com" || len(seen.Recipients) != 1 || seen.Recipients[0] != "alice@example.org" {
+		t.Fatalf("envelope = %q %q", seen.Sender, seen.Recipients)
+	}
+	if seen.Header.Get("Subject") != "Your ticket" || string(seen.Body) != "hello there" || seen.Truncated {
+		t.Fatalf("message = %+v", seen)
+	}
+}
+
+func TestRejectWithReply(t *testing.T) {
+	m := startSession(t, func(context.Context, *Message) Decision {
+		return Decision{Action: Reject, Reply: "550 5.7.1 blocked"}
+	})
+	sendMessage(m, "x")
+	if got := splitNUL(m.expect(respReplyCode)); got[0] != "550 5.7.1 blocked" {
+		t.Fatalf("reply = %q", got)
+	}
+}
+
+func TestQuarantineAndTruncation(t *testing.T) {
+	var truncated bool
+	m := startSession(t, func(_ context.Context, msg *Message) Decision {
+		truncated = msg.Truncated && len(msg.Body) == 16
+		return Decision{Action: Quarantine, Reason: "held"}
+	})
+	sendMessage(m, "this body is longer than sixteen bytes")
+	if got := splitNUL(m.expect(respQuarantine)); got[0] != "held" {
+		t.Fatalf("quarantine reason = %q", got)
+	}
+	m.expect(respAccept)
+	if !truncated {
+		t.Fatal("body was not truncated at MaxBodySize")
+	}
+}
+
+func TestAbortResetsMessage(t *testing.T) {
+	var body string
+	m := startSession(t, func(_ context.Context, msg *Message) Decision {
+		body = string(msg.Body)
+		return Decision{Action: Accept}
+	})
+	m.send(cmdMail, nul("<a@example.com>"))
+	m.expect(respContinue)
+	m.send(cmdBody, []byte("first"))
+	m.expect(respContinue)
+	m.send(cmdAbort, nil)
+
+	sendMessage(m, "second")
+	m.expect(respAccept)
+	if body != "second" {
+		t.Fatalf("body = %q, want only the second message", body)
+	}
+}
@@ -0,0 +1,148 @@
+// Command ogr-milter is an OpenGuardrails gateway-hook integration for mail:
+// a Sendmail/Postfix milter that judges outbound, AI-generated email bodies
+// with the OGR runtime PDP before they leave the MTA.
+//
+//	MTA ──milter──▶ ogr-milter ──GuardEvent──▶ POST /api/public/ogr/v1/evaluate ──▶ Verdict
+//
+// Each selected message becomes one `model_output` GuardEvent
+// (observation_point "gateway"). block rejects the message (or quarantines it),
+// require_approval/redact/modify quarantine it for human review, and allow
+// accepts it with X-OGR-Decision and X-OGR-Guard-Id headers. Like the mitmproxy
+// addon, it carries no detection logic: the runtime's policy decides.
+//
+// Env:
+//
+//	OGR_RUNTIME_URL          runtime base URL (default http://localhost:3000)
+//	OGR_API_KEY              workspace API key, sent as a bearer token
+//	OGR_EVAL_TIMEOUT         seconds per evaluate call (default 10)
+//	OGR_FAIL_MODE_CLOSED     tempfail while the runtime is unreachable (default true)
+//	OGR_AGENT_ID             subject.agent_id override (default "mail:<sender>")
+//	OGR_AGENT_TYPE           subject.agent_type (optional)
+//	OGR_MILTER_LISTEN        tcp "host:port" or "unix:/path" (default 127.0.0.1:8894)
+//	OGR_MILTER_SENDERS       comma-separated sender patterns, e.g. "*@bots.example.com"
+//	OGR_MILTER_HEADER        also judge messages carrying this header, e.g. X-AI-Generated
+//	OGR_MILTER_CATEGORIES    comma-separated category prefixes to enforce (default: all)
+//	OGR_MILTER_BLOCK_ACTION  reject | quarantine (default reject)
+//	OGR_MILTER_MAX_BODY      body bytes buffered per message (default 1048576)
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go/ogr"
+	"github.com/openguardrails/openguardrails/integrations/gateway/milter/internal/milter"
+)
+
+func main() {
+	listen := flag.String("listen", env("OGR_MILTER_LISTEN", "127.0.0.1:8894"),
+		`listen address: tcp "host:port" or "unix:/path"`)
+	flag.Parse()
+
+	logger := log.New(os.Stderr, "ogr-milter: ", log.LstdFlags)
+	g, err := guardFromEnv(logger)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	maxBody, err := strconv.Atoi(env("OGR_MILTER_MAX_BODY", "1048576"))
+	if err != nil {
+		logger.Fatalf("OGR_MILTER_MAX_BODY: %v", err)
+	}
+
+	l, err := listenOn(*listen)
+	if err != nil {
+		logger.Fatal(err)
+	}
+	srv := &milter.Server{Filter: g.filter, MaxBodySize: maxBody, ErrorLog: logger}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-stop
+		l.Close()
+		srv.Close()
+	}()
+
+	logger.Printf("listening on %s → %s (fail_%s)", *listen, env("OGR_RUNTIME_URL", "http://localhost:3000"),
+		map[bool]string{true: "closed", false: "open"}[g.failClosed])
+	if err := srv.Serve(l); err != nil {
+		logger.Fatal(err)
+	}
+}
+
+func guardFromEnv(logger *log.Logger) (*guard, error) {
+	secs, err := strconv.ParseFloat(env("OGR_EVAL_TIMEOUT", "10"), 64)
+	if err != nil || secs <= 0 {
+		return nil, fmt.Errorf("OGR_EVAL_TIMEOUT: invalid value %q", os.Getenv("OGR_EVAL_TIMEOUT"))
+	}
+	timeout := time.Duration(secs * float64(time.Second))
+
+	var blockAction milter.Action
+	switch v := env("OGR_MILTER_BLOCK_ACTION", "reject"); v {
+	case "reject":
+		blockAction = milter.Reject
+	case "quarantine":
+		blockAction = milter.Quarantine
+	default:
+		return nil, fmt.Errorf("OGR_MILTER_BLOCK_ACTION: want reject or quarantine, got %q", v)
+	}
+
+	apiKey := os.Getenv("OGR_API_KEY")
+	if apiKey == "" {
+		logger.Print("OGR_API_KEY is not set — runtime calls will be rejected (401).")
+	}
+	return &guard{
+		pdp:         ogr.NewClient(env("OGR_RUNTIME_URL", "http://localhost:3000"), apiKey, timeout),
+		timeout:     timeout,
+		senders:     list(os.Getenv("OGR_MILTER_SENDERS")),
+		marker:      strings.TrimSpace(os.Getenv("OGR_MILTER_HEADER")),
+		categories:  list(os.Getenv("OGR_MILTER_CATEGORIES")),
+		blockAction: blockAction,
+		failClosed:  truthy(os.Getenv("OGR_FAIL_MODE_CLOSED"), true),
+		agentID:     os.Getenv("OGR_AGENT_ID"),
+		agentType:   os.Getenv("OGR_AGENT_TYPE"),
+		logger:      logger,
+	}, nil
+}
+
+func listenOn(addr string) (net.Listener, error) {
+	if p, ok := strings.CutPrefix(addr, "unix:"); ok {
+		// A stale socket from a previous run would fail the bind.
+		os.Remove(p)
+		return net.Listen("unix", p)
+	}
+	return net.Listen("tcp", strings.TrimPrefix(addr, "tcp:"))
+}
+
+func env(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return def
+}
+
+func truthy(v string, def bool) bool {
+	v = strings.ToLower(strings.TrimSpace(v))
+	if v == "" {
+		return def
+	}
+	return v != "0" && v != "false" && v != "no" && v != "off"
+}
+
+func list(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,30 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+)
+
+// In-place upgrades need fd passing and SO_REUSEPORT, which this platform
+// lacks; ogw drains and exits instead.
+var restartSignals []os.Signal
+
+func listen(addr string, reusePort bool) (net.Listener, error) {
+	if reusePort {
+		return nil, errors.New("reuse_port is not supported on " + runtime.GOOS)
+	}
+	return net.Listen("tcp", addr)
+}
+
+func inheritListeners() map[string]net.Listener { return map[string]net.Listener{} }
+
+func ready() {}
+
+func restart([]net.Listener, []*http.Server) error {
+	return errors.New("restart is not supported on " + runtime.GOOS)
+}
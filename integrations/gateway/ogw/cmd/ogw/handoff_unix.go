@@ -0,0 +1,136 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// A restart hands the listeners to the new process as fds 3, 4, … in the
+// order of envListeners, and a pipe at envReady for it to write to once it
+// serves.
+const (
+	envListeners = "OGW_INHERITED_LISTENERS"
+	envReady     = "OGW_READY_FD"
+)
+
+// restartSignals upgrade ogw in place.
+var restartSignals = []os.Signal{syscall.SIGUSR2}
+
+// listen opens a TCP listener on addr, with SO_REUSEPORT if reusePort.
+func listen(addr string, reusePort bool) (net.Listener, error) {
+	var lc net.ListenConfig
+	if reusePort {
+		lc.Control = func(_, _ string, c syscall.RawConn) error {
+			var err error
+			if cerr := c.Control(func(fd uintptr) {
+				err = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1)
+			}); cerr != nil {
+				return cerr
+			}
+			return err
+		}
+	}
+	return lc.Listen(context.Background(), "tcp", addr)
+}
+
+// inheritListeners returns the listeners handed over by the process that
+// restarted into this one, by address.
+func inheritListeners() map[string]net.Listener {
+	out := map[string]net.Listener{}
+	addrs := os.Getenv(envListeners)
+	if addrs == "" {
+		return out
+	}
+	os.Unsetenv(envListeners)
+	for i, addr := range strings.Split(addrs, ",") {
+		f := os.NewFile(uintptr(3+i), addr)
+		l, err := net.FileListener(f)
+		f.Close()
+		if err == nil {
+			out[addr] = l
+		}
+	}
+	return out
+}
+
+// ready tells the process that restarted into this one, if any, that this
+// one serves now.
+func ready() {
+	fd, err := strconv.Atoi(os.Getenv(envReady))
+	if err != nil {
+		return
+	}
+	os.Unsetenv(envReady)
+	f := os.NewFile(uintptr(fd), "ready")
+	f.Write([]byte{1})
+	f.Close()
+}
+
+// restart starts this binary again with the same arguments, hands it the
+// listeners, and waits until it serves. The new process reads the
+// configuration afresh; if it fails to start, this one keeps serving.
+func restart(listeners []net.Listener, servers []*http.Server) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	var files []*os.File
+	var addrs []string
+	for i, l := range listeners {
+		fl, ok := l.(interface{ File() (*os.File, error) })
+		if !ok {
+			return fmt.Errorf("%s: listener cannot be handed over", servers[i].Addr)
+		}
+		f, err := fl.File()
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		files = append(files, f)
+		addrs = append(addrs, servers[i].Addr)
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = append(files, w)
+	cmd.Env = append(os.Environ(), envListeners+"="+strings.Join(addrs, ","), envReady+"="+strconv.Itoa(3+len(files)))
+	err = cmd.Start()
+	w.Close()
+	if err != nil {
+		return err
+	}
+	go cmd.Wait()
+
+	// The pipe reads one byte once the new process serves, or EOF when it
+	// exits first.
+	readyc := make(chan error, 1)
+	go func() {
+		_, err := r.Read(make([]byte, 1))
+		readyc <- err
+	}()
+	select {
+	case err := <-readyc:
+		if err != nil {
+			return errors.New("the new process exited before serving")
+		}
+		return nil
+	case <-time.After(time.Minute):
+		cmd.Process.Kill()
+		return errors.New("the new process did not serve within a minute")
+	}
+}
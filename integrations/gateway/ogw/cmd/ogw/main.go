@@ -0,0 +1,421 @@
+// Command ogw is the OpenGuardrails security gateway: a single static
+// binary that fronts one or more model backends behind an OpenAI-compatible
+// API and checks every prompt and answer with the OpenGuardrails detection
+// API.
+//
+//	client ──▶ ogw ──▶ backend (/v1/chat/completions)
+//	            │
+//	            └── POST /v1/guardrails (detection API)
+//
+// Rejected prompts never reach the backend, and rejected answers never
+// reach the client. Both get a chat completion carrying the platform's
+// suggested answer (finish_reason "content_filter").
+//
+// With -config (or OGW_CONFIG) set, settings come from a YAML file that can
+// declare several backends and route models to them; see README.md. -check
+// validates the configuration and exits; -schema prints its JSON Schema.
+// Otherwise ogw fronts a single OpenAI-compatible backend configured by
+// env:
+//
+//	OGW_LISTEN            listen address (default :8080)
+//	OGW_UPSTREAM_URL      backend base URL (default https://api.openai.com/v1)
+//	OGW_UPSTREAM_KEY      backend API key
+//	OGW_API_KEYS          comma-separated keys clients must present (empty: no auth)
+//	OGW_TIMEOUT           seconds per backend call (default 300)
+//	OGW_STREAM_CHECK_EVERY  tokens between checks of a streamed answer (default 50)
+//	OGW_STREAM_WINDOW       trailing tokens each stream check sees (default 400)
+//	OGW_STREAM_PASSTHROUGH  forward stream chunks before they are checked (default false)
+//	OGR_BASE_URL          detection API base URL (default https://api.openguardrails.com/v1)
+//	OGR_API_KEY           application API key for the detection API
+//	OGR_FAIL_MODE_CLOSED  refuse while the detection API is unreachable (default true)
+//	OGW_ADMIN_LISTEN      admin API address; needs admin keys (default: no admin API)
+//	OGW_ADMIN_KEYS        comma-separated keys the admin API requires
+//	OGW_ADMIN_DEBUG       serve /debug/ (pprof, config, verdicts) on the admin address (default false)
+//	OGW_OIDC_ISSUER       OpenID Connect issuer whose JWTs clients may present
+//	OGW_OIDC_AUDIENCE     audience those JWTs must carry
+//	OGW_LOG_LEVEL         debug, info, warn or error (default info)
+//	OGW_LOG_FORMAT        text or json (default text)
+//	OGW_DRAIN_TIMEOUT     how long requests in flight may take to finish on shutdown (default 30s)
+//	OGW_REUSE_PORT        open the listeners with SO_REUSEPORT (default false)
+//	OGW_EXT_PROC_LISTEN   also serve Envoy's ext_proc API on this address (default: off)
+//	OGW_EXT_AUTHZ_LISTEN  also serve Envoy's ext_authz API on this address (default: off)
+//
+// SIGHUP, like POST /admin/reload, re-reads the configuration and swaps it
+// in without dropping requests or streams in flight. SIGTERM and SIGINT
+// drain: /healthz fails, and requests, streams and realtime sessions in
+// flight get OGW_DRAIN_TIMEOUT to finish. SIGUSR2 upgrades in place: the
+// binary is started again with the listening sockets handed over, and once
+// it serves, this process drains.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go"
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/archive"
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/config"
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/gateway"
+)
+
+func main() {
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
+	path := flag.String("config", os.Getenv("OGW_CONFIG"), "YAML configuration file")
+	check := flag.Bool("check", false, "validate the configuration and exit")
+	schema := flag.Bool("schema", false, "print the configuration's JSON Schema and exit")
+	flag.Parse()
+
+	if *schema {
+		doc, err := config.JSONSchema()
+		if err != nil {
+			fatal(logger, err)
+		}
+		fmt.Printf("%s\n", doc)
+		return
+	}
+	file, err := load(*path)
+	if err == nil && *check {
+		_, err = gateway.New(file.Config, guardrails.NewClient(), nil)
+	}
+	if err != nil {
+		// Configuration errors are for people: one per line, unadorned.
+		fmt.Fprintf(os.Stderr, "ogw: %v\n", err)
+		os.Exit(2)
+	}
+	if *check {
+		fmt.Println("configuration ok")
+		return
+	}
+	// The log level and format are fixed at startup.
+	handler, err := file.Logging.Handler(os.Stderr)
+	if err != nil {
+		fatal(logger, err)
+	}
+	logger = slog.New(handler)
+	if file.Guardrails.APIKey == "" {
+		logger.Warn("no detection API key is set — detection calls will be rejected (401).")
+	}
+	if len(file.APIKeys) == 0 && len(file.Policies) == 0 {
+		logger.Warn("no client API keys are set — any client can use the backends through this gateway.")
+	}
+	var archiver *archive.Archiver
+	if file.Archive.Sink != "" {
+		guard := guardrails.NewClient(
+			guardrails.WithBaseURL(file.Guardrails.BaseURL),
+			guardrails.WithAPIKey(file.Guardrails.APIKey),
+		)
+		archiver, err = archive.New(file.Archive, func(ctx context.Context, text string) (string, error) {
+			a, err := guard.Anonymize(ctx, text)
+			if err != nil {
+				return "", err
+			}
+			return a.Text, nil
+		}, logger)
+		if err != nil {
+			fatal(logger, err)
+		}
+	}
+
+	// Reloads re-read the configuration source. The listen addresses, admin
+	// keys and archive settings are fixed at startup.
+	first := file
+	server, err := gateway.NewServer(func() (*gateway.Gateway, error) {
+		f := first
+		first = nil
+		if f == nil {
+			var err error
+			if f, err = load(*path); err != nil {
+				return nil, err
+			}
+		}
+		guard := guardrails.NewClient(
+			guardrails.WithBaseURL(f.Guardrails.BaseURL),
+			guardrails.WithAPIKey(f.Guardrails.APIKey),
+		)
+		cfg := f.Config
+		if archiver != nil {
+			cfg.Archiver = archiver
+		}
+		return gateway.New(cfg, guard, logger)
+	}, logger)
+	if err != nil {
+		fatal(logger, err)
+	}
+
+	servers := []*http.Server{{Addr: file.Listen, Handler: server, ReadHeaderTimeout: 10 * time.Second}}
+	if file.Admin.Listen != "" {
+		var admin http.Handler = server.AdminHandler(file.Admin.Keys)
+		if file.Admin.Debug {
+			mux := http.NewServeMux()
+			mux.Handle("/admin/", admin)
+			mux.Handle("/debug/", server.DebugHandler(file.Admin.Keys))
+			admin = mux
+		}
+		servers = append(servers, &http.Server{Addr: file.Admin.Listen, Handler: admin, ReadHeaderTimeout: 10 * time.Second})
+	}
+	// gRPC is served as cleartext HTTP/2; without a TLS handshake the
+	// preface is all that tells the protocols apart.
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetUnencryptedHTTP2(true)
+	if file.ExtProc != nil {
+		servers = append(servers, &http.Server{Addr: file.ExtProc.Listen, Handler: server.ExtProcHandler(), Protocols: protocols, ReadHeaderTimeout: 10 * time.Second})
+	}
+	if file.ExtAuthz != nil {
+		servers = append(servers, &http.Server{Addr: file.ExtAuthz.Listen, Handler: server.ExtAuthzHandler(), Protocols: protocols, ReadHeaderTimeout: 10 * time.Second})
+	}
+	// The listeners are opened up front, so a restart can hand them over.
+	inherited := inheritListeners()
+	listeners := make([]net.Listener, len(servers))
+	for i, srv := range servers {
+		l, ok := inherited[srv.Addr]
+		if !ok {
+			if l, err = listen(srv.Addr, file.ReusePort); err != nil {
+				fatal(logger, err)
+			}
+		}
+		delete(inherited, srv.Addr)
+		listeners[i] = l
+	}
+	for _, l := range inherited {
+		l.Close()
+	}
+	failed := make(chan error, len(servers))
+	for i, srv := range servers {
+		go func(srv *http.Server, l net.Listener) {
+			if err := srv.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+				failed <- err
+			}
+		}(srv, listeners[i])
+	}
+	logger.Info("listening", "addr", file.Listen, "backends", len(file.Backends), "fail_open", file.FailOpen)
+	if file.Admin.Listen != "" {
+		logger.Info("admin API listening", "addr", file.Admin.Listen)
+	}
+	if file.ExtProc != nil {
+		logger.Info("ext_proc listening", "addr", file.ExtProc.Listen)
+	}
+	if file.ExtAuthz != nil {
+		logger.Info("ext_authz listening", "addr", file.ExtAuthz.Listen)
+	}
+	ready()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, append([]os.Signal{syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM}, restartSignals...)...)
+	for {
+		select {
+		case err := <-failed:
+			fatal(logger, err)
+		case s := <-sig:
+			switch {
+			case s == syscall.SIGHUP:
+				if err := server.Reload(); err != nil {
+					logger.Error("reload failed, keeping the current configuration", "err", err)
+				} else {
+					logger.Info("reloaded on SIGHUP")
+				}
+				continue
+			case slices.Contains(restartSignals, s):
+				if err := restart(listeners, servers); err != nil {
+					logger.Error("restart failed, this process keeps serving", "err", err)
+					continue
+				}
+				logger.Info("the new process is serving; draining this one")
+			}
+			drain(logger, server, servers, archiver, file.Drain)
+			return
+		}
+	}
+}
+
+// drain fails /healthz, keeps accepting connections for d.Delay so load
+// balancers notice, then lets requests, streams and realtime sessions in
+// flight finish within d.Timeout. What is still open after that is cut
+// off. Finally, what they left for the archive is written.
+func drain(logger *slog.Logger, server *gateway.Server, servers []*http.Server, archiver *archive.Archiver, d config.Drain) {
+	server.Drain()
+	time.Sleep(d.Delay)
+	ctx, cancel := context.WithTimeout(context.Background(), d.Timeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func(srv *http.Server) {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				logger.Warn("requests cut off at the drain deadline", "addr", srv.Addr)
+				srv.Close()
+			}
+		}(srv)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := server.WaitSessions(ctx); err != nil {
+			logger.Warn("realtime sessions cut off at the drain deadline")
+		}
+	}()
+	wg.Wait()
+	if archiver != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := archiver.Close(ctx); err != nil {
+			logger.Error("archive close failed", "err", err)
+		}
+	}
+}
+
+// load reads the configuration file at path, or the environment when path
+// is empty, and fills in what it leaves out from the environment.
+func load(path string) (*config.File, error) {
+	var file *config.File
+	if path != "" {
+		f, err := config.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		file = f
+	} else {
+		f, err := fromEnv()
+		if err != nil {
+			return nil, err
+		}
+		file = f
+	}
+	if file.Listen == "" {
+		file.Listen = env("OGW_LISTEN", ":8080")
+	}
+	if file.Admin.Listen == "" {
+		file.Admin.Listen = os.Getenv("OGW_ADMIN_LISTEN")
+	}
+	if file.ExtProc == nil {
+		if addr := os.Getenv("OGW_EXT_PROC_LISTEN"); addr != "" {
+			file.ExtProc = &gateway.ExtProc{Listen: addr}
+		}
+	}
+	if file.ExtAuthz == nil {
+		if addr := os.Getenv("OGW_EXT_AUTHZ_LISTEN"); addr != "" {
+			file.ExtAuthz = &gateway.ExtAuthz{Listen: addr}
+		}
+	}
+	if len(file.Admin.Keys) == 0 {
+		file.Admin.Keys = list(os.Getenv("OGW_ADMIN_KEYS"))
+	}
+	if !file.Admin.Debug {
+		file.Admin.Debug = truthy(os.Getenv("OGW_ADMIN_DEBUG"), false)
+	}
+	if !file.ReusePort {
+		file.ReusePort = truthy(os.Getenv("OGW_REUSE_PORT"), false)
+	}
+	if file.Drain.Timeout <= 0 {
+		d, err := time.ParseDuration(env("OGW_DRAIN_TIMEOUT", "30s"))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("OGW_DRAIN_TIMEOUT: invalid value %q", os.Getenv("OGW_DRAIN_TIMEOUT"))
+		}
+		file.Drain.Timeout = d
+	}
+	if file.Logging.Level == "" {
+		file.Logging.Level = os.Getenv("OGW_LOG_LEVEL")
+	}
+	if file.Logging.Format == "" {
+		file.Logging.Format = os.Getenv("OGW_LOG_FORMAT")
+	}
+	if file.Guardrails.BaseURL == "" {
+		file.Guardrails.BaseURL = env("OGR_BASE_URL", guardrails.DefaultBaseURL)
+	}
+	if file.Guardrails.APIKey == "" {
+		file.Guardrails.APIKey = os.Getenv("OGR_API_KEY")
+	}
+	if file.Admin.Listen != "" && len(file.Admin.Keys) == 0 && (file.OIDC == nil || file.OIDC.AdminClaim == "") {
+		return nil, fmt.Errorf("admin.listen needs admin keys or an OIDC admin claim")
+	}
+	return file, nil
+}
+
+// fromEnv is the single-backend configuration used without a config file.
+func fromEnv() (*config.File, error) {
+	secs, err := strconv.ParseFloat(env("OGW_TIMEOUT", "300"), 64)
+	if err != nil || secs <= 0 {
+		return nil, fmt.Errorf("OGW_TIMEOUT: invalid value %q", os.Getenv("OGW_TIMEOUT"))
+	}
+	checkEvery, err := atoi("OGW_STREAM_CHECK_EVERY", "50")
+	if err != nil {
+		return nil, err
+	}
+	window, err := atoi("OGW_STREAM_WINDOW", "400")
+	if err != nil {
+		return nil, err
+	}
+	var oidc *gateway.OIDC
+	if issuer := os.Getenv("OGW_OIDC_ISSUER"); issuer != "" {
+		oidc = &gateway.OIDC{}
+		oidc.Issuer, oidc.Audience = issuer, os.Getenv("OGW_OIDC_AUDIENCE")
+	}
+	return &config.File{Config: gateway.Config{
+		Backends: []gateway.Backend{{
+			Name:   "default",
+			URL:    env("OGW_UPSTREAM_URL", "https://api.openai.com/v1"),
+			APIKey: os.Getenv("OGW_UPSTREAM_KEY"),
+		}},
+		APIKeys:  list(os.Getenv("OGW_API_KEYS")),
+		OIDC:     oidc,
+		FailOpen: !truthy(os.Getenv("OGR_FAIL_MODE_CLOSED"), true),
+		Timeout:  time.Duration(secs * float64(time.Second)),
+		Stream: gateway.StreamConfig{
+			CheckEvery:  checkEvery,
+			Window:      window,
+			Passthrough: truthy(os.Getenv("OGW_STREAM_PASSTHROUGH"), false),
+		},
+	}}, nil
+}
+
+func fatal(logger *slog.Logger, err error) {
+	logger.Error(err.Error())
+	os.Exit(1)
+}
+
+func env(key, def string) string {
+	if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+		return v
+	}
+	return def
+}
+
+func atoi(key, def string) (int, error) {
+	n, err := strconv.Atoi(env(key, def))
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s: invalid value %q", key, os.Getenv(key))
+	}
+	return n, nil
+}
+
+func list(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func truthy(v string, def bool) bool {
+	v = strings.ToLower(strings.TrimSpace(v))
+	if v == "" {
+		return def
+	}
+	return v != "0" && v != "false" && v != "no" && v != "off"
+}
@@ -0,0 +1,6 @@
+//go:build linux && !mips && !mipsle && !mips64 && !mips64le
+
+package main
+
+// soReusePort is SO_REUSEPORT, which package syscall lacks on Linux.
+const soReusePort = 0xf
@@ -0,0 +1,9 @@
+module github.com/openguardrails/openguardrails/integrations/gateway/ogw
+
+go 1.24
+
+require github.com/openguardrails/openguardrails-go v0.0.0
+
+require gopkg.in/yaml.v3 v3.0.1
+
+replace github.com/openguardrails/openguardrails-go => ../../../packages/go
@@ -0,0 +1,314 @@
+// Package archive keeps a copy of the gateway's traffic for compliance
+// retention and offline evaluation. Records are queued without blocking the
+// request, batched, and written as gzipped JSONL objects to S3-compatible
+// storage (AWS S3, MinIO, GCS through its interoperability API) or a local
+// directory.
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go"
+)
+
+// Record is one exchange as archived.
+type Record struct {
+	Time     time.Time `json:"time"`
+	Policy   string    `json:"policy"`
+	Key      string    `json:"key"`
+	User     string    `json:"user,omitempty"`
+	Endpoint string    `json:"endpoint"`
+	Model    string    `json:"model"`
+	Backend  string    `json:"backend"`
+	Stream   bool      `json:"stream,omitempty"`
+	Status   int       `json:"status"`
+	// Failed lists backends that failed before Backend took over.
+	Failed []string `json:"failed,omitempty"`
+	// Cached is set when the answer came from the response cache.
+	Cached bool `json:"cached,omitempty"`
+	// Blocked is where the exchange was stopped: input, output or stream.
+	Blocked          string               `json:"blocked,omitempty"`
+	Messages         []guardrails.Message `json:"messages,omitempty"`
+	Answer           string               `json:"answer,omitempty"`
+	Input            *Verdict             `json:"input,omitempty"`
+	Output           *Verdict             `json:"output,omitempty"`
+	PromptTokens     int64                `json:"prompt_tokens"`
+	CompletionTokens int64                `json:"completion_tokens"`
+	Cost             float64              `json:"cost,omitempty"`
+	LatencyMS        int64                `json:"latency_ms"`
+}
+
+// Verdict is the detection API's answer to one check.
+type Verdict struct {
+	ID         string                `json:"id"`
+	Action     guardrails.Action     `json:"action"`
+	RiskLevel  string                `json:"risk_level"`
+	Categories []guardrails.Category `json:"categories,omitempty"`
+}
+
+// NewVerdict summarizes resp; nil stays nil.
+func NewVerdict(resp *guardrails.Response) *Verdict {
+	if resp == nil {
+		return nil
+	}
+	return &Verdict{ID: resp.ID, Action: resp.SuggestAction, RiskLevel: string(resp.OverallRiskLevel), Categories: resp.Categories()}
+}
+
+// Sinks.
+const (
+	SinkS3  = "s3"
+	SinkDir = "dir"
+)
+
+// Content modes: what of the exchange's text is archived.
+const (
+	ContentFull   = "full"   // messages and answer as they were
+	ContentMasked = "masked" // sensitive data masked by the detection API's anonymizer
+	ContentOmit   = "omit"   // metadata and verdicts only
+)
+
+// Config configures archival. An empty Sink disables it.
+type Config struct {
+	Sink string `yaml:"sink"`
+	// Dir is the directory of the dir sink.
+	Dir string `yaml:"dir"`
+	// S3 is the bucket of the s3 sink.
+	S3 S3Config `yaml:"s3"`
+	// Prefix is prepended to object names, which are
+	// <prefix>YYYY/MM/DD/<time>-<random>.jsonl.gz.
+	Prefix string `yaml:"prefix"`
+	// Content is full (default), masked or omit.
+	Content string `yaml:"content"`
+	// A batch is written when it holds BatchSize records (default 500) or
+	// is FlushInterval old (default 1m).
+	BatchSize     int           `yaml:"batch_size"`
+	FlushInterval time.Duration `yaml:"flush_interval"`
+	// Queue bounds the records waiting to be batched (default 10000);
+	// records beyond it are dropped rather than slowing traffic down.
+	Queue int `yaml:"queue"`
+}
+
+// Sink stores one object.
+type Sink interface {
+	Put(ctx context.Context, name string, body []byte) error
+}
+
+// Redactor masks the sensitive data in text.
+type Redactor func(ctx context.Context, text string) (string, error)
+
+// Archiver batches records and writes them to a Sink.
+type Archiver struct {
+	cfg     Config
+	sink    Sink
+	redact  Redactor
+	logger  *slog.Logger
+	mu      sync.RWMutex // guards closing queue
+	closed  bool
+	queue   chan Record
+	done    chan struct{}
+	dropped atomic.Int64
+}
+
+// New validates cfg and starts an Archiver. redact is used by the masked
+// content mode.
+func New(cfg Config, redact Redactor, logger *slog.Logger) (*Archiver, error) {
+	var sink Sink
+	switch cfg.Sink {
+	case SinkS3:
+		s, err := NewS3(cfg.S3)
+		if err != nil {
+			return nil, fmt.Errorf("archive: %w", err)
+		}
+		sink = s
+	case SinkDir:
+		if cfg.Dir == "" {
+			return nil, fmt.Errorf("archive: the dir sink needs a dir")
+		}
+		sink = Dir(cfg.Dir)
+	default:
+		return nil, fmt.Errorf("archive: unknown sink %q", cfg.Sink)
+	}
+	return NewWithSink(cfg, sink, redact, logger)
+}
+
+// NewWithSink starts an Archiver that writes to sink.
+func NewWithSink(cfg Config, sink Sink, redact Redactor, logger *slog.Logger) (*Archiver, error) {
+	switch cfg.Content {
+	case "":
+		cfg.Content = ContentFull
+	case ContentFull, ContentOmit:
+	case ContentMasked:
+		if redact == nil {
+			return nil, fmt.Errorf("archive: masked content needs the detection API")
+		}
+	default:
+		return nil, fmt.Errorf("archive: unknown content mode %q", cfg.Content)
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 500
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = time.Minute
+	}
+	if cfg.Queue <= 0 {
+		cfg.Queue = 10000
+	}
+	a := &Archiver{
+		cfg: cfg, sink: sink, redact: redact, logger: logger,
+		queue: make(chan Record, cfg.Queue), done: make(chan struct{}),
+	}
+	go a.run()
+	return a, nil
+}
+
+// Add queues rec. It never blocks: when the queue is full, or the Archiver
+// is closed, the record is dropped and counted.
+func (a *Archiver) Add(rec Record) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.closed {
+		a.dropped.Add(1)
+		return
+	}
+	select {
+	case a.queue <- rec:
+	default:
+		if a.dropped.Add(1) == 1 {
+			a.log(slog.LevelWarn, "archive queue full, dropping records")
+		}
+	}
+}
+
+// Dropped is the number of records dropped so far.
+func (a *Archiver) Dropped() int64 { return a.dropped.Load() }
+
+// Close writes what is queued and stops the Archiver.
+func (a *Archiver) Close(ctx context.Context) error {
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.queue)
+	}
+	a.mu.Unlock()
+	select {
+	case <-a.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (a *Archiver) run() {
+	defer close(a.done)
+	tick := time.NewTicker(a.cfg.FlushInterval)
+	defer tick.Stop()
+	var batch []Record
+	for {
+		select {
+		case rec, ok := <-a.queue:
+			if !ok {
+				a.flush(batch)
+				return
+			}
+			batch = append(batch, rec)
+			if len(batch) >= a.cfg.BatchSize {
+				a.flush(batch)
+				batch = nil
+			}
+		case <-tick.C:
+			a.flush(batch)
+			batch = nil
+		}
+	}
+}
+
+// flush writes batch as one object, trying three times.
+func (a *Archiver) flush(batch []Record) {
+	if len(batch) == 0 {
+		return
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for i := range batch {
+		a.prepare(&batch[i])
+		enc.Encode(&batch[i])
+	}
+	zw.Close()
+
+	var rnd [4]byte
+	rand.Read(rnd[:])
+	now := time.Now().UTC()
+	name := a.cfg.Prefix + now.Format("2006/01/02/20060102T150405Z") + "-" + hex.EncodeToString(rnd[:]) + ".jsonl.gz"
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err = a.sink.Put(ctx, name, buf.Bytes())
+		cancel()
+		if err == nil {
+			return
+		}
+	}
+	a.log(slog.LevelError, "archive records lost", "object", name, "records", len(batch), "err", err)
+}
+
+// prepare applies the content mode to rec.
+func (a *Archiver) prepare(rec *Record) {
+	switch a.cfg.Content {
+	case ContentOmit:
+		rec.Messages, rec.Answer = nil, ""
+	case ContentMasked:
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		mask := func(text string) string {
+			if text == "" {
+				return ""
+			}
+			masked, err := a.redact(ctx, text)
+			if err != nil {
+				// Unmasked text is never archived.
+				a.log(slog.LevelError, "archive masking failed", "err", err)
+				return ""
+			}
+			return masked
+		}
+		msgs := make([]guardrails.Message, len(rec.Messages))
+		for i, m := range rec.Messages {
+			msgs[i] = guardrails.Message{Role: m.Role, Content: mask(m.Content)}
+		}
+		rec.Messages, rec.Answer = msgs, mask(rec.Answer)
+	}
+}
+
+func (a *Archiver) log(level slog.Level, msg string, args ...any) {
+	if a.logger != nil {
+		a.logger.Log(context.Background(), level, msg, args...)
+	}
+}
+
+// Dir is a Sink that writes objects as files under a directory.
+type Dir string
+
+// Put writes body to name under d, creating directories as needed.
+func (d Dir) Put(_ context.Context, name string, body []byte) error {
+	path := filepath.Join(string(d), filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	return os.WriteFile(path, body, 0o640)
+}
@@ -0,0 +1,154 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go"
+)
+
+func records(t *testing.T, body []byte) []Record {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []Record
+	sc := bufio.NewScanner(zr)
+	for sc.Scan() {
+		var rec Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, rec)
+	}
+	return out
+}
+
+func exchange() Record {
+	return Record{
+		Policy: "default", Model: "gpt-4o", Status: 200,
+		Messages: []guardrails.Message{{Role: "user", Content: "mail alice@example.com"}},
+		Answer:   "done",
+	}
+}
+
+func TestArchiverBatches(t *testing.T) {
+	dir := t.TempDir()
+	a, err := NewWithSink(Config{Prefix: "ogw/", BatchSize: 2, Content: ContentOmit}, Dir(dir), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		a.Add(exchange())
+	}
+	if err := a.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	a.Add(exchange())
+	if a.Dropped() != 1 {
+		t.Fatalf("dropped %d", a.Dropped())
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "ogw", "*", "*", "*", "*.jsonl.gz"))
+	if len(files) != 2 {
+		t.Fatalf("%v", files)
+	}
+	n := 0
+	for _, f := range files {
+		body, _ := os.ReadFile(f)
+		for _, rec := range records(t, body) {
+			if rec.Messages != nil || rec.Answer != "" || rec.Model != "gpt-4o" {
+				t.Fatalf("omit: %+v", rec)
+			}
+			n++
+		}
+	}
+	if n != 3 {
+		t.Fatalf("%d records", n)
+	}
+}
+
+func TestArchiverMasks(t *testing.T) {
+	var got []byte
+	sink := sinkFunc(func(_ context.Context, _ string, body []byte) error { got = body; return nil })
+	redact := func(_ context.Context, text string) (string, error) {
+		if text == "done" {
+			return "", errors.New("unavailable")
+		}
+		return strings.ReplaceAll(text, "alice@example.com", "__EMAIL_1__"), nil
+	}
+	if _, err := NewWithSink(Config{Content: ContentMasked}, sink, nil, nil); err == nil {
+		t.Fatal("masked without a redactor")
+	}
+	a, _ := NewWithSink(Config{Content: ContentMasked}, sink, redact, nil)
+	a.Add(exchange())
+	a.Close(context.Background())
+	recs := records(t, got)
+	if len(recs) != 1 || recs[0].Messages[0].Content != "mail __EMAIL_1__" || recs[0].Answer != "" {
+		t.Fatalf("%+v", recs)
+	}
+}
+
+type sinkFunc func(ctx context.Context, name string, body []byte) error
+
+func (f sinkFunc) Put(ctx context.Context, name string, body []byte) error { return f(ctx, name, body) }
+
+func TestS3Put(t *testing.T) {
+	var req *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	s, err := NewS3(S3Config{Bucket: "logs", Endpoint: srv.URL, PathStyle: true, Region: "eu-west-1", AccessKey: "AK", SecretKey: "SK"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(context.Background(), "ogw/2026/10/15/a b.jsonl.gz", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if req.Method != "PUT" || req.URL.EscapedPath() != "/logs/ogw/2026/10/15/a%20b.jsonl.gz" || string(body) != "x" {
+		t.Fatalf("%s %s %q", req.Method, req.URL.EscapedPath(), body)
+	}
+	auth := req.Header.Get("Authorization")
+	date := time.Now().UTC().Format("20060102")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AK/"+date+"/eu-west-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=") {
+		t.Fatalf("authorization %q", auth)
+	}
+	if req.Header.Get("Content-Encoding") != "gzip" || req.Header.Get("X-Amz-Content-Sha256") == "" {
+		t.Fatalf("%v", req.Header)
+	}
+
+	if _, err := NewS3(S3Config{Bucket: "logs", AccessKeyEnv: "OGW_TEST_UNSET"}); err == nil {
+		t.Fatal("no credentials accepted")
+	}
+}
+
+// TestSigV4 checks the signing key derivation against the example in AWS's
+// Signature Version 4 documentation.
+func TestSigV4(t *testing.T) {
+	key := []byte("AWS4wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+	for _, part := range []string{"20120215", "us-east-1", "iam", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	if got := hex.EncodeToString(key); got != "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d" {
+		t.Fatalf("signing key %s", got)
+	}
+}
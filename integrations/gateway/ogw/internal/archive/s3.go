@@ -0,0 +1,163 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// S3Config is an S3-compatible bucket. For MinIO set Endpoint and
+// PathStyle; for GCS set Endpoint https://storage.googleapis.com, Region
+// auto and an HMAC key.
+type S3Config struct {
+	Bucket string `yaml:"bucket"`
+	// Region defaults to us-east-1.
+	Region string `yaml:"region"`
+	// Endpoint defaults to https://s3.<region>.amazonaws.com.
+	Endpoint string `yaml:"endpoint"`
+	// PathStyle addresses the bucket as <endpoint>/<bucket> instead of
+	// <bucket>.<endpoint host>.
+	PathStyle bool `yaml:"path_style"`
+	// The credentials default to AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY
+	// and AWS_SESSION_TOKEN.
+	AccessKey       string `yaml:"access_key"`
+	AccessKeyEnv    string `yaml:"access_key_env"`
+	SecretKey       string `yaml:"secret_key"`
+	SecretKeyEnv    string `yaml:"secret_key_env"`
+	SessionTokenEnv string `yaml:"session_token_env"`
+}
+
+// S3 is a Sink that uploads objects with SigV4-signed PUTs.
+type S3 struct {
+	cfg          S3Config
+	base         *url.URL
+	sessionToken string
+	http         *http.Client
+}
+
+// NewS3 resolves cfg's defaults and credentials.
+func NewS3(cfg S3Config) (*S3, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("s3: no bucket")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.Endpoint == "" {
+		cfg.Endpoint = "https://s3." + cfg.Region + ".amazonaws.com"
+	}
+	base, err := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("s3: invalid endpoint %q", cfg.Endpoint)
+	}
+	if cfg.PathStyle {
+		base.Path += "/" + cfg.Bucket
+	} else {
+		base.Host = cfg.Bucket + "." + base.Host
+	}
+	cfg.AccessKey = credential(cfg.AccessKey, cfg.AccessKeyEnv, "AWS_ACCESS_KEY_ID")
+	cfg.SecretKey = credential(cfg.SecretKey, cfg.SecretKeyEnv, "AWS_SECRET_ACCESS_KEY")
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("s3: no credentials")
+	}
+	return &S3{
+		cfg:          cfg,
+		base:         base,
+		sessionToken: credential("", cfg.SessionTokenEnv, "AWS_SESSION_TOKEN"),
+		http:         &http.Client{Timeout: time.Minute},
+	}, nil
+}
+
+func credential(value, env, dflt string) string {
+	if value != "" {
+		return value
+	}
+	if env == "" {
+		env = dflt
+	}
+	return os.Getenv(env)
+}
+
+// Put uploads body as the object name.
+func (s *S3) Put(ctx context.Context, name string, body []byte) error {
+	u := *s.base
+	u.Path += "/" + name
+	u.RawPath = escapePath(u.Path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("Content-Encoding", "gzip")
+	s.sign(req, body, time.Now().UTC())
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("s3: status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// sign adds an AWS Signature Version 4 Authorization header to req.
+func (s *S3) sign(req *http.Request, body []byte, now time.Time) {
+	sum := sha256.Sum256(body)
+	payload := hex.EncodeToString(sum[:])
+	amzDate := now.Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payload)
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	canonHeaders := "host:" + req.URL.Host + "\nx-amz-content-sha256:" + payload + "\nx-amz-date:" + amzDate + "\n"
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+		signed = append(signed, "x-amz-security-token")
+		canonHeaders += "x-amz-security-token:" + s.sessionToken + "\n"
+	}
+	canonical := strings.Join([]string{
+		req.Method, req.URL.EscapedPath(), req.URL.RawQuery, canonHeaders, strings.Join(signed, ";"), payload,
+	}, "\n")
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := []byte("AWS4" + s.cfg.SecretKey)
+	for _, part := range []string{date, s.cfg.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, strings.Join(signed, ";"), hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	io.WriteString(h, data)
+	return h.Sum(nil)
+}
+
+// escapePath percent-encodes everything in p but unreserved characters and
+// slashes, as SigV4's canonical URI requires.
+func escapePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,217 @@
+// Package config loads ogw's YAML configuration file.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/archive"
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/gateway"
+)
+
+// File is the configuration file:
+//
+//	version: 1
+//	listen: ":8080"
+//	guardrails:
+//	  base_url: https://api.openguardrails.com/v1
+//	  api_key_env: OGR_API_KEY
+//	api_keys: [team-a-key]
+//	backends:
+//	  - name: openai
+//	    type: openai
+//	    api_key: ${OPENAI_API_KEY}
+//	routes:
+//	  - model: "gpt-*"
+//	    backend: openai
+//	admin:
+//	  listen: "127.0.0.1:8081"
+//	  keys_env: OGW_ADMIN_KEYS
+//	archive:
+//	  sink: s3
+//	  s3: {bucket: ogw-archive, region: eu-west-1}
+//
+// The gateway settings (backends, routes, api_keys, policies, fail_open,
+// max_body, timeout, stream, quota, user_quota, logging, …) are
+// gateway.Config's.
+type File struct {
+	// Version is the schema version the file is written for (default and
+	// only: 1).
+	Version int    `yaml:"version"`
+	Listen  string `yaml:"listen"`
+	// ReusePort opens the listeners with SO_REUSEPORT, so another ogw can
+	// bind the same addresses while this one drains.
+	ReusePort      bool           `yaml:"reuse_port"`
+	Drain          Drain          `yaml:"drain"`
+	Guardrails     Guardrails     `yaml:"guardrails"`
+	Admin          Admin          `yaml:"admin"`
+	Archive        archive.Config `yaml:"archive"`
+	gateway.Config `yaml:",inline"`
+}
+
+// Drain bounds shutdown on SIGTERM, SIGINT or an in-place upgrade.
+// /healthz fails at once and connections are still accepted for Delay, so
+// load balancers can take the gateway out first. Requests, streams and
+// realtime sessions in flight then get Timeout (default 30s) to finish
+// before they are cut off.
+type Drain struct {
+	Timeout time.Duration `yaml:"timeout"`
+	Delay   time.Duration `yaml:"delay"`
+}
+
+// Admin configures the admin API, which is served on its own address so it
+// can stay off the network clients use. Empty Listen disables it. Debug adds
+// the runtime debug endpoints (pprof, goroutines, config and verdicts) under
+// /debug/ on the same address.
+type Admin struct {
+	Listen  string   `yaml:"listen"`
+	Keys    []string `yaml:"keys"`
+	KeysEnv string   `yaml:"keys_env"`
+	Debug   bool     `yaml:"debug"`
+}
+
+// Guardrails configures the detection API client.
+type Guardrails struct {
+	BaseURL   string `yaml:"base_url"`
+	APIKey    string `yaml:"api_key"`
+	APIKeyEnv string `yaml:"api_key_env"`
+}
+
+// Load reads, validates and decodes the file at path. ${NAME} and
+// ${NAME:-default} in values are replaced by environment variables first,
+// and $$ stands for $. Every setting is then checked against the schema:
+// unknown keys, values of the wrong type, unknown enum values and routes to
+// undefined backends are all reported, each as path:line:column.
+func Load(path string) (*File, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(raw, &root); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	doc := &yaml.Node{Kind: yaml.MappingNode, Line: 1, Column: 1}
+	if len(root.Content) > 0 {
+		doc = root.Content[0]
+	}
+	var errs []error
+	expand(doc, "", &errs)
+	fileSchema.check(doc, "", &errs)
+	if v := lookup(doc, "version"); v != nil {
+		if n, err := strconv.Atoi(v.Value); err == nil && n != Version {
+			errs = append(errs, locate(v, "version", fmt.Sprintf("version %d is not supported; this ogw reads version %d", n, Version)))
+		}
+	}
+	checkRefs(doc, &errs)
+	if len(errs) > 0 {
+		sort.SliceStable(errs, func(i, j int) bool {
+			a, b := errs[i].(*located), errs[j].(*located)
+			return a.line < b.line || a.line == b.line && a.column < b.column
+		})
+		for i, err := range errs {
+			errs[i] = fmt.Errorf("%s:%w", path, err)
+		}
+		return nil, errors.Join(errs...)
+	}
+	var f File
+	if err := doc.Decode(&f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if f.Guardrails.APIKey == "" && f.Guardrails.APIKeyEnv != "" {
+		f.Guardrails.APIKey = os.Getenv(f.Guardrails.APIKeyEnv)
+	}
+	if f.Admin.KeysEnv != "" {
+		for _, k := range strings.Split(os.Getenv(f.Admin.KeysEnv), ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				f.Admin.Keys = append(f.Admin.Keys, k)
+			}
+		}
+	}
+	return &f, nil
+}
+
+// expand substitutes environment variables in the scalar values under n.
+func expand(n *yaml.Node, path string, errs *[]error) {
+	switch n.Kind {
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			expand(c, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			expand(n.Content[i+1], join(path, n.Content[i].Value), errs)
+		}
+	case yaml.ScalarNode:
+		if !strings.Contains(n.Value, "$") {
+			return
+		}
+		v, err := substitute(n.Value, os.LookupEnv)
+		if err != nil {
+			*errs = append(*errs, locate(n, path, err.Error()))
+			return
+		}
+		n.Value = v
+		if n.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+			// Resolve the tag again, so ${PORT} can be a number.
+			n.Tag = ""
+		}
+	}
+}
+
+// substitute replaces ${NAME} and ${NAME:-default} in s; $$ is a literal
+// $, and any other $ is kept as it is. The default applies when NAME is
+// unset or empty; without one, an unset NAME is an error.
+func substitute(s string, lookup func(string) (string, bool)) (string, error) {
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(s, '$')
+		if i < 0 || i == len(s)-1 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		b.WriteString(s[:i])
+		switch s[i+1] {
+		case '$':
+			b.WriteByte('$')
+			s = s[i+2:]
+		case '{':
+			end := strings.IndexByte(s[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unterminated ${")
+			}
+			name, def, hasDef := strings.Cut(s[i+2:i+end], ":-")
+			if !envName(name) {
+				return "", fmt.Errorf("invalid variable name %q", name)
+			}
+			v, ok := lookup(name)
+			switch {
+			case hasDef && v == "":
+				v = def
+			case !ok:
+				return "", fmt.Errorf("${%s} is not set", name)
+			}
+			b.WriteString(v)
+			s = s[i+end+1:]
+		default:
+			b.WriteByte('$')
+			s = s[i+1:]
+		}
+	}
+}
+
+func envName(s string) bool {
+	for i, c := range s {
+		if c != '_' && (c < 'A' || c > 'Z') && (c < 'a' || c > 'z') && (i == 0 || c < '0' || c > '9') {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadExample(t *testing.T) {
+	t.Setenv("OGR_API_KEY", "sk-xxai-test")
+	t.Setenv("OGW_ADMIN_KEYS", "adm-1, adm-2")
+	f, err := Load("../../ogw.example.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Version != Version || f.Listen != ":8080" || f.Drain.Timeout != 2*time.Minute || f.Drain.Delay != 5*time.Second || f.Guardrails.APIKey != "sk-xxai-test" || len(f.APIKeys) != 2 || f.Timeout != 5*time.Minute {
+		t.Fatalf("%+v", f)
+	}
+	if f.Admin.Listen != "127.0.0.1:8081" || len(f.Admin.Keys) != 2 || f.Admin.Keys[1] != "adm-2" || f.Admin.Debug {
+		t.Fatalf("admin %+v", f.Admin)
+	}
+	if f.Archive.Sink != "s3" || f.Archive.S3.Bucket != "ogw-archive" || f.Archive.Content != "masked" {
+		t.Fatalf("archive %+v", f.Archive)
+	}
+	if len(f.Backends) != 6 || f.Backends[1].Deployments["gpt-4o"] != "prod-gpt-4o" || f.Backends[3].Headers["X-Title"] != "ogw" {
+		t.Fatalf("backends %+v", f.Backends)
+	}
+	if len(f.Policies) != 1 || f.Policies[0].Categories["S9"] != "block" || f.Policies[0].Models[1] != "claude-*" || f.Policies[0].Quota.MonthlyTokens != 50000000 ||
+		f.Policies[0].Shadow.Sensitivity != "medium" || f.Policies[0].LogFields["user"] != "hash" {
+		t.Fatalf("policies %+v", f.Policies)
+	}
+	if f.Quota.DailyRequests != 5000 || f.Quota.RequestsPerMinute != 120 || f.UserQuota.DailyTokens != 200000 || f.UserQuota.MonthlyCost != 20 ||
+		len(f.Prices) != 2 || f.Prices[1].Output != 10 {
+		t.Fatalf("quotas %+v %+v", f.Quota, f.UserQuota)
+	}
+	if rl := f.RateLimit; rl == nil || rl.Redis != "redis://localhost:6379/0" {
+		t.Fatalf("rate_limit %+v", f.RateLimit)
+	}
+	if l := f.Logging; l.Format != "json" || l.Fields["prompt"] != "truncate" || l.Truncate != 64 {
+		t.Fatalf("logging %+v", l)
+	}
+	if o := f.OIDC; o == nil || o.Issuer != "https://login.example.com/realms/acme" || o.PolicyClaim != "tenant" || o.AdminValue != "ogw-admin" {
+		t.Fatalf("oidc %+v", f.OIDC)
+	}
+	if len(f.Routes) != 5 || f.Routes[4].RewriteModel != "Qwen/Qwen2.5-7B-Instruct" ||
+		len(f.Routes[0].Fallbacks) != 1 || f.Failover.AttemptTimeout != 20*time.Second || f.Cache == nil || f.Cache.TTL != 5*time.Minute {
+		t.Fatalf("routes %+v", f.Routes)
+	}
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ogw.yaml")
+	os.WriteFile(path, []byte("backends:\n  - name: x\n    api_kye: oops\n"), 0o600)
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "api_kye") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestLoadReportsLocatedErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ogw.yaml")
+	os.WriteFile(path, []byte(`version: 1
+timout: 5m
+max_body: lots
+backends:
+  - name: a
+    type: opena
+    api_key: ${OGW_TEST_UNSET}
+  - name: a
+routes:
+  - model: "*"
+    backend: b
+policies:
+  - name: p
+    keys: [x]
+    sensitivity: extreme
+    log_fields: {answer: full}
+  - keys: [y]
+logging:
+  fields: {prompt: mask}
+failover:
+  cooldown: soon
+`), 0o600)
+	_, err := Load(path)
+	if err == nil {
+		t.Fatal("no error")
+	}
+	want := []string{
+		`:2:1: unknown key "timout" (did you mean timeout?)`,
+		`:3:11: max_body: "lots" is not an integer`,
+		`:6:11: backends[0].type: "opena" is not one of openai,`,
+		`:7:14: backends[0].api_key: ${OGW_TEST_UNSET} is not set`,
+		`:8:11: backends[1].name: backend "a" is defined twice`,
+		`:11:14: routes[0].backend: unknown backend "b"`,
+		`:15:18: policies[0].sensitivity: "extreme" is not one of high, medium, low`,
+		`:16:18: policies[0].log_fields: unknown key "answer"`,
+		`:17:5: policies[1]: name is required`,
+		`:19:20: logging.fields.prompt: "mask" is not one of omit, hash, truncate, full`,
+		`:21:13: failover.cooldown: "soon" is not a duration`,
+	}
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) != len(want) {
+		t.Fatalf("got %d errors:\n%v", len(lines), err)
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(lines[i], path+w) {
+			t.Errorf("error %d = %q, want %q", i, lines[i], w)
+		}
+	}
+
+	os.WriteFile(path, []byte("version: 2\nbackends: [{name: a}]\n"), 0o600)
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "1:10: version: version 2 is not supported") {
+		t.Fatalf("version: %v", err)
+	}
+
+	// Serving ext_proc or ext_authz alone needs no backends.
+	os.WriteFile(path, []byte("listen: :8080\n"), 0o600)
+	if _, err := Load(path); err == nil || !strings.Contains(err.Error(), "1:1: backends is required (or ext_proc or ext_authz)") {
+		t.Fatalf("no backends: %v", err)
+	}
+	os.WriteFile(path, []byte("ext_proc: {listen: \":9000\"}\n"), 0o600)
+	if f, err := Load(path); err != nil || f.ExtProc.Listen != ":9000" {
+		t.Fatalf("ext_proc only: %v", err)
+	}
+	os.WriteFile(path, []byte("ext_authz: {listen: \":9001\"}\n"), 0o600)
+	if f, err := Load(path); err != nil || f.ExtAuthz.Listen != ":9001" {
+		t.Fatalf("ext_authz only: %v", err)
+	}
+}
+
+func TestLoadSubstitutesEnv(t *testing.T) {
+	t.Setenv("OGW_TEST_KEY", "sk-from-env")
+	t.Setenv("OGW_TEST_MAX", "2048")
+	path := filepath.Join(t.TempDir(), "ogw.yaml")
+	os.WriteFile(path, []byte(`max_body: ${OGW_TEST_MAX}
+timeout: ${OGW_TEST_UNSET:-90s}
+backends:
+  - name: a
+    api_key: ${OGW_TEST_KEY}
+    headers: {X-Cost: "$$5 ${OGW_TEST_UNSET:-}"}
+`), 0o600)
+	f, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.MaxBody != 2048 || f.Timeout != 90*time.Second || f.Backends[0].APIKey != "sk-from-env" || f.Backends[0].Headers["X-Cost"] != "$5 " {
+		t.Fatalf("%+v %+v", f.Config, f.Backends[0])
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	env := map[string]string{"A": "1", "EMPTY": ""}
+	lookup := func(k string) (string, bool) { v, ok := env[k]; return v, ok }
+	for in, want := range map[string]string{
+		"plain":          "plain",
+		"${A}-${A}":      "1-1",
+		"${EMPTY}":       "",
+		"${EMPTY:-d}":    "d",
+		"${B:-x:-y}":     "x:-y",
+		"pa$word and $$": "pa$word and $",
+		"trailing $":     "trailing $",
+		"$${A} stays":    "${A} stays",
+	} {
+		if got, err := substitute(in, lookup); err != nil || got != want {
+			t.Errorf("substitute(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"${B}", "${A", "${1A}", "${}"} {
+		if _, err := substitute(in, lookup); err == nil {
+			t.Errorf("substitute(%q): no error", in)
+		}
+	}
+}
+
+func TestSchemaFile(t *testing.T) {
+	want, err := JSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("../../ogw.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(got)) != string(want) {
+		t.Fatal("ogw.schema.json is out of date; regenerate it with: go run ./cmd/ogw -schema > ogw.schema.json")
+	}
+}
@@ -0,0 +1,395 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/archive"
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/gateway"
+)
+
+// Version is the schema version of the configuration files this ogw reads.
+// Files without a version are read as this one.
+const Version = 1
+
+// schema describes a node of the configuration. It is derived from File's
+// yaml tags, so it cannot drift from what Load decodes, and narrowed by
+// rules.
+type schema struct {
+	Type   string // object, array, string, integer, number or boolean
+	Format string // duration, for time.Duration strings
+	// Fields are an object's keys, in declaration order.
+	Fields []field
+	// Items are an array's elements; Values a map's, whose keys are
+	// limited to Keys when set.
+	Items  *schema
+	Values *schema
+	Keys   []string
+	Enum   []string
+}
+
+type field struct {
+	Name     string
+	Schema   *schema
+	Required bool
+}
+
+// rule narrows the schema at a path: keys are dotted, with [] for array
+// elements and * for map values.
+type rule struct {
+	required bool
+	enum     []string
+	keys     []string
+}
+
+var (
+	sensitivities = []string{gateway.SensitivityHigh, gateway.SensitivityMedium, gateway.SensitivityLow}
+	verdicts      = []string{gateway.CategoryBlock, gateway.CategoryAllow}
+	logFields     = []string{gateway.LogFieldPrompt, gateway.LogFieldResponse, gateway.LogFieldUser}
+	logModes      = []string{gateway.LogOmit, gateway.LogHash, gateway.LogTruncate, gateway.LogFull}
+)
+
+var rules = map[string]rule{
+	"backends[].name":                {required: true},
+	"backends[].type":                {enum: []string{gateway.BackendOpenAI, gateway.BackendAzure, gateway.BackendAnthropic, gateway.BackendOpenRouter, gateway.BackendOllama, gateway.BackendVLLM}},
+	"routes[].model":                 {required: true},
+	"routes[].backend":               {required: true},
+	"routes[].fallbacks[].backend":   {required: true},
+	"oidc.issuer":                    {required: true},
+	"ext_proc.listen":                {required: true},
+	"ext_authz.listen":               {required: true},
+	"rate_limit.redis":               {required: true},
+	"policies[].name":                {required: true},
+	"policies[].sensitivity":         {enum: sensitivities},
+	"policies[].categories.*":        {enum: verdicts},
+	"policies[].shadow.sensitivity":  {enum: sensitivities},
+	"policies[].shadow.categories.*": {enum: verdicts},
+	"policies[].log_fields":          {keys: logFields},
+	"policies[].log_fields.*":        {enum: logModes},
+	"sensitivity":                    {enum: sensitivities},
+	"categories.*":                   {enum: verdicts},
+	"shadow.sensitivity":             {enum: sensitivities},
+	"shadow.categories.*":            {enum: verdicts},
+	"prices[].model":                 {required: true},
+	"logging.level":                  {enum: []string{"debug", "info", "warn", "error"}},
+	"logging.format":                 {enum: []string{"text", "json"}},
+	"logging.fields":                 {keys: logFields},
+	"logging.fields.*":               {enum: logModes},
+	"archive.sink":                   {enum: []string{archive.SinkS3, archive.SinkDir}},
+	"archive.content":                {enum: []string{archive.ContentFull, archive.ContentMasked, archive.ContentOmit}},
+}
+
+var fileSchema = schemaOf(reflect.TypeOf(File{}), "")
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func schemaOf(t reflect.Type, path string) *schema {
+	var s *schema
+	switch {
+	case t == durationType:
+		s = &schema{Type: "string", Format: "duration"}
+	case t.Kind() == reflect.Pointer:
+		return schemaOf(t.Elem(), path)
+	case t.Kind() == reflect.Struct:
+		s = &schema{Type: "object"}
+		s.addFields(t, path)
+	case t.Kind() == reflect.Slice:
+		s = &schema{Type: "array", Items: schemaOf(t.Elem(), path+"[]")}
+	case t.Kind() == reflect.Map:
+		s = &schema{Type: "object", Values: schemaOf(t.Elem(), path+".*")}
+	case t.Kind() == reflect.String:
+		s = &schema{Type: "string"}
+	case t.Kind() == reflect.Bool:
+		s = &schema{Type: "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		s = &schema{Type: "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		s = &schema{Type: "number"}
+	default:
+		panic(fmt.Sprintf("config: no schema for %s at %s", t, path))
+	}
+	s.Enum, s.Keys = rules[path].enum, rules[path].keys
+	return s
+}
+
+func (s *schema) addFields(t reflect.Type, path string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if opts == "inline" {
+			s.addFields(f.Type, path)
+			continue
+		}
+		p := name
+		if path != "" {
+			p = path + "." + name
+		}
+		s.Fields = append(s.Fields, field{Name: name, Schema: schemaOf(f.Type, p), Required: rules[p].required})
+	}
+}
+
+// check validates n against s and appends what is wrong to errs.
+func (s *schema) check(n *yaml.Node, path string, errs *[]error) {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n.Kind == yaml.ScalarNode && n.ShortTag() == "!!null" {
+		return
+	}
+	fail := func(n *yaml.Node, format string, args ...any) {
+		*errs = append(*errs, locate(n, path, fmt.Sprintf(format, args...)))
+	}
+	switch s.Type {
+	case "object":
+		if n.Kind != yaml.MappingNode {
+			fail(n, "want a mapping")
+			return
+		}
+		seen := map[string]bool{}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			seen[k.Value] = true
+			if s.Values != nil {
+				if s.Keys != nil && !contains(s.Keys, k.Value) {
+					fail(k, "unknown key %q (want one of %s)", k.Value, strings.Join(s.Keys, ", "))
+					continue
+				}
+				s.Values.check(v, join(path, k.Value), errs)
+				continue
+			}
+			f, ok := s.field(k.Value)
+			if !ok {
+				msg := fmt.Sprintf("unknown key %q", k.Value)
+				if near := s.nearest(k.Value); near != "" {
+					msg += fmt.Sprintf(" (did you mean %s?)", near)
+				}
+				*errs = append(*errs, locate(k, path, msg))
+				continue
+			}
+			f.Schema.check(v, join(path, k.Value), errs)
+		}
+		for _, f := range s.Fields {
+			if f.Required && !seen[f.Name] {
+				fail(n, "%s is required", f.Name)
+			}
+		}
+	case "array":
+		if n.Kind != yaml.SequenceNode {
+			fail(n, "want a list")
+			return
+		}
+		for i, item := range n.Content {
+			s.Items.check(item, fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	default:
+		if n.Kind != yaml.ScalarNode {
+			fail(n, "want %s", scalarNames[s.Type])
+			return
+		}
+		if msg := s.checkScalar(n); msg != "" {
+			fail(n, "%s", msg)
+		}
+	}
+}
+
+var scalarNames = map[string]string{"string": "a string", "integer": "an integer", "number": "a number", "boolean": "true or false"}
+
+func (s *schema) checkScalar(n *yaml.Node) string {
+	tag := n.ShortTag()
+	switch {
+	case s.Format == "duration":
+		if _, err := time.ParseDuration(n.Value); err != nil && tag != "!!int" {
+			return fmt.Sprintf("%q is not a duration (like 30s, 5m or 1h30m)", n.Value)
+		}
+	case s.Type == "integer" && tag != "!!int",
+		s.Type == "number" && tag != "!!int" && tag != "!!float",
+		s.Type == "boolean" && tag != "!!bool":
+		return fmt.Sprintf("%q is not %s", n.Value, scalarNames[s.Type])
+	}
+	if s.Enum != nil && !contains(s.Enum, n.Value) {
+		return fmt.Sprintf("%q is not one of %s", n.Value, strings.Join(s.Enum, ", "))
+	}
+	return ""
+}
+
+func (s *schema) field(name string) (field, bool) {
+	for _, f := range s.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+// nearest is the known key a misspelt one most likely meant, if any is
+// close enough.
+func (s *schema) nearest(name string) string {
+	best, dist := "", 3
+	for _, f := range s.Fields {
+		if d := distance(name, f.Name); d < dist {
+			best, dist = f.Name, d
+		}
+	}
+	return best
+}
+
+// distance is the Levenshtein distance between a and b.
+func distance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// checkRefs checks what the schema cannot: that there are backends unless
+// ext_proc or ext_authz is served, that routes name configured backends and that backend
+// and policy names are unique.
+func checkRefs(root *yaml.Node, errs *[]error) {
+	if root != nil && root.Kind == yaml.MappingNode && lookup(root, "backends") == nil && lookup(root, "ext_proc") == nil && lookup(root, "ext_authz") == nil {
+		*errs = append(*errs, locate(root, "", "backends is required (or ext_proc or ext_authz)"))
+	}
+	backends := map[string]bool{}
+	unique := func(list, what string, into map[string]bool) {
+		for i, item := range items(lookup(root, list)) {
+			if name := lookup(item, "name"); name != nil && name.Value != "" {
+				if into[name.Value] {
+					*errs = append(*errs, locate(name, fmt.Sprintf("%s[%d].name", list, i), fmt.Sprintf("%s %q is defined twice", what, name.Value)))
+				}
+				into[name.Value] = true
+			}
+		}
+	}
+	unique("backends", "backend", backends)
+	unique("policies", "policy", map[string]bool{})
+	ref := func(n *yaml.Node, path string) {
+		if n != nil && n.Kind == yaml.ScalarNode && !backends[n.Value] {
+			*errs = append(*errs, locate(n, path, fmt.Sprintf("unknown backend %q", n.Value)))
+		}
+	}
+	for i, rt := range items(lookup(root, "routes")) {
+		ref(lookup(rt, "backend"), fmt.Sprintf("routes[%d].backend", i))
+		for j, fb := range items(lookup(rt, "fallbacks")) {
+			ref(lookup(fb, "backend"), fmt.Sprintf("routes[%d].fallbacks[%d].backend", i, j))
+		}
+	}
+}
+
+// lookup returns the value of key in mapping n, or nil.
+func lookup(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func items(n *yaml.Node) []*yaml.Node {
+	if n == nil || n.Kind != yaml.SequenceNode {
+		return nil
+	}
+	return n.Content
+}
+
+// located is a problem at a line and column of the file.
+type located struct {
+	line, column int
+	msg          string
+}
+
+func (e *located) Error() string { return fmt.Sprintf("%d:%d: %s", e.line, e.column, e.msg) }
+
+// locate places msg about the setting at path at n.
+func locate(n *yaml.Node, path, msg string) error {
+	if path != "" {
+		msg = path + ": " + msg
+	}
+	return &located{n.Line, n.Column, msg}
+}
+
+func join(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// JSONSchema returns the configuration schema as a JSON Schema document,
+// for editors to validate and complete ogw.yaml with.
+func JSONSchema() ([]byte, error) {
+	doc := fileSchema.json()
+	doc["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	doc["title"] = fmt.Sprintf("ogw configuration, version %d", Version)
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func (s *schema) json() map[string]any {
+	out := map[string]any{"type": s.Type}
+	if s.Format == "duration" {
+		// Durations are strings like 30s, 5m or 1h30m.
+		out["type"] = []string{"string", "integer"}
+		out["pattern"] = `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`
+	}
+	if s.Enum != nil {
+		out["enum"] = s.Enum
+	}
+	switch {
+	case s.Items != nil:
+		out["items"] = s.Items.json()
+	case s.Values != nil:
+		out["additionalProperties"] = s.Values.json()
+		if s.Keys != nil {
+			out["propertyNames"] = map[string]any{"enum": s.Keys}
+		}
+	case s.Type == "object":
+		props := map[string]any{}
+		var required []string
+		for _, f := range s.Fields {
+			props[f.Name] = f.Schema.json()
+			if f.Required {
+				required = append(required, f.Name)
+			}
+		}
+		out["properties"] = props
+		out["additionalProperties"] = false
+		if required != nil {
+			sort.Strings(required)
+			out["required"] = required
+		}
+	}
+	return out
+}
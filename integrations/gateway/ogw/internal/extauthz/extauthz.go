@@ -0,0 +1,165 @@
+// Package extauthz is the server side of Envoy's external authorization
+// service, in both of its flavours: the gRPC one
+// (envoy.service.auth.v3.Authorization), without gRPC or protobuf
+// libraries, and the HTTP one, where Envoy forwards a copy of the request
+// and reads the decision from the status.
+//
+// Envoy asks once per HTTP request, before it goes upstream, and acts on
+// the answer: the request goes on with headers set or removed, or the
+// client gets the denial instead.
+//
+//	http.Server{Handler: extauthz.Handler(func(ctx context.Context, req *extauthz.Request) *extauthz.Response {
+//		if req.Header("authorization") == "" {
+//			return &extauthz.Response{Denied: &extauthz.Denied{Status: http.StatusUnauthorized}}
+//		}
+//		return &extauthz.Response{}
+//	})}
+//
+// The server must accept unencrypted HTTP/2 (http.Protocols) for gRPC
+// unless Envoy connects with TLS.
+package extauthz
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Method is the path of the Check call.
+const Method = "/envoy.service.auth.v3.Authorization/Check"
+
+// MaxMessage bounds a check request, which carries the request body Envoy
+// buffered (with_request_body.max_request_bytes).
+const MaxMessage = 64 << 20
+
+// gRPC status codes.
+const (
+	codeOK              = 0
+	codeInvalidArgument = 3
+	codePermission      = 7
+	codeResource        = 8 // RESOURCE_EXHAUSTED
+	codeUnimplemented   = 12
+)
+
+// HeadersToRemove is the header an HTTP authorization service lists the
+// headers to remove from the request in.
+const HeadersToRemove = "x-envoy-auth-headers-to-remove"
+
+// Handler serves check requests, calling check once per request: gRPC
+// calls to Method, and everything else as the HTTP service's copy of the
+// request to authorize.
+func Handler(check func(context.Context, *Request) *Response) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			serveGRPC(w, r, check)
+			return
+		}
+		serveHTTP(w, r, check)
+	})
+}
+
+func serveGRPC(w http.ResponseWriter, r *http.Request, check func(context.Context, *Request) *Response) {
+	w.Header().Set("Content-Type", "application/grpc")
+	fail := func(code int, msg string) {
+		// A trailers-only response.
+		w.Header().Set("Grpc-Status", strconv.Itoa(code))
+		w.Header().Set("Grpc-Message", url.PathEscape(msg))
+		w.WriteHeader(http.StatusOK)
+	}
+	if r.ProtoMajor != 2 || r.Method != http.MethodPost || r.URL.Path != Method {
+		fail(codeUnimplemented, "unknown method "+r.URL.Path)
+		return
+	}
+	msg, err := readMessage(r.Body)
+	if err != nil {
+		code := codeInvalidArgument
+		if errors.Is(err, errTooLarge) {
+			code = codeResource
+		}
+		fail(code, err.Error())
+		return
+	}
+	req := new(Request)
+	if err := req.UnmarshalBinary(msg); err != nil {
+		fail(codeInvalidArgument, err.Error())
+		return
+	}
+	out, _ := check(r.Context(), req).MarshalBinary()
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+	writeMessage(w, out)
+	w.Header().Set("Grpc-Status", strconv.Itoa(codeOK))
+}
+
+func serveHTTP(w http.ResponseWriter, r *http.Request, check func(context.Context, *Request) *Response) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, MaxMessage+1))
+	if err != nil {
+		http.Error(w, "unreadable request body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > MaxMessage {
+		http.Error(w, errTooLarge.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	req := &Request{Method: r.Method, Path: r.URL.RequestURI(), Host: r.Host, Body: body}
+	for k, vs := range r.Header {
+		for _, v := range vs {
+			req.Headers = append(req.Headers, Header{Key: strings.ToLower(k), Value: v})
+		}
+	}
+	resp := check(r.Context(), req)
+	if d := resp.Denied; d != nil {
+		for _, h := range d.Headers {
+			w.Header().Add(h.Key, h.Value)
+		}
+		w.WriteHeader(d.Status)
+		w.Write(d.Body)
+		return
+	}
+	// Envoy copies the headers its allowed_upstream_headers list names
+	// onto the request.
+	for _, h := range resp.SetHeaders {
+		w.Header().Set(h.Key, h.Value)
+	}
+	if len(resp.RemoveHeaders) > 0 {
+		w.Header().Set(HeadersToRemove, strings.Join(resp.RemoveHeaders, ","))
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+var errTooLarge = fmt.Errorf("extauthz: message larger than %d bytes", MaxMessage)
+
+// readMessage reads a length-prefixed gRPC message.
+func readMessage(r io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, fmt.Errorf("extauthz: truncated message")
+	}
+	if prefix[0] != 0 {
+		return nil, fmt.Errorf("extauthz: compressed messages are not supported")
+	}
+	n := binary.BigEndian.Uint32(prefix[1:])
+	if n > MaxMessage {
+		return nil, errTooLarge
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, fmt.Errorf("extauthz: truncated message")
+	}
+	return msg, nil
+}
+
+// writeMessage writes msg with its gRPC length prefix.
+func writeMessage(w io.Writer, msg []byte) error {
+	buf := make([]byte, 5+len(msg))
+	binary.BigEndian.PutUint32(buf[1:], uint32(len(msg)))
+	copy(buf[5:], msg)
+	_, err := w.Write(buf)
+	return err
+}
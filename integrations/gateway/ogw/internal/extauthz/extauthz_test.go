@@ -0,0 +1,100 @@
+package extauthz_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/extauthz"
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/extauthz/extauthztest"
+)
+
+func TestMessagesRoundTrip(t *testing.T) {
+	req := extauthz.Request{
+		Method: "POST", Path: "/v1/chat/completions?x=1", Host: "api.example.com",
+		Headers: []extauthz.Header{{":method", "POST"}, {"authorization", "Bearer k1"}},
+		Body:    []byte(`{"model": "gpt-4o"}`),
+	}
+	b, _ := req.MarshalBinary()
+	var got extauthz.Request
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, req) {
+		t.Errorf("got %+v, want %+v", got, req)
+	}
+	for _, resp := range []extauthz.Response{
+		{},
+		{SetHeaders: []extauthz.Header{{"x-ogw-risk-level", "no_risk"}}, RemoveHeaders: []string{"authorization"}},
+		{Denied: &extauthz.Denied{Status: 403, Headers: []extauthz.Header{{"content-type", "application/json"}}, Body: []byte("{}")}},
+	} {
+		b, _ := resp.MarshalBinary()
+		var got extauthz.Response
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, resp) {
+			t.Errorf("got %+v, want %+v", got, resp)
+		}
+	}
+	if err := got.UnmarshalBinary([]byte{0x0a, 0x05, 0x22}); err == nil {
+		t.Error("truncated message decoded")
+	}
+}
+
+// deny refuses requests without a key, echoing what it was asked.
+func deny(_ context.Context, req *extauthz.Request) *extauthz.Response {
+	if req.Header("authorization") == "" {
+		return &extauthz.Response{Denied: &extauthz.Denied{
+			Status: http.StatusUnauthorized, Headers: []extauthz.Header{{"x-path", req.Path}}, Body: []byte("no key"),
+		}}
+	}
+	return &extauthz.Response{SetHeaders: []extauthz.Header{{"x-body", string(req.Body)}}, RemoveHeaders: []string{"authorization", "cookie"}}
+}
+
+func TestGRPC(t *testing.T) {
+	srv := httptest.NewUnstartedServer(extauthz.Handler(deny))
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	defer srv.Close()
+
+	resp := extauthztest.Check(t, srv.URL, &extauthz.Request{Method: "POST", Path: "/v1/chat/completions"})
+	if resp.Denied == nil || resp.Denied.Status != 401 || string(resp.Denied.Body) != "no key" ||
+		!reflect.DeepEqual(resp.Denied.Headers, []extauthz.Header{{"x-path", "/v1/chat/completions"}}) {
+		t.Fatalf("denied: %+v", resp)
+	}
+	resp = extauthztest.Check(t, srv.URL, &extauthz.Request{Headers: []extauthz.Header{{"authorization", "k"}}, Body: []byte("hi")})
+	if resp.Denied != nil || resp.SetHeaders[0].Value != "hi" || len(resp.RemoveHeaders) != 2 {
+		t.Fatalf("allowed: %+v", resp)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	srv := httptest.NewServer(extauthz.Handler(deny))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/v1/chat/completions?x=1", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 401 || resp.Header.Get("X-Path") != "/v1/chat/completions?x=1" {
+		t.Fatalf("denied: %d %v", resp.StatusCode, resp.Header)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/v1/chat/completions", bytes.NewReader([]byte(`{"a":1}`)))
+	req.Header.Set("Authorization", "Bearer k")
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 || resp.Header.Get("X-Body") != `{"a":1}` || resp.Header.Get(extauthz.HeadersToRemove) != "authorization,cookie" {
+		t.Fatalf("allowed: %d %v", resp.StatusCode, resp.Header)
+	}
+}
@@ -0,0 +1,54 @@
+// Package extauthztest plays Envoy against an ext_authz server in tests:
+//
+//	resp := extauthztest.Check(t, srv.URL, &extauthz.Request{Method: "POST", Path: "/v1/chat/completions", …})
+//
+// Check makes the gRPC call, over unencrypted HTTP/2. The HTTP flavour
+// needs no helper: Envoy sends the request itself.
+package extauthztest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/extauthz"
+)
+
+// Check calls the server at baseURL (http://host:port) with req and
+// returns its answer. The call must end with grpc-status 0.
+func Check(t testing.TB, baseURL string, req *extauthz.Request) *extauthz.Response {
+	t.Helper()
+	msg, err := req.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+	body := binary.BigEndian.AppendUint32([]byte{0}, uint32(len(msg)))
+	hr, err := http.NewRequest(http.MethodPost, baseURL+extauthz.Method, bytes.NewReader(append(body, msg...)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hr.Header.Set("Content-Type", "application/grpc")
+	hr.Header.Set("Te", "trailers")
+	hresp, err := client.Do(hr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hresp.Body.Close()
+	out, err := io.ReadAll(hresp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := hresp.Trailer.Get("Grpc-Status") + hresp.Header.Get("Grpc-Status"); code != "0" || len(out) < 5 {
+		t.Fatalf("extauthztest: grpc-status %q %s, %d bytes", code, hresp.Trailer.Get("Grpc-Message")+hresp.Header.Get("Grpc-Message"), len(out))
+	}
+	resp := new(extauthz.Response)
+	if err := resp.UnmarshalBinary(out[5:]); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
@@ -0,0 +1,335 @@
+package extauthz
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+// Header is a header as Envoy passes it: lowercase, with the HTTP/2
+// pseudo-headers among them over gRPC.
+type Header struct {
+	Key, Value string
+}
+
+// Request is a CheckRequest, reduced to the HTTP request it asks about.
+// Other attributes (peers, context extensions, metadata) are skipped.
+type Request struct {
+	Method string
+	// Path is the request target: the path and query.
+	Path    string
+	Host    string
+	Headers []Header
+	// Body is what Envoy buffered of the body (with_request_body); empty
+	// without it.
+	Body []byte
+}
+
+// Header returns the value of the header named key (lowercase), or "".
+func (r *Request) Header(key string) string {
+	for _, h := range r.Headers {
+		if h.Key == key {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// Response is a CheckResponse. Without Denied the request is allowed, and
+// goes upstream with SetHeaders set, replacing values of the same name,
+// and RemoveHeaders removed.
+type Response struct {
+	SetHeaders    []Header
+	RemoveHeaders []string
+	Denied        *Denied
+}
+
+// Denied is the reply the client gets instead of the backend's.
+type Denied struct {
+	Status  int
+	Headers []Header
+	Body    []byte
+}
+
+// CheckRequest: attributes 1. AttributeContext: request 4.
+// AttributeContext.Request: http 2. AttributeContext.HttpRequest fields:
+const (
+	httpMethod    = 2
+	httpHeaders   = 3 // map<string, string>
+	httpPath      = 4
+	httpHost      = 5
+	httpBody      = 11
+	httpRawBody   = 12 // with pack_as_bytes
+	httpHeaderMap = 13 // HeaderMap, with encode_raw_headers
+)
+
+// CheckResponse fields, and HeaderValueOption's OVERWRITE_IF_EXISTS_OR_ADD
+// append action.
+const (
+	respStatus     = 1
+	respDenied     = 2
+	respOK         = 3
+	overwriteOrAdd = 2
+)
+
+// UnmarshalBinary decodes a CheckRequest.
+func (r *Request) UnmarshalBinary(b []byte) error {
+	*r = Request{}
+	return within(b, []int{1, 4, 2}, func(p []byte) error {
+		return fields(p, func(num int, v uint64, p []byte) error {
+			switch num {
+			case httpMethod:
+				r.Method = string(p)
+			case httpHeaders:
+				// A map entry: key 1, value 2, as a HeaderValue lays out.
+				return decodeHeader(p, &r.Headers)
+			case httpPath:
+				r.Path = string(p)
+			case httpHost:
+				r.Host = string(p)
+			case httpBody, httpRawBody:
+				r.Body = append([]byte(nil), p...)
+			case httpHeaderMap:
+				return fields(p, func(num int, v uint64, p []byte) error {
+					if num == 1 {
+						return decodeHeader(p, &r.Headers)
+					}
+					return nil
+				})
+			}
+			return nil
+		})
+	})
+}
+
+// within calls fn with the payload of the message nested along path.
+func within(b []byte, path []int, fn func([]byte) error) error {
+	if len(path) == 0 {
+		return fn(b)
+	}
+	return fields(b, func(num int, v uint64, p []byte) error {
+		if num == path[0] {
+			return within(p, path[1:], fn)
+		}
+		return nil
+	})
+}
+
+// MarshalBinary encodes a CheckRequest, as Envoy would.
+func (r *Request) MarshalBinary() ([]byte, error) {
+	var e encoder
+	e.message(1, func(e *encoder) {
+		e.message(4, func(e *encoder) {
+			e.message(2, func(e *encoder) {
+				e.string(httpMethod, r.Method)
+				for _, h := range r.Headers {
+					e.message(httpHeaders, func(e *encoder) {
+						e.string(1, h.Key)
+						e.string(2, h.Value)
+					})
+				}
+				e.string(httpPath, r.Path)
+				e.string(httpHost, r.Host)
+				if len(r.Body) > 0 {
+					e.bytes(httpBody, r.Body)
+				}
+			})
+		})
+	})
+	return e.b, nil
+}
+
+// MarshalBinary encodes a CheckResponse.
+func (r *Response) MarshalBinary() ([]byte, error) {
+	var e encoder
+	if d := r.Denied; d != nil {
+		e.message(respStatus, func(e *encoder) { e.varint(1, codePermission) }) // google.rpc.Status
+		e.message(respDenied, func(e *encoder) {
+			e.message(1, func(e *encoder) { e.varint(1, uint64(d.Status)) }) // HttpStatus
+			encodeOptions(e, 2, d.Headers)
+			if len(d.Body) > 0 {
+				e.bytes(3, d.Body)
+			}
+		})
+		return e.b, nil
+	}
+	e.message(respStatus, func(*encoder) {})
+	e.message(respOK, func(e *encoder) {
+		// OkHttpResponse: headers 2, headers_to_remove 5.
+		encodeOptions(e, 2, r.SetHeaders)
+		for _, k := range r.RemoveHeaders {
+			e.string(5, k)
+		}
+	})
+	return e.b, nil
+}
+
+// UnmarshalBinary decodes a CheckResponse, as Envoy would.
+func (r *Response) UnmarshalBinary(b []byte) error {
+	*r = Response{}
+	return fields(b, func(num int, v uint64, p []byte) error {
+		switch num {
+		case respDenied:
+			d := &Denied{}
+			r.Denied = d
+			return fields(p, func(num int, v uint64, p []byte) error {
+				switch num {
+				case 1:
+					return fields(p, func(num int, v uint64, p []byte) error {
+						if num == 1 {
+							d.Status = int(v)
+						}
+						return nil
+					})
+				case 2:
+					return decodeOption(p, &d.Headers)
+				case 3:
+					d.Body = append([]byte(nil), p...)
+				}
+				return nil
+			})
+		case respOK:
+			return fields(p, func(num int, v uint64, p []byte) error {
+				switch num {
+				case 2:
+					return decodeOption(p, &r.SetHeaders)
+				case 5:
+					r.RemoveHeaders = append(r.RemoveHeaders, string(p))
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+}
+
+// encodeOptions writes headers as HeaderValueOptions (header 1,
+// append_action 3) in field num.
+func encodeOptions(e *encoder, num int, headers []Header) {
+	for _, h := range headers {
+		e.message(num, func(e *encoder) {
+			e.message(1, func(e *encoder) {
+				e.string(1, h.Key)
+				e.string(2, h.Value)
+			})
+			e.varint(3, overwriteOrAdd)
+		})
+	}
+}
+
+// decodeOption appends the header of a HeaderValueOption (header 1).
+func decodeOption(b []byte, into *[]Header) error {
+	return fields(b, func(num int, v uint64, p []byte) error {
+		if num == 1 {
+			return decodeHeader(p, into)
+		}
+		return nil
+	})
+}
+
+// decodeHeader appends a HeaderValue: key 1, value 2, raw_value 3, which
+// Envoy sends instead of value with encode_raw_headers.
+func decodeHeader(b []byte, into *[]Header) error {
+	var h Header
+	err := fields(b, func(num int, v uint64, p []byte) error {
+		switch num {
+		case 1:
+			h.Key = strings.ToLower(string(p))
+		case 2, 3:
+			h.Value = string(p)
+		}
+		return nil
+	})
+	*into = append(*into, h)
+	return err
+}
+
+// Wire types.
+const (
+	wireVarint = 0
+	wireI64    = 1
+	wireLen    = 2
+	wireI32    = 5
+)
+
+var errMalformed = errors.New("extauthz: malformed message")
+
+// fields calls fn for each field of the message b: v carries varints, p
+// length-delimited payloads. Fixed-width fields are skipped.
+func fields(b []byte, fn func(num int, v uint64, p []byte) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errMalformed
+		}
+		b = b[n:]
+		num := int(key >> 3)
+		switch key & 7 {
+		case wireVarint:
+			v, n := binary.Uvarint(b)
+			if n <= 0 {
+				return errMalformed
+			}
+			b = b[n:]
+			if err := fn(num, v, nil); err != nil {
+				return err
+			}
+		case wireLen:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || l > uint64(len(b)-n) {
+				return errMalformed
+			}
+			p := b[n : n+int(l)]
+			b = b[n+int(l):]
+			if err := fn(num, 0, p); err != nil {
+				return err
+			}
+		case wireI64:
+			if len(b) < 8 {
+				return errMalformed
+			}
+			b = b[8:]
+		case wireI32:
+			if len(b) < 4 {
+				return errMalformed
+			}
+			b = b[4:]
+		default:
+			return errMalformed
+		}
+	}
+	return nil
+}
+
+// encoder appends protobuf fields. Scalars at their zero value are left
+// out, as proto3 does; bytes and messages are always written.
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) key(num, wire int) { e.b = binary.AppendUvarint(e.b, uint64(num)<<3|uint64(wire)) }
+
+func (e *encoder) varint(num int, v uint64) {
+	if v != 0 {
+		e.key(num, wireVarint)
+		e.b = binary.AppendUvarint(e.b, v)
+	}
+}
+
+func (e *encoder) bytes(num int, p []byte) {
+	e.key(num, wireLen)
+	e.b = binary.AppendUvarint(e.b, uint64(len(p)))
+	e.b = append(e.b, p...)
+}
+
+func (e *encoder) string(num int, s string) {
+	if s != "" {
+		e.bytes(num, []byte(s))
+	}
+}
+
+func (e *encoder) message(num int, fn func(*encoder)) {
+	var sub encoder
+	fn(&sub)
+	e.bytes(num, sub.b)
+}
@@ -0,0 +1,162 @@
+// Package extproc is the server side of Envoy's external processing
+// service (envoy.service.ext_proc.v3.ExternalProcessor), without gRPC or
+// protobuf libraries: the messages the gateway needs are encoded by hand,
+// and the Process stream is served over HTTP/2 by net/http.
+//
+// Envoy opens one Process stream per HTTP request it proxies and sends a
+// message per processing phase (request headers, request body, response
+// headers, …) as the processing mode asks; each must be answered in order.
+//
+//	http.Server{Handler: extproc.Handler(func(s *extproc.Stream) error {
+//		for {
+//			req, err := s.Recv()
+//			if err != nil {
+//				return err // io.EOF when Envoy is done
+//			}
+//			if err := s.Send(&extproc.Response{Phase: req.Phase}); err != nil {
+//				return err
+//			}
+//		}
+//	})}
+//
+// The server must accept unencrypted HTTP/2 (http.Protocols) unless Envoy
+// connects with TLS.
+package extproc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Method is the path of the Process call.
+const Method = "/envoy.service.ext_proc.v3.ExternalProcessor/Process"
+
+// MaxMessage bounds a message Envoy sends; a buffered body is one message.
+const MaxMessage = 64 << 20
+
+// gRPC status codes.
+const (
+	codeOK            = 0
+	codeCanceled      = 1
+	codeResource      = 8 // RESOURCE_EXHAUSTED
+	codeUnimplemented = 12
+	codeInternal      = 13
+)
+
+// Handler serves the Process call, calling process once per stream. The
+// stream ends with process: a nil or io.EOF error as OK, others as
+// INTERNAL with the error's text (RESOURCE_EXHAUSTED for a message over
+// MaxMessage).
+func Handler(process func(*Stream) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			http.Error(w, "gRPC over HTTP/2 only", http.StatusUnsupportedMediaType)
+			return
+		}
+		w.Header().Set("Content-Type", "application/grpc")
+		if r.Method != http.MethodPost || r.URL.Path != Method {
+			// A trailers-only response.
+			w.Header().Set("Grpc-Status", strconv.Itoa(codeUnimplemented))
+			w.Header().Set("Grpc-Message", url.PathEscape("unknown method "+r.URL.Path))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		w.WriteHeader(http.StatusOK)
+		rc := http.NewResponseController(w)
+		rc.Flush()
+		s := &Stream{ctx: r.Context(), body: r.Body, w: w, rc: rc}
+		err := process(s)
+		code, msg := codeOK, ""
+		switch {
+		case err == nil, errors.Is(err, io.EOF):
+		case errors.Is(err, context.Canceled):
+			code, msg = codeCanceled, err.Error()
+		case errors.Is(err, errTooLarge):
+			code, msg = codeResource, err.Error()
+		default:
+			code, msg = codeInternal, err.Error()
+		}
+		w.Header().Set("Grpc-Status", strconv.Itoa(code))
+		if msg != "" {
+			w.Header().Set("Grpc-Message", url.PathEscape(msg))
+		}
+	})
+}
+
+var errTooLarge = fmt.Errorf("extproc: message larger than %d bytes", MaxMessage)
+
+// Stream is one Process call.
+type Stream struct {
+	ctx  context.Context
+	body io.Reader
+	w    io.Writer
+	rc   *http.ResponseController
+}
+
+// Context is done when Envoy cancels the call.
+func (s *Stream) Context() context.Context { return s.ctx }
+
+// Recv reads the next request; io.EOF means Envoy has closed its side.
+func (s *Stream) Recv() (*Request, error) {
+	msg, err := readMessage(s.body)
+	if err != nil {
+		return nil, err
+	}
+	req := new(Request)
+	if err := req.UnmarshalBinary(msg); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// Send writes a response and flushes it to Envoy.
+func (s *Stream) Send(resp *Response) error {
+	msg, err := resp.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if err := writeMessage(s.w, msg); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// readMessage reads a length-prefixed gRPC message.
+func readMessage(r io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, fmt.Errorf("extproc: truncated message")
+		}
+		return nil, err
+	}
+	if prefix[0] != 0 {
+		return nil, fmt.Errorf("extproc: compressed messages are not supported")
+	}
+	n := binary.BigEndian.Uint32(prefix[1:])
+	if n > MaxMessage {
+		return nil, errTooLarge
+	}
+	msg := make([]byte, n)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, fmt.Errorf("extproc: truncated message")
+	}
+	return msg, nil
+}
+
+// writeMessage writes msg with its gRPC length prefix.
+func writeMessage(w io.Writer, msg []byte) error {
+	buf := make([]byte, 5+len(msg))
+	binary.BigEndian.PutUint32(buf[1:], uint32(len(msg)))
+	copy(buf[5:], msg)
+	_, err := w.Write(buf)
+	return err
+}
@@ -0,0 +1,118 @@
+package extproc_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/extproc"
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/extproc/extproctest"
+)
+
+func TestMessagesRoundTrip(t *testing.T) {
+	for _, req := range []extproc.Request{
+		{Phase: extproc.RequestHeaders, Headers: []extproc.Header{{":method", "POST"}, {":path", "/v1/chat/completions"}}, EndOfStream: true},
+		{Phase: extproc.ResponseBody, Body: []byte("data: x\n\n"), EndOfStream: true},
+		{Phase: extproc.RequestBody, Body: []byte{}},
+		{Phase: extproc.ResponseTrailers, Headers: []extproc.Header{{"grpc-status", "0"}}},
+	} {
+		b, err := req.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got extproc.Request
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Fatal(err)
+		}
+		if got.Phase != req.Phase || !reflect.DeepEqual(got.Headers, req.Headers) || !bytes.Equal(got.Body, req.Body) || got.EndOfStream != req.EndOfStream {
+			t.Errorf("%s: got %+v, want %+v", req.Phase, got, req)
+		}
+	}
+	for _, resp := range []extproc.Response{
+		{Phase: extproc.RequestHeaders},
+		{Phase: extproc.RequestHeaders, SetHeaders: []extproc.Header{{"x-ogw-policy", "default"}}, RemoveHeaders: []string{"authorization"}},
+		{Phase: extproc.ResponseBody, Body: &extproc.BodyMutation{Body: []byte{}}},
+		{Phase: extproc.ResponseBody, Body: &extproc.BodyMutation{Clear: true}, RemoveHeaders: []string{"content-length"}},
+		{Phase: extproc.RequestTrailers, SetHeaders: []extproc.Header{{"x-a", "b"}}},
+		{Immediate: &extproc.ImmediateResponse{Status: 403, Headers: []extproc.Header{{"content-type", "application/json"}}, Body: []byte("{}"), Details: "ogw_blocked"}},
+	} {
+		b, err := resp.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got extproc.Response
+		if err := got.UnmarshalBinary(b); err != nil {
+			t.Fatal(err)
+		}
+		if got.Body != nil && !got.Body.Clear && got.Body.Body == nil {
+			got.Body.Body = []byte{}
+		}
+		if !reflect.DeepEqual(got, resp) {
+			t.Errorf("got %+v, want %+v", got, resp)
+		}
+	}
+	var req extproc.Request
+	if err := req.UnmarshalBinary([]byte{0x12, 0x05, 0x0a}); err == nil {
+		t.Fatal("truncated message decoded")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	srv := httptest.NewUnstartedServer(extproc.Handler(func(s *extproc.Stream) error {
+		for {
+			req, err := s.Recv()
+			if err != nil {
+				return err
+			}
+			if req.Phase == extproc.RequestBody && string(req.Body) == "fail" {
+				return errors.New("no good")
+			}
+			resp := &extproc.Response{Phase: req.Phase}
+			if req.Phase == extproc.RequestHeaders {
+				resp.SetHeaders = []extproc.Header{{"x-path", req.Header(":path")}}
+			}
+			if err := s.Send(resp); err != nil {
+				return err
+			}
+		}
+	}))
+	srv.Config.Protocols = new(http.Protocols)
+	srv.Config.Protocols.SetHTTP1(true)
+	srv.Config.Protocols.SetUnencryptedHTTP2(true)
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	s := extproctest.Open(t, srv.URL)
+	resp := s.Send(&extproc.Request{Phase: extproc.RequestHeaders, Headers: []extproc.Header{{":path", "/v1/models"}}})
+	if resp.Phase != extproc.RequestHeaders || len(resp.SetHeaders) != 1 || resp.SetHeaders[0].Value != "/v1/models" {
+		t.Fatalf("%+v", resp)
+	}
+	if resp := s.Send(&extproc.Request{Phase: extproc.ResponseHeaders}); resp.Phase != extproc.ResponseHeaders {
+		t.Fatalf("%+v", resp)
+	}
+	if err := s.CloseSend(); err != io.EOF {
+		t.Fatalf("status: %v", err)
+	}
+
+	s = extproctest.Open(t, srv.URL)
+	s.Send(&extproc.Request{Phase: extproc.RequestHeaders})
+	s.Write(&extproc.Request{Phase: extproc.RequestBody, Body: []byte("fail")})
+	if _, err := s.Recv(); err == nil || !strings.Contains(err.Error(), "grpc-status 13: no good") {
+		t.Fatalf("status: %v", err)
+	}
+
+	// Plain HTTP is refused.
+	res, err := http.Post(srv.URL+extproc.Method, "application/grpc", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusUnsupportedMediaType {
+		t.Fatalf("HTTP/1.1: %d", res.StatusCode)
+	}
+}
@@ -0,0 +1,147 @@
+// Package extproctest plays Envoy against an ext_proc server in tests:
+//
+//	s := extproctest.Open(t, srv.URL)
+//	resp := s.Send(&extproc.Request{Phase: extproc.RequestHeaders, Headers: …})
+//
+// Each Open starts one Process stream, as Envoy does per HTTP request,
+// over unencrypted HTTP/2.
+package extproctest
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/extproc"
+)
+
+// Stream is a Process call in flight.
+type Stream struct {
+	t      testing.TB
+	pw     *io.PipeWriter
+	resp   *http.Response
+	cancel context.CancelFunc
+}
+
+// Open starts a Process call to the server at baseURL (http://host:port).
+// The call is canceled when the test ends.
+func Open(t testing.TB, baseURL string) *Stream {
+	t.Helper()
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+	ctx, cancel := context.WithCancel(context.Background())
+	pr, pw := io.Pipe()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+extproc.Method, pr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("Te", "trailers")
+	s := &Stream{t: t, pw: pw, cancel: cancel}
+	t.Cleanup(s.Close)
+	// The request body must be writable before the response headers
+	// arrive, so the call is made in the background.
+	done := make(chan error, 1)
+	go func() {
+		resp, err := client.Do(req)
+		s.resp = resp
+		done <- err
+	}()
+	s.writeReady(done)
+	return s
+}
+
+// writeReady waits for the response headers.
+func (s *Stream) writeReady(done chan error) {
+	s.t.Helper()
+	select {
+	case err := <-done:
+		if err != nil {
+			s.t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		s.t.Fatal("extproctest: no response headers")
+	}
+	if s.resp.StatusCode != http.StatusOK || s.resp.Header.Get("Content-Type") != "application/grpc" {
+		s.t.Fatalf("extproctest: %s %v", s.resp.Status, s.resp.Header)
+	}
+}
+
+// Send sends req and returns the server's answer.
+func (s *Stream) Send(req *extproc.Request) *extproc.Response {
+	s.t.Helper()
+	s.Write(req)
+	resp, err := s.Recv()
+	if err != nil {
+		s.t.Fatalf("extproctest: answer to %s: %v", req.Phase, err)
+	}
+	return resp
+}
+
+// Write sends req without waiting for the answer.
+func (s *Stream) Write(req *extproc.Request) {
+	s.t.Helper()
+	msg, err := req.MarshalBinary()
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	buf := binary.BigEndian.AppendUint32([]byte{0}, uint32(len(msg)))
+	if _, err := s.pw.Write(append(buf, msg...)); err != nil {
+		s.t.Fatalf("extproctest: send %s: %v", req.Phase, err)
+	}
+}
+
+// Recv reads the next response; at the end of the call it returns io.EOF
+// for grpc-status 0 and an error carrying the status otherwise.
+func (s *Stream) Recv() (*extproc.Response, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(s.resp.Body, prefix[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, s.status()
+		}
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint32(prefix[1:]))
+	if _, err := io.ReadFull(s.resp.Body, msg); err != nil {
+		return nil, err
+	}
+	resp := new(extproc.Response)
+	if err := resp.UnmarshalBinary(msg); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// CloseSend ends the request side, as Envoy does when the HTTP request is
+// done, and returns the call's status (io.EOF for OK).
+func (s *Stream) CloseSend() error {
+	s.pw.Close()
+	io.Copy(io.Discard, s.resp.Body)
+	return s.status()
+}
+
+func (s *Stream) status() error {
+	code := s.resp.Trailer.Get("Grpc-Status")
+	if code == "" {
+		code = s.resp.Header.Get("Grpc-Status")
+	}
+	if code == "0" {
+		return io.EOF
+	}
+	msg, _ := url.PathUnescape(s.resp.Trailer.Get("Grpc-Message"))
+	return fmt.Errorf("grpc-status %s: %s", code, strings.TrimSpace(msg))
+}
+
+// Close cancels the call.
+func (s *Stream) Close() {
+	s.pw.Close()
+	s.cancel()
+}
@@ -0,0 +1,459 @@
+package extproc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Phase is a processing phase of an HTTP request.
+type Phase int
+
+// Phases, numbered as ProcessingResponse numbers its fields.
+const (
+	RequestHeaders Phase = iota + 1
+	ResponseHeaders
+	RequestBody
+	ResponseBody
+	RequestTrailers
+	ResponseTrailers
+)
+
+func (p Phase) String() string {
+	switch p {
+	case RequestHeaders:
+		return "request_headers"
+	case ResponseHeaders:
+		return "response_headers"
+	case RequestBody:
+		return "request_body"
+	case ResponseBody:
+		return "response_body"
+	case RequestTrailers:
+		return "request_trailers"
+	case ResponseTrailers:
+		return "response_trailers"
+	}
+	return fmt.Sprintf("phase(%d)", int(p))
+}
+
+// Header is a header as Envoy passes it: lowercase, with the HTTP/2
+// pseudo-headers (:method, :path, :authority, :status) among them.
+type Header struct {
+	Key, Value string
+}
+
+// Request is a ProcessingRequest: the headers, a body chunk or the
+// trailers of the request or its response. Other fields (attributes,
+// metadata) are skipped.
+type Request struct {
+	Phase Phase
+	// Headers holds the headers or trailers.
+	Headers []Header
+	// Body is the body, or in streamed mode a chunk of it.
+	Body []byte
+	// EndOfStream is set on the last headers or body message of a
+	// direction.
+	EndOfStream bool
+}
+
+// Header returns the value of the header named key (lowercase), or "".
+func (r *Request) Header(key string) string {
+	for _, h := range r.Headers {
+		if h.Key == key {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// Response is a ProcessingResponse. It answers the request of Phase and
+// lets it continue, with its headers and body mutated as set; or, with
+// Immediate set, ends the exchange with a local reply.
+type Response struct {
+	Phase Phase
+	// SetHeaders are set, replacing values of the same name, and
+	// RemoveHeaders removed. In a trailers phase they apply to the
+	// trailers.
+	SetHeaders    []Header
+	RemoveHeaders []string
+	// Body, if set, replaces the body or chunk.
+	Body      *BodyMutation
+	Immediate *ImmediateResponse
+}
+
+// BodyMutation replaces a body or chunk with Body, or with nothing if
+// Clear is set.
+type BodyMutation struct {
+	Body  []byte
+	Clear bool
+}
+
+// ImmediateResponse is a local reply sent instead of forwarding the request
+// or the backend's response.
+type ImmediateResponse struct {
+	Status  int
+	Headers []Header
+	Body    []byte
+	// Details appears in Envoy's access log as the response code details.
+	Details string
+}
+
+// ProcessingRequest fields.
+const (
+	reqRequestHeaders   = 2
+	reqResponseTrailers = 7
+)
+
+// ProcessingResponse's immediate_response field and HeaderValueOption's
+// OVERWRITE_IF_EXISTS_OR_ADD append action.
+const (
+	respImmediate  = 7
+	overwriteOrAdd = 2
+)
+
+// UnmarshalBinary decodes a ProcessingRequest.
+func (r *Request) UnmarshalBinary(b []byte) error {
+	*r = Request{}
+	return fields(b, func(num int, v uint64, p []byte) error {
+		if num < reqRequestHeaders || num > reqResponseTrailers {
+			return nil
+		}
+		r.Phase = Phase(num - 1)
+		switch r.Phase {
+		case RequestHeaders, ResponseHeaders:
+			// HttpHeaders: headers 1, end_of_stream 3.
+			return fields(p, func(num int, v uint64, p []byte) error {
+				switch num {
+				case 1:
+					return decodeHeaderMap(p, &r.Headers)
+				case 3:
+					r.EndOfStream = v != 0
+				}
+				return nil
+			})
+		case RequestBody, ResponseBody:
+			// HttpBody: body 1, end_of_stream 2.
+			return fields(p, func(num int, v uint64, p []byte) error {
+				switch num {
+				case 1:
+					r.Body = append([]byte(nil), p...)
+				case 2:
+					r.EndOfStream = v != 0
+				}
+				return nil
+			})
+		default:
+			// HttpTrailers: trailers 1.
+			return fields(p, func(num int, v uint64, p []byte) error {
+				if num == 1 {
+					return decodeHeaderMap(p, &r.Headers)
+				}
+				return nil
+			})
+		}
+	})
+}
+
+// MarshalBinary encodes a ProcessingRequest, as Envoy would.
+func (r *Request) MarshalBinary() ([]byte, error) {
+	if r.Phase < RequestHeaders || r.Phase > ResponseTrailers {
+		return nil, fmt.Errorf("extproc: invalid phase %d", r.Phase)
+	}
+	var e encoder
+	e.message(int(r.Phase)+1, func(e *encoder) {
+		switch r.Phase {
+		case RequestHeaders, ResponseHeaders:
+			e.message(1, func(e *encoder) { encodeHeaders(e, r.Headers) })
+			e.bool(3, r.EndOfStream)
+		case RequestBody, ResponseBody:
+			e.bytes(1, r.Body)
+			e.bool(2, r.EndOfStream)
+		default:
+			e.message(1, func(e *encoder) { encodeHeaders(e, r.Headers) })
+		}
+	})
+	return e.b, nil
+}
+
+// MarshalBinary encodes a ProcessingResponse.
+func (r *Response) MarshalBinary() ([]byte, error) {
+	var e encoder
+	if im := r.Immediate; im != nil {
+		e.message(respImmediate, func(e *encoder) {
+			e.message(1, func(e *encoder) { e.varint(1, uint64(im.Status)) }) // HttpStatus
+			if len(im.Headers) > 0 {
+				e.message(2, func(e *encoder) { encodeMutation(e, im.Headers, nil) })
+			}
+			if len(im.Body) > 0 {
+				e.bytes(3, im.Body)
+			}
+			e.string(5, im.Details)
+		})
+		return e.b, nil
+	}
+	if r.Phase < RequestHeaders || r.Phase > ResponseTrailers {
+		return nil, fmt.Errorf("extproc: invalid phase %d", r.Phase)
+	}
+	mutation := len(r.SetHeaders) > 0 || len(r.RemoveHeaders) > 0
+	e.message(int(r.Phase), func(e *encoder) {
+		if r.Phase == RequestTrailers || r.Phase == ResponseTrailers {
+			// TrailersResponse: header_mutation 1.
+			if mutation {
+				e.message(1, func(e *encoder) { encodeMutation(e, r.SetHeaders, r.RemoveHeaders) })
+			}
+			return
+		}
+		// HeadersResponse and BodyResponse: response 1, a CommonResponse
+		// (status 1, left at CONTINUE; header_mutation 2; body_mutation 3).
+		e.message(1, func(e *encoder) {
+			if mutation {
+				e.message(2, func(e *encoder) { encodeMutation(e, r.SetHeaders, r.RemoveHeaders) })
+			}
+			if m := r.Body; m != nil {
+				e.message(3, func(e *encoder) {
+					// A oneof: set even when empty.
+					if m.Clear {
+						e.varint(2, 1)
+					} else {
+						e.bytes(1, m.Body)
+					}
+				})
+			}
+		})
+	})
+	return e.b, nil
+}
+
+// UnmarshalBinary decodes a ProcessingResponse, as Envoy would.
+func (r *Response) UnmarshalBinary(b []byte) error {
+	*r = Response{}
+	mutation := func(p []byte) error {
+		// HeaderMutation: set_headers 1, remove_headers 2.
+		return fields(p, func(num int, v uint64, p []byte) error {
+			switch num {
+			case 1:
+				// HeaderValueOption: header 1.
+				return fields(p, func(num int, v uint64, p []byte) error {
+					if num == 1 {
+						return decodeHeader(p, &r.SetHeaders)
+					}
+					return nil
+				})
+			case 2:
+				r.RemoveHeaders = append(r.RemoveHeaders, string(p))
+			}
+			return nil
+		})
+	}
+	return fields(b, func(num int, v uint64, p []byte) error {
+		switch {
+		case num == respImmediate:
+			im := &ImmediateResponse{}
+			r.Immediate = im
+			return fields(p, func(num int, v uint64, p []byte) error {
+				switch num {
+				case 1:
+					return fields(p, func(num int, v uint64, p []byte) error {
+						if num == 1 {
+							im.Status = int(v)
+						}
+						return nil
+					})
+				case 2:
+					if err := mutation(p); err != nil {
+						return err
+					}
+					im.Headers, r.SetHeaders = r.SetHeaders, nil
+				case 3:
+					im.Body = append([]byte(nil), p...)
+				case 5:
+					im.Details = string(p)
+				}
+				return nil
+			})
+		case num == int(RequestTrailers) || num == int(ResponseTrailers):
+			r.Phase = Phase(num)
+			return fields(p, func(num int, v uint64, p []byte) error {
+				if num == 1 {
+					return mutation(p)
+				}
+				return nil
+			})
+		case num >= int(RequestHeaders) && num <= int(ResponseBody):
+			r.Phase = Phase(num)
+			return fields(p, func(num int, v uint64, p []byte) error {
+				if num != 1 {
+					return nil
+				}
+				return fields(p, func(num int, v uint64, p []byte) error {
+					switch num {
+					case 2:
+						return mutation(p)
+					case 3:
+						r.Body = &BodyMutation{}
+						return fields(p, func(num int, v uint64, p []byte) error {
+							switch num {
+							case 1:
+								r.Body.Body = append([]byte(nil), p...)
+							case 2:
+								r.Body.Clear = v != 0
+							}
+							return nil
+						})
+					}
+					return nil
+				})
+			})
+		}
+		return nil
+	})
+}
+
+// decodeHeaderMap appends the headers of a HeaderMap (headers 1).
+func decodeHeaderMap(b []byte, into *[]Header) error {
+	return fields(b, func(num int, v uint64, p []byte) error {
+		if num == 1 {
+			return decodeHeader(p, into)
+		}
+		return nil
+	})
+}
+
+// decodeHeader appends a HeaderValue: key 1, value 2, raw_value 3, which
+// newer Envoys send instead of value.
+func decodeHeader(b []byte, into *[]Header) error {
+	var h Header
+	err := fields(b, func(num int, v uint64, p []byte) error {
+		switch num {
+		case 1:
+			h.Key = strings.ToLower(string(p))
+		case 2, 3:
+			h.Value = string(p)
+		}
+		return nil
+	})
+	*into = append(*into, h)
+	return err
+}
+
+func encodeHeaders(e *encoder, headers []Header) {
+	for _, h := range headers {
+		e.message(1, func(e *encoder) {
+			e.string(1, h.Key)
+			e.bytes(3, []byte(h.Value))
+		})
+	}
+}
+
+func encodeMutation(e *encoder, set []Header, remove []string) {
+	for _, h := range set {
+		e.message(1, func(e *encoder) {
+			e.message(1, func(e *encoder) {
+				e.string(1, h.Key)
+				e.bytes(3, []byte(h.Value))
+			})
+			e.varint(3, overwriteOrAdd)
+		})
+	}
+	for _, k := range remove {
+		e.string(2, k)
+	}
+}
+
+// Wire types.
+const (
+	wireVarint = 0
+	wireI64    = 1
+	wireLen    = 2
+	wireI32    = 5
+)
+
+var errMalformed = errors.New("extproc: malformed message")
+
+// fields calls fn for each field of the message b: v carries varints, p
+// length-delimited payloads. Fixed-width fields are skipped.
+func fields(b []byte, fn func(num int, v uint64, p []byte) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errMalformed
+		}
+		b = b[n:]
+		num := int(key >> 3)
+		switch key & 7 {
+		case wireVarint:
+			v, n := binary.Uvarint(b)
+			if n <= 0 {
+				return errMalformed
+			}
+			b = b[n:]
+			if err := fn(num, v, nil); err != nil {
+				return err
+			}
+		case wireLen:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || l > uint64(len(b)-n) {
+				return errMalformed
+			}
+			p := b[n : n+int(l)]
+			b = b[n+int(l):]
+			if err := fn(num, 0, p); err != nil {
+				return err
+			}
+		case wireI64:
+			if len(b) < 8 {
+				return errMalformed
+			}
+			b = b[8:]
+		case wireI32:
+			if len(b) < 4 {
+				return errMalformed
+			}
+			b = b[4:]
+		default:
+			return errMalformed
+		}
+	}
+	return nil
+}
+
+// encoder appends protobuf fields. Scalars at their zero value are left
+// out, as proto3 does; bytes and messages are always written.
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) key(num, wire int) { e.b = binary.AppendUvarint(e.b, uint64(num)<<3|uint64(wire)) }
+
+func (e *encoder) varint(num int, v uint64) {
+	if v != 0 {
+		e.key(num, wireVarint)
+		e.b = binary.AppendUvarint(e.b, v)
+	}
+}
+
+func (e *encoder) bool(num int, v bool) {
+	if v {
+		e.varint(num, 1)
+	}
+}
+
+func (e *encoder) bytes(num int, p []byte) {
+	e.key(num, wireLen)
+	e.b = binary.AppendUvarint(e.b, uint64(len(p)))
+	e.b = append(e.b, p...)
+}
+
+func (e *encoder) string(num int, s string) {
+	if s != "" {
+		e.bytes(num, []byte(s))
+	}
+}
+
+func (e *encoder) message(num int, fn func(*encoder)) {
+	var sub encoder
+	fn(&sub)
+	e.bytes(num, sub.b)
+}
@@ -0,0 +1,232 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+)
+
+// Backend types. All but azure speak the OpenAI API under their base URL;
+// anthropic is reached through Anthropic's OpenAI-compatible endpoint.
+const (
+	BackendOpenAI     = "openai"
+	BackendAzure      = "azure"
+	BackendAnthropic  = "anthropic"
+	BackendOpenRouter = "openrouter"
+	BackendOllama     = "ollama"
+	BackendVLLM       = "vllm"
+)
+
+var defaultBackendURLs = map[string]string{
+	BackendOpenAI:     "https://api.openai.com/v1",
+	BackendAnthropic:  "https://api.anthropic.com/v1",
+	BackendOpenRouter: "https://openrouter.ai/api/v1",
+	BackendOllama:     "http://localhost:11434/v1",
+	BackendVLLM:       "http://localhost:8000/v1",
+}
+
+// DefaultAzureAPIVersion is used when an azure backend sets none.
+const DefaultAzureAPIVersion = "2024-10-21"
+
+// Backend is a named model provider.
+type Backend struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+	// URL is the API base: the /v1 root for OpenAI-style backends, the
+	// resource endpoint (https://<name>.openai.azure.com) for azure.
+	// Defaults per type where the provider has a public one.
+	URL    string `yaml:"url"`
+	APIKey string `yaml:"api_key"`
+	// APIKeyEnv names an environment variable holding the key, so the
+	// config file need not contain it. APIKey takes precedence.
+	APIKeyEnv string `yaml:"api_key_env"`
+	// APIVersion is azure's api-version query parameter.
+	APIVersion string `yaml:"api_version"`
+	// Deployments maps model names to azure deployment names; unmapped
+	// models are used as deployment names as they are.
+	Deployments map[string]string `yaml:"deployments"`
+	// ModelPrefix namespaces the backend's models: they are listed as
+	// prefix+name, and a request for prefix+name goes to this backend as
+	// name, ahead of the routes.
+	ModelPrefix string `yaml:"model_prefix"`
+	// Models, if set, is listed instead of asking the backend.
+	Models []string `yaml:"models"`
+	// Headers are added to every request, e.g. OpenRouter's HTTP-Referer.
+	Headers map[string]string `yaml:"headers"`
+
+	base *url.URL
+}
+
+// Route sends requests for matching models to a backend.
+type Route struct {
+	// Model is a model pattern ("gpt-4o*", "openai/*", "*"); see
+	// matchModel.
+	Model   string `yaml:"model"`
+	Backend string `yaml:"backend"`
+	// RewriteModel, if set, replaces the model name sent to the backend.
+	RewriteModel string `yaml:"rewrite_model"`
+	// Fallbacks are tried in order when the backend fails; see
+	// FailoverConfig.
+	Fallbacks []Fallback `yaml:"fallbacks"`
+
+	backend *Backend
+}
+
+func (b *Backend) init() error {
+	if b.Name == "" {
+		return fmt.Errorf("backend without a name")
+	}
+	if b.Type == "" {
+		b.Type = BackendOpenAI
+	}
+	if b.URL == "" {
+		b.URL = defaultBackendURLs[b.Type]
+	}
+	if b.APIKey == "" && b.APIKeyEnv != "" {
+		if b.APIKey = os.Getenv(b.APIKeyEnv); b.APIKey == "" {
+			return fmt.Errorf("backend %q: %s is not set", b.Name, b.APIKeyEnv)
+		}
+	}
+	switch b.Type {
+	case BackendOpenAI, BackendAnthropic, BackendOpenRouter, BackendOllama, BackendVLLM:
+	case BackendAzure:
+		if b.APIVersion == "" {
+			b.APIVersion = DefaultAzureAPIVersion
+		}
+	default:
+		return fmt.Errorf("backend %q: unknown type %q", b.Name, b.Type)
+	}
+	u, err := url.Parse(strings.TrimRight(b.URL, "/"))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("backend %q: invalid url %q", b.Name, b.URL)
+	}
+	b.base = u
+	return nil
+}
+
+// newRequest builds a request for endpoint (the path below /v1, e.g.
+// "/chat/completions") on behalf of model.
+func (b *Backend) newRequest(ctx context.Context, method, endpoint, model string, query url.Values, body []byte) (*http.Request, error) {
+	u := *b.base
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	if b.Type == BackendAzure {
+		dep := b.Deployments[model]
+		if dep == "" {
+			dep = model
+		}
+		switch endpoint {
+		case "/models":
+			u.Path += "/openai/models"
+		case "/realtime":
+			u.Path += "/openai/realtime"
+			q.Del("model")
+			q.Set("deployment", dep)
+		default:
+			u.Path += "/openai/deployments/" + url.PathEscape(dep) + endpoint
+		}
+		q.Set("api-version", b.APIVersion)
+	} else {
+		u.Path += endpoint
+	}
+	u.RawQuery = q.Encode()
+
+	var rd io.Reader
+	if body != nil {
+		rd = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), rd)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range b.Headers {
+		req.Header.Set(k, v)
+	}
+	if b.APIKey != "" {
+		switch b.Type {
+		case BackendAzure:
+			req.Header.Set("api-key", b.APIKey)
+		case BackendAnthropic:
+			req.Header.Set("x-api-key", b.APIKey)
+			req.Header.Set("anthropic-version", "2023-06-01")
+			req.Header.Set("Authorization", "Bearer "+b.APIKey)
+		default:
+			req.Header.Set("Authorization", "Bearer "+b.APIKey)
+		}
+	}
+	return req, nil
+}
+
+// route returns the route for model: the backend whose ModelPrefix it
+// carries, else the first route matching it.
+func (g *Gateway) route(model string) (*Route, bool) {
+	for i := range g.cfg.Backends {
+		b := &g.cfg.Backends[i]
+		if name, ok := strings.CutPrefix(model, b.ModelPrefix); ok && b.ModelPrefix != "" && name != "" {
+			return &Route{Model: b.ModelPrefix + "*", Backend: b.Name, RewriteModel: name, backend: b}, true
+		}
+	}
+	for i := range g.cfg.Routes {
+		rt := &g.cfg.Routes[i]
+		if ok, _ := matchModel(rt.Model, model); ok {
+			return rt, true
+		}
+	}
+	return nil, false
+}
+
+// matchModel reports whether model matches pattern, which has path.Match
+// syntax except that * and ? match '/' too: model names such as
+// "Qwen/Qwen2.5-7B-Instruct" and "openai/gpt-4o" carry one. The error is
+// path.Match's, for malformed patterns.
+func matchModel(pattern, model string) (bool, error) {
+	// path.Match stops * and ? at '/', so a byte no model name carries
+	// stands in for it on both sides.
+	return path.Match(strings.ReplaceAll(pattern, "/", "\x00"), strings.ReplaceAll(model, "/", "\x00"))
+}
+
+// initRoutes resolves backend references and checks the routing table.
+func initRoutes(cfg *Config) error {
+	if len(cfg.Backends) == 0 && cfg.ExtProc == nil && cfg.ExtAuthz == nil {
+		return fmt.Errorf("no backends configured")
+	}
+	byName := map[string]*Backend{}
+	for i := range cfg.Backends {
+		b := &cfg.Backends[i]
+		if err := b.init(); err != nil {
+			return err
+		}
+		if byName[b.Name] != nil {
+			return fmt.Errorf("backend %q defined twice", b.Name)
+		}
+		byName[b.Name] = b
+	}
+	if len(cfg.Routes) == 0 && len(cfg.Backends) > 0 {
+		cfg.Routes = []Route{{Model: "*", Backend: cfg.Backends[0].Name}}
+	}
+	for i := range cfg.Routes {
+		rt := &cfg.Routes[i]
+		if _, err := matchModel(rt.Model, ""); err != nil || rt.Model == "" {
+			return fmt.Errorf("route %d: invalid model pattern %q", i, rt.Model)
+		}
+		if rt.backend = byName[rt.Backend]; rt.backend == nil {
+			return fmt.Errorf("route %d (%s): unknown backend %q", i, rt.Model, rt.Backend)
+		}
+		rt.Fallbacks = append([]Fallback(nil), rt.Fallbacks...)
+		for j := range rt.Fallbacks {
+			fb := &rt.Fallbacks[j]
+			if fb.backend = byName[fb.Backend]; fb.backend == nil {
+				return fmt.Errorf("route %d (%s): unknown fallback backend %q", i, rt.Model, fb.Backend)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package gateway
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type seen struct {
+	Path, Query, Auth, APIKey, Model string
+}
+
+// recording is a backend that records each request and answers with a
+// fixed completion.
+func recording(t *testing.T) (*httptest.Server, func() []seen) {
+	t.Helper()
+	var mu sync.Mutex
+	var reqs []seen
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		reqs = append(reqs, seen{r.URL.Path, r.URL.RawQuery, r.Header.Get("Authorization"), r.Header.Get("api-key"), body.Model})
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":"fine"},"finish_reason":"stop"}]}`)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []seen {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]seen(nil), reqs...)
+	}
+}
+
+func chatModel(model string) string {
+	return `{"model":"` + model + `","messages":[{"role":"user","content":"hi"}],"seed":12345678901234567}`
+}
+
+func TestRoutesByModel(t *testing.T) {
+	oai, oaiSeen := recording(t)
+	az, azSeen := recording(t)
+	local, localSeen := recording(t)
+	h, _ := newGateway(t, Config{
+		Backends: []Backend{
+			{Name: "openai", URL: oai.URL + "/v1", APIKey: "sk-oai"},
+			{Name: "azure", Type: BackendAzure, URL: az.URL, APIKey: "az-key", Deployments: map[string]string{"gpt-4o": "prod-4o"}},
+			{Name: "local", Type: BackendOllama, URL: local.URL + "/v1"},
+		},
+		Routes: []Route{
+			{Model: "gpt-4o", Backend: "azure"},
+			{Model: "gpt-*", Backend: "openai"},
+			{Model: "fast", Backend: "local", RewriteModel: "llama3.1:8b"},
+		},
+	})
+
+	for _, m := range []string{"gpt-4o", "gpt-4o-mini", "fast"} {
+		if w := do(h, "POST", "/v1/chat/completions", "", chatModel(m)); w.Code != 200 {
+			t.Fatalf("%s: %d %s", m, w.Code, w.Body)
+		}
+	}
+	if got := azSeen(); len(got) != 1 || got[0].Path != "/openai/deployments/prod-4o/chat/completions" ||
+		got[0].Query != "api-version="+DefaultAzureAPIVersion || got[0].APIKey != "az-key" || got[0].Auth != "" {
+		t.Fatalf("azure: %+v", got)
+	}
+	if got := oaiSeen(); len(got) != 1 || got[0].Path != "/v1/chat/completions" || got[0].Auth != "Bearer sk-oai" || got[0].Model != "gpt-4o-mini" {
+		t.Fatalf("openai: %+v", got)
+	}
+	if got := localSeen(); len(got) != 1 || got[0].Model != "llama3.1:8b" || got[0].Auth != "" {
+		t.Fatalf("ollama: %+v", got)
+	}
+
+	w := do(h, "POST", "/v1/chat/completions", "", chatModel("claude-3-5-haiku"))
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "model_not_found") {
+		t.Fatalf("unrouted model: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestRoutesSlashedModels(t *testing.T) {
+	vllm, vllmSeen := recording(t)
+	or, orSeen := recording(t)
+	h, _ := newGateway(t, Config{
+		Backends: []Backend{{Name: "vllm", URL: vllm.URL + "/v1"}},
+	})
+	// The default route, "*", takes any model.
+	if w := do(h, "POST", "/v1/chat/completions", "", chatModel("Qwen/Qwen2.5-7B-Instruct")); w.Code != 200 {
+		t.Fatalf("default route: %d %s", w.Code, w.Body)
+	}
+	if got := vllmSeen(); len(got) != 1 || got[0].Model != "Qwen/Qwen2.5-7B-Instruct" {
+		t.Fatalf("vllm: %+v", got)
+	}
+
+	h, _ = newGateway(t, Config{
+		APIKeys:  []string{"k1"},
+		Backends: []Backend{{Name: "openrouter", URL: or.URL + "/v1"}},
+		Routes:   []Route{{Model: "*/*", Backend: "openrouter"}},
+		Policies: []Policy{{Name: "or", Keys: []string{"k-or"}, Models: []string{"openai/*"}}},
+	})
+	if w := do(h, "POST", "/v1/chat/completions", "k-or", chatModel("openai/gpt-4o")); w.Code != 200 {
+		t.Fatalf("openai/gpt-4o: %d %s", w.Code, w.Body)
+	}
+	if w := do(h, "POST", "/v1/chat/completions", "k-or", chatModel("anthropic/claude-3.5-sonnet")); w.Code != http.StatusForbidden {
+		t.Fatalf("policy: %d %s", w.Code, w.Body)
+	}
+	if got := orSeen(); len(got) != 1 || got[0].Model != "openai/gpt-4o" {
+		t.Fatalf("openrouter: %+v", got)
+	}
+}
+
+func TestRewriteKeepsNumbers(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(raw), `"seed":12345678901234567`) {
+			t.Errorf("body altered: %s", raw)
+		}
+		io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":"fine"}}]}`)
+	}))
+	defer srv.Close()
+	h, _ := newGateway(t, Config{
+		Backends: []Backend{{Name: "vllm", Type: BackendVLLM, URL: srv.URL + "/v1"}},
+		Routes:   []Route{{Model: "*", Backend: "vllm", RewriteModel: "Qwen/Qwen2.5-7B-Instruct"}},
+	})
+	if w := do(h, "POST", "/v1/chat/completions", "", chatModel("any")); w.Code != 200 {
+		t.Fatalf("%d %s", w.Code, w.Body)
+	}
+}
+
+func TestAnthropicAuth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "sk-ant" || r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("anthropic request %s %v", r.URL.Path, r.Header)
+		}
+		io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":"fine"}}]}`)
+	}))
+	defer srv.Close()
+	h, _ := newGateway(t, Config{Backends: []Backend{{Name: "claude", Type: BackendAnthropic, URL: srv.URL + "/v1", APIKey: "sk-ant"}}})
+	if w := do(h, "POST", "/v1/chat/completions", "", chatModel("claude-sonnet-4-5")); w.Code != 200 {
+		t.Fatalf("%d %s", w.Code, w.Body)
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	for name, cfg := range map[string]Config{
+		"no backends":     {},
+		"unknown type":    {Backends: []Backend{{Name: "x", Type: "bedrock", URL: "http://x"}}},
+		"bad url":         {Backends: []Backend{{Name: "x", URL: "x"}}},
+		"duplicate":       {Backends: []Backend{{Name: "x", URL: "http://x"}, {Name: "x", URL: "http://y"}}},
+		"unknown backend": {Backends: []Backend{{Name: "x", URL: "http://x"}}, Routes: []Route{{Model: "*", Backend: "y"}}},
+		"bad pattern":     {Backends: []Backend{{Name: "x", URL: "http://x"}}, Routes: []Route{{Model: "[", Backend: "x"}}},
+		"no url":          {Backends: []Backend{{Name: "x", Type: BackendAzure}}},
+	} {
+		if _, err := New(cfg, nil, nil); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package gateway
+
+import (
+	lru "container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CacheConfig enables the response cache: a non-streamed completion that
+// passed both checks is kept for TTL, and an identical request under the
+// same policy whose prompt passes is answered from it without calling the
+// backend or checking the answer again. Requests are identical when their
+// bodies match apart from the user field; map keys are compared sorted, so
+// key order does not matter.
+type CacheConfig struct {
+	TTL        time.Duration `yaml:"ttl"`         // default 5m
+	MaxEntries int           `yaml:"max_entries"` // default 1000
+	MaxBytes   int64         `yaml:"max_bytes"`   // default 64 MiB
+}
+
+// cached is a stored completion.
+type cached struct {
+	key     string
+	header  http.Header
+	body    []byte
+	answer  string
+	expires time.Time
+}
+
+// responseCache is an LRU of completions bounded by entries and bytes.
+type responseCache struct {
+	cfg CacheConfig
+	now func() time.Time
+
+	mu      sync.Mutex
+	order   *lru.List // of *cached, most recent first
+	entries map[string]*lru.Element
+	bytes   int64
+	hits    int64
+	misses  int64
+}
+
+func newResponseCache(cfg CacheConfig) *responseCache {
+	if cfg.TTL <= 0 {
+		cfg.TTL = 5 * time.Minute
+	}
+	if cfg.MaxEntries <= 0 {
+		cfg.MaxEntries = 1000
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 64 << 20
+	}
+	return &responseCache{cfg: cfg, now: time.Now, order: lru.New(), entries: map[string]*lru.Element{}}
+}
+
+// cacheKey names a request under pol: everything in body but the user.
+func cacheKey(pol *Policy, body map[string]any) string {
+	norm := make(map[string]any, len(body))
+	for k, v := range body {
+		if k != "user" {
+			norm[k] = v
+		}
+	}
+	b, _ := json.Marshal(norm) // sorts map keys
+	sum := sha256.Sum256(append([]byte(pol.Name+"\x00"), b...))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *responseCache) get(key string) (*cached, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if ok && c.now().Before(el.Value.(*cached).expires) {
+		c.order.MoveToFront(el)
+		c.hits++
+		return el.Value.(*cached), true
+	}
+	if ok {
+		c.remove(el)
+	}
+	c.misses++
+	return nil, false
+}
+
+func (c *responseCache) put(e *cached) {
+	size := int64(len(e.body))
+	if size > c.cfg.MaxBytes {
+		return
+	}
+	e.expires = c.now().Add(c.cfg.TTL)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[e.key]; ok {
+		c.remove(el)
+	}
+	c.entries[e.key] = c.order.PushFront(e)
+	c.bytes += size
+	for c.order.Len() > c.cfg.MaxEntries || c.bytes > c.cfg.MaxBytes {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *responseCache) remove(el *lru.Element) {
+	e := c.order.Remove(el).(*cached)
+	delete(c.entries, e.key)
+	c.bytes -= int64(len(e.body))
+}
+
+func (c *responseCache) flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.order.Init()
+	c.entries = map[string]*lru.Element{}
+	c.bytes = 0
+}
+
+// CacheStats is the state of the response cache.
+type CacheStats struct {
+	Entries int   `json:"entries"`
+	Bytes   int64 `json:"bytes"`
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+}
+
+func (c *responseCache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return CacheStats{Entries: c.order.Len(), Bytes: c.bytes, Hits: c.hits, Misses: c.misses}
+}
@@ -0,0 +1,94 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go"
+	"github.com/openguardrails/openguardrails-go/guardrailstest"
+)
+
+func TestResponseCache(t *testing.T) {
+	be, seen := recording(t)
+	det := guardrailstest.NewServer()
+	t.Cleanup(det.Close)
+	det.Reject(`^leak`, guardrails.CategoryPrivacy)
+	srv, err := NewServer(func() (*Gateway, error) {
+		return New(Config{
+			APIKeys:  []string{"k1"},
+			Policies: []Policy{{Name: "team-a", Keys: []string{"ka"}}},
+			Cache:    &CacheConfig{TTL: time.Minute},
+			Backends: []Backend{{Name: "b", URL: be.URL + "/v1"}},
+		}, det.Client(), nil)
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gw := srv.Gateway()
+	now := time.Now()
+	gw.cache.now = func() time.Time { return now }
+	req := func(key, body string) string {
+		w := do(srv, "POST", "/v1/chat/completions", key, body)
+		if w.Code != 200 {
+			t.Fatalf("%d %s", w.Code, w.Body)
+		}
+		return w.Header().Get("X-OGW-Cache")
+	}
+
+	first := `{"model":"m","user":"a","messages":[{"role":"user","content":"hi"}],"temperature":0}`
+	same := `{"temperature":0,"messages":[{"role":"user","content":"hi"}],"model":"m","user":"b"}`
+	if got := req("k1", first); got != "miss" {
+		t.Fatalf("first: %q", got)
+	}
+	calls := len(det.Calls())
+	if got := req("k1", same); got != "hit" || len(seen()) != 1 || len(det.Calls()) != calls+1 {
+		t.Fatalf("same request: %q, %d backend calls", got, len(seen()))
+	}
+	// A hit still checks the prompt, under its own user.
+	if c := det.Calls()[calls]; c.UserID != "b" {
+		t.Fatalf("hit checked as %q", c.UserID)
+	}
+	// Other policies and other parameters miss.
+	if got := req("ka", first); got != "miss" {
+		t.Fatalf("other policy: %q", got)
+	}
+	if got := req("k1", `{"model":"m","messages":[{"role":"user","content":"hi"}],"temperature":1}`); got != "miss" {
+		t.Fatalf("other params: %q", got)
+	}
+	// Streams and blocked exchanges are not cached.
+	do(srv, "POST", "/v1/chat/completions", "k1", `{"model":"m","stream":true,"messages":[{"role":"user","content":"hi"}]}`)
+	do(srv, "POST", "/v1/chat/completions", "k1", `{"model":"m","messages":[{"role":"user","content":"leak it"}]}`)
+	if s := gw.cache.stats(); s.Entries != 3 || s.Hits != 1 {
+		t.Fatalf("stats %+v", s)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if got := req("k1", first); got != "miss" {
+		t.Fatalf("expired: %q", got)
+	}
+	if w := do(srv.AdminHandler([]string{"adm"}), "POST", "/admin/cache/flush", "adm", ""); w.Code != 204 || gw.cache.stats().Entries != 0 {
+		t.Fatalf("flush: %d %+v", w.Code, gw.cache.stats())
+	}
+}
+
+func TestResponseCacheBounds(t *testing.T) {
+	c := newResponseCache(CacheConfig{MaxEntries: 2, MaxBytes: 10})
+	c.put(&cached{key: "a", body: []byte("1234")})
+	c.put(&cached{key: "b", body: []byte("1234")})
+	c.get("a")
+	c.put(&cached{key: "c", body: []byte("1234")}) // evicts b, the least recent
+	if _, ok := c.get("b"); ok {
+		t.Fatal("b kept")
+	}
+	if _, ok := c.get("a"); !ok {
+		t.Fatal("a evicted")
+	}
+	c.put(&cached{key: "d", body: []byte("12345678")}) // bytes: evicts c and a
+	if s := c.stats(); s.Entries != 1 || s.Bytes != 8 {
+		t.Fatalf("%+v", s)
+	}
+	c.put(&cached{key: "e", body: make([]byte, 11)}) // too big to keep
+	if _, ok := c.get("e"); ok {
+		t.Fatal("oversized entry kept")
+	}
+}
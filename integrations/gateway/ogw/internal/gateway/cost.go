@@ -0,0 +1,41 @@
+package gateway
+
+import (
+	"fmt"
+)
+
+// Price is what matching models cost per million tokens, in whatever
+// currency the table is kept in.
+type Price struct {
+	// Model is a pattern (see matchModel) of the model names sent to
+	// backends.
+	Model  string  `yaml:"model" json:"model"`
+	Input  float64 `yaml:"input" json:"input"`   // per million prompt tokens
+	Output float64 `yaml:"output" json:"output"` // per million completion tokens
+}
+
+func initPrices(prices []Price) error {
+	for i, p := range prices {
+		if _, err := matchModel(p.Model, ""); err != nil || p.Model == "" {
+			return fmt.Errorf("price %d: invalid model pattern %q", i, p.Model)
+		}
+		if p.Input < 0 || p.Output < 0 {
+			return fmt.Errorf("price %d (%s): negative price", i, p.Model)
+		}
+	}
+	return nil
+}
+
+// cost prices an exchange by the first entry matching one of models, in
+// order: the model the backend served, then the one requested. Unpriced
+// models cost nothing.
+func (g *Gateway) cost(prompt, completion int64, models ...string) float64 {
+	for _, m := range models {
+		for _, p := range g.cfg.Prices {
+			if ok, _ := matchModel(p.Model, m); ok {
+				return (float64(prompt)*p.Input + float64(completion)*p.Output) / 1e6
+			}
+		}
+	}
+	return 0
+}
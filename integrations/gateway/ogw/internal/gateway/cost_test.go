@@ -0,0 +1,66 @@
+package gateway
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openguardrails/openguardrails-go/guardrailstest"
+)
+
+func TestCosts(t *testing.T) {
+	metered := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":"fine"},"finish_reason":"stop"}],"usage":{"prompt_tokens":1000,"completion_tokens":500}}`)
+	}))
+	t.Cleanup(metered.Close)
+	det := guardrailstest.NewServer()
+	t.Cleanup(det.Close)
+	gw, err := New(Config{
+		APIKeys:   []string{"k1"},
+		UserQuota: Quota{MonthlyCost: 0.0008},
+		Prices: []Price{
+			{Model: "gpt-4o-mini*", Input: 0.15, Output: 0.6},
+			{Model: "gpt-4o*", Input: 2.5, Output: 10},
+		},
+		Backends: []Backend{{Name: "oai", URL: metered.URL + "/v1"}},
+		Routes:   []Route{{Model: "cheap", Backend: "oai", RewriteModel: "gpt-4o-mini"}, {Model: "*", Backend: "oai"}},
+	}, det.Client(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := gw.Handler()
+	chatAsUser := func(model, user string) int {
+		return do(h, "POST", "/v1/chat/completions", "k1", `{"model":"`+model+`","user":"`+user+`","messages":[{"role":"user","content":"hi"}]}`).Code
+	}
+
+	// Priced by the model served: 1000 × 0.15 + 500 × 0.6 per million.
+	if chatAsUser("cheap", "ann") != 200 || chatAsUser("cheap", "ann") != 200 {
+		t.Fatal("within budget refused")
+	}
+	if code := chatAsUser("cheap", "ann"); code != 429 {
+		t.Fatalf("over budget: %d", code)
+	}
+	if code := chatAsUser("gpt-4o", "bob"); code != 200 {
+		t.Fatalf("bob: %d", code)
+	}
+	if code := chatAsUser("unpriced", "cat"); code != 200 {
+		t.Fatalf("cat: %d", code)
+	}
+
+	want := map[string]float64{"user:default/ann": 0.0009, "user:default/bob": 0.0075, "user:default/cat": 0}
+	for _, tl := range gw.usage.snapshot() {
+		if w, ok := want[tl.Subject]; ok && (math.Abs(tl.Cost-w) > 1e-12 || tl.MonthCost != tl.Cost) {
+			t.Errorf("%s: cost %v, month %v, want %v", tl.Subject, tl.Cost, tl.MonthCost, w)
+		}
+		if tl.Subject[:4] == "key:" && math.Abs(tl.DayCost-0.0084) > 1e-12 {
+			t.Errorf("key cost %v", tl.DayCost)
+		}
+	}
+
+	if _, err := New(Config{Backends: []Backend{{Name: "b", URL: "http://x"}}, Prices: []Price{{Model: "["}}}, nil, nil); err == nil {
+		t.Fatal("bad price pattern accepted")
+	}
+}
@@ -0,0 +1,183 @@
+package gateway
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"net/url"
+	"runtime"
+	rpprof "runtime/pprof"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go"
+)
+
+// recentVerdicts is how many checks the verdict inspector keeps.
+const recentVerdicts = 200
+
+// VerdictEntry is one check as the verdict inspector shows it. The checked
+// text is left out; ID finds it on the platform.
+type VerdictEntry struct {
+	Time       time.Time             `json:"time"`
+	Policy     string                `json:"policy"`
+	Key        string                `json:"key"`
+	User       string                `json:"user,omitempty"`
+	Stage      string                `json:"stage"`
+	Allowed    bool                  `json:"allowed"`
+	LatencyMS  int64                 `json:"latency_ms"`
+	ID         string                `json:"id,omitempty"`
+	Action     guardrails.Action     `json:"action,omitempty"`
+	RiskLevel  string                `json:"risk_level,omitempty"`
+	Categories []guardrails.Category `json:"categories,omitempty"`
+	// Error is set when the check itself failed.
+	Error string `json:"error,omitempty"`
+}
+
+// verdictLog is a ring of the latest checks.
+type verdictLog struct {
+	mu      sync.Mutex
+	entries []VerdictEntry
+	next    int
+}
+
+func (l *verdictLog) add(e VerdictEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.entries) < recentVerdicts {
+		l.entries = append(l.entries, e)
+		return
+	}
+	l.entries[l.next] = e
+	l.next = (l.next + 1) % recentVerdicts
+}
+
+// snapshot returns the checks, newest first.
+func (l *verdictLog) snapshot() []VerdictEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := make([]VerdictEntry, 0, len(l.entries))
+	for i := range l.entries {
+		out = append(out, l.entries[(l.next+len(l.entries)-1-i)%len(l.entries)])
+	}
+	return out
+}
+
+// DebugHandler returns the runtime debug endpoints, behind the same
+// authentication as the admin API:
+//
+//	GET /debug/pprof/…     net/http/pprof profiles
+//	GET /debug/goroutines  every goroutine's stack
+//	GET /debug/runtime     memory, GC and goroutine counts
+//	GET /debug/config      the configuration in effect, secrets left out
+//	GET /debug/verdicts    the latest checks and their verdicts
+func (s *Server) DebugHandler(keys []string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /debug/pprof/", pprof.Index)
+	mux.HandleFunc("GET /debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("GET /debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("GET /debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("GET /debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("GET /debug/goroutines", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		rpprof.Lookup("goroutine").WriteTo(w, 2)
+	})
+	mux.HandleFunc("GET /debug/runtime", func(w http.ResponseWriter, r *http.Request) {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		writeJSON(w, map[string]any{
+			"go":                runtime.Version(),
+			"uptime":            time.Since(s.started).Round(time.Second).String(),
+			"goroutines":        runtime.NumGoroutine(),
+			"heap_alloc":        m.HeapAlloc,
+			"heap_sys":          m.HeapSys,
+			"heap_objects":      m.HeapObjects,
+			"gc_cycles":         m.NumGC,
+			"gc_pause_total_ms": float64(m.PauseTotalNs) / 1e6,
+		})
+	})
+	mux.HandleFunc("GET /debug/config", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, s.Gateway().redactedConfig())
+	})
+	mux.HandleFunc("GET /debug/verdicts", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"verdicts": s.Gateway().verdicts.snapshot()})
+	})
+	return s.adminAuth(keys, mux)
+}
+
+// redactedConfig is the configuration in effect with every credential
+// replaced by whether it is set.
+func (g *Gateway) redactedConfig() map[string]any {
+	cfg := g.cfg
+	backends := []map[string]any{}
+	for _, b := range cfg.Backends {
+		backends = append(backends, map[string]any{
+			"name": b.Name, "type": b.Type, "url": b.base.String(), "api_key_set": b.APIKey != "",
+			"api_version": b.APIVersion, "deployments": b.Deployments, "model_prefix": b.ModelPrefix,
+			"models": b.Models, "header_names": headerNames(b.Headers),
+		})
+	}
+	routes := []map[string]any{}
+	for _, rt := range cfg.Routes {
+		fallbacks := []map[string]string{}
+		for _, fb := range rt.Fallbacks {
+			fallbacks = append(fallbacks, map[string]string{"backend": fb.Backend, "rewrite_model": fb.RewriteModel})
+		}
+		routes = append(routes, map[string]any{"model": rt.Model, "backend": rt.Backend, "rewrite_model": rt.RewriteModel, "fallbacks": fallbacks})
+	}
+	policies := []map[string]any{}
+	for _, p := range g.policies() {
+		keys := len(p.Keys)
+		if p == g.dflt.policy {
+			keys = len(cfg.APIKeys)
+		}
+		entry := map[string]any{
+			"name": p.Name, "keys": keys, "guardrails_api_key_set": p.GuardrailsAPIKey != "",
+			"sensitivity": p.Sensitivity, "categories": p.Categories, "models": p.Models,
+			"quota": p.Quota, "user_quota": p.UserQuota, "log_fields": p.logFields,
+		}
+		if p.Shadow != nil {
+			entry["shadow"] = map[string]any{
+				"guardrails_api_key_set": p.Shadow.GuardrailsAPIKey != "",
+				"sensitivity":            p.Shadow.Sensitivity, "categories": p.Shadow.Categories,
+			}
+		}
+		policies = append(policies, entry)
+	}
+	out := map[string]any{
+		"backends": backends, "routes": routes, "policies": policies,
+		"fail_open": cfg.FailOpen, "max_body": cfg.MaxBody, "timeout": cfg.Timeout.String(),
+		"stream": cfg.Stream, "failover": map[string]any{
+			"attempt_timeout": cfg.Failover.AttemptTimeout.String(),
+			"unhealthy_after": g.health.after, "cooldown": g.health.cooldown.String(),
+		},
+		"prices": cfg.Prices, "archive": cfg.Archiver != nil,
+		"logging": map[string]any{"level": cfg.Logging.Level, "format": cfg.Logging.Format, "fields": g.dflt.policy.logFields, "truncate": cfg.Logging.Truncate},
+	}
+	if g.cache != nil {
+		out["cache"] = map[string]any{"ttl": g.cache.cfg.TTL.String(), "max_entries": g.cache.cfg.MaxEntries, "max_bytes": g.cache.cfg.MaxBytes}
+	}
+	if rl := g.rates.remote; rl != nil {
+		redis := "(invalid)"
+		if u, err := url.Parse(rl.cfg.Redis); err == nil {
+			redis = u.Redacted()
+		}
+		out["rate_limit"] = map[string]any{"redis": redis, "prefix": rl.cfg.Prefix, "timeout": rl.cfg.Timeout.String()}
+	}
+	if o := cfg.OIDC; o != nil {
+		out["oidc"] = map[string]any{
+			"issuer": o.Issuer, "audience": o.Audience, "jwks_url": o.JWKSURL,
+			"user_claim": o.UserClaim, "policy_claim": o.PolicyClaim, "admin_claim": o.AdminClaim,
+		}
+	}
+	return out
+}
+
+func headerNames(h map[string]string) []string {
+	out := []string{}
+	for k := range h {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
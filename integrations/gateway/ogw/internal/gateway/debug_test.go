@@ -0,0 +1,92 @@
+package gateway
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/openguardrails/openguardrails-go"
+	"github.com/openguardrails/openguardrails-go/guardrailstest"
+)
+
+func TestDebugHandler(t *testing.T) {
+	be := backend(t)
+	det := guardrailstest.NewServer()
+	t.Cleanup(det.Close)
+	det.Reject(`^leak`, guardrails.CategoryPrivacy)
+	srv, err := NewServer(func() (*Gateway, error) {
+		return New(Config{
+			APIKeys: []string{"k1"},
+			Policies: []Policy{{
+				Name: "team-a", Keys: []string{"ka"}, GuardrailsAPIKey: "sk-xxai-team",
+			}},
+			Backends: []Backend{{
+				Name: "b", URL: be.URL + "/v1", APIKey: "sk-upstream",
+				Headers: map[string]string{"X-Secret": "hidden-value"},
+			}},
+		}, det.Client(), nil)
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	do(srv, "POST", "/v1/chat/completions", "k1", chat("hello", false))
+	do(srv, "POST", "/v1/chat/completions", "ka", chat("leak it", false))
+
+	h := srv.DebugHandler([]string{"adm"})
+	if w := do(h, "GET", "/debug/runtime", "", ""); w.Code != 401 {
+		t.Fatalf("no key: %d", w.Code)
+	}
+	if w := do(h, "GET", "/debug/runtime", "nope", ""); w.Code != 401 {
+		t.Fatalf("wrong key: %d", w.Code)
+	}
+
+	w := do(h, "GET", "/debug/config", "adm", "")
+	if w.Code != 200 {
+		t.Fatalf("config: %d %s", w.Code, w.Body)
+	}
+	for _, secret := range []string{"sk-upstream", "sk-xxai-team", "hidden-value", `"k1"`, `"ka"`} {
+		if strings.Contains(w.Body.String(), secret) {
+			t.Fatalf("config leaks %s: %s", secret, w.Body)
+		}
+	}
+	if !strings.Contains(w.Body.String(), `"X-Secret"`) || !strings.Contains(w.Body.String(), `"api_key_set":true`) {
+		t.Fatalf("config: %s", w.Body)
+	}
+
+	w = do(h, "GET", "/debug/verdicts", "adm", "")
+	var got struct{ Verdicts []VerdictEntry }
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	// Newest first: the blocked input, then the allowed exchange.
+	if len(got.Verdicts) != 3 {
+		t.Fatalf("verdicts %+v", got.Verdicts)
+	}
+	if v := got.Verdicts[0]; v.Policy != "team-a" || v.Stage != "input" || v.Allowed || v.User != "u-7" || len(v.Categories) == 0 {
+		t.Fatalf("blocked verdict %+v", v)
+	}
+	if v := got.Verdicts[2]; v.Policy != "default" || !v.Allowed || v.ID == "" {
+		t.Fatalf("allowed verdict %+v", v)
+	}
+
+	if w := do(h, "GET", "/debug/runtime", "adm", ""); w.Code != 200 || !strings.Contains(w.Body.String(), `"goroutines"`) {
+		t.Fatalf("runtime: %d %s", w.Code, w.Body)
+	}
+	if w := do(h, "GET", "/debug/goroutines", "adm", ""); w.Code != 200 || !strings.Contains(w.Body.String(), "goroutine ") {
+		t.Fatalf("goroutines: %d", w.Code)
+	}
+	if w := do(h, "GET", "/debug/pprof/", "adm", ""); w.Code != 200 || !strings.Contains(w.Body.String(), "heap") {
+		t.Fatalf("pprof: %d", w.Code)
+	}
+}
+
+func TestVerdictLogWraps(t *testing.T) {
+	var l verdictLog
+	for i := 0; i < recentVerdicts+5; i++ {
+		l.add(VerdictEntry{LatencyMS: int64(i)})
+	}
+	got := l.snapshot()
+	if len(got) != recentVerdicts || got[0].LatencyMS != recentVerdicts+4 || got[len(got)-1].LatencyMS != 5 {
+		t.Fatalf("%d entries, newest %d, oldest %d", len(got), got[0].LatencyMS, got[len(got)-1].LatencyMS)
+	}
+}
@@ -0,0 +1,69 @@
+package gateway
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/websocket"
+)
+
+// lifecycle is what a Server's gateways share about shutting down: whether
+// the server is draining, and the realtime sessions in flight, which run on
+// hijacked connections that http.Server.Shutdown does not wait for.
+type lifecycle struct {
+	draining atomic.Bool
+
+	mu       sync.Mutex
+	sessions map[*realtimeSession]struct{}
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{sessions: map[*realtimeSession]struct{}{}}
+}
+
+func (l *lifecycle) add(s *realtimeSession) {
+	l.mu.Lock()
+	l.sessions[s] = struct{}{}
+	l.mu.Unlock()
+}
+
+func (l *lifecycle) remove(s *realtimeSession) {
+	l.mu.Lock()
+	delete(l.sessions, s)
+	l.mu.Unlock()
+}
+
+func (l *lifecycle) active() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.sessions)
+}
+
+// Drain marks the server as shutting down: /healthz answers 503 from now
+// on, so load balancers stop sending traffic, while requests keep being
+// served.
+func (s *Server) Drain() { s.Gateway().life.draining.Store(true) }
+
+// WaitSessions waits for the realtime sessions in flight to end. When ctx
+// is done first, it closes them with 1001 (going away), so clients know to
+// reconnect, and returns ctx's error.
+func (s *Server) WaitSessions(ctx context.Context) error {
+	l := s.Gateway().life
+	tick := time.NewTicker(50 * time.Millisecond)
+	defer tick.Stop()
+	for l.active() > 0 {
+		select {
+		case <-ctx.Done():
+			l.mu.Lock()
+			for rs := range l.sessions {
+				go rs.close(websocket.CloseGoingAway, "server restarting")
+			}
+			l.mu.Unlock()
+			return ctx.Err()
+		case <-tick.C:
+		}
+	}
+	return nil
+}
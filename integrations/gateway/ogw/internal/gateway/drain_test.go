@@ -0,0 +1,65 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go/guardrailstest"
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/websocket"
+)
+
+func TestDrain(t *testing.T) {
+	det := guardrailstest.NewServer()
+	t.Cleanup(det.Close)
+	rt := realtimeBackend(t)
+	srv, err := NewServer(func() (*Gateway, error) {
+		return New(Config{
+			APIKeys:  []string{"k1"},
+			Backends: []Backend{{Name: "rt", URL: rt.URL + "/v1", APIKey: "sk-upstream", Models: []string{"echo"}}},
+		}, det.Client(), nil)
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hs := httptest.NewServer(srv)
+	t.Cleanup(hs.Close)
+
+	if w := do(srv, "GET", "/healthz", "", ""); w.Code != 200 {
+		t.Fatalf("healthz: %d", w.Code)
+	}
+	c := dialRealtime(t, hs, "k1")
+	say(c, "hello")
+	if _, closed := events(t, c); closed != nil {
+		t.Fatalf("session closed: %v", closed)
+	}
+
+	srv.Drain()
+	if err := srv.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if w := do(srv, "GET", "/healthz", "", ""); w.Code != 503 || w.Body.String() != "draining" {
+		t.Fatalf("healthz while draining: %d %s", w.Code, w.Body)
+	}
+	// Requests are still served while draining.
+	if w := do(srv, "GET", "/v1/models", "k1", ""); w.Code != 200 {
+		t.Fatalf("models while draining: %d", w.Code)
+	}
+
+	// The session outlives a short deadline and is closed as going away.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := srv.WaitSessions(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("wait: %v", err)
+	}
+	if _, closed := events(t, c); closed == nil || closed.Code != websocket.CloseGoingAway {
+		t.Fatalf("closed %v", closed)
+	}
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.WaitSessions(ctx); err != nil {
+		t.Fatalf("wait after close: %v", err)
+	}
+}
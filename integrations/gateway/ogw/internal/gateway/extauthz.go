@@ -0,0 +1,92 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go"
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/extauthz"
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/extproc"
+)
+
+// ExtAuthz enables the Envoy external authorization server: the request
+// half of ext_proc, for deployments that only guard prompts. Envoy asks
+// once per request, before it goes upstream, and answers are not seen.
+// Backends are optional then.
+type ExtAuthz struct {
+	// Listen is the address of the listener. It serves the gRPC service
+	// as cleartext HTTP/2 and the HTTP service on HTTP/1.1.
+	Listen string `yaml:"listen"`
+}
+
+// ExtAuthzHandler serves Envoy's external authorization API, gRPC and
+// HTTP, with the current Gateway.
+//
+// Requests get what ext_proc gives them before the backend: authentication,
+// the policy's model allowlist, rates, quotas and the input check. Allowed
+// requests go on without the client's key (when the gateway verified one)
+// and, once checked, with X-OGW-Risk-Level set. Refusals carry the body
+// the proxy would send, except that refused prompts get a 403 error with
+// the refusal text. Envoy must send the body (with_request_body) for
+// completions to be checked; one it cut short is refused.
+func (s *Server) ExtAuthzHandler() http.Handler {
+	return extauthz.Handler(func(ctx context.Context, req *extauthz.Request) *extauthz.Response {
+		return s.Gateway().authorize(ctx, req)
+	})
+}
+
+// authorize answers one check request by running it through ext_proc's
+// request phases.
+func (g *Gateway) authorize(ctx context.Context, req *extauthz.Request) *extauthz.Response {
+	x := &processed{g: g, ctx: ctx, start: time.Now()}
+	defer x.finish()
+	headers := []extproc.Header{{Key: ":method", Value: req.Method}, {Key: ":path", Value: req.Path}, {Key: ":authority", Value: req.Host}}
+	for _, h := range req.Headers {
+		headers = append(headers, extproc.Header{Key: h.Key, Value: h.Value})
+	}
+	resp := x.requestHeaders(&extproc.Request{Phase: extproc.RequestHeaders, Headers: headers, EndOfStream: len(req.Body) == 0})
+	if resp.Immediate == nil && len(req.Body) > 0 {
+		if x.guarded && req.Header("x-envoy-auth-partial-body") == "true" {
+			resp = localReply(http.StatusRequestEntityTooLarge, "invalid_request_error", "Request body too large.")
+		} else if body := x.requestBody(&extproc.Request{Phase: extproc.RequestBody, Body: req.Body, EndOfStream: true}); body.Immediate != nil {
+			resp = body
+		}
+	}
+	if x.rec != nil {
+		// There is no answer to record.
+		x.rec.Backend = "ext_authz"
+	}
+	if im := resp.Immediate; im != nil {
+		if im.Status/100 == 2 {
+			// A refused prompt, which the proxy answers with a
+			// content_filter completion. Envoy's HTTP service would read
+			// the 2xx as allowed, so it goes out as an error.
+			im = localReply(http.StatusForbidden, "content_policy_violation", refusalText(im.Body)).Immediate
+			x.status = im.Status
+		}
+		d := &extauthz.Denied{Status: im.Status, Body: im.Body}
+		for _, h := range im.Headers {
+			d.Headers = append(d.Headers, extauthz.Header{Key: h.Key, Value: h.Value})
+		}
+		return &extauthz.Response{Denied: d}
+	}
+	out := &extauthz.Response{RemoveHeaders: resp.RemoveHeaders}
+	if x.rec != nil && x.rec.Input != nil {
+		out.SetHeaders = []extauthz.Header{{Key: "x-ogw-risk-level", Value: x.rec.Input.RiskLevel}}
+	}
+	return out
+}
+
+// refusalText is the answer in a refusal the proxy sends: a completion, or
+// a stream of one chunk.
+func refusalText(body []byte) string {
+	var completion map[string]any
+	if json.Unmarshal(body, &completion) == nil {
+		return guardrails.OpenAIResponse(completion)
+	}
+	m := &meter{}
+	m.write(body)
+	return m.answer()
+}
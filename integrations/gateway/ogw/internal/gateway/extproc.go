@@ -0,0 +1,412 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go"
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/archive"
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/extproc"
+)
+
+// ExtProc enables the Envoy external processing server: Envoy (or Envoy
+// Gateway, or Istio) keeps routing and calling the backends, and asks the
+// gateway about each request and response on the way. Backends are
+// optional then.
+type ExtProc struct {
+	// Listen is the address of the gRPC listener, served as cleartext
+	// HTTP/2.
+	Listen string `yaml:"listen"`
+}
+
+// ExtProcHandler serves Envoy's external processing API with the current
+// Gateway: each Process stream, one per proxied request, is handled by the
+// Gateway current when it opened.
+//
+// Requests get what guarded requests get from the proxy: authentication,
+// the policy's model allowlist, rates, quotas and the input check, with
+// refusals sent as Envoy local replies. POSTs to paths ending in
+// /completions are checked; other paths are only authenticated. Answers
+// are checked when their body is done, streams every Stream.CheckEvery
+// tokens, and blocked ones replaced. Bodies must be sent (Envoy's
+// processing mode BUFFERED or STREAMED): request chunks are held until
+// the request is checked, and answer chunks until the text they carry is.
+// A checked request whose body never came is answered with a 500, and one
+// with no text to check with a 400, as the proxy does.
+func (s *Server) ExtProcHandler() http.Handler {
+	return extproc.Handler(func(st *extproc.Stream) error { return s.Gateway().process(st) })
+}
+
+// process serves one Process stream.
+func (g *Gateway) process(st *extproc.Stream) error {
+	x := &processed{g: g, ctx: st.Context(), start: time.Now()}
+	defer x.finish()
+	for {
+		req, err := st.Recv()
+		if err != nil {
+			return err
+		}
+		var resp *extproc.Response
+		switch req.Phase {
+		case extproc.RequestHeaders:
+			resp = x.requestHeaders(req)
+		case extproc.RequestBody:
+			resp = x.requestBody(req)
+		case extproc.ResponseHeaders:
+			resp = x.responseHeaders(req)
+		case extproc.ResponseBody:
+			resp = x.responseBody(req)
+		default:
+			resp = &extproc.Response{Phase: req.Phase}
+		}
+		if err := st.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// processed is the state of one request Envoy proxies.
+type processed struct {
+	g     *Gateway
+	ctx   context.Context
+	start time.Time
+	// r stands for the request, with the headers Envoy sent and the
+	// client key attached once authenticated.
+	r *http.Request
+	// guarded is set for completion requests, which are checked.
+	guarded bool
+	body    []byte // request chunks held
+
+	// Set once the request passed its checks.
+	pol      *Policy
+	user     string
+	subjects []subject
+	messages []guardrails.Message
+	rec      *archive.Record
+
+	// The answer.
+	status  int
+	sse     bool
+	out     []byte // buffered answer chunks held
+	m       *meter // the text of a streamed answer
+	held    []byte // streamed events held
+	checked int    // runes of the streamed answer checked
+	cut     bool   // the stream has been cut off
+}
+
+func (x *processed) requestHeaders(req *extproc.Request) *extproc.Response {
+	g := x.g
+	method, path := req.Header(":method"), req.Header(":path")
+	u, err := url.ParseRequestURI(path)
+	if err != nil {
+		return localReply(http.StatusBadRequest, "invalid_request_error", "Invalid request path.")
+	}
+	r, err := http.NewRequestWithContext(x.ctx, method, u.String(), nil)
+	if err != nil {
+		return localReply(http.StatusBadRequest, "invalid_request_error", "Invalid request method.")
+	}
+	r.Host = req.Header(":authority")
+	for _, h := range req.Headers {
+		if !strings.HasPrefix(h.Key, ":") {
+			r.Header.Add(h.Key, h.Value)
+		}
+	}
+	rb := &replyBuffer{header: http.Header{}}
+	g.authenticate(http.HandlerFunc(func(_ http.ResponseWriter, ar *http.Request) { x.r = ar })).ServeHTTP(rb, r)
+	if x.r == nil {
+		return rb.reply()
+	}
+	x.guarded = method == http.MethodPost && strings.HasSuffix(u.Path, "/completions")
+	resp := &extproc.Response{Phase: req.Phase}
+	if len(g.keys) > 0 || g.verifier != nil {
+		// The client's key stays here, as it does with the proxy; Envoy
+		// adds the backend's.
+		resp.RemoveHeaders = []string{"authorization"}
+	}
+	if x.guarded && req.EndOfStream {
+		// No body to check: refused as the proxy would refuse it.
+		if reply := x.checkRequest(); reply != nil {
+			return reply
+		}
+	}
+	return resp
+}
+
+func (x *processed) requestBody(req *extproc.Request) *extproc.Response {
+	if !x.guarded || x.rec != nil {
+		return &extproc.Response{Phase: req.Phase}
+	}
+	held := len(x.body) > 0
+	x.body = append(x.body, req.Body...)
+	if !req.EndOfStream {
+		if int64(len(x.body)) > x.g.cfg.MaxBody {
+			return localReply(http.StatusRequestEntityTooLarge, "invalid_request_error", "Request body too large.")
+		}
+		return &extproc.Response{Phase: req.Phase, Body: &extproc.BodyMutation{Clear: true}}
+	}
+	if reply := x.checkRequest(); reply != nil {
+		return reply
+	}
+	resp := &extproc.Response{Phase: req.Phase}
+	if held {
+		resp.Body = &extproc.BodyMutation{Body: x.body}
+	}
+	return resp
+}
+
+// checkRequest runs the proxy's checks on the request body, returning the
+// local reply that refuses it, if any.
+func (x *processed) checkRequest() *extproc.Response {
+	g, r := x.g, x.r
+	r.Body = io.NopCloser(bytes.NewReader(x.body))
+	rb := &replyBuffer{header: http.Header{}}
+	_, body, ok := g.readBody(rb, r)
+	if !ok {
+		return rb.reply()
+	}
+	pol := g.policy(r)
+	model, _ := body["model"].(string)
+	if !pol.allowsModel(model) {
+		return localReply(http.StatusForbidden, "model_not_allowed", fmt.Sprintf("This API key may not use the model %q.", model))
+	}
+	messages := guardrails.OpenAIRequest(body)
+	if len(messages) == 0 {
+		return localReply(http.StatusBadRequest, "invalid_request_error", "The request has no text to check: send messages, or a prompt string or array of strings.")
+	}
+	user, _ := body["user"].(string)
+	if u := g.client(r).user; u != "" {
+		user = u
+	}
+	subjects, ok := g.admit(rb, r, pol, user)
+	if !ok {
+		return rb.reply()
+	}
+	stream, _ := body["stream"].(bool)
+	x.pol, x.user, x.subjects, x.messages = pol, user, subjects, messages
+	x.rec = &archive.Record{
+		Time: x.start.UTC(), Policy: pol.Name, Key: g.client(r).id, User: user, Endpoint: r.URL.Path,
+		Model: model, Backend: "envoy", Stream: stream, Messages: x.messages,
+	}
+	resp, ok := g.check(r, pol, "input", x.messages, user)
+	x.rec.Input = archive.NewVerdict(resp)
+	if !ok {
+		x.rec.Blocked = "input"
+		deny(rb, r, resp, stream)
+		g.log(r, slog.LevelWarn, "input blocked", append(verdictAttrs(resp), g.redacted(pol, LogFieldPrompt, lastUser(x.messages)))...)
+		x.status = rb.status
+		return rb.reply()
+	}
+	return nil
+}
+
+func (x *processed) responseHeaders(req *extproc.Request) *extproc.Response {
+	resp := &extproc.Response{Phase: req.Phase}
+	if x.guarded && x.rec == nil {
+		// Envoy went on without sending the body (request_body_mode
+		// NONE), so the prompt was never checked. The backend has seen
+		// it; its answer at least stays here.
+		x.g.log(x.r, slog.LevelError, "request body not sent for checking; set request_body_mode BUFFERED or STREAMED")
+		return localReply(http.StatusInternalServerError, "guardrails_misconfigured", "The request was not checked.")
+	}
+	if x.rec == nil {
+		return resp
+	}
+	x.status, _ = strconv.Atoi(req.Header(":status"))
+	if x.status/100 != 2 || req.EndOfStream {
+		return resp
+	}
+	mt, _, _ := mime.ParseMediaType(req.Header("content-type"))
+	x.sse = mt == "text/event-stream"
+	x.m = &meter{}
+	// The body may be replaced.
+	resp.RemoveHeaders = []string{"content-length"}
+	return resp
+}
+
+func (x *processed) responseBody(req *extproc.Request) *extproc.Response {
+	resp := &extproc.Response{Phase: req.Phase}
+	if x.rec == nil || x.m == nil {
+		return resp
+	}
+	if x.sse {
+		resp.Body = x.streamChunk(req.Body, req.EndOfStream)
+		return resp
+	}
+	held := len(x.out) > 0
+	x.out = append(x.out, req.Body...)
+	if !req.EndOfStream {
+		resp.Body = &extproc.BodyMutation{Clear: true}
+		return resp
+	}
+	if body := x.checkAnswer(); body != nil {
+		resp.Body = &extproc.BodyMutation{Body: body}
+	} else if held {
+		resp.Body = &extproc.BodyMutation{Body: x.out}
+	}
+	return resp
+}
+
+// checkAnswer counts the usage of a complete answer and checks it,
+// returning the body that replaces it when it is blocked.
+func (x *processed) checkAnswer() []byte {
+	g, r, rec := x.g, x.r, x.rec
+	rec.PromptTokens, rec.CompletionTokens = completionTokens(x.out, x.messages)
+	rec.Cost = g.cost(rec.PromptTokens, rec.CompletionTokens, rec.Model, rec.Model)
+	g.usage.record(x.subjects, rec.PromptTokens, rec.CompletionTokens, rec.Cost)
+	g.rates.record(context.WithoutCancel(x.ctx), x.subjects, rec.PromptTokens+rec.CompletionTokens)
+	var completion map[string]any
+	if json.Unmarshal(x.out, &completion) != nil {
+		return nil
+	}
+	text := guardrails.OpenAIResponse(completion)
+	if text == "" {
+		return nil
+	}
+	rec.Answer = text
+	conv := append(x.messages, guardrails.Message{Role: "assistant", Content: text})
+	resp, ok := g.check(r, x.pol, "output", conv, x.user)
+	rec.Output = archive.NewVerdict(resp)
+	if ok {
+		return nil
+	}
+	rec.Blocked = "output"
+	rb := &replyBuffer{header: http.Header{}}
+	guardrails.OpenAIDeny(rb, r, resp)
+	g.log(r, slog.LevelWarn, "output blocked", append(verdictAttrs(resp), g.redacted(x.pol, LogFieldResponse, text))...)
+	return rb.body.Bytes()
+}
+
+// streamChunk takes the next chunk of a streamed answer and returns what
+// to send in its place: the events whose text has passed a check, or a
+// refusal. Under Stream.Passthrough chunks pass at once, and only the
+// chunk that trips a check is held for it.
+func (x *processed) streamChunk(chunk []byte, end bool) *extproc.BodyMutation {
+	g := x.g
+	if x.cut {
+		return &extproc.BodyMutation{Clear: true}
+	}
+	x.m.write(chunk)
+	x.held = append(x.held, chunk...)
+	text := []rune(x.m.answer())
+	var release []byte
+	if (len(text)-x.checked)/4 >= g.cfg.Stream.CheckEvery || end && x.checked < len(text) {
+		start := max(len(text)-g.cfg.Stream.Window*4, 0)
+		if resp, ok := x.allowStream(string(text[start:])); !ok {
+			x.cut, x.held = true, nil
+			x.rec.Blocked, x.rec.Output = "stream", archive.NewVerdict(resp)
+			g.log(x.r, slog.LevelWarn, "stream cut off", verdictAttrs(resp)...)
+			x.endStream()
+			if resp == nil {
+				// The check failed: a refusal all the same.
+				resp = &guardrails.Response{}
+			}
+			rb := &replyBuffer{header: http.Header{}}
+			deny(rb, x.r, resp, true)
+			return &extproc.BodyMutation{Body: rb.body.Bytes()}
+		}
+		x.checked = len(text)
+		// Events complete so far; the rest of a line waits for its text.
+		if i := bytes.LastIndexByte(x.held, '\n'); i >= 0 {
+			release, x.held = x.held[:i+1], x.held[i+1:]
+		}
+	}
+	if end {
+		release, x.held = append(release, x.held...), nil
+		x.endStream()
+	}
+	if g.cfg.Stream.Passthrough {
+		x.held = nil
+		return nil
+	}
+	return &extproc.BodyMutation{Body: release}
+}
+
+// allowStream checks a window of a streamed answer. It reports whether the
+// stream may go on; on a denial resp is the platform's answer, or nil when
+// the check itself failed.
+func (x *processed) allowStream(window string) (*guardrails.Response, bool) {
+	var opts []guardrails.CheckOption
+	if x.user != "" {
+		opts = append(opts, guardrails.WithUserID(x.user))
+	}
+	resp, err := x.pol.guard.CheckResponseCtx(x.ctx, lastUser(x.messages), window, opts...)
+	if err != nil {
+		x.g.log(x.r, slog.LevelError, "stream check failed", "err", err)
+		return nil, x.g.cfg.FailOpen
+	}
+	return resp, x.g.shadowDecision(x.r, x.pol, x.user)(resp)
+}
+
+// endStream counts the usage of a streamed answer.
+func (x *processed) endStream() {
+	rec := x.rec
+	rec.Answer = x.m.answer()
+	rec.PromptTokens, rec.CompletionTokens = x.m.tokens(x.messages)
+	rec.Cost = x.g.cost(rec.PromptTokens, rec.CompletionTokens, rec.Model, rec.Model)
+	x.g.usage.record(x.subjects, rec.PromptTokens, rec.CompletionTokens, rec.Cost)
+	x.g.rates.record(context.WithoutCancel(x.ctx), x.subjects, rec.PromptTokens+rec.CompletionTokens)
+}
+
+// finish logs and archives the exchange once Envoy is done with it.
+func (x *processed) finish() {
+	if x.rec == nil {
+		return
+	}
+	x.rec.Status = x.status
+	x.rec.LatencyMS = time.Since(x.start).Milliseconds()
+	x.g.logExchange(x.r, x.pol, x.rec)
+	if x.g.cfg.Archiver != nil {
+		x.g.cfg.Archiver.Add(*x.rec)
+	}
+}
+
+// replyBuffer records what the proxy's handlers write, to be sent as an
+// Envoy local reply.
+type replyBuffer struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *replyBuffer) Header() http.Header { return b.header }
+
+func (b *replyBuffer) WriteHeader(code int) {
+	if b.status == 0 {
+		b.status = code
+	}
+}
+
+func (b *replyBuffer) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	return b.body.Write(p)
+}
+
+// reply is the local reply b recorded.
+func (b *replyBuffer) reply() *extproc.Response {
+	im := &extproc.ImmediateResponse{Status: b.status, Body: b.body.Bytes(), Details: "ogw_refused"}
+	for k, vs := range b.header {
+		if !hopHeaders[k] {
+			for _, v := range vs {
+				im.Headers = append(im.Headers, extproc.Header{Key: strings.ToLower(k), Value: v})
+			}
+		}
+	}
+	return &extproc.Response{Immediate: im}
+}
+
+// localReply is a local reply with an error in the OpenAI API's shape.
+func localReply(status int, code, msg string) *extproc.Response {
+	rb := &replyBuffer{header: http.Header{}}
+	openAIError(rb, status, code, msg)
+	return rb.reply()
+}
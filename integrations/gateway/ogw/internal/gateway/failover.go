@@ -0,0 +1,253 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fallback is a backend a route fails over to.
+type Fallback struct {
+	Backend string `yaml:"backend"`
+	// RewriteModel, if set, is the model name sent to this backend; by
+	// default the requested one.
+	RewriteModel string `yaml:"rewrite_model"`
+
+	backend *Backend
+}
+
+// FailoverConfig tunes when a route's fallbacks are tried.
+type FailoverConfig struct {
+	// AttemptTimeout bounds the wait for a backend's response headers
+	// before the next backend is tried (default: only Timeout applies).
+	AttemptTimeout time.Duration `yaml:"attempt_timeout"`
+	// UnhealthyAfter consecutive failures put a backend last in line for
+	// Cooldown, after which it is tried again (defaults 3 and 30s).
+	UnhealthyAfter int           `yaml:"unhealthy_after"`
+	Cooldown       time.Duration `yaml:"cooldown"`
+}
+
+// errAttemptTimeout cancels an attempt that outlived AttemptTimeout.
+var errAttemptTimeout = errors.New("backend did not answer in time")
+
+// attempt is one backend a request may be sent to, and the model name it
+// gets there.
+type attempt struct {
+	backend *Backend
+	model   string
+}
+
+// attempts lists where a request for requested may go under rt: the
+// route's backend, then its fallbacks. Backends in cooldown go last.
+func (g *Gateway) attempts(rt *Route, requested string) []attempt {
+	out := []attempt{{rt.backend, requested}}
+	if rt.RewriteModel != "" {
+		out[0].model = rt.RewriteModel
+	}
+	for _, fb := range rt.Fallbacks {
+		a := attempt{fb.backend, requested}
+		if fb.RewriteModel != "" {
+			a.model = fb.RewriteModel
+		}
+		out = append(out, a)
+	}
+	if len(out) > 1 {
+		sort.SliceStable(out, func(i, j int) bool {
+			return g.health.healthy(out[i].backend.Name) && !g.health.healthy(out[j].backend.Name)
+		})
+	}
+	return out
+}
+
+// forwardRoute sends body to the first backend of rt that answers. A
+// backend that cannot be reached, answers 429 or 5xx, or times out gives
+// way to the next; the last one's answer is returned as it is. A
+// non-streamed request that timed out may still be generating and billed,
+// so it is only retried elsewhere when the client marked it idempotent
+// with an Idempotency-Key header. served is the last backend tried and
+// failed lists the backends given up on.
+func (g *Gateway) forwardRoute(r *http.Request, rt *Route, requested string, body map[string]any, raw []byte, stream bool) (resp *http.Response, served attempt, failed []string, err error) {
+	list := g.attempts(rt, requested)
+	for i, a := range list {
+		last := i == len(list)-1
+		payload := raw
+		if a.model != requested {
+			body["model"] = a.model
+			payload, _ = json.Marshal(body)
+		}
+		if i > 0 {
+			g.health.failover(list[i-1].backend.Name)
+			g.log(r, slog.LevelWarn, "failing over", "from", list[i-1].backend.Name, "to", a.backend.Name)
+		}
+		resp, err = g.attempt(r, a, payload)
+		switch {
+		case err != nil:
+			g.health.record(a.backend.Name, false, a.backend != rt.backend)
+			if r.Context().Err() != nil || last {
+				return nil, a, failed, err
+			}
+			if errors.Is(err, errAttemptTimeout) && !stream && r.Header.Get("Idempotency-Key") == "" {
+				return nil, a, failed, err
+			}
+			g.log(r, slog.LevelWarn, "backend failed", "backend", a.backend.Name, "err", err)
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5:
+			g.health.record(a.backend.Name, false, a.backend != rt.backend)
+			if last {
+				return resp, a, failed, nil
+			}
+			g.log(r, slog.LevelWarn, "backend failed", "backend", a.backend.Name, "status", resp.StatusCode)
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		default:
+			g.health.record(a.backend.Name, true, a.backend != rt.backend)
+			return resp, a, failed, nil
+		}
+		failed = append(failed, a.backend.Name)
+	}
+	panic("unreachable")
+}
+
+// attempt forwards to one backend, giving up on it after AttemptTimeout
+// without response headers.
+func (g *Gateway) attempt(r *http.Request, a attempt, body []byte) (*http.Response, error) {
+	d := g.cfg.Failover.AttemptTimeout
+	if d <= 0 {
+		return g.forward(r, a.backend, a.model, body)
+	}
+	ctx, cancel := context.WithCancelCause(r.Context())
+	timer := time.AfterFunc(d, func() { cancel(errAttemptTimeout) })
+	resp, err := g.forward(r.WithContext(ctx), a.backend, a.model, body)
+	stopped := timer.Stop()
+	if err == nil && !stopped {
+		// Headers arrived as the timer fired; the body is unusable.
+		resp.Body.Close()
+		err = errAttemptTimeout
+	}
+	if err != nil {
+		cancel(nil)
+		if errors.Is(context.Cause(ctx), errAttemptTimeout) || isTimeout(err) {
+			return nil, fmt.Errorf("%w (%v)", errAttemptTimeout, d)
+		}
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{resp.Body, func() { cancel(nil) }}
+	return resp, nil
+}
+
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel func()
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// BackendHealth is what the gateway has seen of one backend.
+type BackendHealth struct {
+	Requests int64 `json:"requests"`
+	Failures int64 `json:"failures"`
+	// Failovers counts requests moved from this backend to the next,
+	// Fallback requests it got as another route's fallback.
+	Failovers int64 `json:"failovers"`
+	Fallback  int64 `json:"fallback"`
+	// Consecutive failures; at FailoverConfig.UnhealthyAfter the backend
+	// is put last until UnhealthyUntil.
+	Consecutive    int       `json:"consecutive_failures"`
+	UnhealthyUntil time.Time `json:"-"`
+}
+
+// health tracks backends by name, so it can be carried across reloads.
+type health struct {
+	mu       sync.Mutex
+	backends map[string]*BackendHealth
+	after    int
+	cooldown time.Duration
+	now      func() time.Time
+}
+
+func newHealth(cfg FailoverConfig) *health {
+	if cfg.UnhealthyAfter <= 0 {
+		cfg.UnhealthyAfter = 3
+	}
+	if cfg.Cooldown <= 0 {
+		cfg.Cooldown = 30 * time.Second
+	}
+	return &health{backends: map[string]*BackendHealth{}, after: cfg.UnhealthyAfter, cooldown: cfg.Cooldown, now: time.Now}
+}
+
+func (h *health) get(name string) *BackendHealth {
+	b := h.backends[name]
+	if b == nil {
+		b = &BackendHealth{}
+		h.backends[name] = b
+	}
+	return b
+}
+
+func (h *health) healthy(name string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	b := h.backends[name]
+	return b == nil || !h.now().Before(b.UnhealthyUntil)
+}
+
+func (h *health) record(name string, ok, fallback bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	b := h.get(name)
+	b.Requests++
+	if fallback {
+		b.Fallback++
+	}
+	if ok {
+		b.Consecutive, b.UnhealthyUntil = 0, time.Time{}
+		return
+	}
+	b.Failures++
+	b.Consecutive++
+	if b.Consecutive >= h.after {
+		b.UnhealthyUntil = h.now().Add(h.cooldown)
+	}
+}
+
+func (h *health) failover(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.get(name).Failovers++
+}
+
+// adopt takes over what old has seen.
+func (h *health) adopt(old *health) {
+	old.mu.Lock()
+	defer old.mu.Unlock()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for name, b := range old.backends {
+		c := *b
+		h.backends[name] = &c
+	}
+}
+
+// snapshot returns a copy of name's health and whether it is healthy.
+func (h *health) snapshot(name string) (BackendHealth, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	b := h.get(name)
+	return *b, !h.now().Before(b.UnhealthyUntil)
+}
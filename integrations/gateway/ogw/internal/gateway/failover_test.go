@@ -0,0 +1,116 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go/guardrailstest"
+)
+
+func TestFailover(t *testing.T) {
+	var failing atomic.Int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failing.Add(1)
+		http.Error(w, `{"error":{"message":"overloaded"}}`, http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(primary.Close)
+	spare, spareSeen := recording(t)
+	gone := httptest.NewServer(http.NotFoundHandler())
+	gone.Close()
+
+	det := guardrailstest.NewServer()
+	t.Cleanup(det.Close)
+	cfg := Config{
+		Backends: []Backend{
+			{Name: "primary", URL: primary.URL + "/v1"},
+			{Name: "gone", URL: gone.URL + "/v1"},
+			{Name: "spare", URL: spare.URL + "/v1"},
+		},
+		Routes: []Route{
+			{Model: "gpt-4o", Backend: "primary", Fallbacks: []Fallback{{Backend: "gone"}, {Backend: "spare", RewriteModel: "llama3.1:70b"}}},
+			{Model: "solo", Backend: "primary"},
+		},
+		Failover: FailoverConfig{UnhealthyAfter: 2, Cooldown: time.Hour},
+	}
+	srv, err := NewServer(func() (*Gateway, error) { return New(cfg, det.Client(), nil) }, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w := do(srv, "POST", "/v1/chat/completions", "", chatModel("gpt-4o")); w.Code != 200 {
+		t.Fatalf("failover: %d %s", w.Code, w.Body)
+	}
+	if got := spareSeen(); len(got) != 1 || got[0].Model != "llama3.1:70b" {
+		t.Fatalf("spare: %+v", got)
+	}
+	// Without fallbacks the backend's error is relayed.
+	if w := do(srv, "POST", "/v1/chat/completions", "", chatModel("solo")); w.Code != 503 || !strings.Contains(w.Body.String(), "overloaded") {
+		t.Fatalf("solo: %d %s", w.Code, w.Body)
+	}
+	// Two failures in a row: primary and gone now go last.
+	if w := do(srv, "POST", "/v1/chat/completions", "", chatModel("gpt-4o")); w.Code != 200 || failing.Load() != 2 {
+		t.Fatalf("unhealthy primary tried first: %d, %d calls", w.Code, failing.Load())
+	}
+
+	var report struct {
+		Backends []struct {
+			Name    string        `json:"name"`
+			Healthy bool          `json:"healthy"`
+			Health  BackendHealth `json:"health"`
+		} `json:"backends"`
+	}
+	json.Unmarshal(do(srv.AdminHandler([]string{"adm"}), "GET", "/admin/backends", "adm", "").Body.Bytes(), &report)
+	if b := report.Backends[0]; b.Healthy || b.Health.Requests != 2 || b.Health.Failovers != 1 || b.Health.Consecutive != 2 {
+		t.Fatalf("primary: %+v", b)
+	}
+	if b := report.Backends[2]; !b.Healthy || b.Health.Requests != 2 || b.Health.Fallback != 2 {
+		t.Fatalf("spare: %+v", b)
+	}
+
+	// Health carries across reloads.
+	if err := srv.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if srv.Gateway().health.healthy("primary") {
+		t.Fatal("health lost on reload")
+	}
+}
+
+func TestFailoverTimeout(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	t.Cleanup(slow.Close)
+	t.Cleanup(func() { close(release) })
+	h, _ := newGateway(t, Config{
+		Backends: []Backend{{Name: "slow", URL: slow.URL + "/v1"}, {Name: "echo", URL: backend(t).URL + "/v1", APIKey: "sk-upstream"}},
+		Routes:   []Route{{Model: "*", Backend: "slow", Fallbacks: []Fallback{{Backend: "echo"}}}},
+		Failover: FailoverConfig{AttemptTimeout: 50 * time.Millisecond},
+	})
+
+	// The slow backend may still be generating: not retried unless the
+	// client says it is safe.
+	if w := do(h, "POST", "/v1/chat/completions", "", chat("hi", false)); w.Code != http.StatusGatewayTimeout || !strings.Contains(w.Body.String(), "upstream_timeout") {
+		t.Fatalf("no idempotency key: %d %s", w.Code, w.Body)
+	}
+	r := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(chat("hi", false)))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Idempotency-Key", "req-1")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if text, _ := content(t, w); text != "echo: hi" {
+		t.Fatalf("idempotent: %q", text)
+	}
+	if w := do(h, "POST", "/v1/chat/completions", "", chat("hi", true)); w.Code != 200 || !strings.Contains(w.Body.String(), "[DONE]") {
+		t.Fatalf("stream: %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,579 @@
+// Package gateway is the request pipeline of ogw, the OpenGuardrails
+// security gateway: an OpenAI-compatible reverse proxy that checks every
+// prompt before it reaches the model and every answer before it reaches the
+// client.
+//
+//	client ──▶ ogw ──check──▶ backend ──check──▶ client
+//	            │                 ▲
+//	            └── detection API ┘
+//
+// Requests are routed by model to one of several named backends; see
+// Backend and Route.
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go"
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/archive"
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/oidc"
+)
+
+// Config is the gateway's configuration.
+type Config struct {
+	// Backends are the model providers requests can be routed to.
+	Backends []Backend `yaml:"backends"`
+	// ExtProc, if set, also serves Envoy's external processing API; with
+	// it, Backends may be empty.
+	ExtProc *ExtProc `yaml:"ext_proc"`
+	// ExtAuthz, if set, also serves Envoy's external authorization API;
+	// with it, Backends may be empty.
+	ExtAuthz *ExtAuthz `yaml:"ext_authz"`
+	// Routes map models to backends; the first matching route wins. Empty
+	// sends everything to the first backend.
+	Routes []Route `yaml:"routes"`
+	// APIKeys are the keys clients must present as bearer tokens. Empty
+	// leaves the gateway open, which is only sensible on a private network.
+	APIKeys []string `yaml:"api_keys"`
+	// OIDC, if set, also admits clients with JWTs from an OpenID Connect
+	// provider.
+	OIDC *OIDC `yaml:"oidc"`
+	// Policies give further client keys their own detection key, verdict
+	// rules and model allowlist.
+	Policies []Policy `yaml:"policies"`
+	// Sensitivity, Categories and DenyMessage are the default policy's
+	// verdict rules, as a Policy's are.
+	Sensitivity string            `yaml:"sensitivity"`
+	Categories  map[string]string `yaml:"categories"`
+	DenyMessage string            `yaml:"deny_message"`
+	// FailOpen forwards traffic while the detection API is unreachable;
+	// by default such requests are refused with 503.
+	FailOpen bool `yaml:"fail_open"`
+	// MaxBody bounds request bodies (default guardrails.DefaultMaxBody).
+	MaxBody int64 `yaml:"max_body"`
+	// Timeout bounds one backend call, generation included (default 5m).
+	Timeout time.Duration `yaml:"timeout"`
+	// Cache, if set, answers repeated identical requests from memory.
+	Cache *CacheConfig `yaml:"cache"`
+	// Failover tunes how routes fall back to other backends.
+	Failover FailoverConfig `yaml:"failover"`
+	// Stream configures moderation of streamed answers.
+	Stream StreamConfig `yaml:"stream"`
+	// Quota and UserQuota bound each key of the default policy and each
+	// end user under it; policies set their own.
+	Quota     Quota `yaml:"quota"`
+	UserQuota Quota `yaml:"user_quota"`
+	// RateLimit, if set, shares the per-minute rates of quotas between
+	// instances through Redis.
+	RateLimit *RateLimit `yaml:"rate_limit"`
+	// Prices turn token usage into cost; the first entry matching the
+	// model wins.
+	Prices []Price `yaml:"prices"`
+	// Logging sets the log level and format and how much of prompts,
+	// answers and user names the logs carry.
+	Logging Logging `yaml:"logging"`
+	// Shadow is a candidate verdict configuration for the default policy.
+	Shadow *Shadow `yaml:"shadow"`
+	// Archiver, if set, receives a record of every exchange that passed
+	// authentication, routing and quotas.
+	Archiver interface{ Add(archive.Record) } `yaml:"-"`
+}
+
+// StreamConfig tunes how streamed answers are moderated: the accumulated
+// text is checked every CheckEvery tokens, each check seeing the last
+// Window tokens.
+type StreamConfig struct {
+	CheckEvery int `yaml:"check_every" json:"check_every"` // default 50
+	Window     int `yaml:"window" json:"window"`           // default 400
+	// Passthrough forwards chunks as they arrive and checks in the
+	// background, cutting the stream off after a reject. By default chunks
+	// are held until the text they carry has been checked, so nothing
+	// unchecked reaches the client.
+	Passthrough bool `yaml:"passthrough" json:"passthrough"`
+}
+
+// Gateway serves the guarded API.
+type Gateway struct {
+	cfg      Config
+	guard    *guardrails.Client
+	http     *http.Client
+	logger   *slog.Logger
+	list     modelList
+	keys     []*clientKey
+	dflt     *clientKey
+	usage    *usage
+	rates    *rates
+	health   *health
+	cache    *responseCache
+	shadows  shadowReport
+	verdicts verdictLog
+	life     *lifecycle
+	// verifier checks client JWTs when Config.OIDC is set.
+	verifier *oidc.Verifier
+}
+
+// New validates cfg and returns a Gateway that checks traffic with guard.
+func New(cfg Config, guard *guardrails.Client, logger *slog.Logger) (*Gateway, error) {
+	cfg.Backends = append([]Backend(nil), cfg.Backends...)
+	cfg.Routes = append([]Route(nil), cfg.Routes...)
+	cfg.Policies = append([]Policy(nil), cfg.Policies...)
+	if err := initRoutes(&cfg); err != nil {
+		return nil, fmt.Errorf("gateway: %w", err)
+	}
+	if err := initPrices(cfg.Prices); err != nil {
+		return nil, fmt.Errorf("gateway: %w", err)
+	}
+	if cfg.MaxBody <= 0 {
+		cfg.MaxBody = guardrails.DefaultMaxBody
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Minute
+	}
+	if cfg.Stream.CheckEvery <= 0 {
+		cfg.Stream.CheckEvery = 50
+	}
+	if cfg.Stream.Window <= 0 {
+		cfg.Stream.Window = 400
+	}
+	g := &Gateway{
+		cfg:    cfg,
+		guard:  guard,
+		http:   &http.Client{Timeout: cfg.Timeout},
+		logger: logger,
+		usage:  newUsage(),
+		health: newHealth(cfg.Failover),
+		life:   newLifecycle(),
+	}
+	g.shadows.inflight = make(chan struct{}, shadowInflight)
+	if cfg.Cache != nil {
+		g.cache = newResponseCache(*cfg.Cache)
+	}
+	rates, err := newRates(cfg.RateLimit, logger)
+	if err != nil {
+		return nil, fmt.Errorf("gateway: %w", err)
+	}
+	g.rates = rates
+	if err := g.initLogging(); err != nil {
+		return nil, fmt.Errorf("gateway: %w", err)
+	}
+	if err := g.initOIDC(); err != nil {
+		return nil, fmt.Errorf("gateway: %w", err)
+	}
+	if err := g.initPolicies(); err != nil {
+		return nil, fmt.Errorf("gateway: %w", err)
+	}
+	return g, nil
+}
+
+// Handler returns the gateway's routes.
+func (g *Gateway) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		if g.life.draining.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			io.WriteString(w, "draining")
+			return
+		}
+		io.WriteString(w, "ok")
+	})
+	mux.Handle("POST /v1/chat/completions", g.authenticate(http.HandlerFunc(g.guarded)))
+	mux.Handle("POST /v1/completions", g.authenticate(http.HandlerFunc(g.guarded)))
+	mux.Handle("GET /v1/models", g.authenticate(http.HandlerFunc(g.models)))
+	mux.Handle("GET /v1/models/{model...}", g.authenticate(http.HandlerFunc(g.model)))
+	mux.Handle("GET /v1/realtime", g.authenticate(http.HandlerFunc(g.realtime)))
+	mux.Handle("POST /v1/moderations", g.authenticate(http.HandlerFunc(g.moderations)))
+	return mux
+}
+
+// guarded checks the prompt, forwards it, and checks the answer.
+func (g *Gateway) guarded(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	raw, body, ok := g.readBody(w, r)
+	if !ok {
+		return
+	}
+	pol := g.policy(r)
+	model, _ := body["model"].(string)
+	if !pol.allowsModel(model) {
+		openAIError(w, http.StatusForbidden, "model_not_allowed", fmt.Sprintf("This API key may not use the model %q.", model))
+		return
+	}
+	rt, ok := g.route(model)
+	if !ok {
+		openAIError(w, http.StatusNotFound, "model_not_found", fmt.Sprintf("The model %q is not served by this gateway.", model))
+		return
+	}
+	// Nothing is forwarded unchecked: a body with no text the detection
+	// API can see is refused, whatever else it carries.
+	messages := guardrails.OpenAIRequest(body)
+	if len(messages) == 0 {
+		openAIError(w, http.StatusBadRequest, "invalid_request_error", "The request has no text to check: send messages, or a prompt string or array of strings.")
+		return
+	}
+	user, _ := body["user"].(string)
+	if u := g.client(r).user; u != "" {
+		user = u
+	}
+	subjects, ok := g.admit(w, r, pol, user)
+	if !ok {
+		return
+	}
+	stream, _ := body["stream"].(bool)
+	rec := &archive.Record{
+		Time: start.UTC(), Policy: pol.Name, Key: g.client(r).id, User: user, Endpoint: r.URL.Path,
+		Model: model, Backend: rt.backend.Name, Stream: stream, Messages: messages,
+	}
+	sw := &statusWriter{ResponseWriter: w}
+	w = sw
+	defer func() {
+		rec.Status = sw.status
+		rec.LatencyMS = time.Since(start).Milliseconds()
+		g.logExchange(r, pol, rec)
+		if g.cfg.Archiver != nil {
+			g.cfg.Archiver.Add(*rec)
+		}
+	}()
+	resp, ok := g.check(r, pol, "input", messages, user)
+	rec.Input = archive.NewVerdict(resp)
+	if !ok {
+		rec.Blocked = "input"
+		deny(w, r, resp, stream)
+		g.log(r, slog.LevelWarn, "input blocked", append(verdictAttrs(resp), g.redacted(pol, LogFieldPrompt, lastUser(messages)))...)
+		return
+	}
+	// The key leaves out the user, so the prompt is checked under this
+	// request's user before a hit: the platform's bans and risk tracking
+	// apply to everyone served. Only answers that passed their check are
+	// cached, so a hit skips that one.
+	var ck string
+	if g.cache != nil && !stream {
+		ck = cacheKey(pol, body)
+		if e, ok := g.cache.get(ck); ok {
+			rec.Cached, rec.Backend, rec.Answer = true, "", e.answer
+			copyHeader(w.Header(), e.header)
+			w.Header().Set("X-OGW-Cache", "hit")
+			w.WriteHeader(http.StatusOK)
+			w.Write(e.body)
+			return
+		}
+	}
+
+	upstream, served, failed, err := g.forwardRoute(r, rt, model, body, raw, stream)
+	rec.Backend, rec.Failed = served.backend.Name, failed
+	if err != nil {
+		g.log(r, slog.LevelError, "backend failed", "backend", served.backend.Name, "err", err)
+		if errors.Is(err, errAttemptTimeout) {
+			openAIError(w, http.StatusGatewayTimeout, "upstream_timeout", "The model backend did not answer in time.")
+			return
+		}
+		openAIError(w, http.StatusBadGateway, "upstream_error", "The model backend is unreachable.")
+		return
+	}
+	defer upstream.Body.Close()
+
+	if stream {
+		m := newMeter(upstream.Body)
+		upstream.Body = m
+		if resp := g.stream(w, r, pol, upstream, messages, user); resp != nil {
+			rec.Blocked, rec.Output = "stream", archive.NewVerdict(resp)
+		}
+		rec.Answer = m.answer()
+		if upstream.StatusCode/100 == 2 {
+			rec.PromptTokens, rec.CompletionTokens = m.tokens(messages)
+			rec.Cost = g.cost(rec.PromptTokens, rec.CompletionTokens, served.model, model)
+			g.usage.record(subjects, rec.PromptTokens, rec.CompletionTokens, rec.Cost)
+			g.rates.record(context.WithoutCancel(r.Context()), subjects, rec.PromptTokens+rec.CompletionTokens)
+		}
+		return
+	}
+	out, err := io.ReadAll(upstream.Body)
+	if err != nil {
+		g.log(r, slog.LevelError, "backend response cut off", "backend", served.backend.Name, "err", err)
+		openAIError(w, http.StatusBadGateway, "upstream_error", "The model backend response was cut off.")
+		return
+	}
+	if upstream.StatusCode/100 == 2 {
+		rec.PromptTokens, rec.CompletionTokens = completionTokens(out, messages)
+		rec.Cost = g.cost(rec.PromptTokens, rec.CompletionTokens, served.model, model)
+		g.usage.record(subjects, rec.PromptTokens, rec.CompletionTokens, rec.Cost)
+		g.rates.record(context.WithoutCancel(r.Context()), subjects, rec.PromptTokens+rec.CompletionTokens)
+	}
+	if upstream.StatusCode/100 == 2 {
+		var completion map[string]any
+		if json.Unmarshal(out, &completion) == nil {
+			if text := guardrails.OpenAIResponse(completion); text != "" {
+				rec.Answer = text
+				conv := append(messages, guardrails.Message{Role: "assistant", Content: text})
+				resp, ok := g.check(r, pol, "output", conv, user)
+				rec.Output = archive.NewVerdict(resp)
+				if !ok {
+					rec.Blocked = "output"
+					guardrails.OpenAIDeny(w, r, resp)
+					g.log(r, slog.LevelWarn, "output blocked", append(verdictAttrs(resp), g.redacted(pol, LogFieldResponse, text))...)
+					return
+				}
+			}
+		}
+	}
+	copyHeader(w.Header(), upstream.Header)
+	if ck != "" && upstream.StatusCode == http.StatusOK && rec.Answer != "" {
+		e := &cached{key: ck, header: http.Header{}, body: out, answer: rec.Answer}
+		copyHeader(e.header, upstream.Header)
+		g.cache.put(e)
+		w.Header().Set("X-OGW-Cache", "miss")
+	}
+	w.WriteHeader(upstream.StatusCode)
+	w.Write(out)
+}
+
+// readBody reads and decodes the JSON body of a guarded request, answering
+// w itself when the body is not acceptable.
+func (g *Gateway) readBody(w http.ResponseWriter, r *http.Request) ([]byte, map[string]any, bool) {
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != "application/json" {
+		// Anything else would reach the backend without being inspected.
+		openAIError(w, http.StatusUnsupportedMediaType, "invalid_request_error", "Content-Type must be application/json.")
+		return nil, nil, false
+	}
+	raw, err := io.ReadAll(io.LimitReader(r.Body, g.cfg.MaxBody+1))
+	if err != nil {
+		openAIError(w, http.StatusBadRequest, "invalid_request_error", "Cannot read request body.")
+		return nil, nil, false
+	}
+	if int64(len(raw)) > g.cfg.MaxBody {
+		openAIError(w, http.StatusRequestEntityTooLarge, "invalid_request_error", "Request body too large.")
+		return nil, nil, false
+	}
+	// Numbers stay json.Number so a rewritten body keeps them exact.
+	var body map[string]any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&body); err != nil {
+		openAIError(w, http.StatusBadRequest, "invalid_request_error", "Request body is not valid JSON.")
+		return nil, nil, false
+	}
+	return raw, body, true
+}
+
+// admit counts a request by user against the rates and quotas of its key
+// and user, and returns the subjects it counted against. When one is used
+// up it answers w with 429.
+func (g *Gateway) admit(w http.ResponseWriter, r *http.Request, pol *Policy, user string) ([]subject, bool) {
+	subjects := g.subjects(r, user)
+	if !g.admitRate(w, r, subjects) {
+		return nil, false
+	}
+	if reset, ok := g.usage.admit(subjects); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(reset).Seconds())+1))
+		openAIError(w, http.StatusTooManyRequests, "insufficient_quota", "The token or request quota of this API key or user is used up.")
+		g.log(r, slog.LevelWarn, "quota exceeded", g.redacted(pol, LogFieldUser, user))
+		return nil, false
+	}
+	return subjects, true
+}
+
+// stream relays a streamed answer through the guard and returns the
+// verdict that cut it off, if any. Backend errors are relayed as they are.
+func (g *Gateway) stream(w http.ResponseWriter, r *http.Request, pol *Policy, upstream *http.Response, messages []guardrails.Message, user string) *guardrails.Response {
+	mt, _, _ := mime.ParseMediaType(upstream.Header.Get("Content-Type"))
+	if upstream.StatusCode/100 != 2 || mt != "text/event-stream" {
+		g.relay(w, upstream.StatusCode, upstream.Header, upstream.Body)
+		return nil
+	}
+	opts := []guardrails.StreamOption{
+		guardrails.WithCheckEvery(g.cfg.Stream.CheckEvery),
+		guardrails.WithWindow(g.cfg.Stream.Window),
+		guardrails.WithStreamDecision(g.shadowDecision(r, pol, user)),
+	}
+	if g.cfg.Stream.Passthrough {
+		opts = append(opts, guardrails.WithStreamPassthrough())
+	}
+	if g.cfg.FailOpen {
+		opts = append(opts, guardrails.WithStreamFailOpen())
+	}
+	if user != "" {
+		opts = append(opts, guardrails.WithStreamCheckOptions(guardrails.WithUserID(user)))
+	}
+	guarded := pol.guard.GuardStream(r.Context(), lastUser(messages), upstream.Body, opts...)
+	defer guarded.Close()
+	g.relay(w, upstream.StatusCode, upstream.Header, guarded)
+	if err := guarded.Err(); err != nil {
+		g.log(r, slog.LevelError, "stream check failed", "err", err)
+	}
+	resp := guarded.Blocked()
+	if resp != nil {
+		g.log(r, slog.LevelWarn, "stream cut off", verdictAttrs(resp)...)
+	}
+	return resp
+}
+
+// completionTokens returns the usage a completion reports, or an estimate
+// when it reports none.
+func completionTokens(out []byte, messages []guardrails.Message) (int64, int64) {
+	var completion struct {
+		Usage *tokenUsage `json:"usage"`
+	}
+	if json.Unmarshal(out, &completion) == nil && completion.Usage != nil {
+		return completion.Usage.PromptTokens, completion.Usage.CompletionTokens
+	}
+	var body map[string]any
+	json.Unmarshal(out, &body)
+	return estimateMessages(messages), estimate(guardrails.OpenAIResponse(body))
+}
+
+// lastUser is the prompt a streamed answer is checked against.
+func lastUser(messages []guardrails.Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messages[i].Content
+		}
+	}
+	return messages[len(messages)-1].Content
+}
+
+// deny answers a blocked exchange in the shape the client asked for: a chat
+// completion, or for streams a single content_filter chunk and [DONE].
+func deny(w http.ResponseWriter, r *http.Request, resp *guardrails.Response, stream bool) {
+	if !stream || resp == nil {
+		guardrails.OpenAIDeny(w, r, resp)
+		return
+	}
+	answer := resp.SuggestAnswer
+	if answer == "" {
+		answer = guardrails.DefaultRefusal
+	}
+	chunk, _ := json.Marshal(map[string]any{
+		"id":      "chatcmpl-" + resp.ID,
+		"object":  "chat.completion.chunk",
+		"created": time.Now().Unix(),
+		"model":   "openguardrails",
+		"choices": []map[string]any{{
+			"index":         0,
+			"delta":         map[string]string{"role": "assistant", "content": answer},
+			"finish_reason": "content_filter",
+		}},
+	})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	fmt.Fprintf(w, "data: %s\n\ndata: [DONE]\n\n", chunk)
+}
+
+// check asks the detection API about messages under pol at stage (input or
+// output). It reports whether the exchange may continue; on a denial resp
+// is the platform's answer, or nil when the check itself failed.
+func (g *Gateway) check(r *http.Request, pol *Policy, stage string, messages []guardrails.Message, user string) (*guardrails.Response, bool) {
+	var opts []guardrails.CheckOption
+	if user != "" {
+		opts = append(opts, guardrails.WithUserID(user))
+	}
+	start := time.Now()
+	resp, err := pol.guard.CheckConversation(r.Context(), messages, opts...)
+	e := VerdictEntry{Time: start.UTC(), Policy: pol.Name, Key: g.client(r).id, User: user, Stage: stage, LatencyMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		g.log(r, slog.LevelError, "check failed", "stage", stage, "err", err)
+		e.Allowed, e.Error = g.cfg.FailOpen, err.Error()
+		g.verdicts.add(e)
+		return nil, g.cfg.FailOpen
+	}
+	ok := pol.decide(resp)
+	e.Allowed, e.ID, e.Action, e.RiskLevel, e.Categories = ok, resp.ID, resp.SuggestAction, string(resp.OverallRiskLevel), resp.Categories()
+	g.verdicts.add(e)
+	g.shadowCheck(r, pol, stage, messages, user, resp, ok)
+	return resp, ok
+}
+
+// forward sends the request to backend b under the backend's own
+// credentials; the client's key never leaves the gateway.
+func (g *Gateway) forward(r *http.Request, b *Backend, model string, body []byte) (*http.Response, error) {
+	endpoint := strings.TrimPrefix(r.URL.Path, "/v1")
+	req, err := b.newRequest(r.Context(), r.Method, endpoint, model, r.URL.Query(), body)
+	if err != nil {
+		return nil, err
+	}
+	headers := []string{"Content-Type", "Accept", "Idempotency-Key"}
+	if b.Type == BackendOpenAI {
+		headers = append(headers, "OpenAI-Organization", "OpenAI-Project")
+	}
+	for _, h := range headers {
+		if v := r.Header.Get(h); v != "" {
+			req.Header.Set(h, v)
+		}
+	}
+	return g.http.Do(req)
+}
+
+// relay copies a response body to the client as it arrives.
+func (g *Gateway) relay(w http.ResponseWriter, status int, header http.Header, body io.Reader) {
+	copyHeader(w.Header(), header)
+	w.WriteHeader(status)
+	rc := http.NewResponseController(w)
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return
+			}
+			rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// hopHeaders are connection-level headers a proxy must not copy, plus
+// Content-Length, which the server recomputes.
+var hopHeaders = map[string]bool{
+	"Connection": true, "Keep-Alive": true, "Proxy-Authenticate": true, "Proxy-Authorization": true,
+	"Te": true, "Trailer": true, "Transfer-Encoding": true, "Upgrade": true, "Content-Length": true,
+}
+
+func copyHeader(dst, src http.Header) {
+	for k, v := range src {
+		if !hopHeaders[k] {
+			dst[k] = v
+		}
+	}
+}
+
+// statusWriter remembers the status sent, for the log and the archive.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusWriter) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusWriter) Write(p []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(p)
+}
+
+func (s *statusWriter) Unwrap() http.ResponseWriter { return s.ResponseWriter }
+
+// openAIError writes an error in the OpenAI API's shape.
+func openAIError(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{"error": map[string]any{
+		"message": msg, "type": code, "code": code,
+	}})
+}
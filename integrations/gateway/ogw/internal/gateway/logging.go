@@ -0,0 +1,171 @@
+package gateway
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/openguardrails/openguardrails-go"
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/archive"
+)
+
+// Redaction modes: how a content field reaches the logs.
+const (
+	LogOmit     = "omit"     // left out
+	LogHash     = "hash"     // sha256:<first 16 hex digits>, to correlate without reading
+	LogTruncate = "truncate" // the first Logging.Truncate characters
+	LogFull     = "full"     // as it was
+)
+
+// Content fields the logs can carry.
+const (
+	LogFieldPrompt   = "prompt"   // the last user message
+	LogFieldResponse = "response" // the answer's text
+	LogFieldUser     = "user"     // the end user the request names
+)
+
+// logDefaults keep content out of the logs unless configured otherwise.
+var logDefaults = map[string]string{
+	LogFieldPrompt:   LogOmit,
+	LogFieldResponse: LogOmit,
+	LogFieldUser:     LogFull,
+}
+
+// Logging configures the gateway's logs. Level and Format are read by ogw
+// at startup; Fields and Truncate take effect on reload.
+type Logging struct {
+	Level  string `yaml:"level"`  // debug, info (default), warn or error
+	Format string `yaml:"format"` // text (default) or json
+	// Fields sets the redaction mode of each content field: prompt and
+	// response are omitted by default, user is logged in full. Policies
+	// override it with LogFields.
+	Fields map[string]string `yaml:"fields"`
+	// Truncate is how many characters the truncate mode keeps (default 64).
+	Truncate int `yaml:"truncate"`
+}
+
+// Handler returns the slog handler l describes, writing to w.
+func (l Logging) Handler(w io.Writer) (slog.Handler, error) {
+	var level slog.Level
+	if l.Level != "" {
+		if err := level.UnmarshalText([]byte(l.Level)); err != nil {
+			return nil, fmt.Errorf("logging: unknown level %q", l.Level)
+		}
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	switch l.Format {
+	case "", "text":
+		return slog.NewTextHandler(w, opts), nil
+	case "json":
+		return slog.NewJSONHandler(w, opts), nil
+	}
+	return nil, fmt.Errorf("logging: unknown format %q", l.Format)
+}
+
+// logFields merges overrides over base, validating both.
+func logFields(base, overrides map[string]string) (map[string]string, error) {
+	out := make(map[string]string, len(logDefaults))
+	for _, m := range []map[string]string{logDefaults, base, overrides} {
+		for field, mode := range m {
+			if _, ok := logDefaults[field]; !ok {
+				return nil, fmt.Errorf("unknown log field %q", field)
+			}
+			switch mode {
+			case LogOmit, LogHash, LogTruncate, LogFull:
+			default:
+				return nil, fmt.Errorf("log field %s: unknown mode %q", field, mode)
+			}
+			out[field] = mode
+		}
+	}
+	return out, nil
+}
+
+// initLogging validates the logging configuration; policies settle their
+// redaction in initPolicies.
+func (g *Gateway) initLogging() error {
+	if _, err := g.cfg.Logging.Handler(io.Discard); err != nil {
+		return err
+	}
+	if g.cfg.Logging.Truncate <= 0 {
+		g.cfg.Logging.Truncate = 64
+	}
+	return nil
+}
+
+// log writes a record about r at level, with the request's method, path,
+// policy and key.
+func (g *Gateway) log(r *http.Request, level slog.Level, msg string, args ...any) {
+	if g.logger == nil {
+		return
+	}
+	k := g.client(r)
+	g.logger.Log(r.Context(), level, msg, append([]any{"method", r.Method, "path", r.URL.Path, "policy", k.policy.Name, "key", k.id}, args...)...)
+}
+
+// redacted is text as field may be logged under pol. Omitted fields are
+// the empty Attr, which handlers drop.
+func (g *Gateway) redacted(pol *Policy, field, text string) slog.Attr {
+	if text == "" {
+		return slog.Attr{}
+	}
+	switch pol.logFields[field] {
+	case LogFull:
+		return slog.String(field, text)
+	case LogHash:
+		sum := sha256.Sum256([]byte(text))
+		return slog.String(field, "sha256:"+hex.EncodeToString(sum[:8]))
+	case LogTruncate:
+		if n := g.cfg.Logging.Truncate; utf8.RuneCountInString(text) > n {
+			text = string([]rune(text)[:n]) + "…"
+		}
+		return slog.String(field, text)
+	}
+	return slog.Attr{}
+}
+
+// verdictAttrs summarize a denial for the log.
+func verdictAttrs(resp *guardrails.Response) []any {
+	if resp == nil {
+		return []any{"verdict", "check failed"}
+	}
+	cats := make([]string, 0, len(resp.Categories()))
+	for _, c := range resp.Categories() {
+		cats = append(cats, string(c))
+	}
+	return []any{"check_id", resp.ID, "action", string(resp.SuggestAction), "risk_level", string(resp.OverallRiskLevel), "categories", strings.Join(cats, ",")}
+}
+
+// logExchange writes the info line of a finished exchange.
+func (g *Gateway) logExchange(r *http.Request, pol *Policy, rec *archive.Record) {
+	args := []any{
+		"model", rec.Model, "backend", rec.Backend, "status", rec.Status, "latency_ms", rec.LatencyMS,
+		"prompt_tokens", rec.PromptTokens, "completion_tokens", rec.CompletionTokens,
+	}
+	if rec.Stream {
+		args = append(args, "stream", true)
+	}
+	if rec.Cached {
+		args = append(args, "cached", true)
+	}
+	if rec.Blocked != "" {
+		args = append(args, "blocked", rec.Blocked)
+	}
+	if len(rec.Failed) > 0 {
+		args = append(args, "failed", strings.Join(rec.Failed, ","))
+	}
+	if rec.Cost > 0 {
+		args = append(args, "cost", rec.Cost)
+	}
+	prompt := ""
+	if len(rec.Messages) > 0 {
+		prompt = lastUser(rec.Messages)
+	}
+	args = append(args, g.redacted(pol, LogFieldUser, rec.User), g.redacted(pol, LogFieldPrompt, prompt), g.redacted(pol, LogFieldResponse, rec.Answer))
+	g.log(r, slog.LevelInfo, "exchange", args...)
+}
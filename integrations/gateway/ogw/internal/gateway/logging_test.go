@@ -0,0 +1,97 @@
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/openguardrails/openguardrails-go"
+	"github.com/openguardrails/openguardrails-go/guardrailstest"
+)
+
+func TestLoggingRedaction(t *testing.T) {
+	be := backend(t)
+	det := guardrailstest.NewServer()
+	t.Cleanup(det.Close)
+	det.Reject(`^leak`, guardrails.CategoryPrivacy)
+	var buf bytes.Buffer
+	gw, err := New(Config{
+		APIKeys: []string{"k1"},
+		Policies: []Policy{{
+			Name: "team-a", Keys: []string{"ka"},
+			LogFields: map[string]string{"prompt": "hash", "user": "omit"},
+		}},
+		Logging:  Logging{Fields: map[string]string{"prompt": "truncate", "response": "full"}, Truncate: 5},
+		Backends: []Backend{{Name: "b", URL: be.URL + "/v1", APIKey: "sk-upstream"}},
+	}, det.Client(), slog.New(slog.NewJSONHandler(&buf, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := gw.Handler()
+	lines := func() []map[string]any {
+		t.Helper()
+		var out []map[string]any
+		sc := bufio.NewScanner(&buf)
+		for sc.Scan() {
+			var m map[string]any
+			if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, m)
+		}
+		buf.Reset()
+		return out
+	}
+
+	do(h, "POST", "/v1/chat/completions", "k1", chat("hello world", false))
+	got := lines()
+	if len(got) != 1 {
+		t.Fatalf("lines %v", got)
+	}
+	if l := got[0]; l["msg"] != "exchange" || l["policy"] != "default" || l["status"] != 200.0 ||
+		l["prompt"] != "hello…" || l["response"] != "echo: hello world" || l["user"] != "u-7" {
+		t.Fatalf("default policy %v", l)
+	}
+
+	do(h, "POST", "/v1/chat/completions", "ka", chat("hello world", false))
+	l := lines()[0]
+	if p, _ := l["prompt"].(string); !strings.HasPrefix(p, "sha256:") || len(p) != len("sha256:")+16 {
+		t.Fatalf("hashed prompt %v", l)
+	}
+	if _, ok := l["user"]; ok {
+		t.Fatalf("user not omitted %v", l)
+	}
+
+	do(h, "POST", "/v1/chat/completions", "ka", chat("leak this", false))
+	got = lines()
+	if len(got) != 2 || got[0]["msg"] != "input blocked" || got[0]["level"] != "WARN" || got[1]["blocked"] != "input" {
+		t.Fatalf("blocked %v", got)
+	}
+	if strings.Contains(got[0]["prompt"].(string), "leak") || got[0]["categories"] != string(guardrails.CategoryPrivacy) {
+		t.Fatalf("blocked line %v", got[0])
+	}
+}
+
+func TestLoggingConfig(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cfg  Config
+	}{
+		{"level", Config{Logging: Logging{Level: "loud"}}},
+		{"format", Config{Logging: Logging{Format: "xml"}}},
+		{"field", Config{Logging: Logging{Fields: map[string]string{"answer": "full"}}}},
+		{"mode", Config{Logging: Logging{Fields: map[string]string{"prompt": "mask"}}}},
+		{"policy mode", Config{Policies: []Policy{{Name: "p", Keys: []string{"k"}, LogFields: map[string]string{"user": "scramble"}}}}},
+	} {
+		tc.cfg.Backends = []Backend{{Name: "b", URL: "http://localhost/v1"}}
+		if _, err := New(tc.cfg, nil, nil); err == nil {
+			t.Errorf("%s: no error", tc.name)
+		}
+	}
+	if _, err := (Logging{Level: "warn", Format: "json"}).Handler(&bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+}
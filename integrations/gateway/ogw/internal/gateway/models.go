@@ -0,0 +1,164 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// modelsTTL is how long the merged model list is reused before the
+// backends are asked again.
+const modelsTTL = time.Minute
+
+// modelList caches the merged GET /v1/models answer.
+type modelList struct {
+	mu      sync.Mutex
+	models  []map[string]any
+	fetched time.Time
+}
+
+// models answers GET /v1/models with every model a client can reach: the
+// lists of all backends, renamed with their ModelPrefix, keeping only
+// models the routing table sends to the backend that listed them, plus the
+// literal model names of routes. Models the caller's policy does not allow
+// are left out.
+func (g *Gateway) models(w http.ResponseWriter, r *http.Request) {
+	pol := g.policy(r)
+	data := []map[string]any{}
+	for _, m := range g.modelList(r) {
+		if id, _ := m["id"].(string); pol.allowsModel(id) {
+			data = append(data, m)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"object": "list", "data": data})
+}
+
+// model answers GET /v1/models/{model} from the merged list.
+func (g *Gateway) model(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("model")
+	if !g.policy(r).allowsModel(id) {
+		openAIError(w, http.StatusNotFound, "model_not_found", fmt.Sprintf("The model %q is not served by this gateway.", id))
+		return
+	}
+	for _, m := range g.modelList(r) {
+		if m["id"] == id {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(m)
+			return
+		}
+	}
+	openAIError(w, http.StatusNotFound, "model_not_found", fmt.Sprintf("The model %q is not served by this gateway.", id))
+}
+
+func (g *Gateway) modelList(r *http.Request) []map[string]any {
+	g.list.mu.Lock()
+	defer g.list.mu.Unlock()
+	if g.list.models != nil && time.Since(g.list.fetched) < modelsTTL {
+		return g.list.models
+	}
+
+	// Backends are asked concurrently; one that fails is left out and
+	// the list is not cached, so it is retried on the next call.
+	lists := make([][]map[string]any, len(g.cfg.Backends))
+	failed := false
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for i := range g.cfg.Backends {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			b := &g.cfg.Backends[i]
+			ms, err := g.backendModels(r.Context(), b)
+			if err != nil {
+				g.log(r, slog.LevelWarn, "listing models failed", "backend", b.Name, "err", err)
+				mu.Lock()
+				failed = true
+				mu.Unlock()
+				return
+			}
+			lists[i] = ms
+		}(i)
+	}
+	wg.Wait()
+
+	seen := map[string]bool{}
+	out := []map[string]any{}
+	for i, ms := range lists {
+		b := &g.cfg.Backends[i]
+		for _, m := range ms {
+			id, _ := m["id"].(string)
+			if id == "" {
+				continue
+			}
+			id = b.ModelPrefix + id
+			if rt, ok := g.route(id); !ok || rt.backend != b || seen[id] {
+				continue
+			}
+			seen[id] = true
+			m["id"] = id
+			if m["owned_by"] == nil {
+				m["owned_by"] = b.Name
+			}
+			m["object"] = "model"
+			out = append(out, m)
+		}
+	}
+	for _, rt := range g.cfg.Routes {
+		if !strings.ContainsAny(rt.Model, `*?[\`) && !seen[rt.Model] {
+			seen[rt.Model] = true
+			out = append(out, map[string]any{"id": rt.Model, "object": "model", "created": 0, "owned_by": rt.Backend})
+		}
+	}
+	if !failed {
+		g.list.models, g.list.fetched = out, time.Now()
+	}
+	return out
+}
+
+// backendModels returns b's model list: its configured Models, an azure
+// backend's deployments, or what the backend's /models endpoint reports.
+func (g *Gateway) backendModels(ctx context.Context, b *Backend) ([]map[string]any, error) {
+	static := b.Models
+	if len(static) == 0 && b.Type == BackendAzure && len(b.Deployments) > 0 {
+		for m := range b.Deployments {
+			static = append(static, m)
+		}
+		sort.Strings(static)
+	}
+	if len(static) > 0 {
+		out := make([]map[string]any, len(static))
+		for i, m := range static {
+			out[i] = map[string]any{"id": m, "object": "model", "created": 0, "owned_by": b.Name}
+		}
+		return out, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := b.newRequest(ctx, http.MethodGet, "/models", "", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := g.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	var body struct {
+		Data []map[string]any `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	return body.Data, nil
+}
@@ -0,0 +1,111 @@
+package gateway
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func lister(t *testing.T, body string, calls *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/models" {
+			http.NotFound(w, r)
+			return
+		}
+		calls.Add(1)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func ids(t *testing.T, w *httptest.ResponseRecorder) []string {
+	t.Helper()
+	var out struct {
+		Object string `json:"object"`
+		Data   []struct {
+			ID      string `json:"id"`
+			OwnedBy string `json:"owned_by"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil || out.Object != "list" {
+		t.Fatalf("%d %s", w.Code, w.Body)
+	}
+	var s []string
+	for _, m := range out.Data {
+		s = append(s, m.ID+"@"+m.OwnedBy)
+	}
+	return s
+}
+
+func TestModelsMerged(t *testing.T) {
+	var calls atomic.Int32
+	oai := lister(t, `{"object":"list","data":[{"id":"gpt-4o","owned_by":"openai"},{"id":"dall-e-3","owned_by":"openai"}]}`, &calls)
+	local := lister(t, `{"object":"list","data":[{"id":"llama3.1:8b"},{"id":"qwen2.5"}]}`, &calls)
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer down.Close()
+
+	h, _ := newGateway(t, Config{
+		Backends: []Backend{
+			{Name: "openai", URL: oai.URL + "/v1"},
+			{Name: "local", Type: BackendOllama, URL: local.URL + "/v1", ModelPrefix: "local/"},
+			{Name: "azure", Type: BackendAzure, URL: "http://azure.invalid", Deployments: map[string]string{"gpt-4o-mini": "d1"}},
+			{Name: "claude", Type: BackendAnthropic, Models: []string{"claude-sonnet-4-5"}},
+			{Name: "flaky", URL: down.URL + "/v1", ModelPrefix: "flaky/"},
+		},
+		Routes: []Route{
+			{Model: "gpt-4o-mini", Backend: "azure"},
+			{Model: "gpt-*", Backend: "openai"},
+			{Model: "claude-*", Backend: "claude"},
+			{Model: "fast", Backend: "local", RewriteModel: "llama3.1:8b"},
+		},
+	})
+
+	w := do(h, "GET", "/v1/models", "", "")
+	got := ids(t, w)
+	want := []string{"gpt-4o@openai", "local/llama3.1:8b@local", "local/qwen2.5@local", "gpt-4o-mini@azure", "claude-sonnet-4-5@claude", "fast@local"}
+	if len(got) != len(want) {
+		t.Fatalf("models %v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("models %v, want %v", got, want)
+		}
+	}
+
+	// A failed backend keeps the list uncached.
+	n := calls.Load()
+	do(h, "GET", "/v1/models", "", "")
+	if calls.Load() == n {
+		t.Fatal("list cached despite a failed backend")
+	}
+
+	if w := do(h, "GET", "/v1/models/local/qwen2.5", "", ""); w.Code != 200 {
+		t.Fatalf("model: %d %s", w.Code, w.Body)
+	}
+	if w := do(h, "GET", "/v1/models/dall-e-3", "", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("unrouted model: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestPrefixRoutes(t *testing.T) {
+	local, seen := recording(t)
+	h, _ := newGateway(t, Config{
+		Backends: []Backend{
+			{Name: "openai", URL: "http://openai.invalid/v1"},
+			{Name: "local", Type: BackendVLLM, URL: local.URL + "/v1", ModelPrefix: "local/"},
+		},
+	})
+	if w := do(h, "POST", "/v1/chat/completions", "", chatModel("local/mistral")); w.Code != 200 {
+		t.Fatalf("%d %s", w.Code, w.Body)
+	}
+	if got := seen(); len(got) != 1 || got[0].Model != "mistral" {
+		t.Fatalf("%+v", got)
+	}
+}
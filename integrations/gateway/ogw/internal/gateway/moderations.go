@@ -0,0 +1,184 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/openguardrails/openguardrails-go"
+)
+
+// moderationCategories are the categories of OpenAI's moderation API, in
+// the order it lists them.
+var moderationCategories = []string{
+	"harassment", "harassment/threatening", "hate", "hate/threatening",
+	"illicit", "illicit/violent", "self-harm", "self-harm/intent",
+	"self-harm/instructions", "sexual", "sexual/minors", "violence",
+	"violence/graphic",
+}
+
+// moderationMap maps risk categories to the moderation categories they
+// flag. Categories the moderation API has no counterpart for (political
+// topics, prompt attacks, privacy, professional advice, data entities)
+// flag none, but still count towards flagged and are listed under
+// openguardrails.categories.
+var moderationMap = map[guardrails.Category][]string{
+	guardrails.CategoryHarmToMinors:     {"sexual", "sexual/minors"},
+	guardrails.CategoryViolentCrime:     {"violence", "illicit/violent"},
+	guardrails.CategoryNonViolentCrime:  {"illicit"},
+	guardrails.CategoryPornography:      {"sexual"},
+	guardrails.CategoryHate:             {"hate"},
+	guardrails.CategoryProfanity:        {"harassment"},
+	guardrails.CategoryCommercial:       {"illicit"},
+	guardrails.CategoryIntellectualProp: {"illicit"},
+	guardrails.CategoryHarassment:       {"harassment"},
+	guardrails.CategoryWMD:              {"violence", "illicit/violent"},
+	guardrails.CategorySelfHarm:         {"self-harm"},
+	guardrails.CategorySexualCrime:      {"sexual", "illicit"},
+	guardrails.CategoryThreats:          {"harassment/threatening", "violence"},
+}
+
+// moderations answers POST /v1/moderations in the shape of OpenAI's
+// moderation API, so tools written against it can use the detection API
+// unchanged. Each input is checked as a user prompt under the caller's
+// policy: flagged is the policy's refusal, and the categories are the
+// flagged risk categories mapped by moderationMap, scored by the
+// detection API's score (1 when it reports none). Image inputs are not
+// supported. A failed check is a 503, whatever FailOpen says: an answer
+// would claim content was checked.
+func (g *Gateway) moderations(w http.ResponseWriter, r *http.Request) {
+	_, body, ok := g.readBody(w, r)
+	if !ok {
+		return
+	}
+	inputs, err := moderationInputs(body["input"])
+	if err != nil {
+		openAIError(w, http.StatusBadRequest, "invalid_request_error", err.Error())
+		return
+	}
+	pol := g.policy(r)
+	user, _ := body["user"].(string)
+	if u := g.client(r).user; u != "" {
+		user = u
+	}
+	if _, ok := g.admit(w, r, pol, user); !ok {
+		return
+	}
+	model, _ := body["model"].(string)
+	if model == "" {
+		model = "openguardrails"
+	}
+	id := ""
+	results := make([]map[string]any, 0, len(inputs))
+	flagged := 0
+	for _, in := range inputs {
+		resp, ok := g.check(r, pol, "moderation", []guardrails.Message{{Role: "user", Content: in}}, user)
+		if resp == nil {
+			guardrails.OpenAIDeny(w, r, nil)
+			return
+		}
+		if id == "" {
+			id = resp.ID
+		}
+		if !ok {
+			flagged++
+		}
+		results = append(results, moderationResult(resp, !ok))
+	}
+	g.log(r, slog.LevelInfo, "moderation", "inputs", len(inputs), "flagged", flagged)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"id": "modr-" + id, "model": model, "results": results})
+}
+
+// moderationInputs reads the input of a moderation request: a string, an
+// array of strings (one result each), or an array of content parts (one
+// result for all).
+func moderationInputs(v any) ([]string, error) {
+	switch v := v.(type) {
+	case string:
+		return []string{v}, nil
+	case []any:
+		if len(v) == 0 {
+			break
+		}
+		if _, parts := v[0].(map[string]any); !parts {
+			out := make([]string, len(v))
+			for i, s := range v {
+				s, ok := s.(string)
+				if !ok {
+					return nil, fmt.Errorf("input[%d] is not a string.", i)
+				}
+				out[i] = s
+			}
+			return out, nil
+		}
+		var texts []string
+		for i, p := range v {
+			p, _ := p.(map[string]any)
+			switch p["type"] {
+			case "text":
+				text, _ := p["text"].(string)
+				texts = append(texts, text)
+			case "image_url":
+				return nil, fmt.Errorf("input[%d]: image inputs are not supported.", i)
+			default:
+				return nil, fmt.Errorf("input[%d] is not a text part.", i)
+			}
+		}
+		return []string{strings.Join(texts, "\n")}, nil
+	}
+	return nil, fmt.Errorf("input must be a string, an array of strings or an array of content parts.")
+}
+
+// moderationResult is one entry of results: resp in the moderation API's
+// terms, with the detection API's own verdict under openguardrails.
+func moderationResult(resp *guardrails.Response, flagged bool) map[string]any {
+	categories := map[string]bool{}
+	scores := map[string]float64{}
+	applied := map[string][]string{}
+	for _, c := range moderationCategories {
+		categories[c], scores[c], applied[c] = false, 0, []string{}
+	}
+	codes := []string{}
+	for _, c := range resp.Categories() {
+		codes = append(codes, string(c))
+		score := categoryScore(resp, c)
+		for _, m := range moderationMap[c] {
+			categories[m], applied[m] = true, []string{"text"}
+			scores[m] = max(scores[m], score)
+		}
+	}
+	return map[string]any{
+		"flagged":                      flagged,
+		"categories":                   categories,
+		"category_scores":              scores,
+		"category_applied_input_types": applied,
+		"openguardrails": map[string]any{
+			"id":             resp.ID,
+			"risk_level":     resp.OverallRiskLevel,
+			"suggest_action": resp.SuggestAction,
+			"categories":     codes,
+		},
+	}
+}
+
+// categoryScore is the score of the dimension that flagged c, else the
+// response's, else 1.
+func categoryScore(resp *guardrails.Response, c guardrails.Category) float64 {
+	d := resp.Result.Compliance
+	switch c.Group() {
+	case guardrails.GroupSecurity:
+		d = resp.Result.Security
+	case guardrails.GroupData:
+		d = resp.Result.Data.Dimension
+	}
+	switch {
+	case d.Score > 0:
+		return d.Score
+	case resp.Score > 0:
+		return resp.Score
+	}
+	return 1
+}
@@ -0,0 +1,79 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/openguardrails/openguardrails-go/guardrailstest"
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/oidc"
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/oidc/oidctest"
+)
+
+func TestOIDC(t *testing.T) {
+	idp := oidctest.NewProvider()
+	t.Cleanup(idp.Close)
+	det := guardrailstest.NewServer()
+	t.Cleanup(det.Close)
+	cfg := Config{
+		APIKeys: []string{"k1"},
+		OIDC: &OIDC{
+			Config:      oidc.Config{Issuer: idp.URL, Audience: "ogw"},
+			UserClaim:   "email",
+			PolicyClaim: "tenant",
+			AdminClaim:  "roles", AdminValue: "ogw-admin",
+		},
+		Policies: []Policy{{Name: "acme", Models: []string{"other-*"}}},
+		Backends: []Backend{{Name: "echo", URL: backend(t).URL + "/v1", APIKey: "sk-upstream"}},
+	}
+	srv, err := NewServer(func() (*Gateway, error) { return New(cfg, det.Client(), nil) }, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := func(claims map[string]any) string {
+		claims["aud"] = "ogw"
+		return idp.Token(claims)
+	}
+
+	// The token's user replaces the request's user field (u-7).
+	alice := token(map[string]any{"sub": "1", "email": "alice@example.com"})
+	if w := do(srv, "POST", "/v1/chat/completions", alice, chat("hi", false)); w.Code != 200 {
+		t.Fatalf("token: %d %s", w.Code, w.Body)
+	}
+	if calls := det.Calls(); len(calls) == 0 || calls[0].UserID != "alice@example.com" {
+		t.Fatalf("calls %+v", calls)
+	}
+	tl := srv.Gateway().usage.snapshot()
+	if len(tl) != 2 || tl[0].Subject != "key:default/oidc" || tl[1].Subject != "user:default/alice@example.com" {
+		t.Fatalf("usage %+v", tl)
+	}
+
+	// The tenant claim selects the policy; acme may not use echo.
+	acme := token(map[string]any{"sub": "2", "tenant": "acme"})
+	if w := do(srv, "POST", "/v1/chat/completions", acme, chat("hi", false)); w.Code != 403 {
+		t.Fatalf("acme: %d %s", w.Code, w.Body)
+	}
+	for name, tok := range map[string]string{
+		"unknown tenant": token(map[string]any{"sub": "3", "tenant": "nobody"}),
+		"wrong audience": idp.Token(map[string]any{"sub": "4", "aud": "elsewhere"}),
+		"garbage":        "eyJhbGciOiJSUzI1NiJ9.e30.c2ln",
+	} {
+		if w := do(srv, "POST", "/v1/chat/completions", tok, chat("hi", false)); w.Code != 401 {
+			t.Errorf("%s: %d", name, w.Code)
+		}
+	}
+	if w := do(srv, "POST", "/v1/chat/completions", "k1", chat("hi", false)); w.Code != 200 {
+		t.Fatalf("static key: %d", w.Code)
+	}
+
+	// Admin tokens need the role; with OIDC admins the API is not open.
+	admin := srv.AdminHandler(nil)
+	if w := do(admin, "GET", "/admin/status", "", ""); w.Code != 401 {
+		t.Fatalf("anonymous admin: %d", w.Code)
+	}
+	if w := do(admin, "GET", "/admin/status", alice, ""); w.Code != 401 {
+		t.Fatalf("admin without role: %d", w.Code)
+	}
+	root := token(map[string]any{"sub": "5", "roles": []string{"dev", "ogw-admin"}})
+	if w := do(admin, "GET", "/admin/status", root, ""); w.Code != 200 {
+		t.Fatalf("admin: %d %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,324 @@
+package gateway
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/openguardrails/openguardrails-go"
+	"github.com/openguardrails/openguardrails-go/verdict"
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/oidc"
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/websocket"
+)
+
+// Sensitivities, from most to least eager to block.
+const (
+	SensitivityHigh   = "high"   // block from low_risk
+	SensitivityMedium = "medium" // block from medium_risk
+	SensitivityLow    = "low"    // block only high_risk
+)
+
+// Category actions.
+const (
+	CategoryBlock = "block"
+	CategoryAllow = "allow"
+)
+
+// Policy is what one set of client keys may do and how its traffic is
+// judged. Keys listed in Config.APIKeys get the default policy: the
+// gateway's detection key, the platform's own verdict and every model.
+type Policy struct {
+	Name string `yaml:"name"`
+	// Keys are the bearer tokens that select this policy. KeysEnv names an
+	// environment variable holding more, comma-separated.
+	Keys    []string `yaml:"keys"`
+	KeysEnv string   `yaml:"keys_env"`
+	// GuardrailsAPIKey is the detection API application key to check this
+	// policy's traffic with, so each team's platform configuration
+	// (blacklists, knowledge bases, ban policy) applies. Defaults to the
+	// gateway's key.
+	GuardrailsAPIKey    string `yaml:"guardrails_api_key"`
+	GuardrailsAPIKeyEnv string `yaml:"guardrails_api_key_env"`
+	// Sensitivity, if set, replaces the platform's suggested action with a
+	// threshold on the overall risk level: high, medium or low.
+	Sensitivity string `yaml:"sensitivity"`
+	// Categories overrides the verdict per risk category (S1…S21): block
+	// denies whenever the category is flagged, allow ignores it.
+	Categories map[string]string `yaml:"categories"`
+	// DenyMessage, if set, is the answer refused requests and answers get
+	// instead of the platform's suggested one.
+	DenyMessage string `yaml:"deny_message"`
+	// Models are patterns (see matchModel) of the models the keys may
+	// use; empty allows all.
+	Models []string `yaml:"models"`
+	// Quota bounds each of the policy's keys, UserQuota each end user
+	// (the request's user field) under the policy.
+	Quota     Quota `yaml:"quota"`
+	UserQuota Quota `yaml:"user_quota"`
+	// Shadow is a candidate verdict configuration judged alongside this
+	// one but never enforced.
+	Shadow *Shadow `yaml:"shadow"`
+	// LogFields overrides Logging.Fields for the policy's traffic.
+	LogFields map[string]string `yaml:"log_fields"`
+
+	guard     *guardrails.Client
+	shadow    *Policy
+	logFields map[string]string
+}
+
+// init validates the policy; keyless allows it no keys of its own, for
+// JWT clients to select.
+func (p *Policy) init(guard *guardrails.Client, keyless bool) error {
+	if p.Name == "" {
+		return fmt.Errorf("policy without a name")
+	}
+	if p.KeysEnv != "" {
+		p.Keys = append(p.Keys, list(os.Getenv(p.KeysEnv))...)
+	}
+	if len(p.Keys) == 0 && !keyless {
+		return fmt.Errorf("policy %q: no keys", p.Name)
+	}
+	if err := p.initVerdict(guard); err != nil {
+		return err
+	}
+	for _, m := range p.Models {
+		if _, err := matchModel(m, ""); err != nil {
+			return fmt.Errorf("policy %q: invalid model pattern %q", p.Name, m)
+		}
+	}
+	return p.initShadow()
+}
+
+// initVerdict sets up what judges the policy's traffic: the detection
+// client, the sensitivity and the category overrides.
+func (p *Policy) initVerdict(guard *guardrails.Client) error {
+	if p.GuardrailsAPIKey == "" && p.GuardrailsAPIKeyEnv != "" {
+		if p.GuardrailsAPIKey = os.Getenv(p.GuardrailsAPIKeyEnv); p.GuardrailsAPIKey == "" {
+			return fmt.Errorf("policy %q: %s is not set", p.Name, p.GuardrailsAPIKeyEnv)
+		}
+	}
+	p.guard = guard
+	if p.GuardrailsAPIKey != "" && guard != nil {
+		p.guard = guard.With(guardrails.WithAPIKey(p.GuardrailsAPIKey))
+	}
+	switch p.Sensitivity {
+	case "", SensitivityHigh, SensitivityMedium, SensitivityLow:
+	default:
+		return fmt.Errorf("policy %q: unknown sensitivity %q", p.Name, p.Sensitivity)
+	}
+	cats := make(map[string]string, len(p.Categories))
+	for c, a := range p.Categories {
+		if a != CategoryBlock && a != CategoryAllow {
+			return fmt.Errorf("policy %q: category %s: unknown action %q", p.Name, c, a)
+		}
+		cats[strings.ToUpper(c)] = a
+	}
+	p.Categories = cats
+	return nil
+}
+
+// allowsModel reports whether the policy's keys may use model.
+func (p *Policy) allowsModel(model string) bool {
+	if len(p.Models) == 0 {
+		return true
+	}
+	for _, m := range p.Models {
+		if ok, _ := matchModel(m, model); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// allows decides a checked exchange under the policy: category overrides
+// first, then the sensitivity threshold, then the platform's suggestion.
+func (p *Policy) allows(resp *guardrails.Response) bool {
+	flagged := resp.Categories()
+	overridden := 0
+	for _, c := range flagged {
+		switch p.Categories[strings.ToUpper(string(c))] {
+		case CategoryBlock:
+			return false
+		case CategoryAllow:
+			overridden++
+		}
+	}
+	if len(flagged) > 0 && overridden == len(flagged) {
+		return true
+	}
+	if p.Sensitivity == "" {
+		return resp.IsSafe()
+	}
+	return riskRank[resp.OverallRiskLevel] < riskRank[threshold[p.Sensitivity]]
+}
+
+// decide is allows, with the policy's deny message put in a refused resp.
+func (p *Policy) decide(resp *guardrails.Response) bool {
+	ok := p.allows(resp)
+	if !ok && p.DenyMessage != "" {
+		resp.SuggestAnswer = p.DenyMessage
+	}
+	return ok
+}
+
+var (
+	riskRank = map[verdict.RiskLevel]int{
+		verdict.NoRisk: 0, verdict.LowRisk: 1, verdict.MediumRisk: 2, verdict.HighRisk: 3,
+	}
+	threshold = map[string]verdict.RiskLevel{
+		SensitivityHigh: verdict.LowRisk, SensitivityMedium: verdict.MediumRisk, SensitivityLow: verdict.HighRisk,
+	}
+)
+
+type clientKeyKey struct{}
+
+// clientKey is a configured key and the policy it selects. id names it in
+// usage tallies without revealing it. Clients with a JWT get one per
+// request, with user set from the token.
+type clientKey struct {
+	key    string
+	id     string
+	policy *Policy
+	user   string
+}
+
+// client returns the key authenticate attached to r, or the default key,
+// which stands for everyone when no keys are configured.
+func (g *Gateway) client(r *http.Request) *clientKey {
+	if k, ok := r.Context().Value(clientKeyKey{}).(*clientKey); ok {
+		return k
+	}
+	return g.dflt
+}
+
+// policy returns the policy of r's key: its own, or the default policy,
+// which applies to keys in Config.APIKeys and to everyone when no keys are
+// configured.
+func (g *Gateway) policy(r *http.Request) *Policy { return g.client(r).policy }
+
+// policies returns the default policy and the configured ones.
+func (g *Gateway) policies() []*Policy {
+	out := []*Policy{g.dflt.policy}
+	for i := range g.cfg.Policies {
+		out = append(out, &g.cfg.Policies[i])
+	}
+	return out
+}
+
+// subjects are the tallies a request by user counts against: its key's,
+// and the user's if the request names one.
+func (g *Gateway) subjects(r *http.Request, user string) []subject {
+	k := g.client(r)
+	out := []subject{{"key:" + k.id, k.policy.Quota}}
+	if user != "" {
+		out = append(out, subject{"user:" + k.policy.Name + "/" + user, k.policy.UserQuota})
+	}
+	return out
+}
+
+// authenticate admits requests bearing a configured key, or a valid JWT
+// when OIDC is configured, and attaches it. WebSocket clients may pass the
+// key as a subprotocol instead. Every key is compared, so timing reveals
+// nothing about which one matched.
+func (g *Gateway) authenticate(next http.Handler) http.Handler {
+	if len(g.keys) == 0 && g.verifier == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if key == "" && websocket.IsUpgrade(r) {
+			for _, p := range websocket.Subprotocols(r) {
+				if k, ok := strings.CutPrefix(p, insecureKeyProtocol); ok {
+					key = k
+				}
+			}
+		}
+		if g.verifier != nil && oidc.LooksLikeJWT(key) {
+			k, err := g.tokenClient(r, key)
+			if err != nil {
+				g.log(r, slog.LevelWarn, "token refused", "err", err)
+				openAIError(w, http.StatusUnauthorized, "invalid_api_key", "Invalid token.")
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientKeyKey{}, k)))
+			return
+		}
+		var match *clientKey
+		for _, k := range g.keys {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(k.key)) == 1 && match == nil {
+				match = k
+			}
+		}
+		if match == nil {
+			openAIError(w, http.StatusUnauthorized, "invalid_api_key", "Invalid API key.")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientKeyKey{}, match)))
+	})
+}
+
+// initPolicies builds the key table from Config.APIKeys and Policies.
+func (g *Gateway) initPolicies() error {
+	dflt := &Policy{
+		Name: "default", Quota: g.cfg.Quota, UserQuota: g.cfg.UserQuota, Shadow: g.cfg.Shadow,
+		Sensitivity: g.cfg.Sensitivity, Categories: g.cfg.Categories, DenyMessage: g.cfg.DenyMessage,
+	}
+	if err := dflt.initVerdict(g.guard); err != nil {
+		return err
+	}
+	if err := dflt.initShadow(); err != nil {
+		return err
+	}
+	fields, err := logFields(g.cfg.Logging.Fields, nil)
+	if err != nil {
+		return fmt.Errorf("logging: %w", err)
+	}
+	dflt.logFields = fields
+	g.dflt = &clientKey{id: dflt.Name, policy: dflt}
+	seen := map[string]string{}
+	add := func(key string, p *Policy) error {
+		if prev, ok := seen[key]; ok {
+			return fmt.Errorf("a client key is listed under both %q and %q", prev, p.Name)
+		}
+		seen[key] = p.Name
+		sum := sha256.Sum256([]byte(key))
+		g.keys = append(g.keys, &clientKey{key: key, id: p.Name + "/" + hex.EncodeToString(sum[:4]), policy: p})
+		return nil
+	}
+	for _, k := range g.cfg.APIKeys {
+		if err := add(k, dflt); err != nil {
+			return err
+		}
+	}
+	for i := range g.cfg.Policies {
+		p := &g.cfg.Policies[i]
+		if err := p.init(g.guard, g.verifier != nil && g.cfg.OIDC.PolicyClaim != ""); err != nil {
+			return err
+		}
+		if p.logFields, err = logFields(g.cfg.Logging.Fields, p.LogFields); err != nil {
+			return fmt.Errorf("policy %q: %w", p.Name, err)
+		}
+		for _, k := range p.Keys {
+			if err := add(k, p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func list(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
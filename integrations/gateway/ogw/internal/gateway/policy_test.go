@@ -0,0 +1,126 @@
+package gateway
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openguardrails/openguardrails-go"
+	"github.com/openguardrails/openguardrails-go/verdict"
+)
+
+func chatAs(model, content string) string {
+	return `{"model":"` + model + `","messages":[{"role":"user","content":"` + content + `"}]}`
+}
+
+func TestPolicies(t *testing.T) {
+	h, det := newGateway(t, Config{
+		APIKeys: []string{"k1"},
+		Policies: []Policy{
+			{Name: "team-a", Keys: []string{"ka"}, GuardrailsAPIKey: "sk-xxai-a", Sensitivity: SensitivityHigh, Models: []string{"echo*"}},
+			{Name: "team-b", Keys: []string{"kb"}, Categories: map[string]string{"s9": CategoryAllow, "S5": CategoryBlock}},
+		},
+	})
+	det.On("borderline", guardrails.Response{SuggestAction: guardrails.ActionPass, OverallRiskLevel: verdict.LowRisk})
+	det.Reject("injection", "S9")
+	det.On("tagged", guardrails.Response{
+		SuggestAction: guardrails.ActionPass, OverallRiskLevel: verdict.LowRisk,
+		Result: guardrails.Result{Compliance: guardrails.Dimension{Categories: []guardrails.Category{"S5"}}},
+	})
+
+	cases := []struct {
+		key, model, prompt string
+		code               int
+		blocked            bool
+	}{
+		{"k1", "other", "hello", 200, false},
+		{"ka", "echo-2", "hello", 200, false},
+		{"ka", "other", "hello", 403, false},
+		{"k1", "other", "borderline", 200, false},
+		{"ka", "echo", "borderline", 200, true},
+		{"k1", "other", "injection", 200, true},
+		{"kb", "other", "injection", 200, false},
+		{"k1", "other", "tagged", 200, false},
+		{"kb", "other", "tagged", 200, true},
+	}
+	for _, c := range cases {
+		w := do(h, "POST", "/v1/chat/completions", c.key, chatAs(c.model, c.prompt))
+		if w.Code != c.code {
+			t.Fatalf("%s %s %q: %d %s", c.key, c.model, c.prompt, w.Code, w.Body)
+		}
+		if c.code != 200 {
+			continue
+		}
+		if _, finish := content(t, w); (finish == "content_filter") != c.blocked {
+			t.Errorf("%s %q: finish %q, blocked want %v", c.key, c.prompt, finish, c.blocked)
+		}
+	}
+
+	keyed := false
+	for _, call := range det.Calls() {
+		if call.Messages[len(call.Messages)-1].Content == "borderline" && call.Header.Get("Authorization") == "Bearer sk-xxai-a" {
+			keyed = true
+		}
+	}
+	if !keyed {
+		t.Fatal("team-a's checks did not use its detection key")
+	}
+
+	if w := do(h, "GET", "/v1/models", "ka", ""); !strings.Contains(w.Body.String(), `"echo"`) {
+		t.Fatalf("models for team-a: %s", w.Body)
+	}
+	h, _ = newGateway(t, Config{Policies: []Policy{{Name: "narrow", Keys: []string{"kn"}, Models: []string{"gpt-*"}}}})
+	if w := do(h, "GET", "/v1/models", "kn", ""); strings.Contains(w.Body.String(), `"echo"`) {
+		t.Fatalf("disallowed model listed: %s", w.Body)
+	}
+	if w := do(h, "GET", "/v1/models", "nope", ""); w.Code != 401 {
+		t.Fatalf("unknown key: %d", w.Code)
+	}
+}
+
+func TestPolicyErrors(t *testing.T) {
+	backends := []Backend{{Name: "x", URL: "http://x"}}
+	for name, p := range map[string][]Policy{
+		"no name":     {{Keys: []string{"a"}}},
+		"no keys":     {{Name: "p"}},
+		"sensitivity": {{Name: "p", Keys: []string{"a"}, Sensitivity: "extreme"}},
+		"action":      {{Name: "p", Keys: []string{"a"}, Categories: map[string]string{"S1": "warn"}}},
+		"pattern":     {{Name: "p", Keys: []string{"a"}, Models: []string{"["}}},
+		"duplicate":   {{Name: "p", Keys: []string{"a"}}, {Name: "q", Keys: []string{"a"}}},
+	} {
+		if _, err := New(Config{Backends: backends, Policies: p}, nil, nil); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestDefaultPolicyRules(t *testing.T) {
+	h, det := newGateway(t, Config{
+		APIKeys:     []string{"kd"},
+		Sensitivity: SensitivityHigh,
+		Categories:  map[string]string{"s9": CategoryAllow},
+		DenyMessage: "Blocked by the platform team.",
+		Policies:    []Policy{{Name: "plain", Keys: []string{"kp"}}},
+	})
+	det.On("borderline", guardrails.Response{SuggestAction: guardrails.ActionPass, OverallRiskLevel: verdict.LowRisk})
+	det.Reject("injection", "S9")
+
+	for _, c := range []struct {
+		key, prompt string
+		blocked     bool
+	}{
+		{"kd", "hello", false},
+		{"kd", "borderline", true},
+		{"kd", "injection", false},
+		{"kp", "borderline", false},
+		{"kp", "injection", true},
+	} {
+		w := do(h, "POST", "/v1/chat/completions", c.key, chatAs("echo", c.prompt))
+		text, finish := content(t, w)
+		if (finish == "content_filter") != c.blocked {
+			t.Errorf("%q %q: finish %q, blocked want %v", c.key, c.prompt, finish, c.blocked)
+		}
+		if c.blocked && c.key == "kd" && text != "Blocked by the platform team." {
+			t.Errorf("%q: deny message %q", c.prompt, text)
+		}
+	}
+}
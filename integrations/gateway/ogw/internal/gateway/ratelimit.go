@@ -0,0 +1,300 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/redis"
+)
+
+// RateLimit keeps the per-minute counters of Quota.RequestsPerMinute and
+// TokensPerMinute in Redis, so that every gateway instance sharing it
+// enforces one limit. Without it each instance counts on its own. While
+// Redis is unreachable, instances fall back to counting on their own.
+type RateLimit struct {
+	// Redis is redis://[[user]:password@]host[:port][/db], or rediss://
+	// for TLS. Write the password as ${REDIS_PASSWORD} to keep it out of
+	// the file.
+	Redis string `yaml:"redis"`
+	// Prefix starts every key the gateway writes (default "ogw:").
+	Prefix string `yaml:"prefix"`
+	// Timeout bounds each round trip to Redis (default 100ms); a request
+	// is never held up longer than that by rate limiting.
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// window is what a subject used in one minute.
+type window struct {
+	requests, tokens int64
+}
+
+// rates enforces the per-minute limits over a sliding window: the
+// current minute's counts plus the previous minute's, weighted by how much
+// of it the last 60 seconds still cover.
+type rates struct {
+	local  *localRates
+	remote *redisRates // nil without Config.RateLimit
+	logger *slog.Logger
+	now    func() time.Time
+
+	mu     sync.Mutex
+	warned time.Time // when the last Redis failure was logged
+}
+
+func newRates(cfg *RateLimit, logger *slog.Logger) (*rates, error) {
+	r := &rates{local: newLocalRates(), logger: logger, now: time.Now}
+	if cfg != nil {
+		remote, err := newRedisRates(*cfg)
+		if err != nil {
+			return nil, fmt.Errorf("rate_limit: %w", err)
+		}
+		r.remote = remote
+	}
+	return r, nil
+}
+
+// adopt carries old's counters over a reload, and its Redis connections
+// if the settings have not changed.
+func (r *rates) adopt(old *rates) {
+	r.local = old.local
+	if old.remote == nil {
+		return
+	}
+	if r.remote != nil && r.remote.cfg == old.remote.cfg {
+		r.remote.client.Close()
+		r.remote = old.remote
+		return
+	}
+	old.remote.client.Close()
+}
+
+// limited describes a refused request.
+type limited struct {
+	what  string // "requests" or "tokens"
+	limit int64
+	retry time.Duration
+}
+
+// limits reports whether q has a per-minute limit.
+func limits(q Quota) bool { return q.RequestsPerMinute > 0 || q.TokensPerMinute > 0 }
+
+// admit counts a request against every subject, unless one of them is at
+// its rate; then nothing is counted and the first such limit is returned.
+func (r *rates) admit(ctx context.Context, subjects []subject) *limited {
+	now := r.now()
+	minute, elapsed := now.Unix()/60, float64(now.UnixNano()%int64(time.Minute))/float64(time.Minute)
+	var counted []subject
+	var refused *limited
+	for _, s := range subjects {
+		if !limits(s.quota) {
+			continue
+		}
+		prev, cur := r.add(ctx, s.name, minute, window{requests: 1})
+		counted = append(counted, s)
+		q := s.quota
+		switch {
+		case q.RequestsPerMinute > 0 && rate(prev.requests, cur.requests, elapsed) > float64(q.RequestsPerMinute):
+			// Room for one more request once the rate, this one not
+			// counted, is below the limit by one.
+			refused = &limited{"requests", q.RequestsPerMinute, wait(prev.requests, cur.requests-1, q.RequestsPerMinute-1, elapsed)}
+		case q.TokensPerMinute > 0 && rate(prev.tokens, cur.tokens, elapsed) >= float64(q.TokensPerMinute):
+			refused = &limited{"tokens", q.TokensPerMinute, wait(prev.tokens, cur.tokens, q.TokensPerMinute-1, elapsed)}
+		}
+		if refused != nil {
+			break
+		}
+	}
+	if refused != nil {
+		for _, s := range counted {
+			r.add(ctx, s.name, minute, window{requests: -1})
+		}
+	}
+	return refused
+}
+
+// record adds the tokens of one exchange to every subject with a
+// per-minute limit.
+func (r *rates) record(ctx context.Context, subjects []subject, tokens int64) {
+	minute := r.now().Unix() / 60
+	for _, s := range subjects {
+		if s.quota.TokensPerMinute > 0 && tokens > 0 {
+			r.add(ctx, s.name, minute, window{tokens: tokens})
+		}
+	}
+}
+
+// add counts in Redis if configured, and locally otherwise or when Redis
+// fails.
+func (r *rates) add(ctx context.Context, name string, minute int64, n window) (window, window) {
+	if r.remote != nil {
+		prev, cur, err := r.remote.add(ctx, name, minute, n)
+		if err == nil {
+			return prev, cur
+		}
+		r.mu.Lock()
+		warn := time.Since(r.warned) >= time.Minute
+		if warn {
+			r.warned = time.Now()
+		}
+		r.mu.Unlock()
+		if warn && r.logger != nil {
+			r.logger.Warn("rate limit counters unavailable; counting locally", "err", err)
+		}
+	}
+	prev, cur, _ := r.local.add(ctx, name, minute, n)
+	return prev, cur
+}
+
+// rate is the sliding-window estimate of the last 60 seconds, elapsed
+// being the part of the current minute gone by.
+func rate(prev, cur int64, elapsed float64) float64 {
+	return float64(prev)*(1-elapsed) + float64(cur)
+}
+
+// wait is how long until rate(prev, cur, ·) drops to at most target.
+func wait(prev, cur, target int64, elapsed float64) time.Duration {
+	if target < 0 {
+		target = 0
+	}
+	if cur > target {
+		// Not this minute; in the next one cur is the previous minute's.
+		return time.Duration((1-elapsed)*float64(time.Minute)) + wait(cur, 0, target, 0)
+	}
+	if prev == 0 {
+		return 0
+	}
+	at := 1 - float64(target-cur)/float64(prev)
+	if at <= elapsed {
+		return 0
+	}
+	return time.Duration((at - elapsed) * float64(time.Minute))
+}
+
+// localRates counts in memory. Subjects idle for two minutes are swept.
+type localRates struct {
+	mu     sync.Mutex
+	counts map[string]*minuteCounts
+	swept  int64
+}
+
+type minuteCounts struct {
+	minute    int64
+	prev, cur window
+}
+
+func newLocalRates() *localRates {
+	return &localRates{counts: map[string]*minuteCounts{}}
+}
+
+// add adds n to name's counts for minute (Unix time / 60) and returns the
+// counts of the minute before and of minute, n included. redisRates.add
+// does the same.
+func (l *localRates) add(_ context.Context, name string, minute int64, n window) (window, window, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.swept < minute {
+		for k, c := range l.counts {
+			if c.minute < minute-1 {
+				delete(l.counts, k)
+			}
+		}
+		l.swept = minute
+	}
+	c := l.counts[name]
+	switch {
+	case c == nil:
+		c = &minuteCounts{minute: minute}
+		l.counts[name] = c
+	case c.minute == minute-1:
+		c.minute, c.prev, c.cur = minute, c.cur, window{}
+	case c.minute < minute-1:
+		c.minute, c.prev, c.cur = minute, window{}, window{}
+	}
+	c.cur.requests += n.requests
+	c.cur.tokens += n.tokens
+	return c.prev, c.cur, nil
+}
+
+// redisRates counts in a Redis hash per subject and minute,
+// <prefix>rate:<subject>:<minute> with fields r and t, which expires once
+// no window can cover it.
+type redisRates struct {
+	cfg    RateLimit
+	client *redis.Client
+}
+
+func newRedisRates(cfg RateLimit) (*redisRates, error) {
+	if cfg.Prefix == "" {
+		cfg.Prefix = "ogw:"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 100 * time.Millisecond
+	}
+	client, err := redis.New(cfg.Redis)
+	if err != nil {
+		return nil, err
+	}
+	return &redisRates{cfg: cfg, client: client}, nil
+}
+
+func (r *redisRates) key(name string, minute int64) string {
+	return r.cfg.Prefix + "rate:" + name + ":" + strconv.FormatInt(minute, 10)
+}
+
+func (r *redisRates) add(ctx context.Context, name string, minute int64, n window) (window, window, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.cfg.Timeout)
+	defer cancel()
+	key := r.key(name, minute)
+	replies, err := r.client.Pipeline(ctx,
+		[]any{"HINCRBY", key, "r", n.requests},
+		[]any{"HINCRBY", key, "t", n.tokens},
+		[]any{"EXPIRE", key, 180},
+		[]any{"HMGET", r.key(name, minute-1), "r", "t"},
+	)
+	if err != nil {
+		return window{}, window{}, err
+	}
+	for _, reply := range replies {
+		if e, ok := reply.(redis.Error); ok {
+			return window{}, window{}, e
+		}
+	}
+	var cur, prev window
+	var ok1, ok2 bool
+	cur.requests, ok1 = replies[0].(int64)
+	cur.tokens, ok2 = replies[1].(int64)
+	fields, ok3 := replies[3].([]any)
+	if !ok1 || !ok2 || !ok3 || len(fields) != 2 {
+		return window{}, window{}, fmt.Errorf("redis: unexpected replies %v", replies)
+	}
+	for i, p := range []*int64{&prev.requests, &prev.tokens} {
+		if s, ok := fields[i].(string); ok {
+			*p, _ = strconv.ParseInt(s, 10, 64)
+		}
+	}
+	return prev, cur, nil
+}
+
+// admitRate refuses r with 429 and Retry-After if a subject is at its
+// per-minute rate.
+func (g *Gateway) admitRate(w http.ResponseWriter, r *http.Request, subjects []subject) bool {
+	l := g.rates.admit(r.Context(), subjects)
+	if l == nil {
+		return true
+	}
+	secs := int64(math.Ceil(l.retry.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.FormatInt(secs, 10))
+	openAIError(w, http.StatusTooManyRequests, "rate_limit_exceeded",
+		fmt.Sprintf("Rate limit of %d %s per minute reached for this API key or user. Try again in %ds.", l.limit, l.what, secs))
+	g.log(r, slog.LevelWarn, "rate limited", "limit", l.what, "retry_after", secs)
+	return false
+}
@@ -0,0 +1,114 @@
+package gateway
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go/guardrailstest"
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/redis/redistest"
+)
+
+func TestRateLimit(t *testing.T) {
+	det := guardrailstest.NewServer()
+	t.Cleanup(det.Close)
+	gw, err := New(Config{
+		APIKeys:   []string{"k1"},
+		Quota:     Quota{RequestsPerMinute: 2},
+		UserQuota: Quota{TokensPerMinute: 8},
+		Backends:  []Backend{{Name: "echo", URL: backend(t).URL + "/v1", APIKey: "sk-upstream"}},
+	}, det.Client(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 2, 9, 0, 30, 0, time.UTC)
+	gw.rates.now = func() time.Time { return now }
+	h := gw.Handler()
+
+	// 3 + 5 estimated tokens use up u-7's tokens per minute.
+	if w := do(h, "POST", "/v1/chat/completions", "k1", chat("hello world", false)); w.Code != 200 {
+		t.Fatalf("first: %d %s", w.Code, w.Body)
+	}
+	// Half a minute to the next, then an eighth of it for the 8 tokens to
+	// slide below the limit.
+	w := do(h, "POST", "/v1/chat/completions", "k1", chat("again", false))
+	if w.Code != 429 || w.Header().Get("Retry-After") != "38" || !strings.Contains(w.Body.String(), "rate_limit_exceeded") ||
+		!strings.Contains(w.Body.String(), "8 tokens per minute") {
+		t.Fatalf("user tokens: %d %v %s", w.Code, w.Header(), w.Body)
+	}
+	// The refused request does not count against the key.
+	anon := `{"model":"echo","messages":[{"role":"user","content":"hi"}]}`
+	if w := do(h, "POST", "/v1/chat/completions", "k1", anon); w.Code != 200 {
+		t.Fatalf("second: %d", w.Code)
+	}
+	if w := do(h, "POST", "/v1/chat/completions", "k1", anon); w.Code != 429 || w.Header().Get("Retry-After") != "60" {
+		t.Fatalf("key requests: %d %v", w.Code, w.Header())
+	}
+
+	// Halfway through the next minute the previous one weighs half.
+	now = now.Add(time.Minute)
+	if w := do(h, "POST", "/v1/chat/completions", "k1", anon); w.Code != 200 {
+		t.Fatalf("next minute: %d", w.Code)
+	}
+	if w := do(h, "POST", "/v1/chat/completions", "k1", anon); w.Code != 429 || w.Header().Get("Retry-After") != "30" {
+		t.Fatalf("sliding: %d %v", w.Code, w.Header())
+	}
+}
+
+func TestRateLimitRedis(t *testing.T) {
+	rs := redistest.NewServer()
+	t.Cleanup(rs.Close)
+	det := guardrailstest.NewServer()
+	t.Cleanup(det.Close)
+	be := backend(t)
+	var logs bytes.Buffer
+	instance := func() *Gateway {
+		gw, err := New(Config{
+			APIKeys:   []string{"k1"},
+			Quota:     Quota{RequestsPerMinute: 1},
+			RateLimit: &RateLimit{Redis: rs.URL, Timeout: time.Second},
+			Backends:  []Backend{{Name: "echo", URL: be.URL + "/v1", APIKey: "sk-upstream"}},
+		}, det.Client(), slog.New(slog.NewTextHandler(&logs, nil)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { gw.rates.remote.client.Close() })
+		return gw
+	}
+	a, b := instance().Handler(), instance().Handler()
+
+	if w := do(a, "POST", "/v1/chat/completions", "k1", chat("hi", false)); w.Code != 200 {
+		t.Fatalf("a: %d", w.Code)
+	}
+	if w := do(b, "POST", "/v1/chat/completions", "k1", chat("hi", false)); w.Code != 429 || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("b shares a's count: %d", w.Code)
+	}
+	if rs.Commands() == 0 {
+		t.Fatal("redis not used")
+	}
+
+	// Without Redis each instance counts on its own.
+	rs.Close()
+	if w := do(b, "POST", "/v1/chat/completions", "k1", chat("hi", false)); w.Code != 200 {
+		t.Fatalf("b without redis: %d", w.Code)
+	}
+	if w := do(b, "POST", "/v1/chat/completions", "k1", chat("hi", false)); w.Code != 429 {
+		t.Fatalf("b counts locally: %d", w.Code)
+	}
+	// Once a minute at most.
+	if n := strings.Count(logs.String(), "counting locally"); n != 1 {
+		t.Fatalf("warned %d times:\n%s", n, logs.String())
+	}
+}
+
+func TestRateLimitConfig(t *testing.T) {
+	_, err := New(Config{
+		RateLimit: &RateLimit{Redis: "memcached://localhost"},
+		Backends:  []Backend{{Name: "b", URL: "http://localhost/v1"}},
+	}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "rate_limit") {
+		t.Fatalf("err %v", err)
+	}
+}
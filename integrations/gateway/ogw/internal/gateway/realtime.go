@@ -0,0 +1,423 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go"
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/archive"
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/websocket"
+)
+
+// insecureKeyProtocol is how browsers, which cannot set headers on a
+// WebSocket, pass an API key to the Realtime API.
+const insecureKeyProtocol = "openai-insecure-api-key."
+
+// realtimeContext is how many transcript turns checks see, and
+// realtimeArchived how many are kept for the archive.
+const (
+	realtimeContext  = 20
+	realtimeArchived = 1000
+)
+
+// realtime relays a Realtime API session (GET /v1/realtime?model=…). Text
+// the client gives the model (items of any role, session and response
+// instructions, a response's inline input) is checked before it is
+// forwarded, audio transcripts as the backend reports them, and the answer
+// transcript as it streams, every Stream.CheckEvery tokens and when it is
+// done. A violation ends the session: the client gets an error event with
+// code content_policy_violation, and both sockets are closed with 1008.
+// The Realtime API speaks JSON text: binary frames, and client events that
+// are not JSON, end the session with 1003.
+func (g *Gateway) realtime(w http.ResponseWriter, r *http.Request) {
+	pol := g.policy(r)
+	model := r.URL.Query().Get("model")
+	if !pol.allowsModel(model) {
+		openAIError(w, http.StatusForbidden, "model_not_allowed", fmt.Sprintf("This API key may not use the model %q.", model))
+		return
+	}
+	rt, ok := g.route(model)
+	if !ok {
+		openAIError(w, http.StatusNotFound, "model_not_found", fmt.Sprintf("The model %q is not served by this gateway.", model))
+		return
+	}
+	user := g.client(r).user
+	subjects, ok := g.admit(w, r, pol, user)
+	if !ok {
+		return
+	}
+	requested := model
+	if rt.RewriteModel != "" {
+		model = rt.RewriteModel
+	}
+
+	q := r.URL.Query()
+	q.Set("model", model)
+	req, err := rt.backend.newRequest(r.Context(), http.MethodGet, "/realtime", model, q, nil)
+	if err != nil {
+		openAIError(w, http.StatusBadGateway, "upstream_error", "The model backend is unreachable.")
+		return
+	}
+	if v := r.Header.Get("OpenAI-Beta"); v != "" {
+		req.Header.Set("OpenAI-Beta", v)
+	}
+	// The backend is called with its own key, so a key passed as a
+	// subprotocol stays here.
+	var protocols []string
+	for _, p := range websocket.Subprotocols(r) {
+		if !strings.HasPrefix(p, insecureKeyProtocol) {
+			protocols = append(protocols, p)
+		}
+	}
+	if len(protocols) > 0 {
+		req.Header.Set("Sec-WebSocket-Protocol", strings.Join(protocols, ", "))
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	up, resp, err := websocket.Dial(ctx, req.URL, req.Header)
+	cancel()
+	if err != nil {
+		g.log(r, slog.LevelError, "backend failed", "backend", rt.backend.Name, "err", err)
+		if resp != nil {
+			g.relay(w, resp.StatusCode, resp.Header, resp.Body)
+			return
+		}
+		openAIError(w, http.StatusBadGateway, "upstream_error", "The model backend is unreachable.")
+		return
+	}
+	client, err := websocket.Accept(w, r, up.Subprotocol)
+	if err != nil {
+		up.Close(websocket.CloseGoingAway, "")
+		return
+	}
+	s := &realtimeSession{
+		g: g, r: r, pol: pol, user: user, model: model, subjects: subjects, client: client, up: up, answers: map[string]*answer{},
+		rec: archive.Record{
+			Time: time.Now().UTC(), Policy: pol.Name, Key: g.client(r).id, User: user, Endpoint: r.URL.Path,
+			Model: requested, Backend: rt.backend.Name, Stream: true, Status: http.StatusSwitchingProtocols,
+		},
+	}
+	s.run()
+}
+
+type realtimeSession struct {
+	g        *Gateway
+	r        *http.Request
+	pol      *Policy
+	user     string
+	model    string // as sent to the backend
+	subjects []subject
+	client   *websocket.Conn
+	up       *websocket.Conn
+
+	mu         sync.Mutex
+	transcript []guardrails.Message
+	answers    map[string]*answer // by response id
+	rec        archive.Record
+	closeOnce  sync.Once
+}
+
+// answer is a response transcript being streamed.
+type answer struct {
+	text    []rune
+	checked int
+}
+
+// realtimeEvent holds the fields of client and server events the session
+// inspects.
+type realtimeEvent struct {
+	Type       string       `json:"type"`
+	ResponseID string       `json:"response_id"`
+	Delta      string       `json:"delta"`
+	Transcript string       `json:"transcript"`
+	Item       realtimeItem `json:"item"`
+	Session    struct {
+		Instructions string `json:"instructions"`
+	} `json:"session"`
+	Response struct {
+		Instructions string         `json:"instructions"`
+		Input        []realtimeItem `json:"input"`
+		Usage        struct {
+			InputTokens  int64 `json:"input_tokens"`
+			OutputTokens int64 `json:"output_tokens"`
+		} `json:"usage"`
+	} `json:"response"`
+}
+
+// realtimeItem is a conversation item: a message, a function call or its
+// output.
+type realtimeItem struct {
+	Type    string `json:"type"`
+	Role    string `json:"role"`
+	Content []struct {
+		Type       string `json:"type"`
+		Text       string `json:"text"`
+		Transcript string `json:"transcript"`
+	} `json:"content"`
+	Arguments string `json:"arguments"`
+	Output    string `json:"output"`
+}
+
+// message is the text of it the model sees, as a message; ok is false when
+// it has none. Developer messages count as system ones, and function
+// output as the user's.
+func (it realtimeItem) message() (m guardrails.Message, ok bool) {
+	var text []string
+	for _, c := range it.Content {
+		for _, t := range []string{c.Text, c.Transcript} {
+			if t != "" {
+				text = append(text, t)
+			}
+		}
+	}
+	for _, t := range []string{it.Arguments, it.Output} {
+		if t != "" {
+			text = append(text, t)
+		}
+	}
+	if len(text) == 0 {
+		return m, false
+	}
+	role := it.Role
+	switch {
+	case it.Type == "function_call_output":
+		role = "user"
+	case it.Type == "function_call":
+		role = "assistant"
+	case role == "developer":
+		role = "system"
+	case role == "":
+		role = "user"
+	}
+	return guardrails.Message{Role: role, Content: strings.Join(text, "\n")}, true
+}
+
+func (s *realtimeSession) run() {
+	start := time.Now()
+	s.g.life.add(s)
+	defer s.g.life.remove(s)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.pump(s.up, s.client, s.fromBackend)
+	}()
+	s.pump(s.client, s.up, s.fromClient)
+	<-done
+	s.mu.Lock()
+	rec := s.rec
+	rec.Messages = append([]guardrails.Message(nil), s.transcript...)
+	s.mu.Unlock()
+	rec.LatencyMS = time.Since(start).Milliseconds()
+	s.g.logExchange(s.r, s.pol, &rec)
+	if s.g.cfg.Archiver != nil {
+		s.g.cfg.Archiver.Add(rec)
+	}
+}
+
+// pump relays messages from src to dst until either side closes. inspect
+// sees each text message first and reports whether to forward it.
+func (s *realtimeSession) pump(src, dst *websocket.Conn, inspect func([]byte) bool) {
+	for {
+		typ, msg, err := src.ReadMessage()
+		if err != nil {
+			code, reason := websocket.CloseGoingAway, ""
+			if ce, ok := err.(*websocket.CloseError); ok && ce.Code != 1005 {
+				code, reason = ce.Code, ce.Reason
+			}
+			s.close(code, reason)
+			return
+		}
+		if typ != websocket.Text {
+			s.close(websocket.CloseUnsupportedData, "binary frames are not supported")
+			return
+		}
+		if !inspect(msg) {
+			return
+		}
+		if err := dst.WriteMessage(typ, msg); err != nil {
+			s.close(websocket.CloseGoingAway, "")
+			return
+		}
+	}
+}
+
+func (s *realtimeSession) close(code int, reason string) {
+	s.closeOnce.Do(func() {
+		s.client.Close(code, reason)
+		s.up.Close(code, reason)
+	})
+}
+
+// fromClient checks text the client gives the model: items it adds to
+// the conversation, session instructions, and a response's instructions
+// and inline input.
+func (s *realtimeSession) fromClient(msg []byte) bool {
+	var ev realtimeEvent
+	if json.Unmarshal(msg, &ev) != nil {
+		s.close(websocket.CloseUnsupportedData, "events must be JSON")
+		return false
+	}
+	var add []guardrails.Message
+	var items []realtimeItem
+	switch ev.Type {
+	case "conversation.item.create":
+		items = append(items, ev.Item)
+	case "session.update":
+		if ev.Session.Instructions != "" {
+			add = append(add, guardrails.Message{Role: "system", Content: ev.Session.Instructions})
+		}
+	case "response.create":
+		if ev.Response.Instructions != "" {
+			add = append(add, guardrails.Message{Role: "system", Content: ev.Response.Instructions})
+		}
+		items = append(items, ev.Response.Input...)
+	}
+	for _, it := range items {
+		if m, ok := it.message(); ok {
+			add = append(add, m)
+		}
+	}
+	if len(add) == 0 {
+		return true
+	}
+	return s.turn("input", add...)
+}
+
+// fromBackend checks audio transcripts and the answer as it streams.
+func (s *realtimeSession) fromBackend(msg []byte) bool {
+	var ev realtimeEvent
+	if json.Unmarshal(msg, &ev) != nil {
+		return true
+	}
+	switch ev.Type {
+	case "conversation.item.input_audio_transcription.completed":
+		if ev.Transcript != "" {
+			return s.turn("input", guardrails.Message{Role: "user", Content: ev.Transcript})
+		}
+	case "response.text.delta", "response.audio_transcript.delta",
+		"response.output_text.delta", "response.output_audio_transcript.delta":
+		s.mu.Lock()
+		a := s.answers[ev.ResponseID]
+		if a == nil {
+			a = &answer{}
+			s.answers[ev.ResponseID] = a
+		}
+		a.text = append(a.text, []rune(ev.Delta)...)
+		due := (len(a.text)-a.checked)/4 >= s.g.cfg.Stream.CheckEvery
+		s.mu.Unlock()
+		if due {
+			return s.checkAnswer(ev.ResponseID, false)
+		}
+	case "response.text.done", "response.audio_transcript.done",
+		"response.output_text.done", "response.output_audio_transcript.done":
+		return s.checkAnswer(ev.ResponseID, true)
+	case "response.done":
+		u := ev.Response.Usage
+		cost := s.g.cost(u.InputTokens, u.OutputTokens, s.model, s.rec.Model)
+		s.g.usage.record(s.subjects, u.InputTokens, u.OutputTokens, cost)
+		s.g.rates.record(context.Background(), s.subjects, u.InputTokens+u.OutputTokens)
+		s.mu.Lock()
+		s.rec.PromptTokens += u.InputTokens
+		s.rec.CompletionTokens += u.OutputTokens
+		s.rec.Cost += cost
+		s.mu.Unlock()
+	}
+	return true
+}
+
+// turn checks messages in the context of the transcript and adds them.
+func (s *realtimeSession) turn(stage string, ms ...guardrails.Message) bool {
+	s.mu.Lock()
+	conv := append(append([]guardrails.Message(nil), s.transcript...), ms...)
+	s.mu.Unlock()
+	if !s.check(stage, conv) {
+		return false
+	}
+	s.remember(ms...)
+	return true
+}
+
+// checkAnswer checks the unchecked part of a response transcript (its
+// trailing Stream.Window tokens) and, once done, adds it to the transcript.
+func (s *realtimeSession) checkAnswer(id string, done bool) bool {
+	s.mu.Lock()
+	a := s.answers[id]
+	if a == nil {
+		s.mu.Unlock()
+		return true
+	}
+	var conv []guardrails.Message
+	if a.checked < len(a.text) {
+		start := max(len(a.text)-s.g.cfg.Stream.Window*4, 0)
+		conv = append(append([]guardrails.Message(nil), s.transcript...), guardrails.Message{Role: "assistant", Content: string(a.text[start:])})
+		a.checked = len(a.text)
+	}
+	text := string(a.text)
+	if done {
+		delete(s.answers, id)
+	}
+	s.mu.Unlock()
+	if conv != nil && !s.check("output", conv) {
+		return false
+	}
+	if done && text != "" {
+		s.remember(guardrails.Message{Role: "assistant", Content: text})
+	}
+	return true
+}
+
+func (s *realtimeSession) remember(ms ...guardrails.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.transcript = append(s.transcript, ms...)
+	keep := realtimeContext
+	if s.g.cfg.Archiver != nil {
+		keep = realtimeArchived
+	}
+	if n := len(s.transcript) - keep; n > 0 {
+		s.transcript = s.transcript[n:]
+	}
+}
+
+// check judges conv under the session's policy. On a denial the session
+// ends.
+func (s *realtimeSession) check(stage string, conv []guardrails.Message) bool {
+	if n := len(conv) - realtimeContext; n > 0 {
+		conv = conv[n:]
+	}
+	resp, ok := s.g.check(s.r, s.pol, stage, conv, s.user)
+	if ok {
+		return true
+	}
+	s.mu.Lock()
+	s.rec.Blocked = stage
+	if stage == "input" {
+		s.rec.Input = archive.NewVerdict(resp)
+	} else {
+		s.rec.Output = archive.NewVerdict(resp)
+	}
+	s.mu.Unlock()
+	s.g.log(s.r, slog.LevelWarn, "realtime session ended", append(verdictAttrs(resp), "stage", stage)...)
+
+	code, reason, message := websocket.ClosePolicyViolation, "content policy violation", guardrails.DefaultRefusal
+	errCode := "content_policy_violation"
+	if resp == nil {
+		code, reason, message = websocket.CloseInternalError, "content safety check unavailable", "Content safety check unavailable."
+		errCode = "guardrails_unavailable"
+	} else if resp.SuggestAnswer != "" {
+		message = resp.SuggestAnswer
+	}
+	ev, _ := json.Marshal(map[string]any{
+		"type":     "error",
+		"event_id": "ogw_" + fmt.Sprint(time.Now().UnixNano()),
+		"error":    map[string]any{"type": "invalid_request_error", "code": errCode, "message": message},
+	})
+	s.client.WriteMessage(websocket.Text, ev)
+	s.close(code, reason)
+	return false
+}
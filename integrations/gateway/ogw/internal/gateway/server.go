@@ -0,0 +1,203 @@
+package gateway
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Server serves a Gateway that can be replaced while running. Each request
+// is served by the Gateway current when it arrived, so a reload never cuts
+// off a request or stream in flight.
+type Server struct {
+	build   func() (*Gateway, error)
+	logger  *slog.Logger
+	started time.Time
+
+	mu      sync.Mutex // serializes reloads
+	cur     atomic.Pointer[served]
+	reloads int
+}
+
+type served struct {
+	gw       *Gateway
+	handler  http.Handler
+	loadedAt time.Time
+}
+
+// NewServer builds the first Gateway with build, which Reload calls again
+// for each replacement.
+func NewServer(build func() (*Gateway, error), logger *slog.Logger) (*Server, error) {
+	s := &Server{build: build, logger: logger, started: time.Now()}
+	gw, err := build()
+	if err != nil {
+		return nil, err
+	}
+	s.swap(gw)
+	return s, nil
+}
+
+// Gateway returns the current Gateway.
+func (s *Server) Gateway() *Gateway { return s.cur.Load().gw }
+
+func (s *Server) swap(gw *Gateway) {
+	s.cur.Store(&served{gw: gw, handler: gw.Handler(), loadedAt: time.Now()})
+}
+
+// ServeHTTP serves r with the current Gateway.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.cur.Load().handler.ServeHTTP(w, r)
+}
+
+// Reload builds a new Gateway and, if that succeeds, serves new requests
+// with it. Usage tallies, backend health and the drain state carry over.
+// On failure the current Gateway stays.
+func (s *Server) Reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	gw, err := s.build()
+	if err != nil {
+		return err
+	}
+	gw.usage = s.Gateway().usage
+	gw.rates.adopt(s.Gateway().rates)
+	gw.health.adopt(s.Gateway().health)
+	gw.life = s.Gateway().life
+	s.swap(gw)
+	s.reloads++
+	return nil
+}
+
+// AdminHandler returns the admin API, which requires one of keys, or a JWT
+// admitted by Config.OIDC, as a bearer token (neither: no one gets in):
+//
+//	GET  /admin/status       when the configuration was loaded, reload count, policies
+//	GET  /admin/backends     backends, the routes that lead to them, and their health
+//	GET  /admin/usage        requests and tokens per client key and user
+//	GET  /admin/shadow       where shadow configurations disagreed with the enforced ones
+//	POST /admin/reload       rebuild from the configuration source
+//	POST /admin/cache/flush  drop cached data (the merged model list, cached responses)
+func (s *Server) AdminHandler(keys []string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/status", s.status)
+	mux.HandleFunc("GET /admin/backends", s.backends)
+	mux.HandleFunc("GET /admin/usage", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{"usage": s.Gateway().usage.snapshot()})
+	})
+	mux.HandleFunc("GET /admin/shadow", func(w http.ResponseWriter, r *http.Request) {
+		gw := s.Gateway()
+		writeJSON(w, map[string]any{"shadow": gw.shadows.snapshot(gw.policies())})
+	})
+	mux.HandleFunc("POST /admin/reload", func(w http.ResponseWriter, r *http.Request) {
+		if err := s.Reload(); err != nil {
+			s.log(slog.LevelError, "reload failed", "err", err)
+			openAIError(w, http.StatusBadRequest, "invalid_config", err.Error())
+			return
+		}
+		s.log(slog.LevelInfo, "reloaded via admin API")
+		s.status(w, r)
+	})
+	mux.HandleFunc("POST /admin/cache/flush", func(w http.ResponseWriter, r *http.Request) {
+		s.Gateway().FlushCaches()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return s.adminAuth(keys, mux)
+}
+
+// adminAuth admits requests to h bearing one of keys or an admin JWT.
+func (s *Server) adminAuth(keys []string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gw := s.Gateway()
+		key, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		ok := gw.adminToken(r, key)
+		for _, k := range keys {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+				ok = true
+			}
+		}
+		if !ok {
+			openAIError(w, http.StatusUnauthorized, "invalid_api_key", "Invalid admin key.")
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) status(w http.ResponseWriter, _ *http.Request) {
+	cur := s.cur.Load()
+	s.mu.Lock()
+	reloads := s.reloads
+	s.mu.Unlock()
+	policies := []string{}
+	for _, p := range cur.gw.cfg.Policies {
+		policies = append(policies, p.Name)
+	}
+	out := map[string]any{
+		"loaded_at": cur.loadedAt.UTC().Format(time.RFC3339),
+		"reloads":   reloads,
+		"backends":  len(cur.gw.cfg.Backends),
+		"routes":    len(cur.gw.cfg.Routes),
+		"policies":  policies,
+	}
+	if cur.gw.cache != nil {
+		out["cache"] = cur.gw.cache.stats()
+	}
+	writeJSON(w, out)
+}
+
+func (s *Server) backends(w http.ResponseWriter, _ *http.Request) {
+	gw := s.Gateway()
+	out := []map[string]any{}
+	for i := range gw.cfg.Backends {
+		b := &gw.cfg.Backends[i]
+		routes := []string{}
+		for _, rt := range gw.cfg.Routes {
+			if rt.backend == b {
+				routes = append(routes, rt.Model)
+			}
+		}
+		if b.ModelPrefix != "" {
+			routes = append([]string{b.ModelPrefix + "*"}, routes...)
+		}
+		h, healthy := gw.health.snapshot(b.Name)
+		// Credentials are never echoed.
+		entry := map[string]any{
+			"name": b.Name, "type": b.Type, "url": b.base.String(),
+			"model_prefix": b.ModelPrefix, "routes": routes,
+			"healthy": healthy, "health": h,
+		}
+		if !healthy {
+			entry["unhealthy_until"] = h.UnhealthyUntil.UTC().Format(time.RFC3339)
+		}
+		out = append(out, entry)
+	}
+	writeJSON(w, map[string]any{"backends": out})
+}
+
+func (s *Server) log(level slog.Level, msg string, args ...any) {
+	if s.logger != nil {
+		s.logger.Log(context.Background(), level, msg, args...)
+	}
+}
+
+// FlushCaches drops the merged model list, so the next GET /v1/models asks
+// the backends again, and the response cache.
+func (g *Gateway) FlushCaches() {
+	g.list.mu.Lock()
+	g.list.models = nil
+	g.list.mu.Unlock()
+	if g.cache != nil {
+		g.cache.flush()
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
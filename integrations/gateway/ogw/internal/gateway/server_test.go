@@ -0,0 +1,95 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/openguardrails/openguardrails-go/guardrailstest"
+)
+
+func TestServerReload(t *testing.T) {
+	det := guardrailstest.NewServer()
+	t.Cleanup(det.Close)
+	url := backend(t).URL + "/v1"
+	cfg := Config{APIKeys: []string{"k1"}, Backends: []Backend{{Name: "echo", URL: url, APIKey: "sk-upstream"}}}
+	var buildErr error
+	srv, err := NewServer(func() (*Gateway, error) {
+		if buildErr != nil {
+			return nil, buildErr
+		}
+		return New(cfg, det.Client(), nil)
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := srv.AdminHandler([]string{"adm"})
+
+	if w := do(srv, "POST", "/v1/chat/completions", "k1", chat("hi", false)); w.Code != 200 {
+		t.Fatalf("before reload: %d %s", w.Code, w.Body)
+	}
+	if w := do(admin, "POST", "/admin/reload", "k1", ""); w.Code != 401 {
+		t.Fatalf("client key on admin API: %d", w.Code)
+	}
+	if w := do(srv.AdminHandler(nil), "GET", "/admin/status", "", ""); w.Code != 401 {
+		t.Fatalf("admin API without keys: %d", w.Code)
+	}
+
+	cfg.APIKeys = []string{"k2"}
+	if w := do(admin, "POST", "/admin/reload", "adm", ""); w.Code != 200 || !strings.Contains(w.Body.String(), `"reloads":1`) {
+		t.Fatalf("reload: %d %s", w.Code, w.Body)
+	}
+	if w := do(srv, "POST", "/v1/chat/completions", "k1", chat("hi", false)); w.Code != 401 {
+		t.Fatalf("old key after reload: %d", w.Code)
+	}
+	if w := do(srv, "POST", "/v1/chat/completions", "k2", chat("hi", false)); w.Code != 200 {
+		t.Fatalf("new key after reload: %d %s", w.Code, w.Body)
+	}
+
+	// A configuration that does not build leaves the current one serving.
+	buildErr = errors.New("backend \"x\": unknown type")
+	if w := do(admin, "POST", "/admin/reload", "adm", ""); w.Code != 400 || !strings.Contains(w.Body.String(), "unknown type") {
+		t.Fatalf("bad reload: %d %s", w.Code, w.Body)
+	}
+	if w := do(srv, "POST", "/v1/chat/completions", "k2", chat("hi", false)); w.Code != 200 {
+		t.Fatalf("after bad reload: %d", w.Code)
+	}
+}
+
+func TestServerAdmin(t *testing.T) {
+	det := guardrailstest.NewServer()
+	t.Cleanup(det.Close)
+	url := backend(t).URL + "/v1"
+	cfg := Config{
+		Backends: []Backend{
+			{Name: "echo", URL: url, APIKey: "sk-upstream"},
+			{Name: "local", URL: url, APIKey: "sk-upstream", ModelPrefix: "local/"},
+		},
+		Routes: []Route{{Model: "echo", Backend: "echo"}},
+	}
+	srv, err := NewServer(func() (*Gateway, error) { return New(cfg, det.Client(), nil) }, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := srv.AdminHandler([]string{"adm"})
+
+	w := do(admin, "GET", "/admin/backends", "adm", "")
+	var out struct {
+		Backends []struct {
+			Name   string   `json:"name"`
+			Routes []string `json:"routes"`
+		} `json:"backends"`
+	}
+	if json.Unmarshal(w.Body.Bytes(), &out); len(out.Backends) != 2 || out.Backends[1].Routes[0] != "local/*" || strings.Contains(w.Body.String(), "sk-upstream") {
+		t.Fatalf("backends: %s", w.Body)
+	}
+
+	do(srv, "GET", "/v1/models", "", "")
+	if srv.Gateway().list.models == nil {
+		t.Fatal("model list not cached")
+	}
+	if w := do(admin, "POST", "/admin/cache/flush", "adm", ""); w.Code != 204 || srv.Gateway().list.models != nil {
+		t.Fatalf("flush: %d", w.Code)
+	}
+}
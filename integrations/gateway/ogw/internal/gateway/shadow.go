@@ -0,0 +1,189 @@
+package gateway
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go"
+)
+
+// Shadow is a candidate verdict configuration: it judges the same traffic
+// as the policy it belongs to, and where the two disagree is reported (GET
+// /admin/shadow), but only the policy's verdict is enforced. With its own
+// detection key, a shadow check is a second, mirrored call made after the
+// enforced one; otherwise the enforced call's answer is judged again.
+type Shadow struct {
+	GuardrailsAPIKey    string            `yaml:"guardrails_api_key"`
+	GuardrailsAPIKeyEnv string            `yaml:"guardrails_api_key_env"`
+	Sensitivity         string            `yaml:"sensitivity"`
+	Categories          map[string]string `yaml:"categories"`
+}
+
+// shadowRecent is how many disagreements the report keeps per policy.
+const shadowRecent = 50
+
+// shadowInflight bounds the mirrored calls running at once; checks beyond
+// it are skipped rather than queued.
+const shadowInflight = 64
+
+func (p *Policy) initShadow() error {
+	if p.Shadow == nil {
+		return nil
+	}
+	p.shadow = &Policy{
+		Name:                p.Name + "/shadow",
+		GuardrailsAPIKey:    p.Shadow.GuardrailsAPIKey,
+		GuardrailsAPIKeyEnv: p.Shadow.GuardrailsAPIKeyEnv,
+		Sensitivity:         p.Shadow.Sensitivity,
+		Categories:          p.Shadow.Categories,
+	}
+	return p.shadow.initVerdict(p.guard)
+}
+
+// ShadowStats is how a policy's shadow compared to it.
+type ShadowStats struct {
+	Policy string `json:"policy"`
+	Checks int64  `json:"checks"`
+	// Disagreements split into checks only the enforced configuration
+	// blocked and checks only the shadow would have blocked.
+	Disagreements int64        `json:"disagreements"`
+	OnlyEnforced  int64        `json:"only_enforced_blocked"`
+	OnlyShadow    int64        `json:"only_shadow_blocked"`
+	Failed        int64        `json:"failed"`
+	Skipped       int64        `json:"skipped"`
+	Recent        []ShadowDiff `json:"recent"`
+}
+
+// ShadowDiff is one check the two configurations judged differently.
+type ShadowDiff struct {
+	Time     time.Time `json:"time"`
+	Stage    string    `json:"stage"` // input, output or stream
+	User     string    `json:"user,omitempty"`
+	Enforced Judgment  `json:"enforced"`
+	Shadow   Judgment  `json:"shadow"`
+}
+
+// Judgment is one configuration's view of a check.
+type Judgment struct {
+	ID         string                `json:"id"`
+	Allowed    bool                  `json:"allowed"`
+	RiskLevel  string                `json:"risk_level"`
+	Categories []guardrails.Category `json:"categories,omitempty"`
+}
+
+func judgment(resp *guardrails.Response, allowed bool) Judgment {
+	return Judgment{ID: resp.ID, Allowed: allowed, RiskLevel: string(resp.OverallRiskLevel), Categories: resp.Categories()}
+}
+
+// shadowReport collects the comparisons of all policies.
+type shadowReport struct {
+	mu       sync.Mutex
+	stats    map[string]*ShadowStats
+	inflight chan struct{}
+}
+
+func (s *shadowReport) get(pol *Policy) *ShadowStats {
+	if s.stats == nil {
+		s.stats = map[string]*ShadowStats{}
+	}
+	st := s.stats[pol.Name]
+	if st == nil {
+		st = &ShadowStats{Policy: pol.Name, Recent: []ShadowDiff{}}
+		s.stats[pol.Name] = st
+	}
+	return st
+}
+
+// snapshot returns the stats of every policy with a shadow.
+func (s *shadowReport) snapshot(policies []*Policy) []ShadowStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := []ShadowStats{}
+	for _, p := range policies {
+		if p.shadow != nil {
+			st := *s.get(p)
+			st.Recent = append([]ShadowDiff(nil), st.Recent...)
+			out = append(out, st)
+		}
+	}
+	return out
+}
+
+// shadowCheck judges a check the policy decided (resp, allowed) under the
+// policy's shadow as well.
+func (g *Gateway) shadowCheck(r *http.Request, pol *Policy, stage string, messages []guardrails.Message, user string, resp *guardrails.Response, allowed bool) {
+	sp := pol.shadow
+	if sp == nil || resp == nil {
+		return
+	}
+	enforced := judgment(resp, allowed)
+	if sp.guard == pol.guard {
+		g.compare(r, pol, stage, user, enforced, judgment(resp, sp.allows(resp)))
+		return
+	}
+	select {
+	case g.shadows.inflight <- struct{}{}:
+	default:
+		g.shadows.mu.Lock()
+		g.shadows.get(pol).Skipped++
+		g.shadows.mu.Unlock()
+		return
+	}
+	ctx := context.WithoutCancel(r.Context())
+	go func() {
+		defer func() { <-g.shadows.inflight }()
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		var opts []guardrails.CheckOption
+		if user != "" {
+			opts = append(opts, guardrails.WithUserID(user))
+		}
+		sresp, err := sp.guard.CheckConversation(ctx, messages, opts...)
+		if err != nil {
+			g.log(r, slog.LevelWarn, "shadow check failed", "stage", stage, "err", err)
+			g.shadows.mu.Lock()
+			g.shadows.get(pol).Failed++
+			g.shadows.mu.Unlock()
+			return
+		}
+		g.compare(r, pol, stage, user, enforced, judgment(sresp, sp.allows(sresp)))
+	}()
+}
+
+// shadowDecision wraps the stream decision of pol so each window is also
+// judged by its shadow. Only shadows sharing the policy's detection key
+// judge streams; a mirrored call per window would double their cost.
+func (g *Gateway) shadowDecision(r *http.Request, pol *Policy, user string) func(*guardrails.Response) bool {
+	if pol.shadow == nil || pol.shadow.guard != pol.guard {
+		return pol.decide
+	}
+	return func(resp *guardrails.Response) bool {
+		ok := pol.decide(resp)
+		g.compare(r, pol, "stream", user, judgment(resp, ok), judgment(resp, pol.shadow.allows(resp)))
+		return ok
+	}
+}
+
+func (g *Gateway) compare(r *http.Request, pol *Policy, stage, user string, enforced, shadow Judgment) {
+	g.shadows.mu.Lock()
+	defer g.shadows.mu.Unlock()
+	st := g.shadows.get(pol)
+	st.Checks++
+	if enforced.Allowed == shadow.Allowed {
+		return
+	}
+	st.Disagreements++
+	if shadow.Allowed {
+		st.OnlyEnforced++
+	} else {
+		st.OnlyShadow++
+	}
+	if len(st.Recent) == shadowRecent {
+		st.Recent = st.Recent[1:]
+	}
+	st.Recent = append(st.Recent, ShadowDiff{Time: time.Now().UTC(), Stage: stage, User: user, Enforced: enforced, Shadow: shadow})
+	g.log(r, slog.LevelInfo, "shadow disagrees", "stage", stage, "enforced_allowed", enforced.Allowed, "shadow_allowed", shadow.Allowed)
+}
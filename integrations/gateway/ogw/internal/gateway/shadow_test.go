@@ -0,0 +1,68 @@
+package gateway
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go"
+	"github.com/openguardrails/openguardrails-go/guardrailstest"
+	"github.com/openguardrails/openguardrails-go/verdict"
+)
+
+func TestShadow(t *testing.T) {
+	det := guardrailstest.NewServer()
+	t.Cleanup(det.Close)
+	cfg := Config{
+		APIKeys: []string{"k1"},
+		Shadow:  &Shadow{Sensitivity: SensitivityHigh},
+		Policies: []Policy{{
+			Name: "team-a", Keys: []string{"ka"},
+			Shadow: &Shadow{GuardrailsAPIKey: "sk-xxai-candidate", Categories: map[string]string{"S9": CategoryAllow}},
+		}},
+		Backends: []Backend{{Name: "echo", URL: backend(t).URL + "/v1", APIKey: "sk-upstream"}},
+	}
+	srv, err := NewServer(func() (*Gateway, error) { return New(cfg, det.Client(), nil) }, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	det.On("borderline", guardrails.Response{SuggestAction: guardrails.ActionPass, OverallRiskLevel: verdict.LowRisk})
+	det.Reject("injection", "S9")
+
+	// The default policy passes borderline prompts; its shadow would not.
+	if _, finish := content(t, do(srv, "POST", "/v1/chat/completions", "k1", chat("borderline", false))); finish == "content_filter" {
+		t.Fatal("shadow verdict enforced")
+	}
+	// team-a's candidate allows S9 and is checked with its own key.
+	if _, finish := content(t, do(srv, "POST", "/v1/chat/completions", "ka", chat("injection", false))); finish != "content_filter" {
+		t.Fatal("shadow verdict enforced")
+	}
+
+	var report struct {
+		Shadow []ShadowStats `json:"shadow"`
+	}
+	admin := srv.AdminHandler([]string{"adm"})
+	for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		json.Unmarshal(do(admin, "GET", "/admin/shadow", "adm", "").Body.Bytes(), &report)
+		if len(report.Shadow) == 2 && report.Shadow[1].Checks > 0 || time.Now().After(deadline) {
+			break
+		}
+	}
+	if len(report.Shadow) != 2 {
+		t.Fatalf("%+v", report)
+	}
+	// borderline: input and output (echo: borderline) both disagree.
+	if d := report.Shadow[0]; d.Policy != "default" || d.Checks != 2 || d.OnlyShadow != 2 || d.Recent[0].Stage != "input" || d.Recent[1].Stage != "output" {
+		t.Fatalf("default: %+v", d)
+	}
+	if d := report.Shadow[1]; d.Policy != "team-a" || d.OnlyEnforced != 1 || d.Recent[0].Enforced.Categories[0] != "S9" {
+		t.Fatalf("team-a: %+v", d)
+	}
+	candidate := false
+	for _, call := range det.Calls() {
+		candidate = candidate || call.Header.Get("Authorization") == "Bearer sk-xxai-candidate"
+	}
+	if !candidate {
+		t.Fatal("shadow check not sent with the candidate key")
+	}
+}
@@ -0,0 +1,243 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go"
+)
+
+// Quota bounds what one client key or end user may consume per minute, UTC
+// day and calendar month. Zero fields are unlimited. Token and cost limits
+// are checked before a request is forwarded, so the request that crosses
+// one completes. Costs come from Config.Prices.
+type Quota struct {
+	// The per-minute rates are measured over a sliding minute; see
+	// RateLimit for sharing them between instances.
+	RequestsPerMinute int64   `yaml:"requests_per_minute" json:"requests_per_minute,omitempty"`
+	TokensPerMinute   int64   `yaml:"tokens_per_minute" json:"tokens_per_minute,omitempty"`
+	DailyTokens       int64   `yaml:"daily_tokens" json:"daily_tokens,omitempty"`
+	MonthlyTokens     int64   `yaml:"monthly_tokens" json:"monthly_tokens,omitempty"`
+	DailyRequests     int64   `yaml:"daily_requests" json:"daily_requests,omitempty"`
+	MonthlyRequests   int64   `yaml:"monthly_requests" json:"monthly_requests,omitempty"`
+	DailyCost         float64 `yaml:"daily_cost" json:"daily_cost,omitempty"`
+	MonthlyCost       float64 `yaml:"monthly_cost" json:"monthly_cost,omitempty"`
+}
+
+// Tally is the recorded usage of one subject: a client key
+// ("key:<policy>/<fingerprint>") or an end user named by the request's
+// user field ("user:<policy>/<user>").
+type Tally struct {
+	Subject          string `json:"subject"`
+	Day              string `json:"day"`
+	Month            string `json:"month"`
+	DayTokens        int64  `json:"day_tokens"`
+	DayRequests      int64  `json:"day_requests"`
+	MonthTokens      int64  `json:"month_tokens"`
+	MonthRequests    int64  `json:"month_requests"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	// Costs priced by Config.Prices: this day, this month and in all.
+	DayCost   float64 `json:"day_cost"`
+	MonthCost float64 `json:"month_cost"`
+	Cost      float64 `json:"cost"`
+}
+
+// roll starts new periods when the day or month has changed.
+func (t *Tally) roll(now time.Time) {
+	if day := now.Format(time.DateOnly); t.Day != day {
+		t.Day, t.DayTokens, t.DayRequests, t.DayCost = day, 0, 0, 0
+	}
+	if month := now.Format("2006-01"); t.Month != month {
+		t.Month, t.MonthTokens, t.MonthRequests, t.MonthCost = month, 0, 0, 0
+	}
+}
+
+// exceeded reports whether t has used up q, and when the exhausted period
+// ends.
+func (t *Tally) exceeded(q Quota, now time.Time) (bool, time.Time) {
+	y, m, d := now.Date()
+	switch {
+	case q.MonthlyTokens > 0 && t.MonthTokens >= q.MonthlyTokens,
+		q.MonthlyRequests > 0 && t.MonthRequests >= q.MonthlyRequests,
+		q.MonthlyCost > 0 && t.MonthCost >= q.MonthlyCost:
+		return true, time.Date(y, m+1, 1, 0, 0, 0, 0, time.UTC)
+	case q.DailyTokens > 0 && t.DayTokens >= q.DailyTokens,
+		q.DailyRequests > 0 && t.DayRequests >= q.DailyRequests,
+		q.DailyCost > 0 && t.DayCost >= q.DailyCost:
+		return true, time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+	}
+	return false, time.Time{}
+}
+
+// usage counts requests and tokens per subject. It lives in memory and is
+// carried across reloads, not restarts.
+type usage struct {
+	mu      sync.Mutex
+	tallies map[string]*Tally
+	now     func() time.Time
+}
+
+func newUsage() *usage {
+	return &usage{tallies: map[string]*Tally{}, now: func() time.Time { return time.Now().UTC() }}
+}
+
+// subject is a tally name and the quota that applies to it.
+type subject struct {
+	name  string
+	quota Quota
+}
+
+func (u *usage) tally(name string, now time.Time) *Tally {
+	t := u.tallies[name]
+	if t == nil {
+		t = &Tally{Subject: name}
+		u.tallies[name] = t
+	}
+	t.roll(now)
+	return t
+}
+
+// admit counts a request against every subject, unless one of them has used
+// up its quota; then it reports when that quota resets.
+func (u *usage) admit(subjects []subject) (time.Time, bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	now := u.now()
+	for _, s := range subjects {
+		if over, reset := u.tally(s.name, now).exceeded(s.quota, now); over {
+			return reset, false
+		}
+	}
+	for _, s := range subjects {
+		t := u.tally(s.name, now)
+		t.DayRequests++
+		t.MonthRequests++
+	}
+	return time.Time{}, true
+}
+
+// record adds the tokens and cost of one exchange to every subject.
+func (u *usage) record(subjects []subject, prompt, completion int64, cost float64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	now := u.now()
+	for _, s := range subjects {
+		t := u.tally(s.name, now)
+		t.DayTokens += prompt + completion
+		t.MonthTokens += prompt + completion
+		t.PromptTokens += prompt
+		t.CompletionTokens += completion
+		t.DayCost += cost
+		t.MonthCost += cost
+		t.Cost += cost
+	}
+}
+
+// snapshot returns the tallies, rolled to the current periods, by subject.
+func (u *usage) snapshot() []Tally {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	now := u.now()
+	out := make([]Tally, 0, len(u.tallies))
+	for name := range u.tallies {
+		out = append(out, *u.tally(name, now))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Subject < out[j].Subject })
+	return out
+}
+
+// tokenUsage is the usage object of an OpenAI-style response or final
+// stream chunk.
+type tokenUsage struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+}
+
+// estimate counts tokens at four characters each, as stream checks do.
+func estimate(text string) int64 {
+	return int64(len([]rune(text))+3) / 4
+}
+
+func estimateMessages(messages []guardrails.Message) int64 {
+	var n int64
+	for _, m := range messages {
+		n += estimate(m.Content)
+	}
+	return n
+}
+
+// meter reads an SSE stream as it passes, keeping the usage chunk if the
+// backend sends one (stream_options.include_usage) and the generated text
+// for an estimate otherwise.
+type meter struct {
+	io.Reader
+	io.Closer
+	mu    sync.Mutex // a guarded stream may still be reading after Close
+	line  []byte
+	text  strings.Builder
+	usage *tokenUsage
+}
+
+func newMeter(body io.ReadCloser) *meter {
+	m := &meter{Closer: body}
+	m.Reader = io.TeeReader(body, writerFunc(m.write))
+	return m
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+func (m *meter) write(p []byte) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.line = append(m.line, p...)
+	for {
+		i := bytes.IndexByte(m.line, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if data, ok := bytes.CutPrefix(bytes.TrimSpace(m.line[:i]), []byte("data:")); ok {
+			var chunk struct {
+				Choices []struct {
+					Delta struct {
+						Content string `json:"content"`
+					} `json:"delta"`
+				} `json:"choices"`
+				Usage *tokenUsage `json:"usage"`
+			}
+			if json.Unmarshal(bytes.TrimSpace(data), &chunk) == nil {
+				for _, ch := range chunk.Choices {
+					m.text.WriteString(ch.Delta.Content)
+				}
+				if chunk.Usage != nil {
+					m.usage = chunk.Usage
+				}
+			}
+		}
+		m.line = m.line[i+1:]
+	}
+}
+
+// answer returns the text the backend generated.
+func (m *meter) answer() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.text.String()
+}
+
+// tokens returns the stream's usage, reported or estimated.
+func (m *meter) tokens(messages []guardrails.Message) (int64, int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.usage != nil {
+		return m.usage.PromptTokens, m.usage.CompletionTokens
+	}
+	return estimateMessages(messages), estimate(m.text.String())
+}
@@ -0,0 +1,80 @@
+package gateway
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go"
+	"github.com/openguardrails/openguardrails-go/guardrailstest"
+)
+
+func TestQuotas(t *testing.T) {
+	det := guardrailstest.NewServer()
+	t.Cleanup(det.Close)
+	gw, err := New(Config{
+		APIKeys:   []string{"k1"},
+		Quota:     Quota{DailyRequests: 2},
+		UserQuota: Quota{DailyTokens: 5},
+		Backends:  []Backend{{Name: "echo", URL: backend(t).URL + "/v1", APIKey: "sk-upstream"}},
+	}, det.Client(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 1, 31, 23, 0, 0, 0, time.UTC)
+	gw.usage.now = func() time.Time { return now }
+	h := gw.Handler()
+
+	// "echo: hello world" and its prompt come to 3 + 5 estimated tokens,
+	// using up u-7's token quota in one request.
+	if w := do(h, "POST", "/v1/chat/completions", "k1", chat("hello world", false)); w.Code != 200 {
+		t.Fatalf("first: %d %s", w.Code, w.Body)
+	}
+	w := do(h, "POST", "/v1/chat/completions", "k1", chat("again", false))
+	if w.Code != 429 || w.Header().Get("Retry-After") == "" || !strings.Contains(w.Body.String(), "insufficient_quota") {
+		t.Fatalf("user quota: %d %s", w.Code, w.Body)
+	}
+	// A refused request counts against nothing; other users share only the
+	// key's request quota.
+	anon := `{"model":"echo","messages":[{"role":"user","content":"hi"}]}`
+	if w := do(h, "POST", "/v1/chat/completions", "k1", anon); w.Code != 200 {
+		t.Fatalf("second: %d", w.Code)
+	}
+	if w := do(h, "POST", "/v1/chat/completions", "k1", anon); w.Code != 429 {
+		t.Fatalf("key quota: %d", w.Code)
+	}
+
+	tallies := gw.usage.snapshot()
+	if len(tallies) != 2 || !strings.HasPrefix(tallies[0].Subject, "key:default/") || tallies[0].DayRequests != 2 {
+		t.Fatalf("%+v", tallies)
+	}
+	if u := tallies[1]; u.Subject != "user:default/u-7" || u.PromptTokens != 3 || u.CompletionTokens != 5 || u.DayRequests != 1 {
+		t.Fatalf("%+v", u)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if w := do(h, "POST", "/v1/chat/completions", "k1", chat("next day", false)); w.Code != 200 {
+		t.Fatalf("next day: %d", w.Code)
+	}
+	if tl := gw.usage.snapshot()[0]; tl.Day != "2026-02-01" || tl.Month != "2026-02" || tl.MonthRequests != 1 {
+		t.Fatalf("%+v", tl)
+	}
+}
+
+func TestMeterStream(t *testing.T) {
+	msgs := []guardrails.Message{{Role: "user", Content: "12345678"}}
+	m := newMeter(io.NopCloser(strings.NewReader(
+		"data: {\"choices\":[{\"delta\":{\"content\":\"abcd\"}}]}\n\ndata: {\"choices\":[{\"delta\":{\"content\":\"efgh\"}}]}\n\ndata: [DONE]\n\n")))
+	io.Copy(io.Discard, m)
+	if p, c := m.tokens(msgs); p != 2 || c != 2 {
+		t.Fatalf("estimated %d %d", p, c)
+	}
+
+	m = newMeter(io.NopCloser(strings.NewReader(
+		"data: {\"choices\":[{\"delta\":{\"content\":\"abcd\"}}]}\n\ndata: {\"choices\":[],\"usage\":{\"prompt_tokens\":11,\"completion_tokens\":7}}\n\ndata: [DONE]\n\n")))
+	io.Copy(io.Discard, m)
+	if p, c := m.tokens(msgs); p != 11 || c != 7 {
+		t.Fatalf("reported %d %d", p, c)
+	}
+}
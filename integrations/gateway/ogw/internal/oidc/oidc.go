@@ -0,0 +1,311 @@
+// Package oidc verifies JSON Web Tokens issued by an OpenID Connect
+// provider: the signature against the provider's published keys (JWKS,
+// found through discovery), and the issuer, audience and validity window.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config configures a Verifier.
+type Config struct {
+	// Issuer is the provider's issuer URL; tokens must carry it as iss.
+	Issuer string `yaml:"issuer"`
+	// Audience, if set, must be among a token's aud.
+	Audience string `yaml:"audience"`
+	// JWKSURL overrides the key set URL found through discovery.
+	JWKSURL string `yaml:"jwks_url"`
+	// Leeway tolerates clock skew on exp and nbf (default 1m).
+	Leeway time.Duration `yaml:"leeway"`
+}
+
+// Claims are a verified token's payload.
+type Claims map[string]any
+
+// String returns claim name as a string; numbers are formatted.
+func (c Claims) String(name string) string {
+	switch v := c[name].(type) {
+	case string:
+		return v
+	case float64:
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// Has reports whether claim name is value or, for a list, contains it.
+func (c Claims) Has(name, value string) bool {
+	switch v := c[name].(type) {
+	case string:
+		return v == value
+	case []any:
+		for _, e := range v {
+			if e == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// keysTTL is how long a fetched key set is used before it is fetched
+// again; an unknown key id refetches it sooner, at most every keysMinAge.
+const (
+	keysTTL    = time.Hour
+	keysMinAge = time.Minute
+)
+
+// Verifier checks tokens. Keys are fetched on first use, so creating one
+// does not touch the network.
+type Verifier struct {
+	cfg  Config
+	http *http.Client
+	now  func() time.Time
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+// New returns a Verifier for cfg.
+func New(cfg Config) (*Verifier, error) {
+	if cfg.Issuer == "" {
+		return nil, errors.New("oidc: no issuer")
+	}
+	if cfg.Leeway <= 0 {
+		cfg.Leeway = time.Minute
+	}
+	return &Verifier{cfg: cfg, http: &http.Client{Timeout: 10 * time.Second}, now: time.Now}, nil
+}
+
+// LooksLikeJWT reports whether token has the three-part shape of a JWT, so
+// it is worth verifying rather than comparing as an API key.
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2 && strings.HasPrefix(token, "eyJ")
+}
+
+// Verify checks token and returns its claims.
+func (v *Verifier) Verify(ctx context.Context, token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("oidc: malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("oidc: header: %w", err)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("oidc: malformed signature")
+	}
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("oidc: claims: %w", err)
+	}
+	now := v.now()
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return nil, errors.New("oidc: token without exp")
+	}
+	if now.After(time.Unix(int64(exp), 0).Add(v.cfg.Leeway)) {
+		return nil, errors.New("oidc: token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(v.cfg.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New("oidc: token not yet valid")
+	}
+	if claims.String("iss") != v.cfg.Issuer {
+		return nil, fmt.Errorf("oidc: issuer %q not accepted", claims.String("iss"))
+	}
+	if v.cfg.Audience != "" && !claims.Has("aud", v.cfg.Audience) {
+		return nil, errors.New("oidc: audience not accepted")
+	}
+	return claims, nil
+}
+
+func decodeSegment(seg string, v any) error {
+	raw, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed string, sig []byte) error {
+	var hash crypto.Hash
+	switch alg[min(2, len(alg)):] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("oidc: unsupported alg %q", alg)
+	}
+	h := hash.New()
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		var err error
+		switch alg[:2] {
+		case "RS":
+			err = rsa.VerifyPKCS1v15(k, hash, digest, sig)
+		case "PS":
+			err = rsa.VerifyPSS(k, hash, digest, sig, nil)
+		default:
+			return fmt.Errorf("oidc: alg %q does not match an RSA key", alg)
+		}
+		if err != nil {
+			return errors.New("oidc: bad signature")
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if alg[:2] != "ES" || len(sig) != 2*size {
+			return fmt.Errorf("oidc: alg %q does not match an EC key", alg)
+		}
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return errors.New("oidc: bad signature")
+		}
+		return nil
+	}
+	return errors.New("oidc: unsupported key type")
+}
+
+// key returns the provider key kid, fetching the key set if it is stale or
+// does not have it.
+func (v *Verifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	age := v.now().Sub(v.fetched)
+	k, ok := v.lookup(kid)
+	if ok && age < keysTTL {
+		return k, nil
+	}
+	if v.keys == nil || age >= keysMinAge {
+		keys, err := v.fetch(ctx)
+		if err != nil {
+			if ok {
+				return k, nil // a stale key beats none while the provider is down
+			}
+			return nil, err
+		}
+		v.keys, v.fetched = keys, v.now()
+		if k, ok = v.lookup(kid); ok {
+			return k, nil
+		}
+	}
+	return nil, fmt.Errorf("oidc: unknown key %q", kid)
+}
+
+// lookup finds kid; a token without kid matches a set of one key.
+func (v *Verifier) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, k := range v.keys {
+			return k, true
+		}
+	}
+	k, ok := v.keys[kid]
+	return k, ok
+}
+
+func (v *Verifier) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	jwksURL := v.cfg.JWKSURL
+	if jwksURL == "" {
+		var disc struct {
+			Issuer  string `json:"issuer"`
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := v.get(ctx, strings.TrimSuffix(v.cfg.Issuer, "/")+"/.well-known/openid-configuration", &disc); err != nil {
+			return nil, fmt.Errorf("oidc: discovery: %w", err)
+		}
+		if disc.Issuer != v.cfg.Issuer || disc.JWKSURI == "" {
+			return nil, fmt.Errorf("oidc: discovery: issuer %q, jwks_uri %q", disc.Issuer, disc.JWKSURI)
+		}
+		jwksURL = disc.JWKSURI
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+	if err := v.get(ctx, jwksURL, &set); err != nil {
+		return nil, fmt.Errorf("oidc: keys: %w", err)
+	}
+	keys := map[string]crypto.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		switch k.Kty {
+		case "RSA":
+			n, err1 := base64.RawURLEncoding.DecodeString(k.N)
+			e, err2 := base64.RawURLEncoding.DecodeString(k.E)
+			if err1 != nil || err2 != nil || len(e) > 4 {
+				continue
+			}
+			keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			curve := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}[k.Crv]
+			x, err1 := base64.RawURLEncoding.DecodeString(k.X)
+			y, err2 := base64.RawURLEncoding.DecodeString(k.Y)
+			if curve == nil || err1 != nil || err2 != nil {
+				continue
+			}
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("oidc: the key set has no usable keys")
+	}
+	return keys, nil
+}
+
+func (v *Verifier) get(ctx context.Context, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := v.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,124 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openguardrails/openguardrails/integrations/gateway/ogw/internal/oidc/oidctest"
+)
+
+func TestVerify(t *testing.T) {
+	p := oidctest.NewProvider()
+	t.Cleanup(p.Close)
+	v, err := New(Config{Issuer: p.URL, Audience: "ogw"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	claims, err := v.Verify(ctx, p.Token(map[string]any{"sub": "alice", "aud": []string{"other", "ogw"}, "roles": []string{"admin"}}))
+	if err != nil || claims.String("sub") != "alice" || !claims.Has("roles", "admin") || claims.Has("roles", "root") {
+		t.Fatalf("valid: %v %v", claims, err)
+	}
+
+	hour := time.Hour.Seconds()
+	for name, c := range map[string]map[string]any{
+		"expired":      {"aud": "ogw", "exp": time.Now().Unix() - int64(hour)},
+		"not yet":      {"aud": "ogw", "nbf": time.Now().Unix() + int64(hour)},
+		"no exp":       {"aud": "ogw", "exp": nil},
+		"audience":     {"aud": "other"},
+		"issuer":       {"aud": "ogw", "iss": "https://evil.example"},
+		"missing aud":  {},
+		"string exp":   {"aud": "ogw", "exp": "never"},
+		"wrong issuer": {"aud": "ogw", "iss": p.URL + "/"},
+	} {
+		if _, err := v.Verify(ctx, p.Token(c)); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+
+	// Tampered payloads and unsigned tokens fail the signature.
+	tok := p.Token(map[string]any{"sub": "alice", "aud": "ogw"})
+	parts := strings.Split(tok, ".")
+	forged, _ := json.Marshal(map[string]any{"iss": p.URL, "aud": "ogw", "sub": "root", "exp": time.Now().Add(time.Hour).Unix()})
+	if _, err := v.Verify(ctx, parts[0]+"."+base64.RawURLEncoding.EncodeToString(forged)+"."+parts[2]); err == nil {
+		t.Error("tampered token accepted")
+	}
+	none := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	if _, err := v.Verify(ctx, none+"."+parts[1]+"."); err == nil {
+		t.Error("alg none accepted")
+	}
+
+	// A rotated key is fetched when a token names it.
+	fetches := p.Fetches()
+	v.now = func() time.Time { return time.Now().Add(2 * keysMinAge) }
+	p.Rotate()
+	if _, err := v.Verify(ctx, p.Token(map[string]any{"aud": "ogw"})); err != nil {
+		t.Fatalf("rotated: %v", err)
+	}
+	if p.Fetches() != fetches+1 {
+		t.Fatalf("fetches %d, want %d", p.Fetches(), fetches+1)
+	}
+	// Unknown keys do not refetch more than once per keysMinAge.
+	if _, err := v.Verify(ctx, strings.Replace(tok, parts[0], base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","kid":"nope"}`)), 1)); err == nil {
+		t.Fatal("unknown key accepted")
+	}
+	if p.Fetches() != fetches+1 {
+		t.Fatalf("refetched within %v", keysMinAge)
+	}
+}
+
+func TestVerifyEC(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	b64 := base64.RawURLEncoding.EncodeToString
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{
+			{"kty": "EC", "kid": "enc", "use": "enc", "crv": "P-256", "x": "AA", "y": "AA"},
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": b64(key.X.FillBytes(make([]byte, 32))), "y": b64(key.Y.FillBytes(make([]byte, 32)))},
+		}})
+	}))
+	t.Cleanup(srv.Close)
+	v, err := New(Config{Issuer: "https://id.example", JWKSURL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sign := func(alg string) string {
+		header, _ := json.Marshal(map[string]string{"alg": alg, "kid": "ec"})
+		payload, _ := json.Marshal(map[string]any{"iss": "https://id.example", "sub": "svc", "exp": time.Now().Add(time.Minute).Unix()})
+		signed := b64(header) + "." + b64(payload)
+		sum := sha256.Sum256([]byte(signed))
+		r, s, _ := ecdsa.Sign(rand.Reader, key, sum[:])
+		return signed + "." + b64(append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...))
+	}
+	if c, err := v.Verify(context.Background(), sign("ES256")); err != nil || c.String("sub") != "svc" {
+		t.Fatalf("ES256: %v %v", c, err)
+	}
+	if _, err := v.Verify(context.Background(), sign("RS256")); err == nil {
+		t.Fatal("RS256 accepted for an EC key")
+	}
+}
+
+func TestLooksLikeJWT(t *testing.T) {
+	for tok, want := range map[string]bool{
+		"eyJhbGciOiJSUzI1NiJ9.eyJzdWIiOiJhIn0.c2ln": true,
+		"sk-team-a":       false,
+		"eyJ.only-two":    false,
+		"a.b.c":           false,
+		"eyJa.b.c.d":      false,
+		"team-a.key.here": false,
+	} {
+		if LooksLikeJWT(tok) != want {
+			t.Errorf("%q: %v", tok, !want)
+		}
+	}
+}
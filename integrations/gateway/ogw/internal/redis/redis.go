@@ -0,0 +1,326 @@
+// Package redis is the small part of the Redis protocol (RESP2) the gateway
+// needs to share counters between instances: commands and pipelines over a
+// pool of TCP or TLS connections.
+//
+//	c, err := redis.New("redis://:secret@redis:6379/0")
+//	n, err := c.Do(ctx, "INCRBY", "hits", 1)
+//
+// Replies are int64 (integers), string (simple and bulk strings), []any
+// (arrays), nil (null) or Error.
+//
+// The gateway is built from the standard library, the SDK and yaml.v3, and
+// is meant to stay small enough to audit whole. A client such as go-redis
+// (which the SDK's rediscache uses) brings its own dependencies and a far
+// larger surface for the handful of commands the shared counters send, so
+// this package implements them directly. It does no RESP3, cluster or
+// sentinel discovery, pub/sub or scripting.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Error is an error reply.
+type Error string
+
+func (e Error) Error() string { return "redis: " + string(e) }
+
+// maxIdle bounds the connections a Client keeps open between commands.
+const maxIdle = 16
+
+// Client is a pool of connections to one server. It is safe for concurrent
+// use.
+type Client struct {
+	addr     string
+	user     string
+	password string
+	db       int
+	tls      *tls.Config
+	// Timeout bounds a command when its context has no deadline (default
+	// 5s).
+	Timeout time.Duration
+
+	mu     sync.Mutex
+	idle   []*conn
+	closed bool
+}
+
+// New returns a client for rawURL, redis://[[user]:password@]host[:port][/db]
+// or rediss:// for TLS. No connection is made until the first command.
+func New(rawURL string) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	c := &Client{Timeout: 5 * time.Second}
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		c.tls = &tls.Config{ServerName: u.Hostname()}
+	default:
+		return nil, fmt.Errorf("redis: unsupported scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("redis: no host in %q", u.Redacted())
+	}
+	port := u.Port()
+	if port == "" {
+		port = "6379"
+	}
+	c.addr = net.JoinHostPort(u.Hostname(), port)
+	if u.User != nil {
+		c.user = u.User.Username()
+		c.password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if c.db, err = strconv.Atoi(db); err != nil || c.db < 0 {
+			return nil, fmt.Errorf("redis: invalid database %q", db)
+		}
+	}
+	return c, nil
+}
+
+// Do sends one command and returns its reply. An error reply is returned
+// as an Error.
+func (c *Client) Do(ctx context.Context, args ...any) (any, error) {
+	replies, err := c.Pipeline(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := replies[0].(Error); ok {
+		return nil, e
+	}
+	return replies[0], nil
+}
+
+// Pipeline sends cmds in one round trip and returns their replies in
+// order. Error replies are returned in their place; err reports a failed
+// connection or a bad argument.
+func (c *Client) Pipeline(ctx context.Context, cmds ...[]any) ([]any, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	replies, err := cn.roundTrip(ctx, c.Timeout, cmds)
+	if err != nil {
+		cn.Close()
+		return nil, err
+	}
+	c.put(cn)
+	return replies, nil
+}
+
+// Close closes the idle connections; connections in use are closed when
+// their command completes.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for _, cn := range c.idle {
+		cn.Close()
+	}
+	c.idle = nil
+	return nil
+}
+
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, errors.New("redis: client closed")
+	}
+	if n := len(c.idle); n > 0 {
+		cn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return cn, nil
+	}
+	c.mu.Unlock()
+	return c.dial(ctx)
+}
+
+func (c *Client) put(cn *conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || len(c.idle) >= maxIdle {
+		cn.Close()
+		return
+	}
+	c.idle = append(c.idle, cn)
+}
+
+// dial connects, authenticates and selects the database.
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", c.addr)
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	if c.tls != nil {
+		tc := tls.Client(nc, c.tls)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		nc = tc
+	}
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+	var setup [][]any
+	switch {
+	case c.user != "":
+		setup = append(setup, []any{"AUTH", c.user, c.password})
+	case c.password != "":
+		setup = append(setup, []any{"AUTH", c.password})
+	}
+	if c.db != 0 {
+		setup = append(setup, []any{"SELECT", c.db})
+	}
+	if len(setup) > 0 {
+		replies, err := cn.roundTrip(ctx, c.Timeout, setup)
+		if err == nil {
+			for _, r := range replies {
+				if e, ok := r.(Error); ok {
+					err = e
+					break
+				}
+			}
+		}
+		if err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+// conn is one connection.
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func (cn *conn) roundTrip(ctx context.Context, timeout time.Duration, cmds [][]any) ([]any, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(timeout)
+	}
+	cn.SetDeadline(deadline)
+	// A context canceled mid-command unblocks the read.
+	stop := context.AfterFunc(ctx, func() { cn.SetDeadline(time.Unix(1, 0)) })
+	defer stop()
+	for _, args := range cmds {
+		if err := writeCommand(cn.w, args); err != nil {
+			return nil, err
+		}
+	}
+	if err := cn.w.Flush(); err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	replies := make([]any, len(cmds))
+	for i := range replies {
+		r, err := readReply(cn.r)
+		if err != nil {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		replies[i] = r
+	}
+	return replies, nil
+}
+
+// writeCommand writes args as an array of bulk strings.
+func writeCommand(w *bufio.Writer, args []any) error {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, a := range args {
+		var s string
+		switch a := a.(type) {
+		case string:
+			s = a
+		case []byte:
+			s = string(a)
+		case int:
+			s = strconv.Itoa(a)
+		case int64:
+			s = strconv.FormatInt(a, 10)
+		default:
+			return fmt.Errorf("redis: unsupported argument type %T", a)
+		}
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(s), s)
+	}
+	return nil
+}
+
+// readReply reads one reply.
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return Error(body), nil
+	case ':':
+		n, err := strconv.ParseInt(body, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed integer %q", body)
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < -1 {
+			return nil, fmt.Errorf("malformed bulk length %q", body)
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		if buf[n] != '\r' || buf[n+1] != '\n' {
+			return nil, fmt.Errorf("bulk string longer than %d bytes", n)
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil || n < -1 {
+			return nil, fmt.Errorf("malformed array length %q", body)
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		out := make([]any, n)
+		for i := range out {
+			if out[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unknown reply type %q", kind)
+}
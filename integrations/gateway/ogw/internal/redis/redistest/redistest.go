@@ -0,0 +1,336 @@
+// Package redistest provides a fake Redis server for tests:
+//
+//	s := redistest.NewServer()
+//	defer s.Close()
+//	c, err := redis.New(s.URL)
+//
+// It keeps strings and hashes in memory and knows the commands the gateway
+// sends: PING, AUTH, SELECT, GET, SET, MGET, INCRBY, DEL, EXPIRE, TTL,
+// HINCRBY and HMGET. Keys expire on the server's clock, which tests can
+// move with Advance.
+package redistest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is a fake Redis server.
+type Server struct {
+	// URL is the server's redis:// URL, with the password if one is set.
+	URL string
+
+	ln       net.Listener
+	password string
+
+	mu       sync.Mutex
+	strings  map[string]string
+	hashes   map[string]map[string]string
+	expires  map[string]time.Time
+	offset   time.Duration
+	commands int
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a server that needs no password.
+func NewServer() *Server { return NewServerWithPassword("") }
+
+// NewServerWithPassword starts a server that refuses commands until a
+// client sends AUTH password.
+func NewServerWithPassword(password string) *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("redistest: listen: %v", err))
+	}
+	s := &Server{
+		ln: ln, password: password,
+		strings: map[string]string{}, hashes: map[string]map[string]string{},
+		expires: map[string]time.Time{}, conns: map[net.Conn]struct{}{},
+	}
+	s.URL = "redis://" + ln.Addr().String()
+	if password != "" {
+		s.URL = "redis://:" + password + "@" + ln.Addr().String()
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Close stops the server and drops its connections.
+func (s *Server) Close() {
+	s.ln.Close()
+	s.mu.Lock()
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Advance moves the server's clock forward by d.
+func (s *Server) Advance(d time.Duration) {
+	s.mu.Lock()
+	s.offset += d
+	s.mu.Unlock()
+}
+
+// Commands returns how many commands the server has run.
+func (s *Server) Commands() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commands
+}
+
+// Get returns a string key's value.
+func (s *Server) Get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(key)
+	v, ok := s.strings[key]
+	return v, ok
+}
+
+// HGet returns a hash field's value.
+func (s *Server) HGet(key, field string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(key)
+	v, ok := s.hashes[key][field]
+	return v, ok
+}
+
+// TTL returns a key's time to live, zero when it has none.
+func (s *Server) TTL(key string) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expire(key)
+	if at, ok := s.expires[key]; ok {
+		return at.Sub(s.now())
+	}
+	return 0
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[c] = struct{}{}
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(c)
+			s.mu.Lock()
+			delete(s.conns, c)
+			s.mu.Unlock()
+			c.Close()
+		}()
+	}
+}
+
+func (s *Server) handle(c net.Conn) {
+	r, w := bufio.NewReader(c), bufio.NewWriter(c)
+	authed := s.password == ""
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		name := strings.ToUpper(args[0])
+		switch {
+		case name == "AUTH":
+			if args[len(args)-1] != s.password {
+				w.WriteString("-WRONGPASS invalid username-password pair\r\n")
+			} else {
+				authed = true
+				w.WriteString("+OK\r\n")
+			}
+		case !authed:
+			w.WriteString("-NOAUTH Authentication required.\r\n")
+		default:
+			s.mu.Lock()
+			s.commands++
+			s.run(w, name, args[1:])
+			s.mu.Unlock()
+		}
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) now() time.Time { return time.Now().Add(s.offset) }
+
+// expire deletes key if its time is up.
+func (s *Server) expire(key string) {
+	if at, ok := s.expires[key]; ok && !s.now().Before(at) {
+		delete(s.strings, key)
+		delete(s.hashes, key)
+		delete(s.expires, key)
+	}
+}
+
+// run executes a command with s.mu held.
+func (s *Server) run(w *bufio.Writer, name string, args []string) {
+	arity := map[string]int{
+		"PING": 0, "SELECT": 1, "GET": 1, "SET": 2, "MGET": -1, "INCRBY": 2, "DEL": -1,
+		"EXPIRE": 2, "TTL": 1, "HINCRBY": 3, "HMGET": -2,
+	}
+	n, ok := arity[name]
+	if !ok {
+		fmt.Fprintf(w, "-ERR unknown command '%s'\r\n", name)
+		return
+	}
+	if n >= 0 && len(args) != n || n < 0 && len(args) < -n {
+		fmt.Fprintf(w, "-ERR wrong number of arguments for '%s' command\r\n", strings.ToLower(name))
+		return
+	}
+	for _, k := range args {
+		s.expire(k)
+	}
+	switch name {
+	case "PING":
+		w.WriteString("+PONG\r\n")
+	case "SELECT":
+		w.WriteString("+OK\r\n")
+	case "GET":
+		bulk(w, s.strings[args[0]], s.has(args[0]))
+	case "SET":
+		s.strings[args[0]] = args[1]
+		delete(s.hashes, args[0])
+		delete(s.expires, args[0])
+		w.WriteString("+OK\r\n")
+	case "MGET":
+		fmt.Fprintf(w, "*%d\r\n", len(args))
+		for _, k := range args {
+			v, ok := s.strings[k]
+			bulk(w, v, ok)
+		}
+	case "INCRBY":
+		by, err1 := strconv.ParseInt(args[1], 10, 64)
+		v, err2 := strconv.ParseInt(or(s.strings[args[0]], "0"), 10, 64)
+		if err1 != nil || err2 != nil {
+			w.WriteString("-ERR value is not an integer or out of range\r\n")
+			return
+		}
+		s.strings[args[0]] = strconv.FormatInt(v+by, 10)
+		fmt.Fprintf(w, ":%d\r\n", v+by)
+	case "DEL":
+		n := 0
+		for _, k := range args {
+			if s.has(k) {
+				n++
+			}
+			delete(s.strings, k)
+			delete(s.hashes, k)
+			delete(s.expires, k)
+		}
+		fmt.Fprintf(w, ":%d\r\n", n)
+	case "EXPIRE":
+		secs, err := strconv.Atoi(args[1])
+		if err != nil {
+			w.WriteString("-ERR value is not an integer or out of range\r\n")
+			return
+		}
+		if !s.has(args[0]) {
+			w.WriteString(":0\r\n")
+			return
+		}
+		s.expires[args[0]] = s.now().Add(time.Duration(secs) * time.Second)
+		w.WriteString(":1\r\n")
+	case "TTL":
+		at, ok := s.expires[args[0]]
+		switch {
+		case !s.has(args[0]):
+			w.WriteString(":-2\r\n")
+		case !ok:
+			w.WriteString(":-1\r\n")
+		default:
+			fmt.Fprintf(w, ":%d\r\n", int64(at.Sub(s.now()).Seconds()+0.5))
+		}
+	case "HINCRBY":
+		h := s.hashes[args[0]]
+		by, err1 := strconv.ParseInt(args[2], 10, 64)
+		v, err2 := strconv.ParseInt(or(h[args[1]], "0"), 10, 64)
+		if err1 != nil || err2 != nil {
+			w.WriteString("-ERR value is not an integer or out of range\r\n")
+			return
+		}
+		if h == nil {
+			h = map[string]string{}
+			s.hashes[args[0]] = h
+		}
+		h[args[1]] = strconv.FormatInt(v+by, 10)
+		fmt.Fprintf(w, ":%d\r\n", v+by)
+	case "HMGET":
+		h := s.hashes[args[0]]
+		fmt.Fprintf(w, "*%d\r\n", len(args)-1)
+		for _, f := range args[1:] {
+			v, ok := h[f]
+			bulk(w, v, ok)
+		}
+	}
+}
+
+func (s *Server) has(key string) bool {
+	_, str := s.strings[key]
+	_, hash := s.hashes[key]
+	return str || hash
+}
+
+func bulk(w *bufio.Writer, v string, ok bool) {
+	if !ok {
+		w.WriteString("$-1\r\n")
+		return
+	}
+	fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+}
+
+func or(v, def string) string {
+	if v == "" {
+		return def
+	}
+	return v
+}
+
+// readCommand reads an array of bulk strings.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil || n < 1 || line[0] != '*' {
+		return nil, fmt.Errorf("redistest: malformed command %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil || line[0] != '$' {
+			return nil, fmt.Errorf("redistest: malformed argument %q", line)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
@@ -0,0 +1,57 @@
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestReadReply(t *testing.T) {
+	for raw, want := range map[string]any{
+		"+OK\r\n":                             "OK",
+		"-ERR unknown command 'NOPE'\r\n":     Error("ERR unknown command 'NOPE'"),
+		":-42\r\n":                            int64(-42),
+		"$5\r\nhe\r\no\r\n":                   "he\r\no",
+		"$0\r\n\r\n":                          "",
+		"$-1\r\n":                             nil,
+		"*-1\r\n":                             nil,
+		"*0\r\n":                              []any{},
+		"*3\r\n:1\r\n$-1\r\n-WRONGTYPE x\r\n": []any{int64(1), nil, Error("WRONGTYPE x")},
+		"*2\r\n*1\r\n+a\r\n$1\r\nb\r\n":       []any{[]any{"a"}, "b"},
+	} {
+		// Replies arriving a byte at a time read as whole ones.
+		got, err := readReply(bufio.NewReader(iotest.OneByteReader(strings.NewReader(raw))))
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: %#v %v", raw, got, err)
+		}
+	}
+}
+
+func TestReadReplyErrors(t *testing.T) {
+	for _, raw := range []string{
+		"",                   // nothing
+		"+OK",                // line cut off
+		"+OK\n",              // no CR
+		"\r\n",               // no type
+		"?x\r\n",             // unknown type
+		":12x\r\n",           // bad integer
+		"$x\r\n",             // bad bulk length
+		"$-2\r\n",            // negative bulk length
+		"$5\r\nhel",          // bulk cut off
+		"$3\r\nhello\r\n",    // bulk longer than its length
+		"*2\r\n:1\r\n",       // array cut off
+		"*-3\r\n",            // negative array length
+		"*1\r\n$3\r\nab\r\n", // element cut off
+	} {
+		if got, err := readReply(bufio.NewReader(strings.NewReader(raw))); err == nil || got != nil {
+			t.Errorf("%q: %#v %v", raw, got, err)
+		}
+	}
+	if _, err := readReply(bufio.NewReader(strings.NewReader("$5\r\nhel"))); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("short bulk: %v", err)
+	}
+}
@@ -0,0 +1,369 @@
+// Package websocket is the small part of RFC 6455 the gateway needs to
+// relay WebSocket APIs: the opening handshake on both ends, and whole
+// text and binary messages with control frames handled underneath.
+// Extensions (permessage-deflate) are not negotiated.
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message types.
+const (
+	Text   = 1
+	Binary = 2
+)
+
+const (
+	opContinuation = 0
+	opClose        = 8
+	opPing         = 9
+	opPong         = 10
+)
+
+// Close codes.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	ClosePolicyViolation = 1008
+	CloseTooBig          = 1009
+	CloseInternalError   = 1011
+)
+
+// DefaultReadLimit bounds a message, fragments included.
+const DefaultReadLimit = 16 << 20
+
+// CloseError is returned by ReadMessage once the peer has closed.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: closed (%d %s)", e.Code, e.Reason)
+}
+
+// Conn is an open WebSocket. One goroutine may read while others write.
+type Conn struct {
+	conn      net.Conn
+	br        *bufio.Reader
+	client    bool // clients mask what they send
+	readLimit int64
+	// Subprotocol is the protocol agreed in the handshake, if any.
+	Subprotocol string
+
+	wmu    sync.Mutex
+	closed bool
+}
+
+// SetReadLimit bounds the messages ReadMessage accepts.
+func (c *Conn) SetReadLimit(n int64) { c.readLimit = n }
+
+// IsUpgrade reports whether r asks for a WebSocket.
+func IsUpgrade(r *http.Request) bool {
+	return headerHas(r.Header, "Connection", "upgrade") && headerHas(r.Header, "Upgrade", "websocket")
+}
+
+// Subprotocols returns the protocols r offers.
+func Subprotocols(r *http.Request) []string {
+	var out []string
+	for _, v := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				out = append(out, p)
+			}
+		}
+	}
+	return out
+}
+
+// Accept completes the server side of the handshake, agreeing to
+// subprotocol if not empty.
+func Accept(w http.ResponseWriter, r *http.Request, subprotocol string) (*Conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if !IsUpgrade(r) || r.Method != http.MethodGet || key == "" || r.Header.Get("Sec-WebSocket-Version") != "13" {
+		http.Error(w, "WebSocket upgrade required", http.StatusUpgradeRequired)
+		return nil, errors.New("websocket: not an upgrade request")
+	}
+	conn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "WebSocket not supported", http.StatusInternalServerError)
+		return nil, err
+	}
+	resp := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: " + acceptKey(key) + "\r\n"
+	if subprotocol != "" {
+		resp += "Sec-WebSocket-Protocol: " + subprotocol + "\r\n"
+	}
+	if _, err := io.WriteString(conn, resp+"\r\n"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Conn{conn: conn, br: brw.Reader, readLimit: DefaultReadLimit, Subprotocol: subprotocol}, nil
+}
+
+// Dial opens a WebSocket to u (ws, wss, http or https) with header added
+// to the handshake request. On a refused handshake the response is
+// returned with the error, its body read.
+func Dial(ctx context.Context, u *url.URL, header http.Header) (*Conn, *http.Response, error) {
+	u2 := *u
+	switch u2.Scheme {
+	case "ws":
+		u2.Scheme = "http"
+	case "wss":
+		u2.Scheme = "https"
+	}
+	host := u2.Host
+	if u2.Port() == "" {
+		host = net.JoinHostPort(u2.Hostname(), map[string]string{"http": "80", "https": "443"}[u2.Scheme])
+	}
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u2.Scheme == "https" {
+		tc := tls.Client(conn, &tls.Config{ServerName: u2.Hostname(), NextProtos: []string{"http/1.1"}})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		conn = tc
+	}
+	if dl, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(dl)
+	}
+
+	var nonce [16]byte
+	rand.Read(nonce[:])
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+	req, err := http.NewRequest(http.MethodGet, u2.String(), nil)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+		resp.Body = io.NopCloser(strings.NewReader(string(body)))
+		conn.Close()
+		return nil, resp, fmt.Errorf("websocket: handshake refused with status %d", resp.StatusCode)
+	}
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		conn.Close()
+		return nil, resp, errors.New("websocket: bad Sec-WebSocket-Accept")
+	}
+	conn.SetDeadline(time.Time{})
+	return &Conn{conn: conn, br: br, client: true, readLimit: DefaultReadLimit, Subprotocol: resp.Header.Get("Sec-WebSocket-Protocol")}, resp, nil
+}
+
+// ReadMessage returns the next text or binary message, answering pings on
+// the way. Once the peer closes it returns a *CloseError.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	var (
+		typ int
+		msg []byte
+	)
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch op {
+		case opPing:
+			c.writeFrame(opPong, payload)
+			continue
+		case opPong:
+			continue
+		case opClose:
+			ce := &CloseError{Code: 1005}
+			if len(payload) >= 2 {
+				ce.Code, ce.Reason = int(binary.BigEndian.Uint16(payload)), string(payload[2:])
+			}
+			code := ce.Code
+			if code == 1005 {
+				code = CloseNormal // 1005 means "no code" and is never sent
+			}
+			c.Close(code, "")
+			return 0, nil, ce
+		case opContinuation:
+			if typ == 0 {
+				c.Close(CloseProtocolError, "unexpected continuation")
+				return 0, nil, errors.New("websocket: unexpected continuation frame")
+			}
+		case Text, Binary:
+			if typ != 0 {
+				c.Close(CloseProtocolError, "expected continuation")
+				return 0, nil, errors.New("websocket: expected continuation frame")
+			}
+			typ = op
+		default:
+			c.Close(CloseProtocolError, "unknown opcode")
+			return 0, nil, fmt.Errorf("websocket: unknown opcode %d", op)
+		}
+		if int64(len(msg)+len(payload)) > c.readLimit {
+			c.Close(CloseTooBig, "message too big")
+			return 0, nil, errors.New("websocket: message too big")
+		}
+		msg = append(msg, payload...)
+		if fin {
+			return typ, msg, nil
+		}
+	}
+}
+
+func (c *Conn) readFrame() (fin bool, op int, payload []byte, err error) {
+	var h [2]byte
+	if _, err = io.ReadFull(c.br, h[:]); err != nil {
+		return
+	}
+	fin, op = h[0]&0x80 != 0, int(h[0]&0x0f)
+	masked := h[1]&0x80 != 0
+	n := int64(h[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		n = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		n = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+	if n < 0 || n > c.readLimit {
+		c.Close(CloseTooBig, "message too big")
+		return false, 0, nil, errors.New("websocket: message too big")
+	}
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+			return
+		}
+	}
+	payload = make([]byte, n)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return
+}
+
+// WriteMessage sends data as one message of type typ.
+func (c *Conn) WriteMessage(typ int, data []byte) error {
+	return c.writeFrame(typ, data)
+}
+
+func (c *Conn) writeFrame(op int, payload []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closed {
+		return net.ErrClosed
+	}
+	return c.writeFrameLocked(op, payload)
+}
+
+func (c *Conn) writeFrameLocked(op int, payload []byte) error {
+	frame := make([]byte, 0, 14+len(payload))
+	frame = append(frame, 0x80|byte(op))
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+	switch n := len(payload); {
+	case n < 126:
+		frame = append(frame, maskBit|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+	if c.client {
+		var mask [4]byte
+		rand.Read(mask[:])
+		frame = append(frame, mask[:]...)
+		start := len(frame)
+		frame = append(frame, payload...)
+		for i := range payload {
+			frame[start+i] ^= mask[i%4]
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// Close sends a close frame with code and reason, then closes the
+// connection. Closing twice is harmless.
+func (c *Conn) Close(code int, reason string) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	c.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	c.writeFrameLocked(opClose, append(binary.BigEndian.AppendUint16(nil, uint16(code)), reason...))
+	return c.conn.Close()
+}
+
+func acceptKey(key string) string {
+	h := sha1.Sum([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+func headerHas(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
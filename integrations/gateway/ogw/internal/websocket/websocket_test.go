@@ -0,0 +1,77 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := Subprotocols(r); len(got) != 2 || got[1] != "b" {
+			t.Errorf("subprotocols %v", got)
+		}
+		c, err := Accept(w, r, "a")
+		if err != nil {
+			return
+		}
+		for {
+			typ, msg, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			if string(msg) == "bye" {
+				c.Close(ClosePolicyViolation, "no")
+				return
+			}
+			c.WriteMessage(typ, append([]byte("echo "), msg...))
+		}
+	}))
+	defer srv.Close()
+
+	u, _ := url.Parse(strings.Replace(srv.URL, "http", "ws", 1))
+	c, _, err := Dial(context.Background(), u, http.Header{"Sec-Websocket-Protocol": {"a, b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Subprotocol != "a" {
+		t.Fatalf("subprotocol %q", c.Subprotocol)
+	}
+	// A ping before the message is answered underneath, and a 70000-byte
+	// message takes the 64-bit length.
+	c.writeFrame(opPing, []byte("p"))
+	big := strings.Repeat("x", 70000)
+	for _, m := range []string{"hi", big} {
+		if err := c.WriteMessage(Text, []byte(m)); err != nil {
+			t.Fatal(err)
+		}
+		typ, got, err := c.ReadMessage()
+		if err != nil || typ != Text || string(got) != "echo "+m {
+			t.Fatalf("%d %.20q %v", typ, got, err)
+		}
+	}
+	c.WriteMessage(Text, []byte("bye"))
+	_, _, err = c.ReadMessage()
+	var ce *CloseError
+	if !errors.As(err, &ce) || ce.Code != ClosePolicyViolation || ce.Reason != "no" {
+		t.Fatalf("%v", err)
+	}
+	if err := c.WriteMessage(Text, []byte("late")); err == nil {
+		t.Fatal("write after close")
+	}
+}
+
+func TestDialRefused(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "nope", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	if _, resp, err := Dial(context.Background(), u, nil); err == nil || resp == nil || resp.StatusCode != 401 {
+		t.Fatalf("%v %v", resp, err)
+	}
+}
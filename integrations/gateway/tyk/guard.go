@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/openguardrails/openguardrails-go"
+)
+
+// settings is the "openguardrails" object of an API's config_data.
+type settings struct {
+	BaseURL   string `json:"base_url"`
+	APIKey    string `json:"api_key"`
+	APIKeyEnv string `json:"api_key_env"`
+	// UserID lists where the end user's ID comes from, tried in order:
+	// meta.<field> (session metadata), alias, key (the key's hash) or
+	// header.<name>. The platform keys risk tracking and ban policies on
+	// it.
+	UserID   string `json:"user_id"`
+	FailOpen bool   `json:"fail_open"`
+	// SkipResponse checks prompts only.
+	SkipResponse bool  `json:"skip_response"`
+	MaxBody      int64 `json:"max_body"`
+}
+
+const defaultUserID = "meta.user_id,alias"
+
+// identity is what the Tyk session says about the caller.
+type identity struct {
+	alias   string
+	keyHash string
+	meta    map[string]any
+}
+
+// guard checks the traffic of one API.
+type guard struct {
+	s      settings
+	client *guardrails.Client
+}
+
+func newGuard(s settings) (*guard, error) {
+	if s.UserID == "" {
+		s.UserID = defaultUserID
+	}
+	if s.MaxBody <= 0 {
+		s.MaxBody = guardrails.DefaultMaxBody
+	}
+	key := s.APIKey
+	if s.APIKeyEnv != "" {
+		key = os.Getenv(s.APIKeyEnv)
+	}
+	if key == "" {
+		return nil, fmt.Errorf("openguardrails: api_key or api_key_env is required")
+	}
+	opts := []guardrails.Option{guardrails.WithAPIKey(key)}
+	if s.BaseURL != "" {
+		opts = append(opts, guardrails.WithBaseURL(s.BaseURL))
+	}
+	return &guard{s: s, client: guardrails.NewClient(opts...)}, nil
+}
+
+// userID resolves the end user behind r from the sources in settings.
+func (g *guard) userID(r *http.Request, id identity) string {
+	for _, src := range strings.Split(g.s.UserID, ",") {
+		src = strings.TrimSpace(src)
+		var v string
+		switch {
+		case src == "alias":
+			v = id.alias
+		case src == "key":
+			v = id.keyHash
+		case strings.HasPrefix(src, "meta."):
+			switch m := id.meta[strings.TrimPrefix(src, "meta.")].(type) {
+			case string:
+				v = m
+			case float64:
+				v = strconv.FormatFloat(m, 'f', -1, 64)
+			}
+		case strings.HasPrefix(src, "header."):
+			v = r.Header.Get(strings.TrimPrefix(src, "header."))
+		}
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// exchange carries the checked prompt from the request hook to the
+// response hook.
+type exchange struct {
+	messages []guardrails.Message
+	user     string
+}
+
+type exchangeKey struct{}
+
+// request checks the prompt of r. It answers w when the prompt is refused,
+// which ends Tyk's chain; otherwise it restores the body for the upstream.
+func (g *guard) request(w http.ResponseWriter, r *http.Request, user string) {
+	if r.Body == nil || r.Body == http.NoBody || !isJSON(r.Header.Get("Content-Type")) {
+		return
+	}
+	raw, err := io.ReadAll(io.LimitReader(r.Body, g.s.MaxBody+1))
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(raw))
+	if err != nil {
+		http.Error(w, "cannot read request body", http.StatusBadRequest)
+		return
+	}
+	if int64(len(raw)) > g.s.MaxBody {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	var body map[string]any
+	if json.Unmarshal(raw, &body) != nil {
+		return
+	}
+	messages := guardrails.OpenAIRequest(body)
+	if len(messages) == 0 {
+		return
+	}
+	resp, ok := g.check(r.Context(), messages, user)
+	if !ok {
+		guardrails.OpenAIDeny(w, r, resp)
+		return
+	}
+	if stream, _ := body["stream"].(bool); stream || g.s.SkipResponse {
+		return
+	}
+	// Tyk hands the response hook the same *http.Request, so the prompt
+	// rides along in its context.
+	*r = *r.WithContext(context.WithValue(r.Context(), exchangeKey{}, &exchange{messages: messages, user: user}))
+}
+
+// response checks the answer in res to the prompt request let through,
+// replacing a refused answer with the platform's.
+func (g *guard) response(res *http.Response, r *http.Request) {
+	x, _ := r.Context().Value(exchangeKey{}).(*exchange)
+	if x == nil || res.StatusCode/100 != 2 || !isJSON(res.Header.Get("Content-Type")) {
+		return
+	}
+	raw, err := io.ReadAll(io.LimitReader(res.Body, g.s.MaxBody+1))
+	if err != nil || int64(len(raw)) > g.s.MaxBody {
+		// Too large to check: pass it on as it is.
+		res.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(raw), res.Body), res.Body}
+		return
+	}
+	res.Body.Close()
+	res.Body = io.NopCloser(bytes.NewReader(raw))
+	var completion map[string]any
+	if json.Unmarshal(raw, &completion) != nil {
+		return
+	}
+	text := guardrails.OpenAIResponse(completion)
+	if text == "" {
+		return
+	}
+	conv := append(x.messages[:len(x.messages):len(x.messages)], guardrails.Message{Role: "assistant", Content: text})
+	resp, ok := g.check(r.Context(), conv, x.user)
+	if ok {
+		return
+	}
+	rec := &recorder{header: http.Header{}}
+	guardrails.OpenAIDeny(rec, r, resp)
+	res.StatusCode, res.Status = rec.status, fmt.Sprintf("%d %s", rec.status, http.StatusText(rec.status))
+	res.Header.Set("Content-Type", rec.header.Get("Content-Type"))
+	res.Header.Set("Content-Length", strconv.Itoa(rec.body.Len()))
+	res.Header.Del("Content-Encoding")
+	res.ContentLength = int64(rec.body.Len())
+	res.Body = io.NopCloser(&rec.body)
+}
+
+// check reports whether messages may pass; resp is nil when the check
+// failed and the guard fails closed.
+func (g *guard) check(ctx context.Context, messages []guardrails.Message, user string) (*guardrails.Response, bool) {
+	var opts []guardrails.CheckOption
+	if user != "" {
+		opts = append(opts, guardrails.WithUserID(user))
+	}
+	resp, err := g.client.CheckConversation(ctx, messages, opts...)
+	if err != nil {
+		warn("openguardrails: check failed", err)
+		return nil, g.s.FailOpen
+	}
+	return resp, resp.IsSafe()
+}
+
+// recorder takes the deny response OpenAIDeny writes.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recorder) Header() http.Header { return w.header }
+
+func (w *recorder) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *recorder) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
+
+func isJSON(contentType string) bool {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
@@ -0,0 +1,12 @@
+//go:build notyk
+
+// Built with -tags notyk, the package leaves out the Tyk hooks in plugin.go,
+// so guard.go can be built and tested without the gateway's source, which
+// only the plugin compiler provides.
+package main
+
+import "log"
+
+func warn(msg string, err error) { log.Printf("%s: %v", msg, err) }
+
+func main() {}
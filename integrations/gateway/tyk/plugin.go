@@ -0,0 +1,83 @@
+//go:build !notyk
+
+// Command tyk is an OpenGuardrails custom Go plugin for the Tyk gateway,
+// built with Tyk's plugin compiler into a shared object:
+//
+//   - GuardRequest, a post_key_auth hook, checks the prompt of JSON
+//     requests and answers refused ones itself.
+//   - GuardResponse, a response hook, checks the answer to a prompt that
+//     passed and replaces a refused one.
+//
+// Each API configures the plugin in its config_data under
+// "openguardrails"; checks are attributed to the end user named by the Tyk
+// session, so the platform's ban policies apply per user. See README.md.
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/TykTechnologies/tyk/apidef"
+	"github.com/TykTechnologies/tyk/ctx"
+	"github.com/TykTechnologies/tyk/log"
+	"github.com/TykTechnologies/tyk/user"
+)
+
+var logger = log.Get()
+
+// warn logs err to the gateway's log.
+func warn(msg string, err error) { logger.WithError(err).Warn(msg) }
+
+// GuardRequest checks the request's prompt.
+func GuardRequest(w http.ResponseWriter, r *http.Request) {
+	g := guardFor(ctx.GetDefinition(r))
+	if g == nil {
+		http.Error(w, "guardrails misconfigured", http.StatusInternalServerError)
+		return
+	}
+	g.request(w, r, g.userID(r, sessionIdentity(ctx.GetSession(r))))
+}
+
+// GuardResponse checks the upstream's answer.
+func GuardResponse(_ http.ResponseWriter, res *http.Response, r *http.Request) {
+	if g := guardFor(ctx.GetDefinition(r)); g != nil {
+		g.response(res, r)
+	}
+}
+
+func sessionIdentity(s *user.SessionState) identity {
+	if s == nil {
+		return identity{}
+	}
+	return identity{alias: s.Alias, keyHash: s.KeyHash(), meta: s.MetaData}
+}
+
+// guards holds a guard per API and config_data, so edited APIs get a new
+// one on Tyk's next reload.
+var guards sync.Map // string → *guard
+
+func guardFor(spec *apidef.APIDefinition) *guard {
+	if spec == nil {
+		return nil
+	}
+	raw, _ := json.Marshal(spec.ConfigData["openguardrails"])
+	id := spec.APIID + "\x00" + string(raw)
+	if g, ok := guards.Load(id); ok {
+		return g.(*guard)
+	}
+	var s settings
+	if err := json.Unmarshal(raw, &s); err != nil {
+		logger.WithError(err).Errorf("openguardrails: config_data of API %s", spec.APIID)
+		return nil
+	}
+	g, err := newGuard(s)
+	if err != nil {
+		logger.WithError(err).Errorf("openguardrails: API %s", spec.APIID)
+		return nil
+	}
+	actual, _ := guards.LoadOrStore(id, g)
+	return actual.(*guard)
+}
+
+func main() {}
@@ -0,0 +1,166 @@
+// Package admin binds the platform's management API — applications and
+// their keys, keyword lists, knowledge bases and detection history — for
+// provisioning and reporting from Go tooling:
+//
+//	ac := admin.NewClient(admin.WithToken(os.Getenv("OGR_ADMIN_TOKEN")))
+//	app, err := ac.CreateApplication(ctx, admin.ApplicationInput{Name: "support-bot"})
+//
+// The admin API authenticates with a tenant token from the console, not an
+// application key. Errors are *guardrails.APIError, as in the detection
+// client.
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go"
+)
+
+// DefaultBaseURL is the hosted platform's management API.
+const DefaultBaseURL = "https://api.openguardrails.com/api/v1"
+
+// Client calls the management API. It is safe for concurrent use.
+type Client struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBaseURL points the client at a private deployment, e.g.
+// "http://guardrails.internal:5000/api/v1".
+func WithBaseURL(u string) Option {
+	return func(c *Client) { c.baseURL = strings.TrimRight(u, "/") }
+}
+
+// WithToken sets the tenant token sent as a bearer token.
+func WithToken(token string) Option {
+	return func(c *Client) { c.token = token }
+}
+
+// WithHTTPClient replaces the underlying HTTP client.
+func WithHTTPClient(h *http.Client) Option {
+	return func(c *Client) { c.http = h }
+}
+
+// NewClient returns a Client for the hosted platform unless options say
+// otherwise.
+func NewClient(opts ...Option) *Client {
+	c := &Client{baseURL: DefaultBaseURL, http: &http.Client{Timeout: guardrails.DefaultTimeout}}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// Time is a platform timestamp. The API emits ISO 8601 with or without a
+// zone; zoneless values are UTC.
+type Time struct{ time.Time }
+
+// UnmarshalJSON accepts RFC 3339 and zoneless ISO 8601.
+func (t *Time) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil || s == "" {
+		return err
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02 15:04:05.999999999"} {
+		if v, err := time.Parse(layout, s); err == nil {
+			t.Time = v
+			return nil
+		}
+	}
+	return fmt.Errorf("admin: unrecognized time %q", s)
+}
+
+// MarshalJSON writes RFC 3339, or null for the zero time.
+func (t Time) MarshalJSON() ([]byte, error) {
+	if t.IsZero() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.Time.Format(time.RFC3339Nano))
+}
+
+// do sends in (if any) as JSON and decodes the answer into out (if any).
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out any) error {
+	if in == nil {
+		return c.send(ctx, method, path, query, "", nil, out)
+	}
+	raw, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return c.send(ctx, method, path, query, "application/json", bytes.NewReader(raw), out)
+}
+
+// send is do with a caller-encoded body, for uploads.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, contentType string, body io.Reader, out any) error {
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "openguardrails-go/"+guardrails.Version)
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return apiError(resp)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("admin: decode response: %w", err)
+	}
+	return nil
+}
+
+// apiError reads the platform's {"detail": ...} body.
+func apiError(resp *http.Response) *guardrails.APIError {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	e := &guardrails.APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(raw))}
+	var body struct {
+		Detail any `json:"detail"`
+	}
+	if json.Unmarshal(raw, &body) == nil {
+		switch d := body.Detail.(type) {
+		case string:
+			e.Message = d
+		case nil:
+		default:
+			b, _ := json.Marshal(d)
+			e.Message = string(b)
+		}
+	}
+	return e
+}
+
+func pathf(format string, ids ...string) string {
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = url.PathEscape(id)
+	}
+	return fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,80 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go"
+)
+
+// fake serves canned bodies by "METHOD path" and records request bodies.
+func fake(t *testing.T, routes map[string]string) (*Client, map[string]json.RawMessage) {
+	t.Helper()
+	got := map[string]json.RawMessage{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer tok" {
+			t.Errorf("auth %q", r.Header.Get("Authorization"))
+		}
+		route := r.Method + " " + r.URL.Path
+		if r.URL.RawQuery != "" {
+			route += "?" + r.URL.RawQuery
+		}
+		raw, _ := io.ReadAll(r.Body)
+		got[route] = raw
+		body, ok := routes[route]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"detail":"no route `+route+`"}`)
+			return
+		}
+		if body == "" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return NewClient(WithBaseURL(srv.URL+"/api/v1/"), WithToken("tok")), got
+}
+
+func TestApplicationsAndKeys(t *testing.T) {
+	c, got := fake(t, map[string]string{
+		"POST /api/v1/applications":                      `{"id":"app-1","name":"bot","is_active":true,"created_at":"2025-03-01T10:00:00.123456"}`,
+		"GET /api/v1/applications":                       `[{"id":"app-1","name":"bot","is_active":true,"created_at":"2025-03-01T10:00:00Z"}]`,
+		"POST /api/v1/applications/app-1/keys":           `{"id":"k1","application_id":"app-1","key":"sk-xxai-new","is_active":true}`,
+		"POST /api/v1/applications/app-1/keys/k1/rotate": `{"id":"k1","application_id":"app-1","key":"sk-xxai-rotated","is_active":true}`,
+		"DELETE /api/v1/applications/app-1/keys/k1":      "",
+	})
+	ctx := context.Background()
+
+	app, err := c.CreateApplication(ctx, ApplicationInput{Name: "bot"})
+	if err != nil || app.ID != "app-1" || app.CreatedAt.Time != time.Date(2025, 3, 1, 10, 0, 0, 123456000, time.UTC) {
+		t.Fatalf("%+v %v", app, err)
+	}
+	if string(got["POST /api/v1/applications"]) != `{"name":"bot"}` {
+		t.Fatalf("body %s", got["POST /api/v1/applications"])
+	}
+	if apps, err := c.ListApplications(ctx); err != nil || len(apps) != 1 {
+		t.Fatalf("%+v %v", apps, err)
+	}
+	if k, err := c.CreateKey(ctx, "app-1", "ci"); err != nil || k.Key != "sk-xxai-new" {
+		t.Fatalf("%+v %v", k, err)
+	}
+	if k, err := c.RotateKey(ctx, "app-1", "k1"); err != nil || k.Key != "sk-xxai-rotated" {
+		t.Fatalf("%+v %v", k, err)
+	}
+	if err := c.DeleteKey(ctx, "app-1", "k1"); err != nil {
+		t.Fatal(err)
+	}
+
+	var apiErr *guardrails.APIError
+	if _, err := c.GetApplication(ctx, "missing"); !errors.As(err, &apiErr) || apiErr.StatusCode != 404 || apiErr.Message != "no route GET /api/v1/applications/missing" {
+		t.Fatalf("%v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+)
+
+// Application is a tenant application: the unit that owns a policy
+// configuration and the API keys that authenticate detection and gateway
+// traffic.
+type Application struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	IsActive    bool   `json:"is_active"`
+	CreatedAt   Time   `json:"created_at"`
+	UpdatedAt   Time   `json:"updated_at"`
+}
+
+// ApplicationInput creates or updates an application. On update, nil
+// fields are left unchanged.
+type ApplicationInput struct {
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	IsActive    *bool  `json:"is_active,omitempty"`
+}
+
+// APIKey is an application key. Key holds the secret only in the answers
+// to CreateKey and RotateKey; listings carry the masked Prefix.
+type APIKey struct {
+	ID            string `json:"id"`
+	ApplicationID string `json:"application_id"`
+	Name          string `json:"name,omitempty"`
+	Key           string `json:"key,omitempty"`
+	Prefix        string `json:"key_prefix,omitempty"`
+	IsActive      bool   `json:"is_active"`
+	CreatedAt     Time   `json:"created_at"`
+	LastUsedAt    *Time  `json:"last_used_at,omitempty"`
+}
+
+// ListApplications returns the tenant's applications.
+func (c *Client) ListApplications(ctx context.Context) ([]Application, error) {
+	var out []Application
+	if err := c.do(ctx, http.MethodGet, "/applications", nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetApplication returns one application.
+func (c *Client) GetApplication(ctx context.Context, id string) (*Application, error) {
+	var out Application
+	if err := c.do(ctx, http.MethodGet, pathf("/applications/%s", id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// CreateApplication creates an application with the default policy.
+func (c *Client) CreateApplication(ctx context.Context, in ApplicationInput) (*Application, error) {
+	var out Application
+	if err := c.do(ctx, http.MethodPost, "/applications", nil, in, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateApplication changes an application's name, description or state.
+func (c *Client) UpdateApplication(ctx context.Context, id string, in ApplicationInput) (*Application, error) {
+	var out Application
+	if err := c.do(ctx, http.MethodPut, pathf("/applications/%s", id), nil, in, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteApplication deletes an application and revokes its keys.
+func (c *Client) DeleteApplication(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, pathf("/applications/%s", id), nil, nil, nil)
+}
+
+// ListKeys returns an application's keys, secrets masked.
+func (c *Client) ListKeys(ctx context.Context, appID string) ([]APIKey, error) {
+	var out []APIKey
+	if err := c.do(ctx, http.MethodGet, pathf("/applications/%s/keys", appID), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateKey issues a key. The secret is in the result's Key and cannot be
+// read again.
+func (c *Client) CreateKey(ctx context.Context, appID, name string) (*APIKey, error) {
+	var out APIKey
+	if err := c.do(ctx, http.MethodPost, pathf("/applications/%s/keys", appID), nil, map[string]string{"name": name}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RotateKey replaces a key's secret, invalidating the old one, and returns
+// the new secret in Key.
+func (c *Client) RotateKey(ctx context.Context, appID, keyID string) (*APIKey, error) {
+	var out APIKey
+	if err := c.do(ctx, http.MethodPost, pathf("/applications/%s/keys/%s/rotate", appID, keyID), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteKey revokes a key.
+func (c *Client) DeleteKey(ctx context.Context, appID, keyID string) error {
+	return c.do(ctx, http.MethodDelete, pathf("/applications/%s/keys/%s", appID, keyID), nil, nil, nil)
+}
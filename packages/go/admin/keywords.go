@@ -0,0 +1,158 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ListKind selects blacklists or whitelists.
+type ListKind string
+
+// Keyword list kinds. Blacklisted keywords reject content outright;
+// whitelisted keywords pass it without model detection.
+const (
+	Blacklist ListKind = "blacklist"
+	Whitelist ListKind = "whitelist"
+)
+
+// KeywordList is a named set of keywords matched against checked content.
+type KeywordList struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Keywords    []string `json:"keywords"`
+	Description string   `json:"description,omitempty"`
+	IsActive    bool     `json:"is_active"`
+	CreatedAt   Time     `json:"created_at"`
+	UpdatedAt   Time     `json:"updated_at"`
+}
+
+// KeywordListInput creates or replaces a keyword list.
+type KeywordListInput struct {
+	Name        string   `json:"name"`
+	Keywords    []string `json:"keywords"`
+	Description string   `json:"description,omitempty"`
+	IsActive    bool     `json:"is_active"`
+}
+
+func (k ListKind) path() string { return "/config/" + string(k) }
+
+// ListKeywordLists returns the tenant's lists of one kind.
+func (c *Client) ListKeywordLists(ctx context.Context, kind ListKind) ([]KeywordList, error) {
+	var out []KeywordList
+	if err := c.do(ctx, http.MethodGet, kind.path(), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// GetKeywordList returns the list with the given id.
+func (c *Client) GetKeywordList(ctx context.Context, kind ListKind, id string) (*KeywordList, error) {
+	lists, err := c.ListKeywordLists(ctx, kind)
+	if err != nil {
+		return nil, err
+	}
+	for i := range lists {
+		if lists[i].ID == id {
+			return &lists[i], nil
+		}
+	}
+	return nil, fmt.Errorf("admin: %s %q not found", kind, id)
+}
+
+// CreateKeywordList creates a list.
+func (c *Client) CreateKeywordList(ctx context.Context, kind ListKind, in KeywordListInput) (*KeywordList, error) {
+	var out KeywordList
+	if err := c.do(ctx, http.MethodPost, kind.path(), nil, in, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UpdateKeywordList replaces a list's name, keywords, description and state.
+func (c *Client) UpdateKeywordList(ctx context.Context, kind ListKind, id string, in KeywordListInput) (*KeywordList, error) {
+	var out KeywordList
+	if err := c.do(ctx, http.MethodPut, kind.path()+pathf("/%s", id), nil, in, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// DeleteKeywordList deletes a list.
+func (c *Client) DeleteKeywordList(ctx context.Context, kind ListKind, id string) error {
+	return c.do(ctx, http.MethodDelete, kind.path()+pathf("/%s", id), nil, nil, nil)
+}
+
+// SetKeywordListActive enables or disables a list.
+func (c *Client) SetKeywordListActive(ctx context.Context, kind ListKind, id string, active bool) (*KeywordList, error) {
+	return c.editKeywords(ctx, kind, id, func(in *KeywordListInput) { in.IsActive = active })
+}
+
+// AddKeywords adds words to a list; words already present are skipped.
+func (c *Client) AddKeywords(ctx context.Context, kind ListKind, id string, words ...string) (*KeywordList, error) {
+	return c.editKeywords(ctx, kind, id, func(in *KeywordListInput) {
+		in.Keywords = mergeKeywords(in.Keywords, words)
+	})
+}
+
+// RemoveKeywords removes words from a list.
+func (c *Client) RemoveKeywords(ctx context.Context, kind ListKind, id string, words ...string) (*KeywordList, error) {
+	drop := map[string]bool{}
+	for _, w := range words {
+		drop[strings.TrimSpace(w)] = true
+	}
+	return c.editKeywords(ctx, kind, id, func(in *KeywordListInput) {
+		kept := in.Keywords[:0:0]
+		for _, w := range in.Keywords {
+			if !drop[w] {
+				kept = append(kept, w)
+			}
+		}
+		in.Keywords = kept
+	})
+}
+
+// SyncKeywordList makes the list called name hold exactly words, creating
+// it (active) if it does not exist. It is the building block for mirroring
+// a corporate blocklist: run it on a schedule with the source of truth.
+func (c *Client) SyncKeywordList(ctx context.Context, kind ListKind, name string, words []string) (*KeywordList, error) {
+	lists, err := c.ListKeywordLists(ctx, kind)
+	if err != nil {
+		return nil, err
+	}
+	words = mergeKeywords(nil, words)
+	for _, l := range lists {
+		if l.Name == name {
+			return c.UpdateKeywordList(ctx, kind, l.ID, KeywordListInput{
+				Name: l.Name, Keywords: words, Description: l.Description, IsActive: l.IsActive,
+			})
+		}
+	}
+	return c.CreateKeywordList(ctx, kind, KeywordListInput{Name: name, Keywords: words, IsActive: true})
+}
+
+// editKeywords is a read-modify-write of one list. The API replaces lists
+// whole, so concurrent editors of the same list can lose updates.
+func (c *Client) editKeywords(ctx context.Context, kind ListKind, id string, edit func(*KeywordListInput)) (*KeywordList, error) {
+	l, err := c.GetKeywordList(ctx, kind, id)
+	if err != nil {
+		return nil, err
+	}
+	in := KeywordListInput{Name: l.Name, Keywords: l.Keywords, Description: l.Description, IsActive: l.IsActive}
+	edit(&in)
+	return c.UpdateKeywordList(ctx, kind, id, in)
+}
+
+// mergeKeywords appends the trimmed, non-empty words not already in base.
+func mergeKeywords(base, words []string) []string {
+	seen := map[string]bool{}
+	out := make([]string, 0, len(base)+len(words))
+	for _, w := range append(append([]string(nil), base...), words...) {
+		if w = strings.TrimSpace(w); w != "" && !seen[w] {
+			seen[w] = true
+			out = append(out, w)
+		}
+	}
+	return out
+}
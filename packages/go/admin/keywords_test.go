@@ -0,0 +1,47 @@
+package admin
+
+import (
+	"context"
+	"testing"
+)
+
+func TestKeywordLists(t *testing.T) {
+	list := `[{"id":"b1","name":"corp","keywords":["alpha","beta"],"is_active":true}]`
+	c, got := fake(t, map[string]string{
+		"GET /api/v1/config/blacklist":    list,
+		"PUT /api/v1/config/blacklist/b1": `{"id":"b1","name":"corp","keywords":["alpha","beta","gamma"],"is_active":true}`,
+		"GET /api/v1/config/whitelist":    `[]`,
+		"POST /api/v1/config/whitelist":   `{"id":"w1","name":"partners","keywords":["acme"],"is_active":true}`,
+	})
+	ctx := context.Background()
+
+	if _, err := c.AddKeywords(ctx, Blacklist, "b1", "gamma", " beta "); err != nil {
+		t.Fatal(err)
+	}
+	if b := string(got["PUT /api/v1/config/blacklist/b1"]); b != `{"name":"corp","keywords":["alpha","beta","gamma"],"is_active":true}` {
+		t.Fatalf("add body %s", b)
+	}
+	if _, err := c.RemoveKeywords(ctx, Blacklist, "b1", "alpha"); err != nil {
+		t.Fatal(err)
+	}
+	if b := string(got["PUT /api/v1/config/blacklist/b1"]); b != `{"name":"corp","keywords":["beta"],"is_active":true}` {
+		t.Fatalf("remove body %s", b)
+	}
+	if _, err := c.SetKeywordListActive(ctx, Blacklist, "b1", false); err != nil {
+		t.Fatal(err)
+	}
+	if b := string(got["PUT /api/v1/config/blacklist/b1"]); b != `{"name":"corp","keywords":["alpha","beta"],"is_active":false}` {
+		t.Fatalf("disable body %s", b)
+	}
+	if _, err := c.GetKeywordList(ctx, Blacklist, "nope"); err == nil {
+		t.Fatal("missing list found")
+	}
+
+	l, err := c.SyncKeywordList(ctx, Whitelist, "partners", []string{"acme", "acme", ""})
+	if err != nil || l.ID != "w1" {
+		t.Fatalf("%+v %v", l, err)
+	}
+	if b := string(got["POST /api/v1/config/whitelist"]); b != `{"name":"partners","keywords":["acme"],"is_active":true}` {
+		t.Fatalf("sync body %s", b)
+	}
+}
@@ -0,0 +1,147 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/openguardrails/openguardrails-go"
+)
+
+// KnowledgeBase is a set of question/answer pairs for one risk category.
+// When content is flagged in that category and resembles a question above
+// the similarity threshold, the pair's answer becomes suggest_answer.
+type KnowledgeBase struct {
+	ID                  string              `json:"id"`
+	Category            guardrails.Category `json:"category"`
+	Name                string              `json:"name"`
+	Description         string              `json:"description,omitempty"`
+	IsActive            bool                `json:"is_active"`
+	TotalQAPairs        int                 `json:"total_qa_pairs"`
+	SimilarityThreshold float64             `json:"similarity_threshold,omitempty"`
+	CreatedAt           Time                `json:"created_at"`
+	UpdatedAt           Time                `json:"updated_at"`
+}
+
+// KnowledgeBaseInput describes a knowledge base to create.
+type KnowledgeBaseInput struct {
+	Category    guardrails.Category
+	Name        string
+	Description string
+	// SimilarityThreshold is the minimum similarity (0–1) for an answer to
+	// be used; 0 keeps the platform default.
+	SimilarityThreshold float64
+}
+
+// QAPair is one entry of a knowledge base.
+type QAPair struct {
+	QuestionID string `json:"questionid,omitempty"`
+	Question   string `json:"question"`
+	Answer     string `json:"answer"`
+}
+
+// KnowledgeMatch is a similarity-test hit.
+type KnowledgeMatch struct {
+	QAPair
+	Similarity float64 `json:"similarity_score"`
+}
+
+// ListKnowledgeBases returns the tenant's knowledge bases; a non-empty
+// category filters to one.
+func (c *Client) ListKnowledgeBases(ctx context.Context, category guardrails.Category) ([]KnowledgeBase, error) {
+	var q url.Values
+	if category != "" {
+		q = url.Values{"category": {string(category)}}
+	}
+	var out []KnowledgeBase
+	if err := c.do(ctx, http.MethodGet, "/config/knowledge-bases", q, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CreateKnowledgeBase uploads pairs as a new, active knowledge base.
+func (c *Client) CreateKnowledgeBase(ctx context.Context, in KnowledgeBaseInput, pairs []QAPair) (*KnowledgeBase, error) {
+	fields := map[string]string{"category": string(in.Category), "name": in.Name, "description": in.Description, "is_active": "true"}
+	if in.SimilarityThreshold > 0 {
+		fields["similarity_threshold"] = strconv.FormatFloat(in.SimilarityThreshold, 'f', -1, 64)
+	}
+	var out KnowledgeBase
+	if err := c.upload(ctx, http.MethodPost, "/config/knowledge-bases", fields, pairs, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ReplaceKnowledgeBasePairs replaces every pair of a knowledge base, which
+// is how a CI pipeline publishes an edited answer set.
+func (c *Client) ReplaceKnowledgeBasePairs(ctx context.Context, id string, pairs []QAPair) (*KnowledgeBase, error) {
+	var out KnowledgeBase
+	if err := c.upload(ctx, http.MethodPut, pathf("/config/knowledge-bases/%s/file", id), nil, pairs, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// KnowledgeBasePairs returns a knowledge base's pairs.
+func (c *Client) KnowledgeBasePairs(ctx context.Context, id string) ([]QAPair, error) {
+	var out []QAPair
+	if err := c.do(ctx, http.MethodGet, pathf("/config/knowledge-bases/%s/qa-pairs", id), nil, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// DeleteKnowledgeBase deletes a knowledge base.
+func (c *Client) DeleteKnowledgeBase(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, pathf("/config/knowledge-bases/%s", id), nil, nil, nil)
+}
+
+// SearchKnowledgeBase runs a similarity test: the topK pairs closest to
+// query, best first, regardless of the threshold. Use it to check that a
+// phrasing will hit the intended answer before publishing.
+func (c *Client) SearchKnowledgeBase(ctx context.Context, id, query string, topK int) ([]KnowledgeMatch, error) {
+	q := url.Values{"query": {query}}
+	if topK > 0 {
+		q.Set("top_k", strconv.Itoa(topK))
+	}
+	var out []KnowledgeMatch
+	if err := c.do(ctx, http.MethodGet, pathf("/config/knowledge-bases/%s/search", id), q, nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// upload sends pairs as the JSONL "file" part of a multipart form, the
+// format the console's upload accepts.
+func (c *Client) upload(ctx context.Context, method, path string, fields map[string]string, pairs []QAPair, out any) error {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for k, v := range fields {
+		if err := mw.WriteField(k, v); err != nil {
+			return err
+		}
+	}
+	fw, err := mw.CreateFormFile("file", "knowledge_base.jsonl")
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(fw)
+	enc.SetEscapeHTML(false)
+	for i, p := range pairs {
+		if p.QuestionID == "" {
+			p.QuestionID = "q" + strconv.Itoa(i+1)
+		}
+		if err := enc.Encode(p); err != nil {
+			return err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+	return c.send(ctx, method, path, nil, mw.FormDataContentType(), &buf, out)
+}
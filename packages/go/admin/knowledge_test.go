@@ -0,0 +1,70 @@
+package admin
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openguardrails/openguardrails-go"
+)
+
+func TestCreateKnowledgeBaseUpload(t *testing.T) {
+	var fields map[string]string
+	var file string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		mr := multipart.NewReader(r.Body, params["boundary"])
+		fields = map[string]string{}
+		for {
+			p, err := mr.NextPart()
+			if err != nil {
+				break
+			}
+			b, _ := io.ReadAll(p)
+			if p.FormName() == "file" {
+				file = string(b)
+			} else {
+				fields[p.FormName()] = string(b)
+			}
+		}
+		io.WriteString(w, `{"id":"kb1","category":"S19","name":"finance","is_active":true,"total_qa_pairs":2}`)
+	}))
+	defer srv.Close()
+
+	c := NewClient(WithBaseURL(srv.URL))
+	kb, err := c.CreateKnowledgeBase(context.Background(), KnowledgeBaseInput{
+		Category: guardrails.CategoryFinancialAdvice, Name: "finance", SimilarityThreshold: 0.8,
+	}, []QAPair{
+		{Question: "Which stock should I buy?", Answer: "I can't give investment advice."},
+		{QuestionID: "tax", Question: "How do I avoid tax?", Answer: "Please consult a tax advisor."},
+	})
+	if err != nil || kb.ID != "kb1" || kb.Category != guardrails.CategoryFinancialAdvice || kb.TotalQAPairs != 2 {
+		t.Fatalf("%+v %v", kb, err)
+	}
+	if fields["category"] != "S19" || fields["similarity_threshold"] != "0.8" || fields["is_active"] != "true" {
+		t.Fatalf("fields %v", fields)
+	}
+	lines := strings.Split(strings.TrimSpace(file), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], `{"questionid":"q1","question":"Which stock`) || !strings.HasPrefix(lines[1], `{"questionid":"tax"`) {
+		t.Fatalf("file %q", file)
+	}
+}
+
+func TestSearchKnowledgeBase(t *testing.T) {
+	c, _ := fake(t, map[string]string{
+		"GET /api/v1/config/knowledge-bases/kb1/search?query=buy+stocks&top_k=3": `[{"question":"Which stock should I buy?","answer":"No advice.","similarity_score":0.91}]`,
+		"GET /api/v1/config/knowledge-bases?category=S19":                        `[{"id":"kb1","category":"S19"}]`,
+	})
+	m, err := c.SearchKnowledgeBase(context.Background(), "kb1", "buy stocks", 3)
+	if err != nil || len(m) != 1 || m[0].Similarity != 0.91 || m[0].Answer != "No advice." {
+		t.Fatalf("%+v %v", m, err)
+	}
+	if kbs, err := c.ListKnowledgeBases(context.Background(), guardrails.CategoryFinancialAdvice); err != nil || len(kbs) != 1 {
+		t.Fatalf("%+v %v", kbs, err)
+	}
+}
@@ -0,0 +1,132 @@
+package admin
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go"
+	"github.com/openguardrails/openguardrails-go/verdict"
+)
+
+// DetectionResult is one past check as the platform recorded it.
+type DetectionResult struct {
+	RequestID        string               `json:"request_id"`
+	ApplicationID    string               `json:"application_id,omitempty"`
+	UserID           string               `json:"user_id,omitempty"`
+	Content          string               `json:"content"`
+	SuggestAction    guardrails.Action    `json:"suggest_action"`
+	SuggestAnswer    string               `json:"suggest_answer,omitempty"`
+	OverallRiskLevel verdict.RiskLevel    `json:"overall_risk_level"`
+	Security         guardrails.Dimension `json:"security"`
+	Compliance       guardrails.Dimension `json:"compliance"`
+	Data             guardrails.Dimension `json:"data"`
+	HasImage         bool                 `json:"has_image,omitempty"`
+	IP               string               `json:"ip_address,omitempty"`
+	CreatedAt        Time                 `json:"created_at"`
+}
+
+// ResultsQuery filters detection history. Zero fields do not filter.
+type ResultsQuery struct {
+	Since, Until  time.Time
+	RiskLevel     verdict.RiskLevel
+	Category      guardrails.Category
+	UserID        string
+	ApplicationID string
+	// PageSize is the number of results per request (platform default when
+	// 0, at most 100).
+	PageSize int
+}
+
+func (q ResultsQuery) values(cursor string) url.Values {
+	v := url.Values{}
+	set := func(k, s string) {
+		if s != "" {
+			v.Set(k, s)
+		}
+	}
+	if !q.Since.IsZero() {
+		set("start_time", q.Since.UTC().Format(time.RFC3339))
+	}
+	if !q.Until.IsZero() {
+		set("end_time", q.Until.UTC().Format(time.RFC3339))
+	}
+	set("risk_level", string(q.RiskLevel))
+	set("category", string(q.Category))
+	set("user_id", q.UserID)
+	set("application_id", q.ApplicationID)
+	if q.PageSize > 0 {
+		set("limit", strconv.Itoa(q.PageSize))
+	}
+	set("cursor", cursor)
+	return v
+}
+
+// ResultsPage is one page of detection history, newest first.
+type ResultsPage struct {
+	Items []DetectionResult `json:"items"`
+	// NextCursor fetches the following page; empty on the last one.
+	NextCursor string `json:"next_cursor"`
+}
+
+// QueryResults returns the page of results matching q that cursor points
+// at; pass "" for the first page.
+func (c *Client) QueryResults(ctx context.Context, q ResultsQuery, cursor string) (*ResultsPage, error) {
+	var out ResultsPage
+	if err := c.do(ctx, http.MethodGet, "/results", q.values(cursor), nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ResultsIterator walks every result matching a query, fetching pages as
+// needed:
+//
+//	it := ac.Results(ctx, admin.ResultsQuery{Since: start, RiskLevel: verdict.HighRisk})
+//	for it.Next() {
+//		r := it.Result()
+//		...
+//	}
+//	if err := it.Err(); err != nil { ... }
+type ResultsIterator struct {
+	ctx    context.Context
+	c      *Client
+	q      ResultsQuery
+	page   []DetectionResult
+	cursor string
+	cur    DetectionResult
+	done   bool
+	err    error
+}
+
+// Results returns an iterator over the results matching q.
+func (c *Client) Results(ctx context.Context, q ResultsQuery) *ResultsIterator {
+	return &ResultsIterator{ctx: ctx, c: c, q: q}
+}
+
+// Next advances to the next result, fetching a page if needed. It returns
+// false at the end or on error.
+func (it *ResultsIterator) Next() bool {
+	for len(it.page) == 0 {
+		if it.done || it.err != nil {
+			return false
+		}
+		p, err := it.c.QueryResults(it.ctx, it.q, it.cursor)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.page, it.cursor = p.Items, p.NextCursor
+		it.done = p.NextCursor == ""
+	}
+	it.cur, it.page = it.page[0], it.page[1:]
+	return true
+}
+
+// Result returns the current result.
+func (it *ResultsIterator) Result() DetectionResult { return it.cur }
+
+// Err returns the error that stopped iteration, if any.
+func (it *ResultsIterator) Err() error { return it.err }
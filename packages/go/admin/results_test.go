@@ -0,0 +1,41 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go"
+	"github.com/openguardrails/openguardrails-go/verdict"
+)
+
+func TestResultsIterator(t *testing.T) {
+	base := "GET /api/v1/results?category=S9&limit=2&risk_level=high_risk&start_time=2025-03-01T00%3A00%3A00Z"
+	c, _ := fake(t, map[string]string{
+		base: `{"items":[{"request_id":"r1","suggest_action":"reject","security":{"risk_level":"high_risk","categories":["S9"]}},{"request_id":"r2"}],"next_cursor":"c2"}`,
+		"GET /api/v1/results?category=S9&cursor=c2&limit=2&risk_level=high_risk&start_time=2025-03-01T00%3A00%3A00Z": `{"items":[{"request_id":"r3"}],"next_cursor":""}`,
+	})
+	it := c.Results(context.Background(), ResultsQuery{
+		Since:     time.Date(2025, 3, 1, 8, 0, 0, 0, time.FixedZone("CST", 8*3600)),
+		RiskLevel: verdict.HighRisk,
+		Category:  guardrails.CategoryPromptAttack,
+		PageSize:  2,
+	})
+	var ids []string
+	for it.Next() {
+		ids = append(ids, it.Result().RequestID)
+	}
+	if it.Err() != nil || len(ids) != 3 || ids[2] != "r3" {
+		t.Fatalf("%v %v", ids, it.Err())
+	}
+}
+
+func TestResultsIteratorError(t *testing.T) {
+	c, _ := fake(t, map[string]string{})
+	it := c.Results(context.Background(), ResultsQuery{UserID: "u-1"})
+	var apiErr *guardrails.APIError
+	if it.Next() || !errors.As(it.Err(), &apiErr) || apiErr.StatusCode != 404 {
+		t.Fatalf("%v", it.Err())
+	}
+}
@@ -0,0 +1,169 @@
+package guardrails
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EntityMap maps the placeholders in anonymized text to the values they
+// replaced, e.g. "__EMAIL_1__" → "alice@example.com". It is the key to undo
+// masking: keep it server-side and never send it to the model.
+type EntityMap map[string]string
+
+// Anonymized is text with its sensitive data masked.
+type Anonymized struct {
+	Text     string
+	Entities EntityMap
+	// Detected lists what the data-security dimension found, with offsets
+	// into the original text.
+	Detected []Entity
+}
+
+type anonymizeRequest struct {
+	Text   string `json:"text"`
+	UserID string `json:"xxai_app_user_id,omitempty"`
+}
+
+type anonymizeResponse struct {
+	Text     string    `json:"anonymized_text"`
+	Mapping  EntityMap `json:"mapping"`
+	Entities []Entity  `json:"entities"`
+}
+
+// Anonymize masks the sensitive data in text using the application's
+// data-security entity types. Send the result to the model and restore its
+// answer with Deanonymize.
+func (c *Client) Anonymize(ctx context.Context, text string, opts ...CheckOption) (*Anonymized, error) {
+	if text == "" {
+		return nil, errors.New("guardrails: no text to anonymize")
+	}
+	var cr checkRequest
+	for _, o := range opts {
+		o(&cr)
+	}
+	ctx, done := c.observe(ctx, OpAnonymize, "")
+	var out anonymizeResponse
+	err := c.post(ctx, "/guardrails/anonymize", anonymizeRequest{Text: text, UserID: cr.UserID}, &out)
+	done(nil, false, err)
+	if err != nil {
+		return nil, err
+	}
+	if out.Mapping == nil {
+		out.Mapping = EntityMap{}
+	}
+	return &Anonymized{Text: out.Text, Entities: out.Mapping, Detected: out.Entities}, nil
+}
+
+// Deanonymize restores the values m's placeholders stand for. Placeholders
+// the model dropped or altered are left as they are.
+func Deanonymize(text string, m EntityMap) string {
+	if len(m) == 0 {
+		return text
+	}
+	return m.replacer().Replace(text)
+}
+
+// replacer substitutes longest placeholders first, so "__EMAIL_10__" is
+// never read as "__EMAIL_1__" followed by "0__".
+func (m EntityMap) replacer() *strings.Replacer {
+	keys := m.keys()
+	pairs := make([]string, 0, 2*len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, k, m[k])
+	}
+	return strings.NewReplacer(pairs...)
+}
+
+// keys returns m's placeholders, longest first.
+func (m EntityMap) keys() []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// AnonymizeConversation anonymizes every message and returns one map for
+// the whole conversation. A value that appears in several messages keeps a
+// single placeholder, and placeholders that the platform numbered
+// independently per message are renumbered so they stay unique.
+func (c *Client) AnonymizeConversation(ctx context.Context, messages []Message, opts ...CheckOption) ([]Message, EntityMap, error) {
+	out := make([]Message, len(messages))
+	merged := EntityMap{}
+	byValue := map[string]string{}
+	for i, msg := range messages {
+		out[i] = msg
+		if msg.Content == "" {
+			continue
+		}
+		a, err := c.Anonymize(ctx, msg.Content, opts...)
+		if err != nil {
+			return nil, nil, err
+		}
+		// Reuse placeholders for known values first, then keep the names
+		// that are free, and only then renumber the ones that clash.
+		rename := EntityMap{}
+		keys := a.Entities.keys()
+		for _, k := range keys {
+			if p := byValue[a.Entities[k]]; p != "" {
+				rename[k] = p
+			}
+		}
+		for _, k := range keys {
+			if _, ok := merged[k]; !ok && rename[k] == "" {
+				rename[k] = k
+				merged[k] = a.Entities[k]
+			}
+		}
+		for _, k := range keys {
+			if rename[k] == "" {
+				rename[k] = renumber(k, merged)
+				merged[rename[k]] = a.Entities[k]
+			}
+			byValue[a.Entities[k]] = rename[k]
+		}
+		out[i].Content = rename.replacer().Replace(a.Text)
+	}
+	return out, merged, nil
+}
+
+// DeanonymizeConversation restores every message with m.
+func DeanonymizeConversation(messages []Message, m EntityMap) []Message {
+	out := make([]Message, len(messages))
+	for i, msg := range messages {
+		out[i] = msg
+		out[i].Content = Deanonymize(msg.Content, m)
+	}
+	return out
+}
+
+// renumber bumps the last number in placeholder until it is not in taken
+// ("__EMAIL_1__" → "__EMAIL_2__"), or appends one if it has none.
+func renumber(placeholder string, taken EntityMap) string {
+	end := strings.LastIndexAny(placeholder, "0123456789") + 1
+	start := end
+	for start > 0 && placeholder[start-1] >= '0' && placeholder[start-1] <= '9' {
+		start--
+	}
+	prefix, suffix, n := placeholder+"_", "", 1
+	if end > 0 {
+		prefix, suffix = placeholder[:start], placeholder[end:]
+		n, _ = strconv.Atoi(placeholder[start:end])
+	}
+	for {
+		n++
+		k := prefix + strconv.Itoa(n) + suffix
+		if _, used := taken[k]; !used {
+			return k
+		}
+	}
+}
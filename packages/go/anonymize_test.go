@@ -0,0 +1,94 @@
+package guardrails
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// maskEmails is a fake anonymize endpoint that numbers the addresses in each
+// text from 1, as the platform does per call.
+func maskEmails(t *testing.T) *Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/guardrails/anonymize" {
+			t.Errorf("path %s", r.URL.Path)
+		}
+		var req anonymizeRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		out := anonymizeResponse{Mapping: EntityMap{}}
+		words := strings.Fields(req.Text)
+		for i, w := range words {
+			if strings.Contains(w, "@") {
+				k := "__EMAIL_" + strconv.Itoa(len(out.Mapping)+1) + "__"
+				out.Mapping[k] = w
+				words[i] = k
+			}
+		}
+		out.Text = strings.Join(words, " ")
+		json.NewEncoder(w).Encode(out)
+	}))
+	t.Cleanup(srv.Close)
+	return NewClient(WithBaseURL(srv.URL), WithRetry(NoRetry))
+}
+
+func TestAnonymizeRoundTrip(t *testing.T) {
+	a, err := maskEmails(t).Anonymize(context.Background(), "mail a@x.io or b@y.io")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Text != "mail __EMAIL_1__ or __EMAIL_2__" || len(a.Entities) != 2 {
+		t.Fatalf("%+v", a)
+	}
+	if got := Deanonymize("sent to __EMAIL_2__", a.Entities); got != "sent to b@y.io" {
+		t.Fatal(got)
+	}
+}
+
+func TestDeanonymizeLongestFirst(t *testing.T) {
+	m := EntityMap{"[P1]": "one"}
+	for i := 10; i <= 11; i++ {
+		m["[P1"+strconv.Itoa(i-10)+"]"] = "ten" + strconv.Itoa(i-10)
+	}
+	if got := Deanonymize("[P10] [P1] [P11] [P2]", m); got != "ten0 one ten1 [P2]" {
+		t.Fatal(got)
+	}
+}
+
+func TestAnonymizeConversation(t *testing.T) {
+	msgs := []Message{
+		{Role: "user", Content: "write to a@x.io"},
+		{Role: "assistant", Content: "ok"},
+		{Role: "user", Content: "cc b@y.io and a@x.io"},
+	}
+	out, m, err := maskEmails(t).AnonymizeConversation(context.Background(), msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out[0].Content != "write to __EMAIL_1__" || out[2].Content != "cc __EMAIL_2__ and __EMAIL_1__" {
+		t.Fatalf("%+v", out)
+	}
+	if len(m) != 2 || m["__EMAIL_2__"] != "b@y.io" {
+		t.Fatalf("%v", m)
+	}
+	back := DeanonymizeConversation(out, m)
+	for i := range msgs {
+		if back[i] != msgs[i] {
+			t.Fatalf("%d: %+v", i, back[i])
+		}
+	}
+}
+
+func TestRenumber(t *testing.T) {
+	taken := EntityMap{"__EMAIL_2__": "", "<NAME>_2": ""}
+	if got := renumber("__EMAIL_1__", taken); got != "__EMAIL_3__" {
+		t.Fatal(got)
+	}
+	if got := renumber("<NAME>", taken); got != "<NAME>_3" {
+		t.Fatal(got)
+	}
+}
@@ -0,0 +1,70 @@
+package guardrails
+
+import (
+	"context"
+	"sync"
+)
+
+// Conversation is one item of a batch: the messages CheckConversation
+// would take.
+type Conversation []Message
+
+// BatchResult is the outcome for one conversation of a batch.
+type BatchResult struct {
+	Response *Response
+	Err      error
+}
+
+// BatchOption configures CheckBatch.
+type BatchOption func(*batchConfig)
+
+type batchConfig struct {
+	workers   int
+	checkOpts []CheckOption
+}
+
+// WithConcurrency sets how many checks run at once (default 8).
+func WithConcurrency(n int) BatchOption {
+	return func(b *batchConfig) { b.workers = n }
+}
+
+// WithBatchCheckOptions passes options (e.g. WithUserID) to every check.
+func WithBatchCheckOptions(opts ...CheckOption) BatchOption {
+	return func(b *batchConfig) { b.checkOpts = opts }
+}
+
+// CheckBatch checks many conversations concurrently on a bounded worker
+// pool, for offline dataset scans and high-throughput pipelines. Results are
+// in input order, one per conversation; a failed check sets Err and does not
+// stop the others. Each check gets the client's retry policy. Once ctx is
+// done, the remaining conversations fail with its error.
+func (c *Client) CheckBatch(ctx context.Context, convs []Conversation, opts ...BatchOption) []BatchResult {
+	cfg := batchConfig{workers: 8}
+	for _, o := range opts {
+		o(&cfg)
+	}
+	workers := min(max(cfg.workers, 1), len(convs))
+
+	results := make([]BatchResult, len(convs))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if err := ctx.Err(); err != nil {
+					results[i].Err = err
+					continue
+				}
+				results[i].Response, results[i].Err = c.CheckConversation(ctx, convs[i], cfg.checkOpts...)
+			}
+		}()
+	}
+	for i := range convs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,76 @@
+package guardrails
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCheckBatch(t *testing.T) {
+	var inflight, peak atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inflight.Add(1)
+		defer inflight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		var req checkRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		text := req.Messages[0].Content
+		switch {
+		case text == "broken":
+			http.Error(w, `{"detail":"bad input"}`, http.StatusBadRequest)
+		case strings.HasPrefix(text, "bad"):
+			io.WriteString(w, `{"id":"`+text+`","suggest_action":"reject"}`)
+		default:
+			io.WriteString(w, `{"id":"`+text+`","suggest_action":"pass"}`)
+		}
+	}))
+	defer srv.Close()
+
+	var convs []Conversation
+	for _, s := range []string{"ok-0", "bad-1", "ok-2", "broken", "ok-4", "bad-5", "ok-6", "ok-7"} {
+		convs = append(convs, Conversation{{Role: "user", Content: s}})
+	}
+	res := NewClient(WithBaseURL(srv.URL)).CheckBatch(context.Background(), convs, WithConcurrency(3))
+	if len(res) != len(convs) {
+		t.Fatalf("%d results", len(res))
+	}
+	for i, r := range res {
+		want := convs[i][0].Content
+		if want == "broken" {
+			if r.Err == nil {
+				t.Errorf("%d: expected an error", i)
+			}
+			continue
+		}
+		if r.Err != nil || r.Response.ID != want || r.Response.IsSafe() == strings.HasPrefix(want, "bad") {
+			t.Errorf("%d: %+v %v", i, r.Response, r.Err)
+		}
+	}
+	if p := peak.Load(); p > 3 || p < 2 {
+		t.Fatalf("peak concurrency %d, want 2..3", p)
+	}
+}
+
+func TestCheckBatchCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	res := NewClient(WithBaseURL("http://127.0.0.1:1")).CheckBatch(ctx, []Conversation{{{Role: "user", Content: "x"}}})
+	if res[0].Err != context.Canceled {
+		t.Fatalf("err = %v", res[0].Err)
+	}
+	if got := NewClient().CheckBatch(context.Background(), nil); len(got) != 0 {
+		t.Fatal("empty batch")
+	}
+}
@@ -0,0 +1,139 @@
+package guardrails
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache stores check results by content hash. Implementations must be safe
+// for concurrent use; errors are treated as misses, so a cache outage only
+// costs latency. NewLRUCache is in-process; the rediscache module shares
+// results across replicas.
+type Cache interface {
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// DefaultCacheTTL is how long WithCache keeps a result when given ttl 0.
+const DefaultCacheTTL = 5 * time.Minute
+
+// WithCache deduplicates CheckConversation calls (and the helpers built on
+// it) through cache. Results live for ttl, which should stay short: a
+// cached pass outlives any policy change made in the meantime.
+func WithCache(cache Cache, ttl time.Duration) Option {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return func(c *Client) { c.cache, c.cacheTTL = cache, ttl }
+}
+
+// CacheKey is the key a check is cached under: a SHA-256 over the model,
+// the user, and each message's role and content with whitespace runs
+// collapsed, so trivially reformatted text hits the same entry.
+func CacheKey(model, userID string, messages []Message) string {
+	h := sha256.New()
+	write := func(s string) {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	write(model)
+	write(userID)
+	for _, m := range messages {
+		write(m.Role)
+		write(strings.Join(strings.Fields(m.Content), " "))
+	}
+	return "ogr:v1:" + hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *Client) cachedCheck(ctx context.Context, req checkRequest) (*Response, bool, error) {
+	key := CacheKey(req.Model, req.UserID, req.Messages)
+	if raw, ok, err := c.cache.Get(ctx, key); err == nil && ok {
+		var r Response
+		if json.Unmarshal(raw, &r) == nil {
+			return &r, true, nil
+		}
+	}
+	var out Response
+	if err := c.post(ctx, "/guardrails", req, &out); err != nil {
+		return nil, false, err
+	}
+	if raw, err := json.Marshal(&out); err == nil {
+		c.cache.Set(ctx, key, raw, c.cacheTTL)
+	}
+	return &out, false, nil
+}
+
+// LRUCache is an in-process Cache holding at most a fixed number of
+// entries.
+type LRUCache struct {
+	mu    sync.Mutex
+	size  int
+	order *list.List
+	items map[string]*list.Element
+	now   func() time.Time
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewLRUCache returns a cache of at most size entries.
+func NewLRUCache(size int) *LRUCache {
+	if size < 1 {
+		size = 1
+	}
+	return &LRUCache{size: size, order: list.New(), items: map[string]*list.Element{}, now: time.Now}
+}
+
+// Get returns the live entry for key.
+func (l *LRUCache) Get(_ context.Context, key string) ([]byte, bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	el, ok := l.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*lruEntry)
+	if l.now().After(e.expires) {
+		l.order.Remove(el)
+		delete(l.items, key)
+		return nil, false, nil
+	}
+	l.order.MoveToFront(el)
+	return e.value, true, nil
+}
+
+// Set stores value for ttl, evicting the least recently used entry when
+// full.
+func (l *LRUCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if el, ok := l.items[key]; ok {
+		e := el.Value.(*lruEntry)
+		e.value, e.expires = value, l.now().Add(ttl)
+		l.order.MoveToFront(el)
+		return nil
+	}
+	l.items[key] = l.order.PushFront(&lruEntry{key: key, value: value, expires: l.now().Add(ttl)})
+	for l.order.Len() > l.size {
+		old := l.order.Back()
+		l.order.Remove(old)
+		delete(l.items, old.Value.(*lruEntry).key)
+	}
+	return nil
+}
+
+// Len reports the number of entries, expired ones included.
+func (l *LRUCache) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
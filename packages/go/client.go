@@ -0,0 +1,249 @@
+package guardrails
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Version is the SDK version sent in the User-Agent header.
+const Version = "0.1.0"
+
+// Defaults for NewClient.
+const (
+	DefaultBaseURL = "https://api.openguardrails.com/v1"
+	DefaultModel   = "OpenGuardrails-Text"
+	// DefaultVisionModel judges conversations that carry images.
+	DefaultVisionModel = "OpenGuardrails-VL"
+	DefaultTimeout     = 30 * time.Second
+)
+
+// Client calls the detection API. It is safe for concurrent use.
+type Client struct {
+	baseURL string
+	apiKey  string
+	model   string
+	vision  string
+	timeout time.Duration
+	retry   RetryPolicy
+	http    *http.Client
+
+	cache    Cache
+	cacheTTL time.Duration
+	observer Observer
+	limiter  *limiter
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithBaseURL points the client at a private deployment, e.g.
+// "http://guardrails.internal:5001/v1".
+func WithBaseURL(u string) Option {
+	return func(c *Client) { c.baseURL = strings.TrimRight(u, "/") }
+}
+
+// WithAPIKey sets the application API key sent as a bearer token.
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithModel selects the detection model.
+func WithModel(model string) Option {
+	return func(c *Client) { c.model = model }
+}
+
+// WithVisionModel selects the model for image and multimodal checks.
+func WithVisionModel(model string) Option {
+	return func(c *Client) { c.vision = model }
+}
+
+// WithTimeout bounds each call end to end, retries included (default 30s;
+// 0 disables). A deadline on the call's context applies as well; the
+// earlier one wins.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) { c.timeout = d }
+}
+
+// WithRetry sets the retry policy (default DefaultRetryPolicy).
+func WithRetry(p RetryPolicy) Option {
+	return func(c *Client) { c.retry = p }
+}
+
+// WithHTTPClient replaces the underlying HTTP client (transport, proxies,
+// TLS).
+func WithHTTPClient(h *http.Client) Option {
+	return func(c *Client) { c.http = h }
+}
+
+// NewClient returns a Client for the hosted platform unless options say
+// otherwise.
+func NewClient(opts ...Option) *Client {
+	c := &Client{
+		baseURL: DefaultBaseURL,
+		model:   DefaultModel,
+		vision:  DefaultVisionModel,
+		timeout: DefaultTimeout,
+		retry:   DefaultRetryPolicy,
+		http:    &http.Client{},
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// With returns a copy of c with opts applied, e.g. another application's
+// key. The copy shares c's HTTP client, cache, observer and rate limiter;
+// give it its own WithCache when the two must not share results.
+func (c *Client) With(opts ...Option) *Client {
+	cp := *c
+	for _, o := range opts {
+		o(&cp)
+	}
+	return &cp
+}
+
+// CheckOption adjusts one check.
+type CheckOption func(*checkRequest)
+
+// WithUserID attributes the check to an end user of your application, which
+// the platform uses for per-user risk tracking and ban policies.
+func WithUserID(id string) CheckOption {
+	return func(r *checkRequest) { r.UserID = id }
+}
+
+type checkRequest struct {
+	Model    string    `json:"model"`
+	Messages []Message `json:"messages"`
+	UserID   string    `json:"xxai_app_user_id,omitempty"`
+}
+
+// CheckPrompt checks a user prompt before it reaches the model.
+func (c *Client) CheckPrompt(ctx context.Context, prompt string, opts ...CheckOption) (*Response, error) {
+	return c.CheckConversation(ctx, []Message{{Role: "user", Content: prompt}}, opts...)
+}
+
+// CheckResponseCtx checks a model response in the context of the prompt
+// that produced it.
+func (c *Client) CheckResponseCtx(ctx context.Context, prompt, response string, opts ...CheckOption) (*Response, error) {
+	return c.CheckConversation(ctx, []Message{
+		{Role: "user", Content: prompt},
+		{Role: "assistant", Content: response},
+	}, opts...)
+}
+
+// CheckConversation checks a conversation. The last message is the one
+// judged; earlier messages are context.
+func (c *Client) CheckConversation(ctx context.Context, messages []Message, opts ...CheckOption) (*Response, error) {
+	if len(messages) == 0 {
+		return nil, errors.New("guardrails: no messages to check")
+	}
+	req := checkRequest{Model: c.model, Messages: messages}
+	for _, o := range opts {
+		o(&req)
+	}
+	ctx, done := c.observe(ctx, OpCheck, req.Model)
+	r, hit, err := c.check(ctx, req)
+	done(r, hit, err)
+	return r, err
+}
+
+// check runs req through the cache, if any, and reports whether it hit.
+func (c *Client) check(ctx context.Context, req checkRequest) (*Response, bool, error) {
+	if c.cache != nil {
+		return c.cachedCheck(ctx, req)
+	}
+	var out Response
+	if err := c.post(ctx, "/guardrails", req, &out); err != nil {
+		return nil, false, err
+	}
+	return &out, false, nil
+}
+
+// APIError is a non-2xx answer from the platform.
+type APIError struct {
+	StatusCode int
+	Message    string
+	// RetryAfter is the server's Retry-After hint, if it sent one.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("guardrails: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+func (c *Client) post(ctx context.Context, path string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	return c.retry.do(ctx, func(ctx context.Context) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "openguardrails-go/"+Version)
+		if c.apiKey != "" {
+			req.Header.Set("Authorization", "Bearer "+c.apiKey)
+		}
+		if c.limiter == nil {
+			return c.http.Do(req)
+		}
+		if err := c.limiter.wait(ctx); err != nil {
+			return nil, err
+		}
+		resp, err := c.http.Do(req)
+		if err == nil {
+			c.limiter.observe(resp)
+		}
+		return resp, err
+	}, func(resp *http.Response) error {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("guardrails: decode response: %w", err)
+		}
+		return nil
+	})
+}
+
+// newAPIError reads the platform's {"detail": ...} or {"error": ...} body,
+// falling back to the raw text.
+func newAPIError(resp *http.Response) *APIError {
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	e := &APIError{
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(raw)),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+	var body struct {
+		Detail any `json:"detail"`
+		Error  any `json:"error"`
+	}
+	if json.Unmarshal(raw, &body) == nil {
+		for _, v := range []any{body.Detail, body.Error} {
+			switch v := v.(type) {
+			case string:
+				e.Message = v
+				return e
+			case map[string]any:
+				if m, ok := v["message"].(string); ok {
+					e.Message = m
+					return e
+				}
+			}
+		}
+	}
+	return e
+}
@@ -0,0 +1,20 @@
+// Package guardrails is the Go client for the OpenGuardrails detection API
+// (POST /v1/guardrails). It checks prompts, conversations and model
+// responses and returns the platform's typed verdict, so Go services can
+// integrate without hand-rolling HTTP calls.
+//
+//	c := guardrails.NewClient(guardrails.WithAPIKey(os.Getenv("OPENGUARDRAILS_API_KEY")))
+//	r, err := c.CheckPrompt(ctx, prompt)
+//	if err != nil {
+//		return err // transport or API error; apply your fail mode
+//	}
+//	switch r.SuggestAction {
+//	case guardrails.ActionReject:
+//		// refuse
+//	case guardrails.ActionReplace:
+//		// answer with r.SuggestAnswer instead
+//	}
+//
+// The verdict subpackage turns responses from this and other detectors into
+// one enforcement decision.
+package guardrails
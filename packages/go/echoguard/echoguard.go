@@ -0,0 +1,68 @@
+// Package echoguard adapts the OpenGuardrails net/http middleware to Echo.
+//
+//	e := echo.New()
+//	e.POST("/v1/chat/completions", chatHandler, echoguard.Middleware(client))
+//	api := e.Group("/api", echoguard.MiddlewareWithConfig(echoguard.Config{
+//		Client:  client,
+//		Skipper: func(c echo.Context) bool { return c.Path() == "/api/health" },
+//		Options: []guardrails.MiddlewareOption{
+//			guardrails.WithRequestExtractor(guardrails.RequestPaths("question")),
+//		},
+//	}))
+//
+// The request is checked before the handler runs and the buffered response
+// before it is sent, with the same rules as Client.Middleware. Errors a
+// handler returns are rendered by Echo's HTTPErrorHandler inside the guard,
+// so error bodies are buffered like any other response.
+package echoguard
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/openguardrails/openguardrails-go"
+)
+
+// Config configures MiddlewareWithConfig.
+type Config struct {
+	Client *guardrails.Client
+	// Skipper bypasses the guard for matching requests.
+	Skipper middleware.Skipper
+	Options []guardrails.MiddlewareOption
+}
+
+// Middleware guards routes with the default options plus opts.
+func Middleware(c *guardrails.Client, opts ...guardrails.MiddlewareOption) echo.MiddlewareFunc {
+	return MiddlewareWithConfig(Config{Client: c, Options: opts})
+}
+
+// MiddlewareWithConfig guards routes as cfg describes. It panics without a
+// Client, like Echo's own middleware on invalid config.
+func MiddlewareWithConfig(cfg Config) echo.MiddlewareFunc {
+	if cfg.Client == nil {
+		panic("echoguard: Config.Client is required")
+	}
+	if cfg.Skipper == nil {
+		cfg.Skipper = middleware.DefaultSkipper
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if cfg.Skipper(c) {
+				return next(c)
+			}
+			inner := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				res := c.Response()
+				orig := res.Writer
+				res.Writer = w
+				defer func() { res.Writer = orig }()
+				c.SetRequest(r)
+				if err := next(c); err != nil {
+					c.Error(err)
+				}
+			})
+			cfg.Client.Middleware(inner, cfg.Options...).ServeHTTP(c.Response().Writer, c.Request())
+			return nil
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package echoguard
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/openguardrails/openguardrails-go"
+)
+
+func platform(t *testing.T, bad string) *guardrails.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []guardrails.Message `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if strings.Contains(req.Messages[len(req.Messages)-1].Content, bad) {
+			io.WriteString(w, `{"id":"g","suggest_action":"reject","suggest_answer":"no"}`)
+			return
+		}
+		io.WriteString(w, `{"suggest_action":"pass"}`)
+	}))
+	t.Cleanup(srv.Close)
+	return guardrails.NewClient(guardrails.WithBaseURL(srv.URL), guardrails.WithRetry(guardrails.NoRetry))
+}
+
+func server(c *guardrails.Client, handler echo.HandlerFunc) *echo.Echo {
+	e := echo.New()
+	e.POST("/ask", handler, MiddlewareWithConfig(Config{
+		Client:  c,
+		Skipper: func(c echo.Context) bool { return c.QueryParam("skip") == "1" },
+		Options: []guardrails.MiddlewareOption{
+			guardrails.WithRequestExtractor(guardrails.RequestPaths("question")),
+			guardrails.WithResponseExtractor(guardrails.ResponsePaths("answer")),
+		},
+	}))
+	return e
+}
+
+var answer = func(c echo.Context) error {
+	var in struct {
+		Question string `json:"question"`
+	}
+	c.Bind(&in)
+	return c.JSON(http.StatusOK, map[string]string{"answer": "you asked: " + in.Question})
+}
+
+func do(h http.Handler, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestEchoPasses(t *testing.T) {
+	rec := do(server(platform(t, "forbidden"), answer), "/ask", `{"question":"weather?"}`)
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), "you asked: weather?") {
+		t.Fatalf("%d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestEchoRejects(t *testing.T) {
+	called := false
+	rec := do(server(platform(t, "forbidden"), func(c echo.Context) error { called = true; return nil }),
+		"/ask", `{"question":"forbidden thing"}`)
+	if called || !strings.Contains(rec.Body.String(), `"content":"no"`) {
+		t.Fatalf("called=%v %s", called, rec.Body)
+	}
+
+	rec = do(server(platform(t, "you asked"), answer), "/ask", `{"question":"hi"}`)
+	if strings.Contains(rec.Body.String(), "you asked") {
+		t.Fatalf("output not checked: %s", rec.Body)
+	}
+}
+
+func TestEchoSkipperAndErrors(t *testing.T) {
+	rec := do(server(platform(t, "forbidden"), answer), "/ask?skip=1", `{"question":"forbidden"}`)
+	if !strings.Contains(rec.Body.String(), "you asked: forbidden") {
+		t.Fatalf("skipper ignored: %s", rec.Body)
+	}
+	rec = do(server(platform(t, "forbidden"), func(c echo.Context) error {
+		return echo.NewHTTPError(http.StatusTeapot, "short and stout")
+	}), "/ask", `{"question":"hi"}`)
+	if rec.Code != http.StatusTeapot || !strings.Contains(rec.Body.String(), "short and stout") {
+		t.Fatalf("handler error: %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,24 @@
+module github.com/openguardrails/openguardrails-go/echoguard
+
+go 1.22
+
+require (
+	github.com/labstack/echo/v4 v4.12.0
+	github.com/openguardrails/openguardrails-go v0.0.0
+)
+
+require (
+	github.com/golang-jwt/jwt v3.2.2+incompatible // indirect
+	github.com/labstack/gommon v0.4.2 // indirect
+	github.com/mattn/go-colorable v0.1.13 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	github.com/valyala/fasttemplate v1.2.2 // indirect
+	golang.org/x/crypto v0.22.0 // indirect
+	golang.org/x/net v0.24.0 // indirect
+	golang.org/x/sys v0.19.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+	golang.org/x/time v0.5.0 // indirect
+)
+
+replace github.com/openguardrails/openguardrails-go => ../
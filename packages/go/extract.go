@@ -0,0 +1,86 @@
+package guardrails
+
+import (
+	"strconv"
+	"strings"
+)
+
+// RequestPaths builds a RequestExtractor from dotted JSON paths, for APIs
+// that are not OpenAI-shaped: "input", "data.prompt", "turns.0.text". A
+// path may resolve to a string (one user message), an array of strings, or
+// an array of {role, content} messages. Results of all paths are
+// concatenated in order.
+func RequestPaths(paths ...string) RequestExtractor {
+	return func(body map[string]any) []Message {
+		var out []Message
+		for _, p := range paths {
+			v, ok := lookupPath(body, p)
+			if !ok {
+				continue
+			}
+			switch v := v.(type) {
+			case string:
+				if v != "" {
+					out = append(out, Message{Role: "user", Content: v})
+				}
+			case []any:
+				for _, item := range v {
+					switch item := item.(type) {
+					case string:
+						if item != "" {
+							out = append(out, Message{Role: "user", Content: item})
+						}
+					case map[string]any:
+						role, _ := item["role"].(string)
+						if role == "" {
+							role = "user"
+						}
+						if text := contentText(item["content"]); text != "" {
+							out = append(out, Message{Role: role, Content: text})
+						}
+					}
+				}
+			}
+		}
+		return out
+	}
+}
+
+// ResponsePaths builds a ResponseExtractor from dotted JSON paths, e.g.
+// "output.text". String values of all paths are joined with newlines.
+func ResponsePaths(paths ...string) ResponseExtractor {
+	return func(body map[string]any) string {
+		var parts []string
+		for _, p := range paths {
+			if v, ok := lookupPath(body, p); ok {
+				if s := contentText(v); s != "" {
+					parts = append(parts, s)
+				}
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+}
+
+// lookupPath resolves a dotted path through objects and (by index) arrays.
+func lookupPath(v any, path string) (any, bool) {
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]any:
+			next, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			v = next
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			v = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,31 @@
+package guardrails
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPathExtractors(t *testing.T) {
+	var body map[string]any
+	json.Unmarshal([]byte(`{
+		"system": "be brief",
+		"data": {"prompt": "hello", "history": [{"role": "assistant", "content": "hi"}, {"content": "again"}]},
+		"tags": ["a", "b"],
+		"output": [{"text": "one"}, {"text": "two"}]
+	}`), &body)
+
+	got := RequestPaths("data.history", "data.prompt", "missing.path", "tags.1", "tags.9")(body)
+	want := []Message{{"assistant", "hi"}, {"user", "again"}, {"user", "hello"}, {"user", "b"}}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("message %d: %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if s := ResponsePaths("output.0.text", "output.1.text", "output.x")(body); s != "one\ntwo" {
+		t.Fatalf("response %q", s)
+	}
+}
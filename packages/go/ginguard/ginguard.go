@@ -0,0 +1,95 @@
+// Package ginguard adapts the OpenGuardrails net/http middleware to Gin.
+//
+//	r := gin.New()
+//	r.POST("/v1/chat/completions", ginguard.Middleware(client), chatHandler)
+//	r.POST("/ask", ginguard.Middleware(client,
+//		guardrails.WithRequestExtractor(guardrails.RequestPaths("question")),
+//		guardrails.WithResponseExtractor(guardrails.ResponsePaths("answer")),
+//	), askHandler)
+//
+// Attach it per route or per group; each attachment has its own options.
+// The request is checked before the rest of the chain runs and the buffered
+// response before it is sent, with the same rules as Client.Middleware.
+package ginguard
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openguardrails/openguardrails-go"
+)
+
+// Middleware returns a Gin handler that guards the rest of the chain.
+func Middleware(c *guardrails.Client, opts ...guardrails.MiddlewareOption) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ran := false
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ran = true
+			orig := ctx.Writer
+			ctx.Request = r
+			ctx.Writer = &writer{ResponseWriter: w, status: http.StatusOK, orig: orig}
+			defer func() { ctx.Writer = orig }()
+			ctx.Next()
+		})
+		c.Middleware(next, opts...).ServeHTTP(ctx.Writer, ctx.Request)
+		if !ran {
+			ctx.Abort()
+		}
+	}
+}
+
+// writer presents the middleware's buffering writer as a gin.ResponseWriter
+// to the downstream handlers.
+type writer struct {
+	http.ResponseWriter
+	orig    gin.ResponseWriter
+	status  int
+	size    int
+	written bool
+}
+
+var _ gin.ResponseWriter = (*writer)(nil)
+
+func (w *writer) WriteHeader(code int) {
+	if !w.written {
+		w.status = code
+	}
+}
+
+func (w *writer) WriteHeaderNow() {
+	if !w.written {
+		w.written = true
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	w.WriteHeaderNow()
+	n, err := w.ResponseWriter.Write(p)
+	w.size += n
+	return n, err
+}
+
+func (w *writer) WriteString(s string) (int, error) { return w.Write([]byte(s)) }
+
+func (w *writer) Status() int { return w.status }
+
+func (w *writer) Size() int {
+	if !w.written {
+		return -1
+	}
+	return w.size
+}
+
+func (w *writer) Written() bool { return w.written }
+
+// Flush is a no-op: the response is buffered until it has been checked.
+func (w *writer) Flush() {}
+
+func (w *writer) Hijack() (net.Conn, *bufio.ReadWriter, error) { return w.orig.Hijack() }
+
+func (w *writer) CloseNotify() <-chan bool { return w.orig.CloseNotify() }
+
+func (w *writer) Pusher() http.Pusher { return nil }
@@ -0,0 +1,82 @@
+package ginguard
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/openguardrails/openguardrails-go"
+)
+
+func platform(t *testing.T, bad string) *guardrails.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []guardrails.Message `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if strings.Contains(req.Messages[len(req.Messages)-1].Content, bad) {
+			io.WriteString(w, `{"suggest_action":"reject","suggest_answer":"no"}`)
+			return
+		}
+		io.WriteString(w, `{"suggest_action":"pass"}`)
+	}))
+	t.Cleanup(srv.Close)
+	return guardrails.NewClient(guardrails.WithBaseURL(srv.URL), guardrails.WithRetry(guardrails.NoRetry))
+}
+
+func router(c *guardrails.Client, after *bool) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/ask", Middleware(c,
+		guardrails.WithRequestExtractor(guardrails.RequestPaths("question")),
+		guardrails.WithResponseExtractor(guardrails.ResponsePaths("answer")),
+		guardrails.WithDeny(func(w http.ResponseWriter, r *http.Request, resp *guardrails.Response) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			io.WriteString(w, `{"error":"blocked"}`)
+		}),
+	), func(ctx *gin.Context) {
+		var in struct{ Question string }
+		ctx.ShouldBindJSON(&in)
+		ctx.JSON(http.StatusOK, gin.H{"answer": "you asked: " + in.Question})
+	}, func(ctx *gin.Context) { *after = true })
+	return r
+}
+
+func do(r http.Handler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/ask", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestGinPasses(t *testing.T) {
+	var after bool
+	rec := do(router(platform(t, "forbidden"), &after), `{"question":"weather?"}`)
+	if rec.Code != 200 || !strings.Contains(rec.Body.String(), "you asked: weather?") || !after {
+		t.Fatalf("%d %s after=%v", rec.Code, rec.Body, after)
+	}
+}
+
+func TestGinRejectsInputAndAborts(t *testing.T) {
+	var after bool
+	rec := do(router(platform(t, "forbidden"), &after), `{"question":"forbidden thing"}`)
+	if rec.Code != http.StatusForbidden || rec.Body.String() != `{"error":"blocked"}` || after {
+		t.Fatalf("%d %s after=%v", rec.Code, rec.Body, after)
+	}
+}
+
+func TestGinRejectsOutput(t *testing.T) {
+	var after bool
+	// The question passes; the handler's echo of it is checked as output.
+	rec := do(router(platform(t, "you asked"), &after), `{"question":"hi"}`)
+	if rec.Code != http.StatusForbidden || strings.Contains(rec.Body.String(), "you asked") {
+		t.Fatalf("%d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,3 @@
+module github.com/openguardrails/openguardrails-go
+
+go 1.22
@@ -0,0 +1,123 @@
+package grpcguard
+
+import (
+	"strings"
+
+	"github.com/openguardrails/openguardrails-go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// extract returns the text the fields at paths of msg carry, as messages
+// of the given role. nil paths mean every string field. A path that ends
+// at a message with string role and content fields (or a list of them)
+// yields those turns with their own roles. Paths a message does not have
+// are skipped, so one mask can serve several message types.
+func extract(msg any, paths []string, role string) []guardrails.Message {
+	m, ok := msg.(proto.Message)
+	if !ok || m == nil {
+		return nil
+	}
+	x := &extractor{role: role}
+	rm := m.ProtoReflect()
+	if paths == nil {
+		x.all(rm)
+	}
+	for _, p := range paths {
+		x.field(rm, strings.Split(p, "."))
+	}
+	return x.out
+}
+
+type extractor struct {
+	role string
+	out  []guardrails.Message
+}
+
+func (x *extractor) add(role, text string) {
+	if text != "" {
+		x.out = append(x.out, guardrails.Message{Role: role, Content: text})
+	}
+}
+
+func (x *extractor) field(m protoreflect.Message, path []string) {
+	fd := m.Descriptor().Fields().ByName(protoreflect.Name(path[0]))
+	if fd == nil || !m.Has(fd) {
+		return
+	}
+	x.value(fd, m.Get(fd), path[1:])
+}
+
+// value collects what the value v of field fd holds at the rest of a path.
+func (x *extractor) value(fd protoreflect.FieldDescriptor, v protoreflect.Value, rest []string) {
+	switch {
+	case fd.IsList():
+		l := v.List()
+		for i := 0; i < l.Len(); i++ {
+			x.item(fd, l.Get(i), rest)
+		}
+	case fd.IsMap():
+		if len(rest) > 0 {
+			return
+		}
+		v.Map().Range(func(_ protoreflect.MapKey, mv protoreflect.Value) bool {
+			x.item(fd.MapValue(), mv, nil)
+			return true
+		})
+	default:
+		x.item(fd, v, rest)
+	}
+}
+
+// item collects one element of a field.
+func (x *extractor) item(fd protoreflect.FieldDescriptor, v protoreflect.Value, rest []string) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		if len(rest) == 0 {
+			x.add(x.role, v.String())
+		}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		m := v.Message()
+		if len(rest) > 0 {
+			x.field(m, rest)
+			return
+		}
+		if role, content, ok := turn(m); ok {
+			if role == "" {
+				role = x.role
+			}
+			x.add(role, content)
+			return
+		}
+		x.all(m)
+	}
+}
+
+// all collects every string field of m, in field order.
+func (x *extractor) all(m protoreflect.Message) {
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		if fd := fields.Get(i); m.Has(fd) {
+			x.value(fd, m.Get(fd), nil)
+		}
+	}
+}
+
+// turn reads a chat turn: a message with string role and content fields.
+func turn(m protoreflect.Message) (role, content string, ok bool) {
+	fields := m.Descriptor().Fields()
+	r, c := fields.ByName("role"), fields.ByName("content")
+	if r == nil || c == nil || r.Kind() != protoreflect.StringKind || c.Kind() != protoreflect.StringKind || r.IsList() || c.IsList() {
+		return "", "", false
+	}
+	return m.Get(r).String(), m.Get(c).String(), true
+}
+
+// text joins what messages say.
+func text(messages []guardrails.Message) string {
+	parts := make([]string, len(messages))
+	for i, m := range messages {
+		parts[i] = m.Content
+	}
+	return strings.Join(parts, "\n")
+}
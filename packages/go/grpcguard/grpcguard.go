@@ -0,0 +1,267 @@
+// Package grpcguard checks LLM traffic served over gRPC, with unary and
+// streaming interceptors for either end of a call:
+//
+//	srv := grpc.NewServer(
+//		grpc.ChainUnaryInterceptor(grpcguard.UnaryServerInterceptor(client, opts...)),
+//		grpc.ChainStreamInterceptor(grpcguard.StreamServerInterceptor(client, opts...)),
+//	)
+//	opts := []grpcguard.Option{
+//		grpcguard.WithRequestMask(&fieldmaskpb.FieldMask{Paths: []string{"messages", "system"}}),
+//		grpcguard.WithResponseMask(&fieldmaskpb.FieldMask{Paths: []string{"text"}}),
+//	}
+//
+// Field masks name the string fields to check in request and response
+// messages; a path may also end at a message with role and content fields,
+// or a list of them, which is read as a conversation. Without a mask every
+// string field is checked.
+//
+// Requests are checked before they reach the handler (or the wire, on the
+// client), and responses in the context of the last request checked. A
+// rejected message fails the call with PERMISSION_DENIED carrying the
+// platform's suggested answer; a failed check with UNAVAILABLE unless
+// WithFailOpen. Streamed responses are held until the text they carry has
+// been checked, every WithCheckEvery tokens, as GuardStream does for SSE.
+package grpcguard
+
+import (
+	"context"
+
+	"github.com/openguardrails/openguardrails-go"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// Option configures an interceptor.
+type Option func(*guard)
+
+// WithRequestMask sets the request fields to check. A mask without paths
+// checks nothing.
+func WithRequestMask(m *fieldmaskpb.FieldMask) Option {
+	return func(g *guard) { g.reqPaths = paths(m) }
+}
+
+// WithResponseMask sets the response fields to check. A mask without paths
+// checks nothing (input guarding only).
+func WithResponseMask(m *fieldmaskpb.FieldMask) Option {
+	return func(g *guard) { g.respPaths = paths(m) }
+}
+
+// WithMethods limits the interceptor to the given full method names
+// ("/pkg.Service/Method"); other calls pass through.
+func WithMethods(methods ...string) Option {
+	return func(g *guard) {
+		g.methods = map[string]bool{}
+		for _, m := range methods {
+			g.methods[m] = true
+		}
+	}
+}
+
+// WithUserIDFunc attributes each check to the end user behind the call,
+// e.g. from incoming metadata on the server.
+func WithUserIDFunc(f func(context.Context) string) Option {
+	return func(g *guard) { g.userID = f }
+}
+
+// WithFailOpen lets traffic through when a check fails. By default a
+// failed check fails the call.
+func WithFailOpen() Option {
+	return func(g *guard) { g.failOpen = true }
+}
+
+// WithCheckEvery sets how many new tokens of a streamed response
+// accumulate between checks (default 50).
+func WithCheckEvery(tokens int) Option {
+	return func(g *guard) { g.every = tokens }
+}
+
+// WithWindow sets how many trailing tokens of a streamed response each
+// check sees (default 400).
+func WithWindow(tokens int) Option {
+	return func(g *guard) { g.window = tokens }
+}
+
+// WithDeny replaces the error a rejected message fails the call with.
+// stage is "request" or "response"; resp is nil when the check failed.
+func WithDeny(f func(stage string, resp *guardrails.Response) error) Option {
+	return func(g *guard) { g.deny = f }
+}
+
+func paths(m *fieldmaskpb.FieldMask) []string {
+	if m == nil {
+		return nil
+	}
+	return append([]string{}, m.GetPaths()...)
+}
+
+type guard struct {
+	c         *guardrails.Client
+	reqPaths  []string
+	respPaths []string
+	methods   map[string]bool
+	userID    func(context.Context) string
+	failOpen  bool
+	every     int
+	window    int
+	deny      func(stage string, resp *guardrails.Response) error
+}
+
+func newGuard(c *guardrails.Client, opts []Option) *guard {
+	g := &guard{c: c, every: 50, window: 400, deny: Deny}
+	for _, o := range opts {
+		o(g)
+	}
+	return g
+}
+
+// Deny is the default deny: PERMISSION_DENIED with the platform's
+// suggested answer, or UNAVAILABLE when the check failed.
+func Deny(stage string, resp *guardrails.Response) error {
+	if resp == nil {
+		return status.Error(codes.Unavailable, "content safety check unavailable")
+	}
+	answer := resp.SuggestAnswer
+	if answer == "" {
+		answer = guardrails.DefaultRefusal
+	}
+	return status.Error(codes.PermissionDenied, answer)
+}
+
+func (g *guard) guards(method string) bool {
+	return g.methods == nil || g.methods[method]
+}
+
+func (g *guard) checkOpts(ctx context.Context) []guardrails.CheckOption {
+	if g.userID == nil {
+		return nil
+	}
+	if id := g.userID(ctx); id != "" {
+		return []guardrails.CheckOption{guardrails.WithUserID(id)}
+	}
+	return nil
+}
+
+// request checks a request message, returning the conversation it carries
+// (nil if none) or the error that fails the call.
+func (g *guard) request(ctx context.Context, msg any) ([]guardrails.Message, error) {
+	if g.reqPaths != nil && len(g.reqPaths) == 0 {
+		return nil, nil
+	}
+	conv := extract(msg, g.reqPaths, "user")
+	if len(conv) == 0 {
+		return nil, nil
+	}
+	resp, err := g.c.CheckConversation(ctx, conv, g.checkOpts(ctx)...)
+	if err := g.verdict("request", resp, err); err != nil {
+		return nil, err
+	}
+	return conv, nil
+}
+
+// responseText is the text of a response message to check, "" for none.
+func (g *guard) responseText(msg any) string {
+	if g.respPaths != nil && len(g.respPaths) == 0 {
+		return ""
+	}
+	return text(extract(msg, g.respPaths, "assistant"))
+}
+
+// response checks answer in the context of prompt.
+func (g *guard) response(ctx context.Context, prompt []guardrails.Message, answer string) error {
+	conv := append(prompt[:len(prompt):len(prompt)], guardrails.Message{Role: "assistant", Content: answer})
+	resp, err := g.c.CheckConversation(ctx, conv, g.checkOpts(ctx)...)
+	return g.verdict("response", resp, err)
+}
+
+func (g *guard) verdict(stage string, resp *guardrails.Response, err error) error {
+	if err != nil {
+		if g.failOpen {
+			return nil
+		}
+		return g.deny(stage, nil)
+	}
+	if resp.IsSafe() {
+		return nil
+	}
+	return g.deny(stage, resp)
+}
+
+// UnaryServerInterceptor checks the request before the handler runs and
+// its response before it is sent.
+func UnaryServerInterceptor(c *guardrails.Client, opts ...Option) grpc.UnaryServerInterceptor {
+	g := newGuard(c, opts)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if !g.guards(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		prompt, err := g.request(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := handler(ctx, req)
+		if err != nil {
+			return resp, err
+		}
+		if answer := g.responseText(resp); answer != "" {
+			if err := g.response(ctx, prompt, answer); err != nil {
+				return nil, err
+			}
+		}
+		return resp, nil
+	}
+}
+
+// UnaryClientInterceptor checks the request before it is sent and the
+// reply before the caller sees it.
+func UnaryClientInterceptor(c *guardrails.Client, opts ...Option) grpc.UnaryClientInterceptor {
+	g := newGuard(c, opts)
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		if !g.guards(method) {
+			return invoker(ctx, method, req, reply, cc, callOpts...)
+		}
+		prompt, err := g.request(ctx, req)
+		if err != nil {
+			return err
+		}
+		if err := invoker(ctx, method, req, reply, cc, callOpts...); err != nil {
+			return err
+		}
+		if answer := g.responseText(reply); answer != "" {
+			return g.response(ctx, prompt, answer)
+		}
+		return nil
+	}
+}
+
+// StreamServerInterceptor checks each request message as the handler
+// receives it, and holds response messages until their text is checked.
+func StreamServerInterceptor(c *guardrails.Client, opts ...Option) grpc.StreamServerInterceptor {
+	g := newGuard(c, opts)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !g.guards(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		s := &serverStream{ServerStream: ss, a: g.answer(ss.Context())}
+		return s.close(handler(srv, s))
+	}
+}
+
+// StreamClientInterceptor checks each request message before it is sent,
+// and reads response messages ahead until their text is checked.
+func StreamClientInterceptor(c *guardrails.Client, opts ...Option) grpc.StreamClientInterceptor {
+	g := newGuard(c, opts)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if !g.guards(method) {
+			return streamer(ctx, desc, cc, method, callOpts...)
+		}
+		ctx, cancel := context.WithCancel(ctx)
+		cs, err := streamer(ctx, desc, cc, method, callOpts...)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		return &clientStream{ClientStream: cs, a: g.answer(ctx), cancel: cancel}, nil
+	}
+}
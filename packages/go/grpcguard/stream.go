@@ -0,0 +1,236 @@
+package grpcguard
+
+import (
+	"context"
+	"sync"
+
+	"github.com/openguardrails/openguardrails-go"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+)
+
+// answer checks a streamed response as it accumulates. Tokens are
+// estimated at four runes each.
+type answer struct {
+	g       *guard
+	ctx     context.Context
+	prompt  []guardrails.Message
+	text    []rune
+	checked int
+}
+
+func (g *guard) answer(ctx context.Context) *answer {
+	return &answer{g: g, ctx: ctx}
+}
+
+// ask starts the answer to a request checked on the stream, after
+// checking what is left of the previous one.
+func (a *answer) ask(prompt []guardrails.Message) error {
+	if prompt == nil {
+		return nil
+	}
+	if err := a.final(); err != nil {
+		return err
+	}
+	a.prompt, a.text, a.checked = prompt, nil, 0
+	return nil
+}
+
+// add appends the text of a response message and checks it when a check
+// is due. It reports whether all the text so far has passed.
+func (a *answer) add(s string) (bool, error) {
+	if s != "" {
+		if len(a.text) > 0 {
+			s = "\n" + s
+		}
+		a.text = append(a.text, []rune(s)...)
+	}
+	if (len(a.text)-a.checked)/4 >= a.g.every {
+		if err := a.check(); err != nil {
+			return false, err
+		}
+	}
+	return a.checked == len(a.text), nil
+}
+
+// check checks the trailing window of the text.
+func (a *answer) check() error {
+	start := max(len(a.text)-a.g.window*4, 0)
+	if err := a.g.response(a.ctx, a.prompt, string(a.text[start:])); err != nil {
+		return err
+	}
+	a.checked = len(a.text)
+	return nil
+}
+
+// final checks what is left unchecked.
+func (a *answer) final() error {
+	if a.checked == len(a.text) {
+		return nil
+	}
+	return a.check()
+}
+
+// serverStream checks what a streaming handler receives and holds what it
+// sends. gRPC allows one goroutine to send while another receives, hence
+// mu.
+type serverStream struct {
+	grpc.ServerStream
+	mu   sync.Mutex
+	a    *answer
+	held []any
+	err  error
+}
+
+func (s *serverStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	prompt, err := s.a.g.request(s.Context(), m)
+	if err == nil && prompt == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil {
+		err = s.a.ask(prompt)
+	}
+	if err != nil {
+		s.err, s.held = err, nil
+		return err
+	}
+	// The previous answer has passed.
+	return s.flush()
+}
+
+func (s *serverStream) SendMsg(m any) error {
+	pm, ok := m.(proto.Message)
+	if !ok {
+		return s.ServerStream.SendMsg(m)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	// Handlers may reuse m once SendMsg returns.
+	s.held = append(s.held, proto.Clone(pm))
+	passed, err := s.a.add(s.a.g.responseText(m))
+	if err != nil {
+		s.err, s.held = err, nil
+		return err
+	}
+	if !passed {
+		return nil
+	}
+	return s.flush()
+}
+
+// close checks the tail of the answer once the handler has returned and
+// sends what passed. It returns the error that fails the call instead of
+// err, if any.
+func (s *serverStream) close(err error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if ferr := s.a.final(); ferr != nil {
+		return ferr
+	}
+	if ferr := s.flush(); ferr != nil && err == nil {
+		return ferr
+	}
+	return err
+}
+
+// flush sends the messages whose text has passed. s.mu is held.
+func (s *serverStream) flush() error {
+	held := s.held
+	s.held = nil
+	for _, m := range held {
+		if err := s.ServerStream.SendMsg(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// clientStream checks what the caller sends and reads responses ahead of
+// the caller until their text has passed. mu guards the answer and the
+// queues, not the reads, so SendMsg is not held up by a waiting RecvMsg.
+type clientStream struct {
+	grpc.ClientStream
+	cancel  context.CancelFunc
+	mu      sync.Mutex
+	a       *answer
+	pending []proto.Message // received, not yet checked
+	ready   []proto.Message // checked, for RecvMsg
+	done    error           // how the stream ended
+}
+
+func (s *clientStream) SendMsg(m any) error {
+	prompt, err := s.a.g.request(s.Context(), m)
+	if err != nil {
+		return err
+	}
+	if prompt != nil {
+		s.mu.Lock()
+		err = s.a.ask(prompt)
+		s.settle(err, nil)
+		s.mu.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return s.ClientStream.SendMsg(m)
+}
+
+func (s *clientStream) RecvMsg(m any) error {
+	pm, ok := m.(proto.Message)
+	if !ok {
+		return s.ClientStream.RecvMsg(m)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.ready) == 0 {
+		if s.done != nil {
+			return s.done
+		}
+		next := pm.ProtoReflect().New().Interface()
+		s.mu.Unlock()
+		err := s.ClientStream.RecvMsg(next)
+		s.mu.Lock()
+		if err != nil {
+			s.settle(s.a.final(), err)
+			continue
+		}
+		s.pending = append(s.pending, next)
+		if passed, err := s.a.add(s.a.g.responseText(next)); err != nil || passed {
+			s.settle(err, nil)
+		}
+	}
+	proto.Reset(pm)
+	proto.Merge(pm, s.ready[0])
+	s.ready = s.ready[1:]
+	return nil
+}
+
+// settle releases the pending messages, or drops them and ends the call
+// when checkErr is set; err, if set, is how the stream itself ended. s.mu
+// is held.
+func (s *clientStream) settle(checkErr, err error) {
+	if checkErr != nil {
+		s.pending, s.ready = nil, nil
+		if s.done == nil {
+			s.done = checkErr
+		}
+		s.cancel()
+		return
+	}
+	s.ready, s.pending = append(s.ready, s.pending...), nil
+	if err != nil && s.done == nil {
+		s.done = err
+		s.cancel()
+	}
+}
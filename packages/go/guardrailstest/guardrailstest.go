@@ -0,0 +1,290 @@
+// Package guardrailstest provides a fake detection API for tests of code
+// built on the SDK:
+//
+//	srv := guardrailstest.NewServer()
+//	defer srv.Close()
+//	srv.Reject(`(?i)ignore (all )?previous instructions`, guardrails.CategoryPromptAttack)
+//	client := srv.Client()
+//
+// Content no rule matches passes, and anonymize requests mask only what
+// Mask rules match. The server speaks the platform's HTTP
+// contract, so it can also stand in for the platform behind code that is
+// not written in Go: point that code at srv.URL.
+package guardrailstest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go"
+	"github.com/openguardrails/openguardrails-go/verdict"
+)
+
+// RejectAnswer is the suggested answer of responses built by Reject.
+const RejectAnswer = "Sorry, I can't help with that."
+
+// Call is one request the server received.
+type Call struct {
+	Path   string
+	Model  string
+	UserID string
+	// Messages holds the conversation with multimodal content flattened to
+	// its text parts.
+	Messages []guardrails.Message
+	Images   int
+	// Text is the text of an anonymize request.
+	Text   string
+	Header http.Header
+}
+
+type rule struct {
+	re   *regexp.Regexp
+	resp guardrails.Response
+}
+
+type mask struct {
+	re     *regexp.Regexp
+	entity string
+}
+
+type failure struct {
+	status int
+	left   int
+}
+
+// Server is a programmable fake of the detection API.
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	rules   []rule
+	masks   []mask
+	latency time.Duration
+	fail    failure
+	calls   []Call
+	seq     int
+}
+
+// NewServer starts a server on which every check passes.
+func NewServer() *Server {
+	s := &Server{}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Client returns a client for the server with retries off; opts apply
+// after.
+func (s *Server) Client(opts ...guardrails.Option) *guardrails.Client {
+	return guardrails.NewClient(append([]guardrails.Option{
+		guardrails.WithBaseURL(s.URL), guardrails.WithRetry(guardrails.NoRetry),
+	}, opts...)...)
+}
+
+// On answers checks whose last message matches pattern (a regexp) with r.
+// Rules are tried in the order they were added; the first match wins. An
+// empty r.ID is filled in per call.
+func (s *Server) On(pattern string, r guardrails.Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = append(s.rules, rule{re: regexp.MustCompile(pattern), resp: r})
+}
+
+// Reject rejects content matching pattern with the given categories, each
+// reported under its dimension at its taxonomy severity.
+func (s *Server) Reject(pattern string, categories ...guardrails.Category) {
+	r := guardrails.Response{
+		SuggestAction:    guardrails.ActionReject,
+		SuggestAnswer:    RejectAnswer,
+		OverallRiskLevel: verdict.HighRisk,
+		Score:            1,
+	}
+	if len(categories) > 0 {
+		r.OverallRiskLevel = verdict.NoRisk
+	}
+	for _, c := range categories {
+		d := &r.Result.Security
+		switch c.Group() {
+		case guardrails.GroupCompliance:
+			d = &r.Result.Compliance
+		case guardrails.GroupData:
+			d = &r.Result.Data.Dimension
+		}
+		d.Categories = append(d.Categories, c)
+		d.RiskLevel = higher(d.RiskLevel, c.Severity())
+		r.OverallRiskLevel = higher(r.OverallRiskLevel, c.Severity())
+	}
+	s.On(pattern, r)
+}
+
+// Replace answers content matching pattern with a replace suggestion.
+func (s *Server) Replace(pattern, answer string) {
+	s.On(pattern, guardrails.Response{
+		SuggestAction:    guardrails.ActionReplace,
+		SuggestAnswer:    answer,
+		OverallRiskLevel: verdict.MediumRisk,
+		Score:            0.5,
+	})
+}
+
+// Mask makes anonymize requests replace text matching pattern (a regexp)
+// with placeholders for entity, numbered per request: "__EMAIL_1__" for
+// the first address Mask(`\S+@\S+`, "EMAIL") matches. Masks are applied in
+// the order they were added.
+func (s *Server) Mask(pattern, entity string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.masks = append(s.masks, mask{re: regexp.MustCompile(pattern), entity: entity})
+}
+
+// SetLatency delays every answer by d, honouring client cancellation.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// FailNext answers the next n requests with status instead of a result.
+func (s *Server) FailNext(n, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fail = failure{status: status, left: n}
+}
+
+// Calls returns the requests received so far.
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// Reset clears rules, masks, latency, failures and recorded calls.
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules, s.masks, s.latency, s.fail, s.calls = nil, nil, 0, failure{}, nil
+}
+
+type request struct {
+	Model    string `json:"model"`
+	Text     string `json:"text"`
+	UserID   string `json:"xxai_app_user_id"`
+	Messages []struct {
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+	} `json:"messages"`
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	var req request
+	if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&req) != nil {
+		http.Error(w, `{"detail":"bad request"}`, http.StatusBadRequest)
+		return
+	}
+	call := Call{Path: r.URL.Path, Model: req.Model, UserID: req.UserID, Text: req.Text, Header: r.Header.Clone()}
+	for _, m := range req.Messages {
+		text, images := flatten(m.Content)
+		call.Messages = append(call.Messages, guardrails.Message{Role: m.Role, Content: text})
+		call.Images += images
+	}
+
+	s.mu.Lock()
+	s.calls = append(s.calls, call)
+	s.seq++
+	id, latency := fmt.Sprintf("guardrails-test-%d", s.seq), s.latency
+	status := 0
+	if s.fail.left > 0 {
+		s.fail.left--
+		status = s.fail.status
+	}
+	var out any
+	resp := guardrails.Response{ID: id, SuggestAction: guardrails.ActionPass, OverallRiskLevel: verdict.NoRisk}
+	if strings.HasSuffix(r.URL.Path, "/anonymize") {
+		out = s.anonymize(req.Text)
+	} else if n := len(call.Messages); n > 0 && status == 0 {
+		for _, rl := range s.rules {
+			if rl.re.MatchString(call.Messages[n-1].Content) {
+				resp = rl.resp
+				if resp.ID == "" {
+					resp.ID = id
+				}
+				break
+			}
+		}
+	}
+	if out == nil {
+		out = resp
+	}
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if status != 0 {
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"detail":%q}`, http.StatusText(status))
+		return
+	}
+	json.NewEncoder(w).Encode(out)
+}
+
+// anonymize answers an anonymize request for text with s.masks applied.
+// It is called with s.mu held.
+func (s *Server) anonymize(text string) map[string]any {
+	mapping := map[string]string{}
+	for _, m := range s.masks {
+		n := 0
+		byValue := map[string]string{}
+		text = m.re.ReplaceAllStringFunc(text, func(v string) string {
+			if p, ok := byValue[v]; ok {
+				return p
+			}
+			n++
+			p := fmt.Sprintf("__%s_%d__", m.entity, n)
+			byValue[v], mapping[p] = p, v
+			return p
+		})
+	}
+	return map[string]any{"anonymized_text": text, "mapping": mapping}
+}
+
+// flatten returns the text of a message's content, which is either a string
+// or a list of OpenAI content parts, and how many images it carries.
+func flatten(raw json.RawMessage) (string, int) {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s, 0
+	}
+	var parts []guardrails.Part
+	json.Unmarshal(raw, &parts)
+	var texts []string
+	images := 0
+	for _, p := range parts {
+		switch p.Type {
+		case "text":
+			texts = append(texts, p.Text)
+		case "image_url":
+			images++
+		}
+	}
+	return strings.Join(texts, "\n"), images
+}
+
+var rank = map[verdict.RiskLevel]int{verdict.NoRisk: 0, verdict.LowRisk: 1, verdict.MediumRisk: 2, verdict.HighRisk: 3}
+
+func higher(a, b verdict.RiskLevel) verdict.RiskLevel {
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
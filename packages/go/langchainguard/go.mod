@@ -0,0 +1,14 @@
+module github.com/openguardrails/openguardrails-go/langchainguard
+
+go 1.22.0
+
+require github.com/openguardrails/openguardrails-go v0.0.0
+
+require (
+	github.com/dlclark/regexp2 v1.10.0 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/pkoukk/tiktoken-go v0.1.6 // indirect
+	github.com/tmc/langchaingo v0.1.12
+)
+
+replace github.com/openguardrails/openguardrails-go => ../
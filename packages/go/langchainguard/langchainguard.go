@@ -0,0 +1,220 @@
+// Package langchainguard adds OpenGuardrails checks to langchaingo.
+//
+// langchaingo callback handlers cannot stop a chain — their methods return
+// nothing — so enforcement wraps the model instead:
+//
+//	llm, _ := openai.New()
+//	guarded := langchainguard.Wrap(llm, client)
+//	chain := chains.NewLLMChain(guarded, prompt)
+//
+// The wrapper checks every prompt before the model runs and every answer
+// after. A rejected exchange fails with a *BlockedError, which aborts the
+// chain or agent, or — with WithSubstitute — completes with the platform's
+// suggested answer instead.
+//
+// Handler is the observe-only counterpart: a callbacks.Handler that checks
+// the same traffic and reports violations without changing it, for chains
+// whose model you cannot wrap or for a monitoring rollout.
+package langchainguard
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/openguardrails/openguardrails-go"
+	"github.com/tmc/langchaingo/callbacks"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// Phases a check runs in.
+const (
+	PhaseInput  = "input"
+	PhaseOutput = "output"
+)
+
+// BlockedError aborts a generation the platform rejected.
+type BlockedError struct {
+	Phase    string
+	Response *guardrails.Response
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("langchainguard: %s blocked (%s, %s)", e.Phase, e.Response.OverallRiskLevel, e.Response.SuggestAction)
+}
+
+// Option configures Wrap.
+type Option func(*Model)
+
+// WithSubstitute answers a rejected exchange with the platform's suggested
+// answer (or guardrails.DefaultRefusal) instead of failing it.
+func WithSubstitute() Option {
+	return func(m *Model) { m.substitute = true }
+}
+
+// WithFailOpen lets the generation proceed when a check fails. By default a
+// failed check fails the generation.
+func WithFailOpen() Option {
+	return func(m *Model) { m.failOpen = true }
+}
+
+// WithUserID attributes checks to the end user carried in the context.
+func WithUserID(f func(context.Context) string) Option {
+	return func(m *Model) { m.userID = f }
+}
+
+// Model is an llms.Model whose traffic is checked.
+type Model struct {
+	llms.Model
+	client     *guardrails.Client
+	substitute bool
+	failOpen   bool
+	userID     func(context.Context) string
+}
+
+var _ llms.Model = (*Model)(nil)
+
+// Wrap guards model with client.
+func Wrap(model llms.Model, client *guardrails.Client, opts ...Option) *Model {
+	m := &Model{Model: model, client: client}
+	for _, o := range opts {
+		o(m)
+	}
+	return m
+}
+
+// GenerateContent checks messages, runs the wrapped model, and checks its
+// first choice in the context of messages.
+func (m *Model) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	conv := Conversation(messages)
+	if resp, err := m.check(ctx, PhaseInput, conv); err != nil || resp != nil {
+		return resp, err
+	}
+	out, err := m.Model.GenerateContent(ctx, messages, options...)
+	if err != nil || len(out.Choices) == 0 || out.Choices[0].Content == "" {
+		return out, err
+	}
+	conv = append(conv, guardrails.Message{Role: "assistant", Content: out.Choices[0].Content})
+	if resp, err := m.check(ctx, PhaseOutput, conv); err != nil || resp != nil {
+		return resp, err
+	}
+	return out, nil
+}
+
+// Call is the single-prompt form of GenerateContent.
+func (m *Model) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
+
+// check returns (nil, nil) to continue, or the substitute response or the
+// error that ends the generation.
+func (m *Model) check(ctx context.Context, phase string, conv []guardrails.Message) (*llms.ContentResponse, error) {
+	if len(conv) == 0 {
+		return nil, nil
+	}
+	var opts []guardrails.CheckOption
+	if m.userID != nil {
+		if id := m.userID(ctx); id != "" {
+			opts = append(opts, guardrails.WithUserID(id))
+		}
+	}
+	r, err := m.client.CheckConversation(ctx, conv, opts...)
+	if err != nil {
+		if m.failOpen {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("langchainguard: %s check: %w", phase, err)
+	}
+	if r.IsSafe() {
+		return nil, nil
+	}
+	if !m.substitute {
+		return nil, &BlockedError{Phase: phase, Response: r}
+	}
+	answer := r.SuggestAnswer
+	if answer == "" {
+		answer = guardrails.DefaultRefusal
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{
+		Content:        answer,
+		StopReason:     "content_filter",
+		GenerationInfo: map[string]any{"guardrails_phase": phase, "guardrails_id": r.ID},
+	}}}, nil
+}
+
+// Conversation converts langchaingo messages to the detection API's shape,
+// keeping text parts only.
+func Conversation(messages []llms.MessageContent) []guardrails.Message {
+	var out []guardrails.Message
+	for _, mc := range messages {
+		var texts []string
+		for _, p := range mc.Parts {
+			if t, ok := p.(llms.TextContent); ok && t.Text != "" {
+				texts = append(texts, t.Text)
+			}
+		}
+		if len(texts) > 0 {
+			out = append(out, guardrails.Message{Role: role(mc.Role), Content: strings.Join(texts, "\n")})
+		}
+	}
+	return out
+}
+
+func role(t llms.ChatMessageType) string {
+	switch t {
+	case llms.ChatMessageTypeHuman, llms.ChatMessageTypeGeneric:
+		return "user"
+	case llms.ChatMessageTypeAI:
+		return "assistant"
+	}
+	return string(t) // system, tool, function
+}
+
+// Handler is an observe-only callbacks.Handler. It checks prompts when a
+// generation starts, answers when it ends, and tool outputs, and reports
+// each violation to OnViolation. Checks run synchronously in the callback.
+type Handler struct {
+	callbacks.SimpleHandler
+	Client *guardrails.Client
+	// OnViolation receives every response that is not a pass.
+	OnViolation func(ctx context.Context, phase string, r *guardrails.Response)
+	// OnError receives failed checks; nil ignores them.
+	OnError func(ctx context.Context, phase string, err error)
+}
+
+var _ callbacks.Handler = (*Handler)(nil)
+
+// HandleLLMGenerateContentStart checks the prompt messages.
+func (h *Handler) HandleLLMGenerateContentStart(ctx context.Context, ms []llms.MessageContent) {
+	h.observe(ctx, PhaseInput, Conversation(ms))
+}
+
+// HandleLLMGenerateContentEnd checks the first choice.
+func (h *Handler) HandleLLMGenerateContentEnd(ctx context.Context, res *llms.ContentResponse) {
+	if res != nil && len(res.Choices) > 0 && res.Choices[0].Content != "" {
+		h.observe(ctx, PhaseOutput, []guardrails.Message{{Role: "assistant", Content: res.Choices[0].Content}})
+	}
+}
+
+// HandleToolEnd checks tool output, which re-enters the model's context.
+func (h *Handler) HandleToolEnd(ctx context.Context, output string) {
+	if output != "" {
+		h.observe(ctx, PhaseInput, []guardrails.Message{{Role: "user", Content: output}})
+	}
+}
+
+func (h *Handler) observe(ctx context.Context, phase string, conv []guardrails.Message) {
+	if len(conv) == 0 {
+		return
+	}
+	r, err := h.Client.CheckConversation(ctx, conv)
+	if err != nil {
+		if h.OnError != nil {
+			h.OnError(ctx, phase, err)
+		}
+		return
+	}
+	if !r.IsSafe() && h.OnViolation != nil {
+		h.OnViolation(ctx, phase, r)
+	}
+}
@@ -0,0 +1,115 @@
+package langchainguard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openguardrails/openguardrails-go"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func platform(t *testing.T, bad string) *guardrails.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Messages []guardrails.Message `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if strings.Contains(req.Messages[len(req.Messages)-1].Content, bad) {
+			io.WriteString(w, `{"id":"g1","suggest_action":"reject","overall_risk_level":"high_risk","suggest_answer":"I can't help with that."}`)
+			return
+		}
+		io.WriteString(w, `{"suggest_action":"pass"}`)
+	}))
+	t.Cleanup(srv.Close)
+	return guardrails.NewClient(guardrails.WithBaseURL(srv.URL), guardrails.WithRetry(guardrails.NoRetry))
+}
+
+// echo is a fake model that answers with the last text it was given.
+type echo struct{ calls int }
+
+func (e *echo) GenerateContent(_ context.Context, ms []llms.MessageContent, _ ...llms.CallOption) (*llms.ContentResponse, error) {
+	e.calls++
+	last := ms[len(ms)-1].Parts[0].(llms.TextContent).Text
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: "echo: " + last}}}, nil
+}
+
+func (e *echo) Call(ctx context.Context, prompt string, opts ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, e, prompt, opts...)
+}
+
+func TestWrapPasses(t *testing.T) {
+	m := Wrap(&echo{}, platform(t, "attack"))
+	out, err := m.Call(context.Background(), "hello")
+	if err != nil || out != "echo: hello" {
+		t.Fatalf("%q %v", out, err)
+	}
+}
+
+func TestWrapBlocksInput(t *testing.T) {
+	llm := &echo{}
+	_, err := Wrap(llm, platform(t, "attack")).Call(context.Background(), "prompt attack")
+	var blocked *BlockedError
+	if !errors.As(err, &blocked) || blocked.Phase != PhaseInput || llm.calls != 0 {
+		t.Fatalf("err=%v calls=%d", err, llm.calls)
+	}
+}
+
+func TestWrapSubstitutesOutput(t *testing.T) {
+	m := Wrap(&echo{}, platform(t, "echo: secret"), WithSubstitute())
+	resp, err := m.GenerateContent(context.Background(), []llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeSystem, "be nice"),
+		llms.TextParts(llms.ChatMessageTypeHuman, "secret"),
+	})
+	if err != nil || resp.Choices[0].Content != "I can't help with that." || resp.Choices[0].StopReason != "content_filter" {
+		t.Fatalf("%+v %v", resp, err)
+	}
+}
+
+func TestWrapFailMode(t *testing.T) {
+	down := guardrails.NewClient(guardrails.WithBaseURL("http://127.0.0.1:1"), guardrails.WithRetry(guardrails.NoRetry))
+	if _, err := Wrap(&echo{}, down).Call(context.Background(), "hi"); err == nil {
+		t.Fatal("fail closed expected an error")
+	}
+	if out, err := Wrap(&echo{}, down, WithFailOpen()).Call(context.Background(), "hi"); err != nil || out != "echo: hi" {
+		t.Fatalf("fail open: %q %v", out, err)
+	}
+}
+
+func TestConversationRoles(t *testing.T) {
+	got := Conversation([]llms.MessageContent{
+		llms.TextParts(llms.ChatMessageTypeSystem, "sys"),
+		llms.TextParts(llms.ChatMessageTypeHuman, "a", "b"),
+		llms.TextParts(llms.ChatMessageTypeAI, "c"),
+		{Role: llms.ChatMessageTypeHuman, Parts: []llms.ContentPart{llms.ImageURLContent{URL: "x"}}},
+	})
+	want := []guardrails.Message{{Role: "system", Content: "sys"}, {Role: "user", Content: "a\nb"}, {Role: "assistant", Content: "c"}}
+	if len(got) != len(want) {
+		t.Fatalf("%+v", got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("%d: %+v", i, got[i])
+		}
+	}
+}
+
+func TestHandlerObserves(t *testing.T) {
+	var phases []string
+	h := &Handler{Client: platform(t, "bad"), OnViolation: func(_ context.Context, phase string, _ *guardrails.Response) {
+		phases = append(phases, phase)
+	}}
+	ctx := context.Background()
+	h.HandleLLMGenerateContentStart(ctx, []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "bad prompt")})
+	h.HandleLLMGenerateContentEnd(ctx, &llms.ContentResponse{Choices: []*llms.ContentChoice{{Content: "fine"}}})
+	h.HandleToolEnd(ctx, "bad tool output")
+	if strings.Join(phases, ",") != "input,input" {
+		t.Fatalf("phases %v", phases)
+	}
+}
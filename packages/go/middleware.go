@@ -0,0 +1,341 @@
+package guardrails
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultMaxBody is the largest request or response body the middleware
+// buffers for inspection.
+const DefaultMaxBody = 4 << 20
+
+// RequestExtractor returns the conversation to check from a decoded JSON
+// request body, or nil to let the request through unchecked.
+type RequestExtractor func(body map[string]any) []Message
+
+// ResponseExtractor returns the model output to check from a decoded JSON
+// response body, or "" to pass the response through unchecked.
+type ResponseExtractor func(body map[string]any) string
+
+// DenyFunc writes the response for a blocked exchange. resp is nil when the
+// check itself failed and the middleware fails closed.
+type DenyFunc func(w http.ResponseWriter, r *http.Request, resp *Response)
+
+// MiddlewareOption configures Middleware.
+type MiddlewareOption func(*middleware)
+
+// WithRequestExtractor replaces OpenAIRequest.
+func WithRequestExtractor(f RequestExtractor) MiddlewareOption {
+	return func(m *middleware) { m.reqExtract = f }
+}
+
+// WithResponseExtractor replaces OpenAIResponse. nil disables response
+// checks (input guarding only).
+func WithResponseExtractor(f ResponseExtractor) MiddlewareOption {
+	return func(m *middleware) { m.respExtract = f }
+}
+
+// WithDeny replaces OpenAIDeny.
+func WithDeny(f DenyFunc) MiddlewareOption {
+	return func(m *middleware) { m.deny = f }
+}
+
+// WithUserIDFunc attributes each check to the end user behind the request.
+func WithUserIDFunc(f func(*http.Request) string) MiddlewareOption {
+	return func(m *middleware) { m.userID = f }
+}
+
+// WithMiddlewareFailOpen lets traffic through when a check fails. By default
+// a failed check denies.
+func WithMiddlewareFailOpen() MiddlewareOption {
+	return func(m *middleware) { m.failOpen = true }
+}
+
+// WithMaxBody sets the buffering limit (default DefaultMaxBody). Larger
+// requests are refused with 413; larger responses are passed through
+// unchecked.
+func WithMaxBody(n int64) MiddlewareOption {
+	return func(m *middleware) { m.maxBody = n }
+}
+
+// WithLogger receives one line per failed check.
+func WithLogger(l *log.Logger) MiddlewareOption {
+	return func(m *middleware) { m.logger = l }
+}
+
+type middleware struct {
+	c           *Client
+	next        http.Handler
+	reqExtract  RequestExtractor
+	respExtract ResponseExtractor
+	deny        DenyFunc
+	userID      func(*http.Request) string
+	failOpen    bool
+	maxBody     int64
+	logger      *log.Logger
+}
+
+// Middleware guards next: the JSON request body is checked before next
+// runs, and next's buffered JSON response is checked before it reaches the
+// client. A reject or replace verdict on either side is answered by the
+// DenyFunc (by default an OpenAI chat completion carrying suggest_answer).
+//
+// Requests that are not JSON, or from which the extractor finds nothing to
+// check, pass through untouched. Streaming requests ("stream": true) are
+// checked on input only; guard their output with GuardStream.
+func (c *Client) Middleware(next http.Handler, opts ...MiddlewareOption) http.Handler {
+	m := &middleware{
+		c:           c,
+		next:        next,
+		reqExtract:  OpenAIRequest,
+		respExtract: OpenAIResponse,
+		deny:        OpenAIDeny,
+		maxBody:     DefaultMaxBody,
+	}
+	for _, o := range opts {
+		o(m)
+	}
+	return m
+}
+
+func (m *middleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Body == nil || r.Body == http.NoBody || !isJSON(r.Header.Get("Content-Type")) {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+	raw, err := io.ReadAll(io.LimitReader(r.Body, m.maxBody+1))
+	r.Body.Close()
+	if err != nil {
+		http.Error(w, "cannot read request body", http.StatusBadRequest)
+		return
+	}
+	if int64(len(raw)) > m.maxBody {
+		http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(raw))
+	r.ContentLength = int64(len(raw))
+
+	var body map[string]any
+	if json.Unmarshal(raw, &body) != nil {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+	messages := m.reqExtract(body)
+	if len(messages) == 0 {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+	if !m.check(w, r, messages) {
+		return
+	}
+	if stream, _ := body["stream"].(bool); stream || m.respExtract == nil {
+		m.next.ServeHTTP(w, r)
+		return
+	}
+
+	rec := &recorder{w: w, header: http.Header{}, status: http.StatusOK, limit: m.maxBody}
+	m.next.ServeHTTP(rec, r)
+	if rec.overflow {
+		return // already streamed to the client unchecked
+	}
+	if rec.status/100 == 2 && isJSON(rec.header.Get("Content-Type")) {
+		var out map[string]any
+		if json.Unmarshal(rec.body.Bytes(), &out) == nil {
+			if text := m.respExtract(out); text != "" {
+				if !m.check(w, r, append(messages, Message{Role: "assistant", Content: text})) {
+					return
+				}
+			}
+		}
+	}
+	rec.flushTo(w)
+}
+
+// check runs one check and, unless it passes, writes the denial. It reports
+// whether the exchange may continue.
+func (m *middleware) check(w http.ResponseWriter, r *http.Request, messages []Message) bool {
+	var opts []CheckOption
+	if m.userID != nil {
+		if id := m.userID(r); id != "" {
+			opts = append(opts, WithUserID(id))
+		}
+	}
+	resp, err := m.c.CheckConversation(r.Context(), messages, opts...)
+	if err != nil {
+		if m.logger != nil {
+			m.logger.Printf("guardrails: %s %s: %v", r.Method, r.URL.Path, err)
+		}
+		if m.failOpen {
+			return true
+		}
+		m.deny(w, r, nil)
+		return false
+	}
+	if resp.IsSafe() {
+		return true
+	}
+	m.deny(w, r, resp)
+	return false
+}
+
+// OpenAIRequest extracts the conversation from an OpenAI-style body:
+// "messages" (string content, or the text parts of multimodal content),
+// else a "prompt" or "input" string, or array of strings, which are checked
+// together as one user message.
+func OpenAIRequest(body map[string]any) []Message {
+	if msgs, ok := body["messages"].([]any); ok {
+		var out []Message
+		for _, raw := range msgs {
+			msg, _ := raw.(map[string]any)
+			role, _ := msg["role"].(string)
+			if text := contentText(msg["content"]); role != "" && text != "" {
+				out = append(out, Message{Role: role, Content: text})
+			}
+		}
+		return out
+	}
+	for _, key := range []string{"prompt", "input"} {
+		if text := promptText(body[key]); text != "" {
+			return []Message{{Role: "user", Content: text}}
+		}
+	}
+	return nil
+}
+
+// promptText flattens a string or array-of-strings prompt to text. Token
+// arrays have no text and yield "".
+func promptText(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []any:
+		var parts []string
+		for _, p := range v {
+			if s, ok := p.(string); ok && s != "" {
+				parts = append(parts, s)
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return ""
+}
+
+// OpenAIResponse extracts the first choice's message (or legacy text) from
+// an OpenAI-style completion.
+func OpenAIResponse(body map[string]any) string {
+	choices, _ := body["choices"].([]any)
+	if len(choices) == 0 {
+		return ""
+	}
+	choice, _ := choices[0].(map[string]any)
+	if msg, ok := choice["message"].(map[string]any); ok {
+		return contentText(msg["content"])
+	}
+	text, _ := choice["text"].(string)
+	return text
+}
+
+// OpenAIDeny answers with a chat completion whose content is the platform's
+// suggested answer (finish_reason "content_filter"), so OpenAI clients show
+// a refusal instead of an error. A failed check gets 503.
+func OpenAIDeny(w http.ResponseWriter, r *http.Request, resp *Response) {
+	if resp == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]any{"error": map[string]any{
+			"message": "content safety check unavailable", "type": "guardrails_unavailable",
+		}})
+		return
+	}
+	answer := resp.SuggestAnswer
+	if answer == "" {
+		answer = DefaultRefusal
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"id":      "chatcmpl-" + resp.ID,
+		"object":  "chat.completion",
+		"created": time.Now().Unix(),
+		"model":   "openguardrails",
+		"choices": []map[string]any{{
+			"index":         0,
+			"message":       map[string]string{"role": "assistant", "content": answer},
+			"finish_reason": "content_filter",
+		}},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// contentText flattens string or content-part message content to text.
+func contentText(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []any:
+		var parts []string
+		for _, p := range v {
+			part, _ := p.(map[string]any)
+			if t, ok := part["text"].(string); ok && t != "" {
+				parts = append(parts, t)
+			}
+		}
+		return strings.Join(parts, "\n")
+	}
+	return ""
+}
+
+func isJSON(contentType string) bool {
+	mt, _, _ := mime.ParseMediaType(contentType)
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
+
+// recorder buffers a handler's response so it can be checked before any of
+// it reaches the client. Past limit it gives up inspecting and streams the
+// rest straight to w.
+type recorder struct {
+	w        http.ResponseWriter
+	header   http.Header
+	status   int
+	body     bytes.Buffer
+	limit    int64
+	overflow bool
+	wrote    bool
+}
+
+func (r *recorder) Header() http.Header { return r.header }
+
+func (r *recorder) WriteHeader(status int) {
+	if !r.wrote {
+		r.status, r.wrote = status, true
+	}
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	r.wrote = true
+	if r.overflow {
+		return r.w.Write(p)
+	}
+	if int64(r.body.Len()+len(p)) > r.limit {
+		r.overflow = true
+		r.flushTo(r.w)
+		return r.w.Write(p)
+	}
+	return r.body.Write(p)
+}
+
+func (r *recorder) flushTo(w http.ResponseWriter) {
+	for k, v := range r.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(r.status)
+	w.Write(r.body.Bytes())
+}
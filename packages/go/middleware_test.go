@@ -0,0 +1,191 @@
+package guardrails
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// platform fakes the detection API: any conversation whose last message
+// contains a key of verdicts gets that action.
+func platform(t *testing.T, verdicts map[string]Action) (*Client, *[]checkRequest) {
+	t.Helper()
+	var seen []checkRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req checkRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		seen = append(seen, req)
+		last := req.Messages[len(req.Messages)-1].Content
+		for word, action := range verdicts {
+			if strings.Contains(last, word) {
+				json.NewEncoder(w).Encode(Response{ID: "g1", SuggestAction: action, SuggestAnswer: "safe answer"})
+				return
+			}
+		}
+		io.WriteString(w, `{"suggest_action":"pass"}`)
+	}))
+	t.Cleanup(srv.Close)
+	return NewClient(WithBaseURL(srv.URL), WithRetry(NoRetry)), &seen
+}
+
+// echoLLM answers every chat completion with the last user message,
+// upper-cased.
+var echoLLM = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Messages []Message `json:"messages"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	reply := strings.ToUpper(req.Messages[len(req.Messages)-1].Content)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"id": "chatcmpl-x", "choices": []any{map[string]any{"message": map[string]string{"role": "assistant", "content": reply}}},
+	})
+})
+
+func chat(h http.Handler, content string) *httptest.ResponseRecorder {
+	body := `{"model":"m","messages":[{"role":"system","content":"be nice"},{"role":"user","content":` + quote(content) + `}]}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func quote(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func replyOf(t *testing.T, rec *httptest.ResponseRecorder) (string, string) {
+	t.Helper()
+	var out struct {
+		Choices []struct {
+			Message      Message `json:"message"`
+			FinishReason string  `json:"finish_reason"`
+		} `json:"choices"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil || len(out.Choices) == 0 {
+		t.Fatalf("bad body %q", rec.Body.String())
+	}
+	return out.Choices[0].Message.Content, out.Choices[0].FinishReason
+}
+
+func TestMiddlewarePasses(t *testing.T) {
+	c, seen := platform(t, nil)
+	rec := chat(c.Middleware(echoLLM), "hello")
+	if got, _ := replyOf(t, rec); got != "HELLO" {
+		t.Fatalf("reply %q", got)
+	}
+	if len(*seen) != 2 || len((*seen)[0].Messages) != 2 || (*seen)[1].Messages[2].Role != "assistant" {
+		t.Fatalf("checks %+v", *seen)
+	}
+}
+
+func TestMiddlewareRejectsInput(t *testing.T) {
+	c, seen := platform(t, map[string]Action{"attack": ActionReject})
+	called := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true })
+	rec := chat(c.Middleware(next), "prompt attack")
+	if called {
+		t.Fatal("handler ran for a rejected request")
+	}
+	if got, reason := replyOf(t, rec); got != "safe answer" || reason != "content_filter" || rec.Code != 200 {
+		t.Fatalf("%d %q %q", rec.Code, got, reason)
+	}
+	if len(*seen) != 1 {
+		t.Fatal("output checked after input reject")
+	}
+}
+
+func TestMiddlewareReplacesOutput(t *testing.T) {
+	c, _ := platform(t, map[string]Action{"SECRET": ActionReplace})
+	rec := chat(c.Middleware(echoLLM), "tell me the secret")
+	if got, _ := replyOf(t, rec); got != "safe answer" {
+		t.Fatalf("reply %q", got)
+	}
+}
+
+func TestMiddlewareFailMode(t *testing.T) {
+	c := NewClient(WithBaseURL("http://127.0.0.1:1"), WithRetry(NoRetry))
+	if rec := chat(c.Middleware(echoLLM), "hi"); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("fail closed: %d", rec.Code)
+	}
+	rec := chat(c.Middleware(echoLLM, WithMiddlewareFailOpen()), "hi")
+	if got, _ := replyOf(t, rec); got != "HI" {
+		t.Fatalf("fail open: %q", got)
+	}
+}
+
+func TestMiddlewareSkipsNonJSONAndStreams(t *testing.T) {
+	c, seen := platform(t, nil)
+	h := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		w.Write(b)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader("raw bytes"))
+	req.Header.Set("Content-Type", "text/plain")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Body.String() != "raw bytes" || len(*seen) != 0 {
+		t.Fatalf("non-JSON: %q, %d checks", rec.Body.String(), len(*seen))
+	}
+
+	body := `{"stream":true,"messages":[{"role":"user","content":"hi"}]}`
+	req = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Body.String() != body || len(*seen) != 1 {
+		t.Fatalf("stream: body %q, %d checks", rec.Body.String(), len(*seen))
+	}
+}
+
+func TestMiddlewareBodyLimits(t *testing.T) {
+	c, seen := platform(t, nil)
+	big := strings.Repeat("x", 150)
+	if rec := chat(c.Middleware(echoLLM, WithMaxBody(120)), big); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("request limit: %d", rec.Code)
+	}
+	h := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"choices":[{"text":"` + big + `"}]}`))
+	}), WithMaxBody(120))
+	rec := chat(h, "hi")
+	if !strings.Contains(rec.Body.String(), big) || len(*seen) != 1 {
+		t.Fatalf("oversized response should stream unchecked: %d checks", len(*seen))
+	}
+}
+
+func TestOpenAIRequestMultimodal(t *testing.T) {
+	var body map[string]any
+	json.Unmarshal([]byte(`{"messages":[{"role":"user","content":[{"type":"text","text":"what is"},{"type":"image_url","image_url":{"url":"x"}},{"type":"text","text":"this?"}]}]}`), &body)
+	got := OpenAIRequest(body)
+	if len(got) != 1 || got[0].Content != "what is\nthis?" {
+		t.Fatalf("%+v", got)
+	}
+}
+
+func TestOpenAIRequestPromptArrays(t *testing.T) {
+	for body, want := range map[string]string{
+		`{"prompt":"say hi"}`:                  "say hi",
+		`{"prompt":["say hi","and bye"]}`:      "say hi\nand bye",
+		`{"input":["embed me"]}`:               "embed me",
+		`{"prompt":[[1,2,3]]}`:                 "",
+		`{"model":"m","anything":"unchecked"}`: "",
+	} {
+		var m map[string]any
+		json.Unmarshal([]byte(body), &m)
+		got := OpenAIRequest(m)
+		var text string
+		if len(got) == 1 {
+			text = got[0].Content
+		}
+		if len(got) > 1 || text != want {
+			t.Errorf("%s: %+v", body, got)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package guardrails
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// MaxImageBytes is the largest image CheckImage and ImageBytes accept
+// before encoding.
+const MaxImageBytes = 10 << 20
+
+// Part is one piece of a multimodal message, in the OpenAI content-part
+// shape the vision model reads.
+type Part struct {
+	Type     string    `json:"type"` // "text" | "image_url"
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+// ImageURL is an http(s) URL or a base64 data URL.
+type ImageURL struct {
+	URL string `json:"url"`
+}
+
+// MultimodalMessage is a conversation turn that may carry images.
+type MultimodalMessage struct {
+	Role    string `json:"role"`
+	Content []Part `json:"content"`
+}
+
+// TextPart returns a text part.
+func TextPart(text string) Part {
+	return Part{Type: "text", Text: text}
+}
+
+// ImagePart returns an image part for an http(s) or data URL.
+func ImagePart(url string) Part {
+	return Part{Type: "image_url", ImageURL: &ImageURL{URL: url}}
+}
+
+// ImageBytes returns an image part carrying data inline as a base64 data
+// URL. The media type is sniffed from the content; data that is not an
+// image, or is larger than MaxImageBytes, is an error.
+func ImageBytes(data []byte) (Part, error) {
+	if len(data) == 0 {
+		return Part{}, errors.New("guardrails: empty image")
+	}
+	if len(data) > MaxImageBytes {
+		return Part{}, fmt.Errorf("guardrails: image is %d bytes, limit is %d", len(data), MaxImageBytes)
+	}
+	mime := http.DetectContentType(data)
+	if !strings.HasPrefix(mime, "image/") {
+		return Part{}, fmt.Errorf("guardrails: content is %s, not an image", mime)
+	}
+	return ImagePart("data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(data)), nil
+}
+
+type multimodalRequest struct {
+	Model    string              `json:"model"`
+	Messages []MultimodalMessage `json:"messages"`
+	UserID   string              `json:"xxai_app_user_id,omitempty"`
+}
+
+// CheckImage checks an image on its own. url may be an http(s) URL or a
+// data URL; use CheckImageBytes for raw bytes.
+func (c *Client) CheckImage(ctx context.Context, url string, opts ...CheckOption) (*Response, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "data:image/") {
+		return nil, fmt.Errorf("guardrails: unsupported image URL %.40q", url)
+	}
+	return c.CheckMultimodal(ctx, []MultimodalMessage{{Role: "user", Content: []Part{ImagePart(url)}}}, opts...)
+}
+
+// CheckImageBytes checks an image given as raw bytes.
+func (c *Client) CheckImageBytes(ctx context.Context, data []byte, opts ...CheckOption) (*Response, error) {
+	p, err := ImageBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	return c.CheckMultimodal(ctx, []MultimodalMessage{{Role: "user", Content: []Part{p}}}, opts...)
+}
+
+// CheckMultimodal checks a conversation whose messages mix text and images
+// with the vision model. As with CheckConversation, the last message is the
+// one judged.
+func (c *Client) CheckMultimodal(ctx context.Context, messages []MultimodalMessage, opts ...CheckOption) (*Response, error) {
+	if len(messages) == 0 {
+		return nil, errors.New("guardrails: no messages to check")
+	}
+	for i, m := range messages {
+		if len(m.Content) == 0 {
+			return nil, fmt.Errorf("guardrails: message %d has no content", i)
+		}
+		for _, p := range m.Content {
+			if p.Type == "image_url" && (p.ImageURL == nil || p.ImageURL.URL == "") {
+				return nil, fmt.Errorf("guardrails: message %d has an image part without a URL", i)
+			}
+		}
+	}
+	var cr checkRequest
+	for _, o := range opts {
+		o(&cr)
+	}
+	ctx, done := c.observe(ctx, OpCheckMultimodal, c.vision)
+	var out Response
+	if err := c.post(ctx, "/guardrails", multimodalRequest{Model: c.vision, Messages: messages, UserID: cr.UserID}, &out); err != nil {
+		done(nil, false, err)
+		return nil, err
+	}
+	done(&out, false, nil)
+	return &out, nil
+}
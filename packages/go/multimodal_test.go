@@ -0,0 +1,66 @@
+package guardrails
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckMultimodal(t *testing.T) {
+	var got multimodalRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		io.WriteString(w, `{"suggest_action":"pass"}`)
+	}))
+	defer srv.Close()
+	c := NewClient(WithBaseURL(srv.URL))
+
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 2, 2)))
+	img, err := ImageBytes(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.CheckMultimodal(context.Background(), []MultimodalMessage{
+		{Role: "user", Content: []Part{TextPart("what is this?"), img}},
+	}, WithUserID("u-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Model != DefaultVisionModel || got.UserID != "u-1" || len(got.Messages[0].Content) != 2 {
+		t.Fatalf("request %+v", got)
+	}
+	if u := got.Messages[0].Content[1].ImageURL.URL; !strings.HasPrefix(u, "data:image/png;base64,") {
+		t.Fatalf("url %.40s", u)
+	}
+
+	if _, err := c.CheckImage(context.Background(), "https://example.com/cat.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if got.Messages[0].Content[0].ImageURL.URL != "https://example.com/cat.jpg" {
+		t.Fatalf("request %+v", got)
+	}
+}
+
+func TestImageValidation(t *testing.T) {
+	c := NewClient(WithBaseURL("http://127.0.0.1:1"))
+	if _, err := ImageBytes([]byte("plain text, not an image")); err == nil {
+		t.Fatal("text accepted as an image")
+	}
+	if _, err := ImageBytes(make([]byte, MaxImageBytes+1)); err == nil {
+		t.Fatal("oversized image accepted")
+	}
+	if _, err := c.CheckImage(context.Background(), "file:///etc/passwd"); err == nil {
+		t.Fatal("file URL accepted")
+	}
+	if _, err := c.CheckMultimodal(context.Background(), []MultimodalMessage{{Role: "user", Content: []Part{{Type: "image_url"}}}}); err == nil {
+		t.Fatal("image part without URL accepted")
+	}
+}
@@ -0,0 +1,48 @@
+package guardrails
+
+import (
+	"context"
+	"time"
+)
+
+// Operations reported to an Observer.
+const (
+	OpCheck           = "check"
+	OpCheckMultimodal = "check_multimodal"
+	OpAnonymize       = "anonymize"
+)
+
+// CheckOutcome describes one finished call.
+type CheckOutcome struct {
+	Op    string
+	Model string
+	// Response is nil for failed calls and for OpAnonymize.
+	Response *Response
+	Err      error
+	CacheHit bool
+	Duration time.Duration
+}
+
+// Observer is told about every platform call, for tracing and metrics; the
+// otelguard module implements it with OpenTelemetry. StartCheck runs before
+// the call and may return a derived context (carrying a span, say), which
+// the call then uses; the returned func runs once with the outcome.
+type Observer interface {
+	StartCheck(ctx context.Context, op, model string) (context.Context, func(CheckOutcome))
+}
+
+// WithObserver reports every call to o.
+func WithObserver(o Observer) Option {
+	return func(c *Client) { c.observer = o }
+}
+
+func (c *Client) observe(ctx context.Context, op, model string) (context.Context, func(*Response, bool, error)) {
+	if c.observer == nil {
+		return ctx, func(*Response, bool, error) {}
+	}
+	start := time.Now()
+	ctx, end := c.observer.StartCheck(ctx, op, model)
+	return ctx, func(r *Response, hit bool, err error) {
+		end(CheckOutcome{Op: op, Model: model, Response: r, Err: err, CacheHit: hit, Duration: time.Since(start)})
+	}
+}
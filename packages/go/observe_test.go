@@ -0,0 +1,47 @@
+package guardrails
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type ctxKey struct{}
+
+type observerRec struct{ outcomes []CheckOutcome }
+
+func (r *observerRec) StartCheck(ctx context.Context, op, model string) (context.Context, func(CheckOutcome)) {
+	return context.WithValue(ctx, ctxKey{}, op), func(o CheckOutcome) { r.outcomes = append(r.outcomes, o) }
+}
+
+func TestObserver(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, rejectBody)
+	}))
+	defer srv.Close()
+	rec := &observerRec{}
+	var seen any
+	c := NewClient(WithBaseURL(srv.URL), WithObserver(rec), WithCache(NewLRUCache(4), 0),
+		WithHTTPClient(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			seen = req.Context().Value(ctxKey{})
+			return http.DefaultTransport.RoundTrip(req)
+		})}))
+
+	c.CheckPrompt(context.Background(), "x")
+	c.CheckPrompt(context.Background(), "x")
+	if len(rec.outcomes) != 2 || rec.outcomes[0].CacheHit || !rec.outcomes[1].CacheHit {
+		t.Fatalf("%+v", rec.outcomes)
+	}
+	if o := rec.outcomes[0]; o.Op != OpCheck || o.Model != DefaultModel || o.Response.SuggestAction != ActionReject || o.Duration <= 0 {
+		t.Fatalf("%+v", o)
+	}
+	if seen != OpCheck {
+		t.Fatal("observer context not used for the request")
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
@@ -0,0 +1,143 @@
+// Package ogr is a minimal client for the OpenGuardrails runtime PDP
+// (POST /api/public/ogr/v1/evaluate) for Policy Enforcement Points such as
+// the mail milter and the chat relay. A PEP normalizes what it sees into an
+// OGR GuardEvent, asks the runtime for a verdict.Verdict and enforces it; it
+// carries no detection logic. Protocol: OGR 0.4 — GuardEvent in, Verdict
+// out. See
+// https://github.com/openguardrails/openguardrails/tree/main/schema
+package ogr
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go/verdict"
+)
+
+// Version is the OGR wire version stamped on every event.
+const Version = verdict.Version
+
+// EvaluatePath is appended to the runtime base URL.
+const EvaluatePath = "/api/public/ogr/v1/evaluate"
+
+// Subject identifies the actor an event is attributed to.
+type Subject struct {
+	AgentID   string `json:"agent_id,omitempty"`
+	AgentType string `json:"agent_type,omitempty"`
+	Principal string `json:"principal,omitempty"`
+}
+
+// Provenance records where a piece of content came from and how far it is
+// trusted.
+type Provenance struct {
+	Source string `json:"source"`
+	Trust  string `json:"trust"`
+}
+
+// GuardEvent is one unit observed at an interception point.
+type GuardEvent struct {
+	OGRVersion       string         `json:"ogr_version"`
+	EventID          string         `json:"event_id"`
+	GuardID          string         `json:"guard_id"`
+	SessionID        string         `json:"session_id,omitempty"`
+	Timestamp        string         `json:"timestamp"`
+	ObservationPoint string         `json:"observation_point"`
+	Kind             string         `json:"kind"`
+	Subject          Subject        `json:"subject"`
+	Payload          map[string]any `json:"payload"`
+	Provenance       []Provenance   `json:"provenance,omitempty"`
+}
+
+// procTag is folded into every generated id. A bare counter reuses ids after
+// a restart, and the runtime's analytics store treats a reused event id as a
+// newer version of the old row; the tag keeps ids from different processes
+// disjoint while leaving them readable and sortable.
+var (
+	procTag = newProcTag()
+	seq     atomic.Uint64
+)
+
+func newProcTag() string {
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return fmt.Sprintf("%08x", time.Now().UnixNano()&0xffffffff)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// NewID returns a process-unique id such as "evt-1a2b3c4d-000001".
+func NewID(prefix string) string {
+	return fmt.Sprintf("%s-%s-%06d", prefix, procTag, seq.Add(1))
+}
+
+// NewEvent builds a gateway GuardEvent with fresh ids and timestamp.
+func NewEvent(kind string, subject Subject, payload map[string]any) *GuardEvent {
+	return &GuardEvent{
+		OGRVersion:       Version,
+		EventID:          NewID("evt"),
+		GuardID:          NewID("gw"),
+		Timestamp:        time.Now().UTC().Format(time.RFC3339),
+		ObservationPoint: "gateway",
+		Kind:             kind,
+		Subject:          subject,
+		Payload:          payload,
+	}
+}
+
+// Client posts GuardEvents to a runtime and returns its verdicts.
+type Client struct {
+	endpoint string
+	apiKey   string
+	http     *http.Client
+}
+
+// NewClient returns a Client for the runtime at baseURL. timeout bounds each
+// evaluate call end to end.
+func NewClient(baseURL, apiKey string, timeout time.Duration) *Client {
+	return &Client{
+		endpoint: strings.TrimRight(baseURL, "/") + EvaluatePath,
+		apiKey:   apiKey,
+		http:     &http.Client{Timeout: timeout},
+	}
+}
+
+// Evaluate posts one GuardEvent and returns the Verdict. Transport errors and
+// non-2xx statuses are returned as errors; the caller maps them to its fail
+// mode.
+func (c *Client) Evaluate(ctx context.Context, ev *GuardEvent) (*verdict.Verdict, error) {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("ogr: evaluate: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var v verdict.Verdict
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		return nil, fmt.Errorf("ogr: decode verdict: %w", err)
+	}
+	return &v, nil
+}
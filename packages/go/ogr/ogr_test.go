@@ -0,0 +1,68 @@
+package ogr
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go/verdict"
+)
+
+func TestEvaluate(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != EvaluatePath {
+			t.Errorf("path = %s", r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer ogr_test" {
+			t.Errorf("authorization = %q", got)
+		}
+		var ev GuardEvent
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+			t.Fatal(err)
+		}
+		if ev.OGRVersion != Version || ev.Kind != "model_output" || ev.ObservationPoint != "gateway" {
+			t.Errorf("event = %+v", ev)
+		}
+		json.NewEncoder(w).Encode(verdict.Verdict{
+			EventID: ev.EventID, GuardID: ev.GuardID, Provider: "test", Decision: verdict.Redact,
+			Categories:    []verdict.Category{{ID: "safety.pii.email", Domain: "safety", Score: 0.9}},
+			Modifications: &verdict.Modifications{Kind: "redact", Spans: []verdict.Span{{Path: "payload.text", Start: 0, End: 2}}},
+		})
+	}))
+	defer srv.Close()
+
+	c := NewClient(srv.URL+"/", "ogr_test", time.Second)
+	ev := NewEvent("model_output", Subject{AgentID: "mail:bot@example.com"}, map[string]any{"text": "hi"})
+	v, err := c.Evaluate(context.Background(), ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Decision != verdict.Redact || v.EventID != ev.EventID || v.Categories[0].ID != "safety.pii.email" ||
+		v.Masked("payload.text", "hi there") != "[REDACTED] there" {
+		t.Fatalf("verdict = %+v", v)
+	}
+}
+
+func TestEvaluateNon2xx(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad key", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	_, err := NewClient(srv.URL, "", time.Second).Evaluate(context.Background(),
+		NewEvent("model_output", Subject{}, nil))
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("err = %v, want a 401 error", err)
+	}
+}
+
+func TestNewIDUnique(t *testing.T) {
+	a, b := NewID("evt"), NewID("evt")
+	if a == b || !strings.HasPrefix(a, "evt-"+procTag+"-") {
+		t.Fatalf("ids %q %q", a, b)
+	}
+}
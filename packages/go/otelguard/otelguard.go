@@ -0,0 +1,135 @@
+// Package otelguard reports SDK calls to OpenTelemetry:
+//
+//	client := guardrails.NewClient(guardrails.WithObserver(otelguard.New()))
+//
+// Every call becomes a client span ("guardrails.check",
+// "guardrails.check_multimodal", "guardrails.anonymize") under the caller's
+// span, and feeds two instruments:
+//
+//   - guardrails.client.duration (histogram, seconds)
+//   - guardrails.client.checks (counter)
+//
+// both attributed with the operation, model, suggested action, risk level,
+// cache hit and error type, so block rates and guardrails overhead fall out
+// of a dashboard query. For per-attempt HTTP spans, also pass an
+// otelhttp-instrumented client with guardrails.WithHTTPClient.
+package otelguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/openguardrails/openguardrails-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const scope = "github.com/openguardrails/openguardrails-go/otelguard"
+
+// Attribute keys.
+const (
+	AttrOperation = attribute.Key("guardrails.operation")
+	AttrModel     = attribute.Key("guardrails.model")
+	AttrAction    = attribute.Key("guardrails.action")
+	AttrRiskLevel = attribute.Key("guardrails.risk_level")
+	AttrCacheHit  = attribute.Key("guardrails.cache_hit")
+	AttrResultID  = attribute.Key("guardrails.result_id")
+	AttrErrorType = attribute.Key("error.type")
+)
+
+// Option configures New.
+type Option func(*config)
+
+type config struct {
+	tp trace.TracerProvider
+	mp metric.MeterProvider
+}
+
+// WithTracerProvider replaces the global tracer provider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) { c.tp = tp }
+}
+
+// WithMeterProvider replaces the global meter provider.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) { c.mp = mp }
+}
+
+// Observer is a guardrails.Observer that emits spans and metrics.
+type Observer struct {
+	tracer   trace.Tracer
+	duration metric.Float64Histogram
+	checks   metric.Int64Counter
+}
+
+var _ guardrails.Observer = (*Observer)(nil)
+
+// New returns an Observer using the global providers unless options say
+// otherwise.
+func New(opts ...Option) *Observer {
+	c := config{tp: otel.GetTracerProvider(), mp: otel.GetMeterProvider()}
+	for _, o := range opts {
+		o(&c)
+	}
+	meter := c.mp.Meter(scope, metric.WithInstrumentationVersion(guardrails.Version))
+	o := &Observer{tracer: c.tp.Tracer(scope, trace.WithInstrumentationVersion(guardrails.Version))}
+	var err error
+	if o.duration, err = meter.Float64Histogram("guardrails.client.duration",
+		metric.WithUnit("s"), metric.WithDescription("Duration of OpenGuardrails API calls.")); err != nil {
+		otel.Handle(err)
+	}
+	if o.checks, err = meter.Int64Counter("guardrails.client.checks",
+		metric.WithUnit("{call}"), metric.WithDescription("OpenGuardrails API calls by outcome.")); err != nil {
+		otel.Handle(err)
+	}
+	return o
+}
+
+// StartCheck opens the call's span.
+func (o *Observer) StartCheck(ctx context.Context, op, model string) (context.Context, func(guardrails.CheckOutcome)) {
+	attrs := []attribute.KeyValue{AttrOperation.String(op)}
+	if model != "" {
+		attrs = append(attrs, AttrModel.String(model))
+	}
+	ctx, span := o.tracer.Start(ctx, "guardrails."+op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+	return ctx, func(out guardrails.CheckOutcome) {
+		attrs = append(attrs, AttrCacheHit.Bool(out.CacheHit))
+		if r := out.Response; r != nil {
+			attrs = append(attrs, AttrAction.String(string(r.SuggestAction)), AttrRiskLevel.String(string(r.OverallRiskLevel)))
+			span.SetAttributes(AttrResultID.String(r.ID))
+		}
+		if out.Err != nil {
+			attrs = append(attrs, AttrErrorType.String(errorType(out.Err)))
+			span.RecordError(out.Err)
+			span.SetStatus(codes.Error, out.Err.Error())
+		}
+		span.SetAttributes(attrs...)
+		span.End()
+		set := metric.WithAttributes(attrs...)
+		if o.duration != nil {
+			o.duration.Record(ctx, out.Duration.Seconds(), set)
+		}
+		if o.checks != nil {
+			o.checks.Add(ctx, 1, set)
+		}
+	}
+}
+
+// errorType keeps error.type low-cardinality: the HTTP status for platform
+// errors, the Go type otherwise.
+func errorType(err error) string {
+	var apiErr *guardrails.APIError
+	switch {
+	case errors.As(err, &apiErr):
+		return fmt.Sprint(apiErr.StatusCode)
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	}
+	return fmt.Sprintf("%T", err)
+}
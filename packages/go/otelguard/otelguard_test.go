@@ -0,0 +1,69 @@
+package otelguard
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openguardrails/openguardrails-go"
+	"github.com/openguardrails/openguardrails-go/guardrailstest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSpansAndMetrics(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+
+	srv := guardrailstest.NewServer()
+	defer srv.Close()
+	srv.Reject(`attack`, guardrails.CategoryPromptAttack)
+	c := srv.Client(guardrails.WithObserver(New(WithTracerProvider(tp), WithMeterProvider(mp))))
+
+	ctx := context.Background()
+	c.CheckPrompt(ctx, "hello")
+	c.CheckPrompt(ctx, "an attack")
+	srv.FailNext(1, 503)
+	c.CheckPrompt(ctx, "hello")
+
+	ended := spans.Ended()
+	if len(ended) != 3 || ended[0].Name() != "guardrails.check" {
+		t.Fatalf("%d spans", len(ended))
+	}
+	if v := attr(ended[1].Attributes(), AttrAction); v != "reject" {
+		t.Fatalf("action %q", v)
+	}
+	if ended[2].Status().Code != codes.Error || attr(ended[2].Attributes(), AttrErrorType) != "503" {
+		t.Fatalf("status %+v", ended[2].Status())
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(ctx, &rm); err != nil {
+		t.Fatal(err)
+	}
+	var total int64
+	for _, m := range rm.ScopeMetrics[0].Metrics {
+		if sum, ok := m.Data.(metricdata.Sum[int64]); ok && m.Name == "guardrails.client.checks" {
+			for _, dp := range sum.DataPoints {
+				total += dp.Value
+			}
+		}
+	}
+	if total != 3 {
+		t.Fatalf("checks counter %d", total)
+	}
+}
+
+func attr(kvs []attribute.KeyValue, k attribute.Key) string {
+	for _, kv := range kvs {
+		if kv.Key == k {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
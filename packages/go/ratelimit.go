@@ -0,0 +1,141 @@
+package guardrails
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrRateLimited is returned, wrapped, when a rate-limited client rejects a
+// call rather than wait for capacity.
+var ErrRateLimited = errors.New("guardrails: rate limited")
+
+// RateLimit keeps a client within its plan. A local token bucket spaces
+// calls out, and the platform's X-RateLimit-Remaining/X-RateLimit-Reset
+// and 429 Retry-After answers pause every call until the window resets, so
+// one throttled call does not become a burst of them.
+type RateLimit struct {
+	// Rate is the sustained requests per second; 0 relies on the server's
+	// headers alone.
+	Rate float64
+	// Burst is how many calls may go at once after idling (default 1).
+	Burst int
+	// Reject fails calls that would have to wait, with ErrRateLimited,
+	// instead of queueing them.
+	Reject bool
+	// MaxWait bounds how long a queued call waits before failing with
+	// ErrRateLimited (0: until the call's deadline).
+	MaxWait time.Duration
+}
+
+// WithRateLimit applies rl to every attempt, retries included.
+func WithRateLimit(rl RateLimit) Option {
+	return func(c *Client) { c.limiter = newLimiter(rl) }
+}
+
+type limiter struct {
+	RateLimit
+	now func() time.Time
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+	// pausedUntil is set from the server's rate-limit answers.
+	pausedUntil time.Time
+}
+
+func newLimiter(rl RateLimit) *limiter {
+	if rl.Burst < 1 {
+		rl.Burst = 1
+	}
+	return &limiter{RateLimit: rl, now: time.Now, tokens: float64(rl.Burst)}
+}
+
+// wait reserves capacity for one attempt, sleeping until it is due.
+func (l *limiter) wait(ctx context.Context) error {
+	delay, cancel := l.reserve()
+	if delay <= 0 {
+		return nil
+	}
+	if l.Reject || (l.MaxWait > 0 && delay > l.MaxWait) {
+		cancel()
+		return fmt.Errorf("%w: capacity in %s", ErrRateLimited, delay.Round(time.Millisecond))
+	}
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		cancel()
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// reserve takes a token, going into debt if none is left, and returns how
+// long the caller must wait plus a func that gives the token back.
+func (l *limiter) reserve() (time.Duration, func()) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	var delay time.Duration
+	if l.Rate > 0 {
+		if !l.last.IsZero() {
+			l.tokens = min(float64(l.Burst), l.tokens+now.Sub(l.last).Seconds()*l.Rate)
+		}
+		l.last = now
+		l.tokens--
+		if l.tokens < 0 {
+			delay = time.Duration(-l.tokens / l.Rate * float64(time.Second))
+		}
+	}
+	if d := l.pausedUntil.Sub(now); d > delay {
+		delay = d
+	}
+	return delay, func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.Rate > 0 {
+			l.tokens++
+		}
+	}
+}
+
+// observe pauses the limiter when the server says the window is spent.
+func (l *limiter) observe(resp *http.Response) {
+	now := l.now()
+	var pause time.Duration
+	if resp.StatusCode == http.StatusTooManyRequests {
+		pause = parseRetryAfter(resp.Header.Get("Retry-After"), now)
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		if d := parseRateLimitReset(resp.Header.Get("X-RateLimit-Reset"), now); d > pause {
+			pause = d
+		}
+	}
+	if pause <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := now.Add(pause); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// parseRateLimitReset reads X-RateLimit-Reset, which servers send either as
+// seconds until the reset or as its Unix time.
+func parseRateLimitReset(v string, now time.Time) time.Duration {
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || n <= 0 {
+		return 0
+	}
+	if n > 1e9 {
+		return time.Unix(0, int64(n*float64(time.Second))).Sub(now)
+	}
+	return time.Duration(n * float64(time.Second))
+}
@@ -0,0 +1,98 @@
+package guardrails
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimiterBucket(t *testing.T) {
+	now := time.Unix(1000, 0)
+	l := newLimiter(RateLimit{Rate: 2, Burst: 2})
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if d, _ := l.reserve(); d != 0 {
+			t.Fatalf("burst call %d waits %s", i, d)
+		}
+	}
+	if d, _ := l.reserve(); d != 500*time.Millisecond {
+		t.Fatalf("third call waits %s", d)
+	}
+	now = now.Add(2 * time.Second)
+	if d, _ := l.reserve(); d != 0 {
+		t.Fatalf("after refill waits %s", d)
+	}
+}
+
+func TestLimiterServerHeaders(t *testing.T) {
+	now := time.Unix(2_000_000_000, 0)
+	l := newLimiter(RateLimit{})
+	l.now = func() time.Time { return now }
+	for _, tc := range []struct {
+		status int
+		header http.Header
+		want   time.Duration
+	}{
+		{200, http.Header{"X-Ratelimit-Remaining": {"5"}, "X-Ratelimit-Reset": {"30"}}, 0},
+		{200, http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"3"}}, 3 * time.Second},
+		{200, http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"2000000010"}}, 10 * time.Second},
+		{429, http.Header{"Retry-After": {"20"}}, 20 * time.Second},
+	} {
+		l.pausedUntil = time.Time{}
+		l.observe(&http.Response{StatusCode: tc.status, Header: tc.header})
+		if d, _ := l.reserve(); d != tc.want {
+			t.Errorf("%v: wait %s, want %s", tc.header, d, tc.want)
+		}
+	}
+}
+
+func TestRateLimitReject(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "60")
+		io.WriteString(w, `{"suggest_action":"pass"}`)
+	}))
+	defer srv.Close()
+	c := NewClient(WithBaseURL(srv.URL), WithRateLimit(RateLimit{Reject: true}))
+	if _, err := c.CheckPrompt(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CheckPrompt(context.Background(), "b"); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("%v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("%d calls reached the server", calls.Load())
+	}
+}
+
+func TestRateLimitQueue(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"suggest_action":"pass"}`)
+	}))
+	defer srv.Close()
+	c := NewClient(WithBaseURL(srv.URL), WithRateLimit(RateLimit{Rate: 20, MaxWait: time.Second}))
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := c.CheckPrompt(context.Background(), "x"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d < 90*time.Millisecond {
+		t.Fatalf("3 calls at 20/s took %s", d)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	c = NewClient(WithBaseURL(srv.URL), WithRetry(NoRetry), WithRateLimit(RateLimit{Rate: 0.1}))
+	c.CheckPrompt(context.Background(), "x")
+	if _, err := c.CheckPrompt(ctx, "x"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("%v", err)
+	}
+}
@@ -0,0 +1,49 @@
+// Package rediscache is a Redis-backed guardrails.Cache, so replicas of a
+// service share check results:
+//
+//	rdb := redis.NewClient(&redis.Options{Addr: "redis:6379"})
+//	client := guardrails.NewClient(guardrails.WithCache(rediscache.New(rdb), time.Minute))
+//
+// Entries expire through Redis TTLs; nothing needs cleaning up.
+package rediscache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/openguardrails/openguardrails-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache stores results in Redis under Prefix+key.
+type Cache struct {
+	rdb redis.UniversalClient
+	// Prefix namespaces keys when the Redis instance is shared.
+	Prefix string
+}
+
+var _ guardrails.Cache = (*Cache)(nil)
+
+// New returns a Cache over rdb, which may be a client, a cluster client or a
+// ring.
+func New(rdb redis.UniversalClient) *Cache {
+	return &Cache{rdb: rdb}
+}
+
+// Get returns the entry for key; a missing key is a miss, not an error.
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	v, err := c.rdb.Get(ctx, c.Prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return v, true, nil
+}
+
+// Set stores value for ttl.
+func (c *Cache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.rdb.Set(ctx, c.Prefix+key, value, ttl).Err()
+}
@@ -0,0 +1,51 @@
+package rediscache
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/openguardrails/openguardrails-go"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestSharedAcrossClients(t *testing.T) {
+	mr := miniredis.RunT(t)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		io.WriteString(w, `{"id":"g1","suggest_action":"pass","overall_risk_level":"no_risk"}`)
+	}))
+	defer srv.Close()
+
+	replica := func() *guardrails.Client {
+		cache := New(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+		cache.Prefix = "app:"
+		return guardrails.NewClient(guardrails.WithBaseURL(srv.URL), guardrails.WithCache(cache, time.Minute))
+	}
+	ctx := context.Background()
+	for _, c := range []*guardrails.Client{replica(), replica()} {
+		if r, err := c.CheckPrompt(ctx, "hello"); err != nil || !r.IsSafe() || r.ID != "g1" {
+			t.Fatalf("%+v %v", r, err)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("%d platform calls, want 1", calls.Load())
+	}
+	keys := mr.Keys()
+	if len(keys) != 1 || mr.TTL(keys[0]) != time.Minute || keys[0][:4] != "app:" {
+		t.Fatalf("keys %v ttl %v", keys, mr.TTL(keys[0]))
+	}
+}
+
+func TestMiss(t *testing.T) {
+	c := New(redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()}))
+	if _, ok, err := c.Get(context.Background(), "absent"); ok || err != nil {
+		t.Fatal(ok, err)
+	}
+}
@@ -0,0 +1,184 @@
+package guardrails
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/openguardrails/openguardrails-go/verdict"
+)
+
+// Action is the platform's suggested handling of checked content.
+type Action string
+
+// Suggested actions.
+const (
+	ActionPass    Action = "pass"
+	ActionReject  Action = "reject"
+	ActionReplace Action = "replace"
+)
+
+// Message is one turn of a conversation, in the OpenAI chat shape.
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// Dimension is the result for one detection dimension.
+type Dimension struct {
+	RiskLevel  verdict.RiskLevel `json:"risk_level"`
+	Categories []Category        `json:"categories"`
+	Score      float64           `json:"score,omitempty"`
+}
+
+// Entity is sensitive data found by the data-security dimension.
+type Entity struct {
+	Type  string `json:"entity_type"`
+	Text  string `json:"text"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// DataDimension is the data-security result, with the entities it found.
+type DataDimension struct {
+	Dimension
+	Entities []Entity `json:"entities,omitempty"`
+}
+
+// Result groups the per-dimension results.
+type Result struct {
+	Compliance Dimension     `json:"compliance"`
+	Security   Dimension     `json:"security"`
+	Data       DataDimension `json:"data"`
+}
+
+// Response is the detection API's answer (OpenGuardrailsResponse).
+type Response struct {
+	ID               string            `json:"id"`
+	Result           Result            `json:"result"`
+	OverallRiskLevel verdict.RiskLevel `json:"overall_risk_level"`
+	SuggestAction    Action            `json:"suggest_action"`
+	// SuggestAnswer is the safe replacement for ActionReject and
+	// ActionReplace; empty when the platform has none.
+	SuggestAnswer string  `json:"suggest_answer"`
+	Score         float64 `json:"score,omitempty"`
+}
+
+// IsSafe reports whether the platform suggests passing the content.
+func (r *Response) IsSafe() bool { return r.SuggestAction == ActionPass }
+
+// Categories returns every risk code across the dimensions, deduplicated,
+// in compliance, security, data order.
+func (r *Response) Categories() []Category {
+	var out []Category
+	seen := map[Category]bool{}
+	for _, d := range []Dimension{r.Result.Compliance, r.Result.Security, r.Result.Data.Dimension} {
+		for _, c := range d.Categories {
+			if !seen[c] {
+				seen[c] = true
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
+
+// HasCategory reports whether the response flags any of the codes.
+func (r *Response) HasCategory(codes ...Category) bool {
+	for _, c := range r.Categories() {
+		for _, want := range codes {
+			if strings.EqualFold(string(c), string(want)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasGroup reports whether any flagged category belongs to one of groups.
+func (r *Response) HasGroup(groups ...Group) bool {
+	for _, c := range r.Categories() {
+		for _, g := range groups {
+			if c.Group() == g {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DataOnly reports whether every category the response flags is a
+// data-security one, which masking the data addresses.
+func (r *Response) DataOnly() bool {
+	cats := r.Categories()
+	for _, c := range cats {
+		if c.Group() != GroupData {
+			return false
+		}
+	}
+	return len(cats) > 0
+}
+
+// Describe summarizes the response for logs: its action, risk level and
+// sorted categories, as in "reject, high_risk [S11,S9]". A nil response,
+// from a failed check, is "check unavailable".
+func (r *Response) Describe() string {
+	if r == nil {
+		return "check unavailable"
+	}
+	var cats []string
+	for _, c := range r.Categories() {
+		cats = append(cats, string(c))
+	}
+	sort.Strings(cats)
+	return fmt.Sprintf("%s, %s [%s]", r.SuggestAction, r.OverallRiskLevel, strings.Join(cats, ","))
+}
+
+// Provider identifies the detection API on verdicts built by Verdict.
+const Provider = "openguardrails.com/guardrails"
+
+// Verdict converts the response to an OGR Verdict, so it can be composed
+// with other detectors by verdict.Engine. reject becomes block; replace
+// becomes modify, with the suggested answer as the rewrite payload.
+// Categories carry their OGR taxonomy ids, scored by the response score (1
+// when the platform reports none).
+func (r *Response) Verdict(eventID, guardID string) *verdict.Verdict {
+	v := &verdict.Verdict{
+		OGRVersion: verdict.Version,
+		EventID:    eventID,
+		GuardID:    guardID,
+		Provider:   Provider,
+		Decision:   verdict.Allow,
+	}
+	switch r.SuggestAction {
+	case ActionPass:
+	case ActionReplace:
+		v.Decision = verdict.Modify
+		v.Modifications = &verdict.Modifications{Kind: "rewrite", Payload: map[string]any{"text": r.SuggestAnswer}}
+	default:
+		// reject, and any action this SDK does not know.
+		v.Decision = verdict.Block
+	}
+	score := r.Score
+	if score <= 0 {
+		score = 1
+	}
+	seen := map[string]bool{}
+	for _, c := range r.Categories() {
+		id := c.OGRID()
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		domain, _, _ := strings.Cut(id, ".")
+		if domain == "x" {
+			domain = "safety"
+		}
+		v.Categories = append(v.Categories, verdict.Category{ID: id, Domain: domain, Score: score})
+		v.Reasons = append(v.Reasons, string(c)+" "+c.Name())
+	}
+	if len(v.Reasons) == 0 {
+		v.Reasons = append(v.Reasons, "no finding")
+	}
+	return v
+}
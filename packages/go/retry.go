@@ -0,0 +1,126 @@
+package guardrails
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy decides when a failed call is tried again. Transport errors
+// and the listed statuses are retried; cancellation of the caller's context
+// never is.
+type RetryPolicy struct {
+	// MaxAttempts counts the first try; 1 disables retries.
+	MaxAttempts int
+	// InitialBackoff is the ceiling of the first wait; each later ceiling
+	// doubles up to MaxBackoff. Waits are drawn uniformly below the ceiling
+	// (full jitter), so a fleet of clients does not retry in lockstep.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// AttemptTimeout bounds a single attempt (0: only the call's deadline).
+	AttemptTimeout time.Duration
+	// RetryableStatus lists the HTTP statuses worth retrying.
+	RetryableStatus []int
+}
+
+// DefaultRetryPolicy retries twice on transport errors, 408, 429 and 5xx
+// gateway-class statuses.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:     3,
+	InitialBackoff:  200 * time.Millisecond,
+	MaxBackoff:      2 * time.Second,
+	RetryableStatus: []int{408, 429, 500, 502, 503, 504},
+}
+
+// NoRetry makes every call a single attempt.
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// do runs send until it succeeds, fails for good, or attempts run out, and
+// hands a 2xx response to decode.
+func (p RetryPolicy) do(ctx context.Context, send func(context.Context) (*http.Response, error), decode func(*http.Response) error) error {
+	attempts := max(p.MaxAttempts, 1)
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			wait := p.backoff(attempt, lastErr)
+			t := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return fmt.Errorf("guardrails: %w (last error: %v)", ctx.Err(), lastErr)
+			case <-t.C:
+			}
+		}
+		retry, err := p.attempt(ctx, send, decode)
+		if err == nil {
+			return nil
+		}
+		lastErr = err
+		if !retry || ctx.Err() != nil {
+			break
+		}
+	}
+	return lastErr
+}
+
+// attempt makes one try and reports whether its failure is retryable.
+func (p RetryPolicy) attempt(ctx context.Context, send func(context.Context) (*http.Response, error), decode func(*http.Response) error) (bool, error) {
+	if p.AttemptTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.AttemptTimeout)
+		defer cancel()
+	}
+	resp, err := send(ctx)
+	if errors.Is(err, ErrRateLimited) {
+		return false, err
+	}
+	if err != nil {
+		return true, fmt.Errorf("guardrails: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		apiErr := newAPIError(resp)
+		return slices.Contains(p.RetryableStatus, resp.StatusCode), apiErr
+	}
+	return false, decode(resp)
+}
+
+// backoff returns the wait before attempt n (n >= 1). A Retry-After from
+// the previous answer is honoured as a floor, capped at MaxBackoff.
+func (p RetryPolicy) backoff(n int, lastErr error) time.Duration {
+	ceiling := p.InitialBackoff << (n - 1)
+	if p.MaxBackoff > 0 && (ceiling > p.MaxBackoff || ceiling <= 0) {
+		ceiling = p.MaxBackoff
+	}
+	var wait time.Duration
+	if ceiling > 0 {
+		wait = rand.N(ceiling)
+	}
+	var apiErr *APIError
+	if errors.As(lastErr, &apiErr) && apiErr.RetryAfter > wait {
+		wait = apiErr.RetryAfter
+		if p.MaxBackoff > 0 && wait > p.MaxBackoff {
+			wait = p.MaxBackoff
+		}
+	}
+	return wait
+}
+
+// parseRetryAfter reads a Retry-After header in seconds or HTTP-date form.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
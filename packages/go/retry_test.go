@@ -0,0 +1,124 @@
+package guardrails
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var fastRetry = RetryPolicy{
+	MaxAttempts:     3,
+	InitialBackoff:  time.Millisecond,
+	MaxBackoff:      5 * time.Millisecond,
+	RetryableStatus: []int{429, 503},
+}
+
+func flaky(failures int, status int) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if int(calls.Add(1)) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		io.WriteString(w, `{"suggest_action":"pass"}`)
+	}))
+	return srv, &calls
+}
+
+func TestRetrySucceeds(t *testing.T) {
+	srv, calls := flaky(2, http.StatusServiceUnavailable)
+	defer srv.Close()
+	r, err := NewClient(WithBaseURL(srv.URL), WithRetry(fastRetry)).CheckPrompt(context.Background(), "x")
+	if err != nil || !r.IsSafe() || calls.Load() != 3 {
+		t.Fatalf("calls=%d err=%v", calls.Load(), err)
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	srv, calls := flaky(5, http.StatusServiceUnavailable)
+	defer srv.Close()
+	_, err := NewClient(WithBaseURL(srv.URL), WithRetry(fastRetry)).CheckPrompt(context.Background(), "x")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 503 || calls.Load() != 3 {
+		t.Fatalf("calls=%d err=%v", calls.Load(), err)
+	}
+}
+
+func TestNoRetryOnClientError(t *testing.T) {
+	srv, calls := flaky(5, http.StatusBadRequest)
+	defer srv.Close()
+	NewClient(WithBaseURL(srv.URL), WithRetry(fastRetry)).CheckPrompt(context.Background(), "x")
+	if calls.Load() != 1 {
+		t.Fatalf("a 400 was retried: %d calls", calls.Load())
+	}
+}
+
+func TestRetryAfterIsHonoured(t *testing.T) {
+	var calls atomic.Int32
+	var first time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			first = time.Now()
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if d := time.Since(first); d < 150*time.Millisecond {
+			t.Errorf("retried after %v", d)
+		}
+		io.WriteString(w, `{"suggest_action":"pass"}`)
+	}))
+	defer srv.Close()
+	p := fastRetry
+	p.MaxBackoff = 200 * time.Millisecond // caps the one-second hint
+	if _, err := NewClient(WithBaseURL(srv.URL), WithRetry(p)).CheckPrompt(context.Background(), "x"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCallTimeoutSpansRetries(t *testing.T) {
+	release := make(chan struct{})
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		select {
+		case <-release:
+		case <-time.After(time.Second):
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	p := fastRetry
+	p.AttemptTimeout = 30 * time.Millisecond
+	start := time.Now()
+	_, err := NewClient(WithBaseURL(srv.URL), WithRetry(p), WithTimeout(50*time.Millisecond)).
+		CheckPrompt(context.Background(), "x")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v", err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Fatalf("call took %v", d)
+	}
+	if calls.Load() < 2 {
+		t.Fatalf("per-attempt timeout should have allowed a retry: %d calls", calls.Load())
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if d := parseRetryAfter("7", now); d != 7*time.Second {
+		t.Fatal(d)
+	}
+	if d := parseRetryAfter(now.Add(3*time.Second).Format(http.TimeFormat), now); d != 3*time.Second {
+		t.Fatal(d)
+	}
+	if d := parseRetryAfter("soon", now); d != 0 {
+		t.Fatal(d)
+	}
+}
@@ -0,0 +1,371 @@
+package guardrails
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+)
+
+// DefaultRefusal is sent in place of a blocked stream when the platform
+// suggests no answer of its own.
+const DefaultRefusal = "Sorry, I can't continue with this response."
+
+// StreamOption configures GuardStream.
+type StreamOption func(*streamGuard)
+
+// WithCheckEvery sets how many new tokens accumulate between checks
+// (default 50). It bounds the extra latency the guard adds: events are held
+// back until the text they carry has been checked.
+func WithCheckEvery(tokens int) StreamOption {
+	return func(g *streamGuard) { g.every = tokens }
+}
+
+// WithWindow sets how many trailing tokens of the response each check sees
+// (default 400). The window overlaps the previous check, so content spanning
+// a check boundary is still judged whole.
+func WithWindow(tokens int) StreamOption {
+	return func(g *streamGuard) { g.window = tokens }
+}
+
+// WithStreamRefusal sets the replacement text used when the platform
+// suggests none.
+func WithStreamRefusal(text string) StreamOption {
+	return func(g *streamGuard) { g.refusal = text }
+}
+
+// WithStreamFailOpen keeps streaming when a check fails (transport or API
+// error). By default a failed check ends the stream like a reject.
+func WithStreamFailOpen() StreamOption {
+	return func(g *streamGuard) { g.failOpen = true }
+}
+
+// WithStreamPassthrough releases events as soon as they arrive and checks
+// the window in the background, one check at a time. It removes the guard's
+// latency at the cost of exposure: text generated while a check is in
+// flight reaches the reader before its verdict, and a reject ends the
+// stream after it. The tail of the response is still checked before [DONE]
+// is released.
+func WithStreamPassthrough() StreamOption {
+	return func(g *streamGuard) { g.passthrough = true }
+}
+
+// WithStreamDecision replaces Response.IsSafe as the test of whether a
+// checked window may be released, e.g. to apply a local category policy.
+func WithStreamDecision(allow func(*Response) bool) StreamOption {
+	return func(g *streamGuard) { g.decide = allow }
+}
+
+// WithStreamCheckOptions passes options (e.g. WithUserID) to every check.
+func WithStreamCheckOptions(opts ...CheckOption) StreamOption {
+	return func(g *streamGuard) { g.checkOpts = opts }
+}
+
+// GuardedStream is an OpenAI-compatible SSE stream that has passed through
+// GuardStream.
+type GuardedStream struct {
+	pr     *io.PipeReader
+	closer io.Closer
+	once   sync.Once
+
+	mu      sync.Mutex
+	blocked *Response
+	err     error
+}
+
+// Read returns the guarded SSE bytes.
+func (s *GuardedStream) Read(p []byte) (int, error) { return s.pr.Read(p) }
+
+// Close stops guarding and closes the upstream stream if it is an io.Closer.
+func (s *GuardedStream) Close() error {
+	var err error
+	s.once.Do(func() {
+		s.pr.Close()
+		if s.closer != nil {
+			err = s.closer.Close()
+		}
+	})
+	return err
+}
+
+// Blocked returns the check that ended the stream, or nil.
+func (s *GuardedStream) Blocked() *Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.blocked
+}
+
+// Err returns the check error that ended the stream (or that was ignored
+// under WithStreamFailOpen), if any.
+func (s *GuardedStream) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+type streamGuard struct {
+	c           *Client
+	ctx         context.Context
+	prompt      string
+	every       int
+	window      int
+	refusal     string
+	failOpen    bool
+	passthrough bool
+	checkOpts   []CheckOption
+	decide      func(*Response) bool
+	out         *GuardedStream
+}
+
+// GuardStream wraps the body of an OpenAI-compatible streaming completion
+// (server-sent "data: {chunk}" events ending in "data: [DONE]") and checks
+// the accumulating response in the context of prompt as it arrives.
+//
+// Events are released once the text they carry has passed a check. When a
+// check rejects, the held events are dropped and the stream ends with one
+// chunk carrying the suggested answer (finish_reason "content_filter")
+// followed by [DONE]. Tokens are estimated at four characters each.
+//
+// A channel of chunks can be guarded through an io.Pipe.
+func (c *Client) GuardStream(ctx context.Context, prompt string, upstream io.Reader, opts ...StreamOption) *GuardedStream {
+	pr, pw := io.Pipe()
+	out := &GuardedStream{pr: pr}
+	if cl, ok := upstream.(io.Closer); ok {
+		out.closer = cl
+	}
+	g := &streamGuard{c: c, ctx: ctx, prompt: prompt, every: 50, window: 400, refusal: DefaultRefusal, decide: (*Response).IsSafe, out: out}
+	for _, o := range opts {
+		o(g)
+	}
+	go func() { pw.CloseWithError(g.run(upstream, pw)) }()
+	return out
+}
+
+type sseChunk struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Created int64  `json:"created"`
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+}
+
+func (g *streamGuard) run(upstream io.Reader, w io.Writer) error {
+	if g.passthrough {
+		return g.runPassthrough(upstream, w)
+	}
+	r := bufio.NewReader(upstream)
+	var (
+		text      []rune // the whole response so far
+		checked   int    // runes of text already checked
+		held      bytes.Buffer
+		id, model string
+		created   int64
+	)
+	// release checks the unchecked text and flushes the held events, or
+	// ends the stream with a refusal.
+	release := func() (bool, error) {
+		if checked < len(text) {
+			start := len(text) - g.window*4
+			if start < 0 {
+				start = 0
+			}
+			if !g.allow(string(text[start:])) {
+				held.Reset()
+				_, err := io.WriteString(w, g.refusalEvents(id, model, created))
+				return false, err
+			}
+			checked = len(text)
+		}
+		_, err := held.WriteTo(w)
+		return true, err
+	}
+
+	for {
+		event, err := readEvent(r)
+		if len(event) > 0 {
+			data := eventData(event)
+			if data == "[DONE]" {
+				if ok, werr := release(); !ok || werr != nil {
+					return werr
+				}
+				_, werr := w.Write(event)
+				return werr
+			}
+			held.Write(event)
+			var chunk sseChunk
+			if json.Unmarshal([]byte(data), &chunk) == nil {
+				if chunk.ID != "" {
+					id, model, created = chunk.ID, chunk.Model, chunk.Created
+				}
+				for _, ch := range chunk.Choices {
+					text = append(text, []rune(ch.Delta.Content)...)
+				}
+			}
+			if (len(text)-checked)/4 >= g.every {
+				if ok, werr := release(); !ok || werr != nil {
+					return werr
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			// Upstream ended without [DONE]; check what arrived.
+			_, werr := release()
+			return werr
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// runPassthrough is run under WithStreamPassthrough.
+func (g *streamGuard) runPassthrough(upstream io.Reader, w io.Writer) error {
+	r := bufio.NewReader(upstream)
+	var (
+		text      []rune
+		checked   int
+		id, model string
+		created   int64
+		inflight  chan bool // result of the running check, nil when idle
+	)
+	window := func() string {
+		start := max(len(text)-g.window*4, 0)
+		return string(text[start:])
+	}
+	start := func() {
+		ch := make(chan bool, 1)
+		win := window()
+		checked = len(text)
+		inflight = ch
+		go func() { ch <- g.allow(win) }()
+	}
+	refuse := func() error {
+		_, err := io.WriteString(w, g.refusalEvents(id, model, created))
+		return err
+	}
+	// finish waits for the running check and checks any text it did not
+	// cover; it reports whether the stream may end normally.
+	finish := func() bool {
+		if inflight != nil && !<-inflight {
+			return false
+		}
+		inflight = nil
+		return checked == len(text) || g.allow(window())
+	}
+
+	for {
+		if inflight != nil {
+			select {
+			case ok := <-inflight:
+				inflight = nil
+				if !ok {
+					return refuse()
+				}
+			default:
+			}
+		}
+		event, err := readEvent(r)
+		if len(event) > 0 {
+			data := eventData(event)
+			if data == "[DONE]" {
+				if !finish() {
+					return refuse()
+				}
+				_, werr := w.Write(event)
+				return werr
+			}
+			var chunk sseChunk
+			if json.Unmarshal([]byte(data), &chunk) == nil {
+				if chunk.ID != "" {
+					id, model, created = chunk.ID, chunk.Model, chunk.Created
+				}
+				for _, ch := range chunk.Choices {
+					text = append(text, []rune(ch.Delta.Content)...)
+				}
+			}
+			if _, werr := w.Write(event); werr != nil {
+				return werr
+			}
+			if inflight == nil && (len(text)-checked)/4 >= g.every {
+				start()
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			if !finish() {
+				return refuse()
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// allow checks window and records the outcome on the stream.
+func (g *streamGuard) allow(window string) bool {
+	r, err := g.c.CheckResponseCtx(g.ctx, g.prompt, window, g.checkOpts...)
+	g.out.mu.Lock()
+	defer g.out.mu.Unlock()
+	if err != nil {
+		g.out.err = err
+		return g.failOpen
+	}
+	if g.decide(r) {
+		return true
+	}
+	g.out.blocked = r
+	if r.SuggestAnswer != "" {
+		g.refusal = r.SuggestAnswer
+	}
+	return false
+}
+
+func (g *streamGuard) refusalEvents(id, model string, created int64) string {
+	chunk, _ := json.Marshal(map[string]any{
+		"id":      id,
+		"object":  "chat.completion.chunk",
+		"created": created,
+		"model":   model,
+		"choices": []map[string]any{{
+			"index":         0,
+			"delta":         map[string]string{"content": g.refusal},
+			"finish_reason": "content_filter",
+		}},
+	})
+	return "data: " + string(chunk) + "\n\ndata: [DONE]\n\n"
+}
+
+// readEvent returns the next SSE event including its terminating blank line.
+func readEvent(r *bufio.Reader) ([]byte, error) {
+	var ev []byte
+	for {
+		line, err := r.ReadBytes('\n')
+		ev = append(ev, line...)
+		if err != nil {
+			return ev, err
+		}
+		if len(bytes.TrimRight(line, "\r\n")) == 0 && len(bytes.TrimSpace(ev)) > 0 {
+			return ev, nil
+		}
+	}
+}
+
+// eventData joins the event's data lines.
+func eventData(ev []byte) string {
+	var parts []string
+	for _, line := range strings.Split(string(ev), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if v, ok := strings.CutPrefix(line, "data:"); ok {
+			parts = append(parts, strings.TrimPrefix(v, " "))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
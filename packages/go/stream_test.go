@@ -0,0 +1,151 @@
+package guardrails
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func sse(words ...string) string {
+	var b strings.Builder
+	for _, w := range words {
+		chunk, _ := json.Marshal(map[string]any{
+			"id": "chatcmpl-1", "model": "m", "object": "chat.completion.chunk",
+			"choices": []map[string]any{{"index": 0, "delta": map[string]string{"content": w}}},
+		})
+		fmt.Fprintf(&b, "data: %s\n\n", chunk)
+	}
+	b.WriteString("data: [DONE]\n\n")
+	return b.String()
+}
+
+// checker rejects any window containing bad and records what it saw.
+func checker(t *testing.T, bad string) (*Client, *[]string) {
+	t.Helper()
+	var mu sync.Mutex
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req checkRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		text := req.Messages[len(req.Messages)-1].Content
+		mu.Lock()
+		seen = append(seen, text)
+		mu.Unlock()
+		if bad != "" && strings.Contains(text, bad) {
+			io.WriteString(w, `{"suggest_action":"reject","overall_risk_level":"high_risk","suggest_answer":"[filtered]"}`)
+			return
+		}
+		io.WriteString(w, `{"suggest_action":"pass","overall_risk_level":"no_risk"}`)
+	}))
+	t.Cleanup(srv.Close)
+	return NewClient(WithBaseURL(srv.URL)), &seen
+}
+
+func TestGuardStreamPassesCleanStream(t *testing.T) {
+	c, seen := checker(t, "")
+	in := sse("Hello", " there,", " friend.")
+	s := c.GuardStream(context.Background(), "hi", strings.NewReader(in), WithCheckEvery(2))
+	out, err := io.ReadAll(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != in {
+		t.Fatalf("stream altered:\n%s", out)
+	}
+	if s.Blocked() != nil || len(*seen) == 0 || (*seen)[len(*seen)-1] != "Hello there, friend." {
+		t.Fatalf("checks %q", *seen)
+	}
+}
+
+func TestGuardStreamStopsOnReject(t *testing.T) {
+	c, _ := checker(t, "secret")
+	in := sse("The", " answer", " is", " the", " secret", " plan", " details")
+	s := c.GuardStream(context.Background(), "hi", strings.NewReader(in), WithCheckEvery(3))
+	out, _ := io.ReadAll(s)
+	got := string(out)
+	if strings.Contains(got, "secret") || strings.Contains(got, "plan") {
+		t.Fatalf("flagged content leaked:\n%s", got)
+	}
+	if !strings.Contains(got, `"content":"[filtered]"`) || !strings.Contains(got, `"finish_reason":"content_filter"`) ||
+		!strings.HasSuffix(got, "data: [DONE]\n\n") || strings.Count(got, "[DONE]") != 1 {
+		t.Fatalf("no replacement chunk:\n%s", got)
+	}
+	if !strings.Contains(got, `"content":"The"`) {
+		t.Fatalf("checked prefix should have been released:\n%s", got)
+	}
+	if s.Blocked() == nil || s.Blocked().SuggestAction != ActionReject {
+		t.Fatal("Blocked() not recorded")
+	}
+}
+
+func TestGuardStreamWindow(t *testing.T) {
+	c, seen := checker(t, "")
+	words := make([]string, 20)
+	for i := range words {
+		words[i] = "abcd"
+	}
+	s := c.GuardStream(context.Background(), "hi", strings.NewReader(sse(words...)), WithCheckEvery(1), WithWindow(3))
+	io.ReadAll(s)
+	for _, w := range *seen {
+		if len(w) > 12 {
+			t.Fatalf("window of %d runes exceeds 3 tokens", len(w))
+		}
+	}
+}
+
+func TestGuardStreamFailMode(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+	c := NewClient(WithBaseURL(srv.URL))
+
+	s := c.GuardStream(context.Background(), "hi", strings.NewReader(sse("one", " two")))
+	out, _ := io.ReadAll(s)
+	if !strings.Contains(string(out), DefaultRefusal) || s.Err() == nil {
+		t.Fatalf("fail closed: %s", out)
+	}
+
+	in := sse("one", " two")
+	s = c.GuardStream(context.Background(), "hi", strings.NewReader(in), WithStreamFailOpen())
+	out, _ = io.ReadAll(s)
+	if string(out) != in || s.Err() == nil {
+		t.Fatalf("fail open: %s", out)
+	}
+}
+
+func TestGuardStreamPassthrough(t *testing.T) {
+	c, _ := checker(t, "")
+	in := sse("Hello", " there,", " friend.")
+	out, _ := io.ReadAll(c.GuardStream(context.Background(), "hi", strings.NewReader(in), WithCheckEvery(1), WithStreamPassthrough()))
+	if string(out) != in {
+		t.Fatalf("stream altered:\n%s", out)
+	}
+
+	// The flagged tail is only seen by the final check, which must run
+	// before [DONE] is released.
+	c, _ = checker(t, "secret")
+	in = sse("The", " answer", " is", " secret")
+	s := c.GuardStream(context.Background(), "hi", strings.NewReader(in), WithCheckEvery(100), WithStreamPassthrough())
+	out, _ = io.ReadAll(s)
+	got := string(out)
+	if !strings.Contains(got, `"finish_reason":"content_filter"`) || strings.Count(got, "[DONE]") != 1 || s.Blocked() == nil {
+		t.Fatalf("not cut off:\n%s", got)
+	}
+}
+
+func TestGuardStreamDecision(t *testing.T) {
+	c, _ := checker(t, "secret")
+	in := sse("The", " secret", " is", " out")
+	allowAll := func(*Response) bool { return true }
+	out, _ := io.ReadAll(c.GuardStream(context.Background(), "hi", strings.NewReader(in), WithCheckEvery(1), WithStreamDecision(allowAll)))
+	if string(out) != in {
+		t.Fatalf("decision ignored:\n%s", out)
+	}
+}
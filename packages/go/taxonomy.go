@@ -0,0 +1,136 @@
+package guardrails
+
+import (
+	"strings"
+
+	"github.com/openguardrails/openguardrails-go/verdict"
+)
+
+// Category is a risk code reported by the detection API: "S1"–"S21" for
+// compliance and security, or an entity type for the data dimension.
+type Category string
+
+// Risk categories.
+const (
+	CategoryGeneralPolitical   Category = "S1"
+	CategorySensitivePolitical Category = "S2"
+	CategoryNationalSymbols    Category = "S3"
+	CategoryHarmToMinors       Category = "S4"
+	CategoryViolentCrime       Category = "S5"
+	CategoryNonViolentCrime    Category = "S6"
+	CategoryPornography        Category = "S7"
+	CategoryHate               Category = "S8"
+	CategoryPromptAttack       Category = "S9"
+	CategoryProfanity          Category = "S10"
+	CategoryPrivacy            Category = "S11"
+	CategoryCommercial         Category = "S12"
+	CategoryIntellectualProp   Category = "S13"
+	CategoryHarassment         Category = "S14"
+	CategoryWMD                Category = "S15"
+	CategorySelfHarm           Category = "S16"
+	CategorySexualCrime        Category = "S17"
+	CategoryThreats            Category = "S18"
+	CategoryFinancialAdvice    Category = "S19"
+	CategoryMedicalAdvice      Category = "S20"
+	CategoryLegalAdvice        Category = "S21"
+)
+
+// Group is the detection dimension a category belongs to.
+type Group string
+
+// Groups, matching the keys of Response.Result.
+const (
+	GroupCompliance Group = "compliance"
+	GroupSecurity   Group = "security"
+	GroupData       Group = "data"
+)
+
+// CategoryInfo describes one risk category.
+type CategoryInfo struct {
+	Code     Category
+	Name     string
+	Group    Group
+	Severity verdict.RiskLevel
+	// OGRID is the OGR taxonomy id the category maps to (see
+	// specification/taxonomy.md), e.g. "security.prompt_injection".
+	OGRID string
+}
+
+var taxonomy = []CategoryInfo{
+	{CategoryGeneralPolitical, "General Political Topics", GroupCompliance, verdict.MediumRisk, "x.ogr.politics.general"},
+	{CategorySensitivePolitical, "Sensitive Political Topics", GroupCompliance, verdict.HighRisk, "x.ogr.politics.sensitive"},
+	{CategoryNationalSymbols, "Insult to National Symbols or Leaders", GroupCompliance, verdict.HighRisk, "x.ogr.national_symbols"},
+	{CategoryHarmToMinors, "Harm to Minors", GroupCompliance, verdict.MediumRisk, "safety.sexual.minors"},
+	{CategoryViolentCrime, "Violent Crime", GroupCompliance, verdict.HighRisk, "safety.violence"},
+	{CategoryNonViolentCrime, "Non-Violent Crime", GroupCompliance, verdict.MediumRisk, "safety.illicit"},
+	{CategoryPornography, "Pornography", GroupCompliance, verdict.MediumRisk, "safety.sexual"},
+	{CategoryHate, "Hate & Discrimination", GroupCompliance, verdict.LowRisk, "safety.toxicity.hate"},
+	{CategoryPromptAttack, "Prompt Attacks", GroupSecurity, verdict.HighRisk, "security.prompt_injection"},
+	{CategoryProfanity, "Profanity", GroupCompliance, verdict.LowRisk, "safety.toxicity.profanity"},
+	{CategoryPrivacy, "Privacy Invasion", GroupCompliance, verdict.LowRisk, "safety.pii"},
+	{CategoryCommercial, "Commercial Violations", GroupCompliance, verdict.LowRisk, "safety.illicit.commercial"},
+	{CategoryIntellectualProp, "Intellectual Property Infringement", GroupCompliance, verdict.LowRisk, "safety.illicit.ip"},
+	{CategoryHarassment, "Harassment", GroupCompliance, verdict.LowRisk, "safety.toxicity.harassment"},
+	{CategoryWMD, "Weapons of Mass Destruction", GroupCompliance, verdict.HighRisk, "safety.weapons"},
+	{CategorySelfHarm, "Self-Harm", GroupCompliance, verdict.MediumRisk, "safety.self_harm"},
+	{CategorySexualCrime, "Sexual Crimes", GroupCompliance, verdict.HighRisk, "safety.illicit.sexual_crime"},
+	{CategoryThreats, "Threats", GroupCompliance, verdict.LowRisk, "safety.violence.threat"},
+	{CategoryFinancialAdvice, "Professional Financial Advice", GroupCompliance, verdict.LowRisk, "safety.unsafe_advice.financial"},
+	{CategoryMedicalAdvice, "Professional Medical Advice", GroupCompliance, verdict.LowRisk, "safety.unsafe_advice.medical"},
+	{CategoryLegalAdvice, "Professional Legal Advice", GroupCompliance, verdict.LowRisk, "safety.unsafe_advice.legal"},
+}
+
+var taxonomyByCode = func() map[Category]CategoryInfo {
+	m := make(map[Category]CategoryInfo, len(taxonomy))
+	for _, c := range taxonomy {
+		m[c.Code] = c
+	}
+	return m
+}()
+
+// Categories returns the S1–S21 taxonomy in code order.
+func Categories() []CategoryInfo {
+	return append([]CategoryInfo(nil), taxonomy...)
+}
+
+// LookupCategory returns the description of an S-code. Data-dimension
+// entity types are not in the table.
+func LookupCategory(c Category) (CategoryInfo, bool) {
+	info, ok := taxonomyByCode[Category(strings.ToUpper(string(c)))]
+	return info, ok
+}
+
+// Name returns the human-readable name, or the code itself when unknown.
+func (c Category) Name() string {
+	if info, ok := LookupCategory(c); ok {
+		return info.Name
+	}
+	return string(c)
+}
+
+// Severity returns the category's tier. Unknown codes are HighRisk, so an
+// unrecognised category is never under-weighted.
+func (c Category) Severity() verdict.RiskLevel {
+	if info, ok := LookupCategory(c); ok {
+		return info.Severity
+	}
+	return verdict.HighRisk
+}
+
+// Group returns the dimension the category belongs to. Codes outside the
+// table are data-dimension entity types.
+func (c Category) Group() Group {
+	if info, ok := LookupCategory(c); ok {
+		return info.Group
+	}
+	return GroupData
+}
+
+// OGRID returns the OGR taxonomy id for the category. Data-dimension entity
+// types map to "safety.pii".
+func (c Category) OGRID() string {
+	if info, ok := LookupCategory(c); ok {
+		return info.OGRID
+	}
+	return "safety.pii"
+}
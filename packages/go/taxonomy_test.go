@@ -0,0 +1,91 @@
+package guardrails
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/openguardrails/openguardrails-go/verdict"
+)
+
+func TestTaxonomyTable(t *testing.T) {
+	cats := Categories()
+	if len(cats) != 21 {
+		t.Fatalf("%d categories, want 21", len(cats))
+	}
+	for i, c := range cats {
+		if want := Category(fmt.Sprintf("S%d", i+1)); c.Code != want {
+			t.Errorf("entry %d is %s, want %s", i, c.Code, want)
+		}
+		if c.Name == "" || c.OGRID == "" || c.Severity == "" {
+			t.Errorf("%s: incomplete entry %+v", c.Code, c)
+		}
+	}
+}
+
+func TestCategoryHelpers(t *testing.T) {
+	if CategoryPromptAttack.Name() != "Prompt Attacks" || CategoryPromptAttack.Group() != GroupSecurity ||
+		CategoryPromptAttack.Severity() != verdict.HighRisk {
+		t.Fatal("S9 metadata")
+	}
+	if Category("s10").Name() != "Profanity" {
+		t.Fatal("lookup must be case-insensitive")
+	}
+	unknown := Category("EMAIL_ADDRESS")
+	if unknown.Name() != "EMAIL_ADDRESS" || unknown.Group() != GroupData || unknown.Severity() != verdict.HighRisk || unknown.OGRID() != "safety.pii" {
+		t.Fatal("unknown codes")
+	}
+}
+
+func TestResponseCategoryHelpers(t *testing.T) {
+	var r Response
+	if err := json.Unmarshal([]byte(rejectBody), &r); err != nil {
+		t.Fatal(err)
+	}
+	if !r.HasCategory(CategoryPrivacy) || r.HasCategory(CategoryHate) {
+		t.Fatal("HasCategory")
+	}
+	if !r.HasGroup(GroupSecurity) || r.HasGroup(GroupData) {
+		t.Fatal("HasGroup")
+	}
+	if r.DataOnly() || (&Response{}).DataOnly() {
+		t.Fatal("DataOnly")
+	}
+	r.Result = Result{Data: DataDimension{Dimension: Dimension{Categories: []Category{"EMAIL_ADDRESS"}}}}
+	if !r.DataOnly() {
+		t.Fatal("DataOnly: data findings alone")
+	}
+	if got := r.Describe(); got != "reject, high_risk [EMAIL_ADDRESS]" {
+		t.Fatalf("Describe: %q", got)
+	}
+	if got := (*Response)(nil).Describe(); got != "check unavailable" {
+		t.Fatalf("Describe nil: %q", got)
+	}
+}
+
+func TestResponseVerdict(t *testing.T) {
+	var r Response
+	json.Unmarshal([]byte(rejectBody), &r)
+	v := r.Verdict("evt-1", "gw-1")
+	if v.Decision != verdict.Block || v.EventID != "evt-1" || v.Provider != Provider {
+		t.Fatalf("%+v", v)
+	}
+	if !v.HasCategory("security.prompt_injection") || !v.HasCategory("safety.pii") || v.Categories[0].Score != 0.97 {
+		t.Fatalf("categories %+v", v.Categories)
+	}
+
+	replace := Response{SuggestAction: ActionReplace, SuggestAnswer: "Let's talk about something else.",
+		Result: Result{Compliance: Dimension{Categories: []Category{CategorySensitivePolitical}}}}
+	v = replace.Verdict("e", "g")
+	if v.Decision != verdict.Modify || v.Modifications.Payload["text"] != replace.SuggestAnswer {
+		t.Fatalf("replace: %+v", v)
+	}
+	if v.Categories[0].ID != "x.ogr.politics.sensitive" || v.Categories[0].Domain != "safety" || v.Categories[0].Score != 1 {
+		t.Fatalf("vendor category %+v", v.Categories[0])
+	}
+
+	pass := Response{SuggestAction: ActionPass}
+	if v := pass.Verdict("e", "g"); v.Decision != verdict.Allow || len(v.Categories) != 0 {
+		t.Fatalf("pass: %+v", v)
+	}
+}
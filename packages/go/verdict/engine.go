@@ -0,0 +1,291 @@
+package verdict
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Providers stamped on verdicts the Engine produces.
+const (
+	ComposedProvider = "ogr.runtime/composed"
+	LocalProvider    = "ogr.local/rules"
+)
+
+// Composition strategies (spec: composition.md).
+const (
+	DenyWins       = "deny-wins"
+	Quorum         = "quorum"
+	FirstAvailable = "first-available"
+)
+
+// CompositionRule says how verdicts for one category group combine.
+type CompositionRule struct {
+	Strategy string `json:"strategy,omitempty"`
+	Quorum   *struct {
+		Count    int     `json:"count,omitempty"`
+		MinScore float64 `json:"min_score,omitempty"`
+	} `json:"quorum,omitempty"`
+	// OnAllFailed is the decision when no detector produced a verdict —
+	// the fail mode for the group. Default allow.
+	OnAllFailed Decision `json:"on_all_failed,omitempty"`
+}
+
+// Rule is a local content rule, evaluated in-process before (or instead of)
+// a remote detector. It has the shape of the base policy's command_rules.
+type Rule struct {
+	ID       string   `json:"id"`
+	Regex    string   `json:"regex"`
+	Category string   `json:"category"`
+	Domain   string   `json:"domain,omitempty"`
+	Decision Decision `json:"decision"`
+	Score    float64  `json:"score,omitempty"`
+	Why      string   `json:"why,omitempty"`
+}
+
+// Policy is the part of a deployer's policy the Engine reads. Composition
+// uses the same keys as the Python and JavaScript runtimes ("security.*",
+// "safety.toxicity", "default"), so one policy document drives all three.
+type Policy struct {
+	Composition map[string]CompositionRule `json:"composition,omitempty"`
+	// LocalRules run against the content on every Decide call.
+	LocalRules []Rule `json:"local_rules,omitempty"`
+	// Thresholds drop categories scored below the value for their longest
+	// matching prefix. A verdict left without categories is an allow.
+	Thresholds map[string]float64 `json:"category_thresholds,omitempty"`
+	// Actions replace a detector's decision for the categories under a
+	// prefix, e.g. {"safety.pii": "redact", "safety.toxicity": "allow"}.
+	Actions map[string]Decision `json:"category_actions,omitempty"`
+}
+
+// Engine turns remote verdicts and local rules into one effective Verdict
+// under a Policy. It is safe for concurrent use.
+type Engine struct {
+	policy Policy
+	rules  []compiledRule
+}
+
+type compiledRule struct {
+	Rule
+	re *regexp.Regexp
+}
+
+// NewEngine validates p and compiles its local rules.
+func NewEngine(p Policy) (*Engine, error) {
+	e := &Engine{policy: p}
+	for prefix, d := range p.Actions {
+		if Severity(d) < 0 {
+			return nil, fmt.Errorf("verdict: category_actions[%q]: unknown decision %q", prefix, d)
+		}
+	}
+	for prefix, r := range p.Composition {
+		switch r.Strategy {
+		case "", DenyWins, Quorum, FirstAvailable:
+		default:
+			return nil, fmt.Errorf("verdict: composition[%q]: unknown strategy %q", prefix, r.Strategy)
+		}
+	}
+	for i, r := range p.LocalRules {
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("verdict: local_rules[%d] (%s): %w", i, r.ID, err)
+		}
+		if Severity(r.Decision) < 0 {
+			return nil, fmt.Errorf("verdict: local_rules[%d] (%s): unknown decision %q", i, r.ID, r.Decision)
+		}
+		e.rules = append(e.rules, compiledRule{Rule: r, re: re})
+	}
+	return e, nil
+}
+
+// Local evaluates the local rules against text. It returns nil when no rule
+// matches, so callers can tell "no finding" from an explicit allow.
+func (e *Engine) Local(eventID, guardID, text string) *Verdict {
+	var hits []compiledRule
+	for _, r := range e.rules {
+		if r.re.MatchString(text) {
+			hits = append(hits, r)
+		}
+	}
+	if len(hits) == 0 {
+		return nil
+	}
+	v := &Verdict{OGRVersion: Version, EventID: eventID, GuardID: guardID, Provider: LocalProvider, Decision: Allow}
+	for _, r := range hits {
+		v.Decision = MostSevere(v.Decision, r.Decision)
+		domain, score := r.Domain, r.Score
+		if domain == "" {
+			domain, _, _ = strings.Cut(r.Category, ".")
+		}
+		if score == 0 {
+			score = 1
+		}
+		v.Categories = append(v.Categories, Category{ID: r.Category, Domain: domain, Score: score})
+		v.Reasons = append(v.Reasons, r.ID+": "+r.Why)
+	}
+	return v
+}
+
+// Decide is the full pipeline: local rules run against text, every verdict
+// is adjusted by thresholds and category actions, and the result is composed
+// by the rule whose category prefix best matches the findings. remote may
+// be empty (or hold nils) when detectors failed; the matched rule's
+// on_all_failed then decides.
+func (e *Engine) Decide(eventID, guardID, text string, remote ...*Verdict) *Verdict {
+	var vs []*Verdict
+	for _, v := range remote {
+		if v != nil {
+			vs = append(vs, e.adjust(v))
+		}
+	}
+	if text != "" {
+		if v := e.Local(eventID, guardID, text); v != nil {
+			vs = append(vs, e.adjust(v))
+		}
+	}
+	return e.compose(eventID, guardID, vs, e.selectRule(vs))
+}
+
+// adjust applies thresholds and category actions to a copy of v.
+func (e *Engine) adjust(v *Verdict) *Verdict {
+	out := *v
+	if len(v.Categories) == 0 || v.Decision == Allow {
+		return &out
+	}
+	out.Categories = nil
+	for _, c := range v.Categories {
+		if min, ok := longestPrefix(e.policy.Thresholds, c); ok && c.Score < min {
+			continue
+		}
+		out.Categories = append(out.Categories, c)
+	}
+	if len(out.Categories) == 0 {
+		out.Decision = Allow
+		out.Reasons = append(append([]string(nil), v.Reasons...), "all categories below threshold")
+		return &out
+	}
+	if len(e.policy.Actions) == 0 {
+		return &out
+	}
+	// Each category contributes its mapped action, or the detector's own
+	// decision when unmapped; the most severe contribution wins.
+	decision := Allow
+	for _, c := range out.Categories {
+		if a, ok := longestPrefix(e.policy.Actions, c); ok {
+			decision = MostSevere(decision, a)
+		} else {
+			decision = MostSevere(decision, v.Decision)
+		}
+	}
+	out.Decision = decision
+	return &out
+}
+
+// selectRule picks the composition rule whose category prefix best matches
+// the findings, falling back to "default" and then to deny-wins.
+func (e *Engine) selectRule(vs []*Verdict) CompositionRule {
+	best, bestLen := CompositionRule{Strategy: DenyWins}, -1
+	if r, ok := e.policy.Composition["default"]; ok {
+		best = r
+	}
+	for prefix, r := range e.policy.Composition {
+		if prefix == "default" || prefix == "conflict_default" {
+			continue
+		}
+		base := strings.TrimSuffix(strings.TrimSuffix(prefix, "*"), ".")
+		for _, v := range vs {
+			if v.HasCategory(prefix) && len(base) > bestLen {
+				best, bestLen = r, len(base)
+			}
+		}
+	}
+	return best
+}
+
+func (e *Engine) compose(eventID, guardID string, vs []*Verdict, rule CompositionRule) *Verdict {
+	if len(vs) == 0 {
+		d := rule.OnAllFailed
+		if d == "" {
+			d = Allow
+		}
+		return &Verdict{OGRVersion: Version, EventID: eventID, GuardID: guardID, Provider: ComposedProvider,
+			Decision: d, Reasons: []string{"no detector produced a verdict"}}
+	}
+	switch rule.Strategy {
+	case Quorum:
+		count, minScore := 2, 0.0
+		if rule.Quorum != nil {
+			if rule.Quorum.Count > 0 {
+				count = rule.Quorum.Count
+			}
+			minScore = rule.Quorum.MinScore
+		}
+		var votes []Decision
+		for _, v := range vs {
+			if v.Decision != Allow && (len(v.Categories) == 0 || v.MaxScore() >= minScore) {
+				votes = append(votes, v.Decision)
+			}
+		}
+		if len(votes) >= count {
+			d := MostSevere(votes...)
+			return merge(eventID, guardID, d, vs, fmt.Sprintf("quorum %d/%d → %s", len(votes), count, d))
+		}
+		return merge(eventID, guardID, Allow, vs, "quorum not reached → allow")
+	case FirstAvailable:
+		return merge(eventID, guardID, vs[0].Decision, vs, FirstAvailable)
+	}
+	d := Allow
+	for _, v := range vs {
+		d = MostSevere(d, v.Decision)
+	}
+	return merge(eventID, guardID, d, vs, "deny-wins → "+string(d))
+}
+
+// merge builds the composed verdict: categories keep their highest score,
+// reasons are attributed to their provider, and for redact/modify the spans
+// of every verdict that asked for the same decision are carried over.
+func merge(eventID, guardID string, d Decision, vs []*Verdict, reason string) *Verdict {
+	out := &Verdict{OGRVersion: Version, EventID: eventID, GuardID: guardID, Provider: ComposedProvider,
+		Decision: d, Reasons: []string{reason}}
+	seen := map[string]int{}
+	for _, v := range vs {
+		for _, c := range v.Categories {
+			if i, ok := seen[c.ID]; ok {
+				if c.Score > out.Categories[i].Score {
+					out.Categories[i] = c
+				}
+				continue
+			}
+			seen[c.ID] = len(out.Categories)
+			out.Categories = append(out.Categories, c)
+		}
+		for _, r := range v.Reasons {
+			out.Reasons = append(out.Reasons, "["+v.Provider+"] "+r)
+		}
+		out.Evidence = append(out.Evidence, map[string]any{
+			"provider": v.Provider, "decision": string(v.Decision), "latency_ms": v.LatencyMS,
+		})
+		if (d == Redact || d == Modify) && v.Decision == d && v.Modifications != nil {
+			if out.Modifications == nil {
+				out.Modifications = &Modifications{Kind: v.Modifications.Kind, Payload: v.Modifications.Payload}
+			}
+			out.Modifications.Spans = append(out.Modifications.Spans, v.Modifications.Spans...)
+		}
+	}
+	return out
+}
+
+// longestPrefix looks up the entry whose key is the longest prefix matching c.
+func longestPrefix[T any](m map[string]T, c Category) (T, bool) {
+	var (
+		best    T
+		bestLen = -1
+	)
+	for prefix, val := range m {
+		base := strings.TrimSuffix(strings.TrimSuffix(prefix, "*"), ".")
+		if c.Matches(prefix) && len(base) > bestLen {
+			best, bestLen = val, len(base)
+		}
+	}
+	return best, bestLen >= 0
+}
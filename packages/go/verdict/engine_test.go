@@ -0,0 +1,175 @@
+package verdict
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func remote(provider string, d Decision, cats ...Category) *Verdict {
+	return &Verdict{Provider: provider, Decision: d, Categories: cats}
+}
+
+func mustEngine(t *testing.T, p Policy) *Engine {
+	t.Helper()
+	e, err := NewEngine(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// basePolicy loads the composition section of the policy the reference
+// runtimes ship, so the Go engine is tested against the same document.
+func basePolicy(t *testing.T) Policy {
+	t.Helper()
+	b, err := os.ReadFile("../../python/src/openguardrails/base.policy.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var p Policy
+	if err := json.Unmarshal(b, &p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestDenyWins(t *testing.T) {
+	e := mustEngine(t, basePolicy(t))
+	v := e.Decide("evt-1", "gw-1", "",
+		remote("a", Allow),
+		remote("b", RequireApproval, Category{ID: "security.prompt_injection", Domain: "security", Score: 0.6}),
+		remote("c", Redact, Category{ID: "security.prompt_injection", Domain: "security", Score: 0.9}))
+	if v.Decision != RequireApproval || v.Provider != ComposedProvider || v.EventID != "evt-1" {
+		t.Fatalf("got %+v", v)
+	}
+	if len(v.Categories) != 1 || v.Categories[0].Score != 0.9 {
+		t.Fatalf("categories not merged by max score: %+v", v.Categories)
+	}
+	if !strings.HasPrefix(v.Reasons[0], "deny-wins") || len(v.Evidence) != 3 {
+		t.Fatalf("reasons %v evidence %v", v.Reasons, v.Evidence)
+	}
+}
+
+func TestQuorumFromBasePolicy(t *testing.T) {
+	e := mustEngine(t, basePolicy(t))
+	tox := func(s float64) Category { return Category{ID: "safety.toxicity", Domain: "safety", Score: s} }
+
+	v := e.Decide("e", "g", "", remote("a", Block, tox(0.95)), remote("b", Allow))
+	if v.Decision != Allow {
+		t.Fatalf("one vote must not reach quorum: %s", v.Decision)
+	}
+	v = e.Decide("e", "g", "", remote("a", Block, tox(0.95)), remote("b", Block, tox(0.5)))
+	if v.Decision != Allow {
+		t.Fatalf("a vote under min_score must not count: %s", v.Decision)
+	}
+	v = e.Decide("e", "g", "", remote("a", Block, tox(0.95)), remote("b", Redact, tox(0.85)))
+	if v.Decision != Block {
+		t.Fatalf("quorum reached: %s", v.Decision)
+	}
+}
+
+func TestFirstAvailable(t *testing.T) {
+	e := mustEngine(t, Policy{Composition: map[string]CompositionRule{"default": {Strategy: FirstAvailable}}})
+	if v := e.Decide("e", "g", "", remote("a", Allow), remote("b", Block)); v.Decision != Allow {
+		t.Fatalf("got %s", v.Decision)
+	}
+}
+
+func TestOnAllFailed(t *testing.T) {
+	e := mustEngine(t, Policy{Composition: map[string]CompositionRule{"default": {OnAllFailed: Block}}})
+	if v := e.Decide("e", "g", "", nil); v.Decision != Block {
+		t.Fatalf("got %s", v.Decision)
+	}
+	if v := mustEngine(t, Policy{}).Decide("e", "g", ""); v.Decision != Allow {
+		t.Fatalf("default fail mode: %s", v.Decision)
+	}
+}
+
+func TestThresholds(t *testing.T) {
+	e := mustEngine(t, Policy{Thresholds: map[string]float64{"safety": 0.5, "safety.pii": 0.9}})
+
+	v := e.Decide("e", "g", "", remote("a", Block, Category{ID: "safety.pii.email", Score: 0.8}))
+	if v.Decision != Allow {
+		t.Fatalf("longest prefix (0.9) should drop 0.8: %s", v.Decision)
+	}
+	v = e.Decide("e", "g", "", remote("a", Block,
+		Category{ID: "safety.pii.email", Score: 0.8}, Category{ID: "safety.violence", Score: 0.6}))
+	if v.Decision != Block || len(v.Categories) != 1 || v.Categories[0].ID != "safety.violence" {
+		t.Fatalf("got %s %+v", v.Decision, v.Categories)
+	}
+}
+
+func TestCategoryActions(t *testing.T) {
+	e := mustEngine(t, Policy{Actions: map[string]Decision{
+		"safety.pii":      Redact,
+		"safety.toxicity": Allow,
+	}})
+	pii := Category{ID: "safety.pii", Score: 1}
+	tox := Category{ID: "safety.toxicity", Score: 1}
+	secret := Category{ID: "security.secret_leak", Score: 1}
+
+	if v := e.Decide("e", "g", "", remote("a", Block, pii)); v.Decision != Redact {
+		t.Fatalf("pii: %s", v.Decision)
+	}
+	if v := e.Decide("e", "g", "", remote("a", Block, tox)); v.Decision != Allow {
+		t.Fatalf("toxicity: %s", v.Decision)
+	}
+	if v := e.Decide("e", "g", "", remote("a", RequireApproval, pii, secret)); v.Decision != RequireApproval {
+		t.Fatalf("an unmapped category keeps the detector's decision: %s", v.Decision)
+	}
+	if v := e.Decide("e", "g", "", remote("a", Allow, pii)); v.Decision != Allow {
+		t.Fatalf("actions must not escalate an allow: %s", v.Decision)
+	}
+}
+
+func TestLocalRules(t *testing.T) {
+	e := mustEngine(t, Policy{LocalRules: []Rule{
+		{ID: "rm-rf-root", Regex: `rm\s+-rf\s+/(\s|$)`, Category: "security.malicious_command", Decision: Block, Score: 0.95, Why: "recursive delete of /"},
+		{ID: "ssn", Regex: `\b\d{3}-\d{2}-\d{4}\b`, Category: "safety.pii.ssn", Decision: Redact},
+	}})
+	if v := e.Local("e", "g", "hello"); v != nil {
+		t.Fatalf("no rule should match: %+v", v)
+	}
+	v := e.Local("e", "g", "ssn 123-45-6789 then rm -rf /")
+	if v.Decision != Block || len(v.Categories) != 2 || v.Categories[1].Domain != "safety" || v.Categories[1].Score != 1 {
+		t.Fatalf("local verdict %+v", v)
+	}
+	// The local verdict composes with remote ones.
+	v = e.Decide("e", "g", "please rm -rf / now", remote("a", Allow))
+	if v.Decision != Block || !v.HasCategory("security") {
+		t.Fatalf("decide: %+v", v)
+	}
+}
+
+func TestMergeCarriesSpans(t *testing.T) {
+	span := func(s, end int) *Modifications {
+		return &Modifications{Kind: "redact", Spans: []Span{{Path: "payload.text", Start: s, End: end}}}
+	}
+	a := remote("a", Redact, Category{ID: "safety.pii", Score: 1})
+	a.Modifications = span(0, 4)
+	b := remote("b", Redact, Category{ID: "safety.pii", Score: 1})
+	b.Modifications = span(5, 9)
+	c := remote("c", Allow)
+	c.Modifications = span(10, 14) // not a redact verdict: ignored
+
+	v := mustEngine(t, Policy{}).Decide("e", "g", "", a, b, c)
+	if got := v.Masked("payload.text", "aaaa bbbb cccc"); got != "[REDACTED] [REDACTED] cccc" {
+		t.Fatalf("masked = %q", got)
+	}
+}
+
+func TestNewEngineValidates(t *testing.T) {
+	bad := []Policy{
+		{LocalRules: []Rule{{ID: "x", Regex: "(", Decision: Block}}},
+		{LocalRules: []Rule{{ID: "x", Regex: "a", Decision: "deny"}}},
+		{Actions: map[string]Decision{"safety": "reject"}},
+		{Composition: map[string]CompositionRule{"default": {Strategy: "majority"}}},
+	}
+	for i, p := range bad {
+		if _, err := NewEngine(p); err == nil {
+			t.Errorf("policy %d: expected an error", i)
+		}
+	}
+}
@@ -0,0 +1,212 @@
+// Package verdict is the Go port of the OGR Verdict and its composition
+// mechanism (spec: composition.md). It is the one place Go integrations turn
+// detector output into an enforcement decision, so a gateway, an SDK
+// middleware and an external processor given the same inputs and policy all
+// reach the same decision.
+//
+// Protocol: OGR 0.4. Types mirror schema/verdict.schema.json.
+package verdict
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// Version is the OGR wire version of the types in this package.
+const Version = "0.4"
+
+// Decision is what a Verdict asks the enforcement point to do.
+type Decision string
+
+// Decisions, most severe first.
+const (
+	Block           Decision = "block"
+	RequireApproval Decision = "require_approval"
+	Redact          Decision = "redact"
+	Modify          Decision = "modify"
+	Allow           Decision = "allow"
+)
+
+var decisions = []Decision{Block, RequireApproval, Redact, Modify, Allow}
+
+// Severity orders decisions: a lower value is more severe. Unknown decisions
+// rank above block, so a value this build does not recognise is never read
+// as permissive.
+func Severity(d Decision) int {
+	for i, x := range decisions {
+		if x == d {
+			return i
+		}
+	}
+	return -1
+}
+
+// MostSevere returns the most severe of ds, or Allow when ds is empty.
+func MostSevere(ds ...Decision) Decision {
+	out := Allow
+	for _, d := range ds {
+		if Severity(d) < Severity(out) {
+			out = d
+		}
+	}
+	return out
+}
+
+// Category is a taxonomy id attached to a Verdict, e.g. "safety.pii".
+type Category struct {
+	ID     string  `json:"id"`
+	Domain string  `json:"domain"`
+	Score  float64 `json:"score"`
+}
+
+// UnmarshalJSON defaults an absent score to 1, as the reference runtimes do:
+// a detector that names a category without scoring it is certain.
+func (c *Category) UnmarshalJSON(b []byte) error {
+	type raw Category
+	r := raw{Score: 1}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return err
+	}
+	*c = Category(r)
+	return nil
+}
+
+// Matches reports whether the category falls under prefix: "safety" and
+// "safety.*" match "safety.pii"; "safety.pii" matches itself and
+// "safety.pii.email". An empty prefix or "*" matches everything.
+func (c Category) Matches(prefix string) bool {
+	base := strings.TrimSuffix(strings.TrimSuffix(prefix, "*"), ".")
+	return base == "" || c.ID == base || strings.HasPrefix(c.ID, base+".")
+}
+
+// Span is one transformed region of a payload field.
+type Span struct {
+	Path        string `json:"path"`
+	Start       int    `json:"start"`
+	End         int    `json:"end"`
+	Replacement string `json:"replacement,omitempty"`
+	Operator    string `json:"operator,omitempty"`
+	Ref         string `json:"ref,omitempty"`
+}
+
+// Modifications is what a redact or modify Verdict asks the PEP to apply.
+type Modifications struct {
+	Kind    string         `json:"kind"`
+	Spans   []Span         `json:"spans,omitempty"`
+	Payload map[string]any `json:"payload,omitempty"`
+}
+
+// Verdict is a detector's (or the composed) decision about one GuardEvent.
+type Verdict struct {
+	OGRVersion    string           `json:"ogr_version"`
+	EventID       string           `json:"event_id"`
+	GuardID       string           `json:"guard_id"`
+	Provider      string           `json:"provider"`
+	Decision      Decision         `json:"decision"`
+	Confidence    float64          `json:"confidence,omitempty"`
+	LatencyMS     float64          `json:"latency_ms,omitempty"`
+	Reasons       []string         `json:"reasons,omitempty"`
+	Categories    []Category       `json:"categories,omitempty"`
+	Modifications *Modifications   `json:"modifications,omitempty"`
+	Evidence      []map[string]any `json:"evidence,omitempty"`
+}
+
+// HasCategory reports whether any of the verdict's categories falls under
+// one of the prefixes (see Category.Matches).
+func (v *Verdict) HasCategory(prefixes ...string) bool {
+	for _, c := range v.Categories {
+		for _, p := range prefixes {
+			if c.Matches(p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// MaxScore returns the highest category score, or 0 without categories.
+func (v *Verdict) MaxScore() float64 {
+	var m float64
+	for _, c := range v.Categories {
+		if c.Score > m {
+			m = c.Score
+		}
+	}
+	return m
+}
+
+// Risk grades the verdict for display and metrics. An allow verdict is
+// NoRisk whatever its scores; otherwise the grade follows the highest
+// category score, and a non-allow verdict without scores is HighRisk.
+func (v *Verdict) Risk() RiskLevel {
+	if v.Decision == Allow {
+		return NoRisk
+	}
+	if len(v.Categories) == 0 {
+		return HighRisk
+	}
+	return RiskFromScore(v.MaxScore())
+}
+
+// Masked returns text with the verdict's spans for path (e.g.
+// "payload.text") applied. Offsets count Unicode code points, as the
+// reference runtimes index strings. Spans without a replacement are masked
+// with "[REDACTED]"; spans out of range or overlapping an earlier one are
+// skipped rather than corrupting the text.
+func (v *Verdict) Masked(path, text string) string {
+	if v.Modifications == nil || len(v.Modifications.Spans) == 0 {
+		return text
+	}
+	var spans []Span
+	for _, s := range v.Modifications.Spans {
+		if s.Path == path {
+			spans = append(spans, s)
+		}
+	}
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].Start < spans[j].Start })
+
+	runes := []rune(text)
+	var b strings.Builder
+	pos := 0
+	for _, s := range spans {
+		if s.Start < pos || s.End < s.Start || s.End > len(runes) {
+			continue
+		}
+		b.WriteString(string(runes[pos:s.Start]))
+		if s.Replacement != "" {
+			b.WriteString(s.Replacement)
+		} else {
+			b.WriteString("[REDACTED]")
+		}
+		pos = s.End
+	}
+	b.WriteString(string(runes[pos:]))
+	return b.String()
+}
+
+// RiskLevel is a coarse grade of a verdict, spelled as the platform's
+// detection API reports overall_risk_level.
+type RiskLevel string
+
+// Risk levels, least severe first.
+const (
+	NoRisk     RiskLevel = "no_risk"
+	LowRisk    RiskLevel = "low_risk"
+	MediumRisk RiskLevel = "medium_risk"
+	HighRisk   RiskLevel = "high_risk"
+)
+
+// RiskFromScore maps a category score to a RiskLevel: below 0.4 is low,
+// below 0.7 medium, anything higher high.
+func RiskFromScore(score float64) RiskLevel {
+	switch {
+	case score <= 0:
+		return NoRisk
+	case score < 0.4:
+		return LowRisk
+	case score < 0.7:
+		return MediumRisk
+	}
+	return HighRisk
+}
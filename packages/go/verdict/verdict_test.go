@@ -0,0 +1,74 @@
+package verdict
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestSeverityOrder(t *testing.T) {
+	if MostSevere(Allow, Redact, RequireApproval) != RequireApproval {
+		t.Fatal("require_approval must outrank redact")
+	}
+	if MostSevere() != Allow {
+		t.Fatal("empty set must be allow")
+	}
+	if MostSevere(Block, "quarantine") != "quarantine" {
+		t.Fatal("unknown decisions must rank most severe")
+	}
+}
+
+func TestCategoryScoreDefaultsToOne(t *testing.T) {
+	var v Verdict
+	if err := json.Unmarshal([]byte(`{"decision":"block","categories":[{"id":"security.secret_leak","domain":"security"},{"id":"safety.pii","domain":"safety","score":0.2}]}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Categories[0].Score != 1 || v.Categories[1].Score != 0.2 {
+		t.Fatalf("scores = %v, %v", v.Categories[0].Score, v.Categories[1].Score)
+	}
+}
+
+func TestCategoryMatches(t *testing.T) {
+	c := Category{ID: "safety.pii.email"}
+	for _, p := range []string{"", "*", "safety", "safety.*", "safety.pii", "safety.pii.email"} {
+		if !c.Matches(p) {
+			t.Errorf("%q should match %s", p, c.ID)
+		}
+	}
+	for _, p := range []string{"security", "safety.p", "safety.pii.emails"} {
+		if c.Matches(p) {
+			t.Errorf("%q should not match %s", p, c.ID)
+		}
+	}
+}
+
+func TestMasked(t *testing.T) {
+	v := &Verdict{Modifications: &Modifications{Kind: "redact", Spans: []Span{
+		{Path: "payload.text", Start: 15, End: 31, Replacement: "[CARD]"},
+		{Path: "payload.text", Start: 4, End: 9},
+		{Path: "payload.text", Start: 6, End: 12}, // overlaps the span above
+		{Path: "payload.other", Start: 0, End: 1},
+		{Path: "payload.text", Start: 34, End: 99}, // out of range
+	}}}
+	got := v.Masked("payload.text", "pay Émile with 4111111111111111 ok")
+	if want := "pay [REDACTED] with [CARD] ok"; got != want {
+		t.Fatalf("Masked = %q, want %q", got, want)
+	}
+}
+
+func TestRisk(t *testing.T) {
+	cases := []struct {
+		v    Verdict
+		want RiskLevel
+	}{
+		{Verdict{Decision: Allow, Categories: []Category{{Score: 0.9}}}, NoRisk},
+		{Verdict{Decision: Block}, HighRisk},
+		{Verdict{Decision: Redact, Categories: []Category{{Score: 0.3}}}, LowRisk},
+		{Verdict{Decision: Block, Categories: []Category{{Score: 0.3}, {Score: 0.5}}}, MediumRisk},
+		{Verdict{Decision: Block, Categories: []Category{{Score: 0.7}}}, HighRisk},
+	}
+	for i, c := range cases {
+		if got := c.v.Risk(); got != c.want {
+			t.Errorf("case %d: Risk = %s, want %s", i, got, c.want)
+		}
+	}
+}